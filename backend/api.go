@@ -8,7 +8,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"os"
@@ -21,12 +20,9 @@ import (
 )
 
 type Api struct {
-	androidAPIVersion int64
-	model             string
-	make              string
-	clientVersionCode int64
 	userAgent         string
 	language          string
+	device            DeviceProfile
 	authData          string
 	client            *http.Client
 	authResponseCache map[string]string
@@ -74,7 +70,7 @@ func (a *Api) doProtobufPOST(endpoint string, requestData []byte) ([]byte, error
 			reader = gz
 		}
 		body, _ := io.ReadAll(reader)
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var reader io.Reader = resp.Body
@@ -121,19 +117,21 @@ func NewApi() (*Api, error) {
 		return nil, fmt.Errorf("no credentials with matching selcted email found")
 	}
 
+	device, err := accountDeviceProfile(selectedEmail)
+	if err != nil {
+		return nil, err
+	}
+
 	client, err := NewHTTPClientWithProxy(AppConfig.Proxy)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create HTTP client: %w", err)
 	}
 
 	api := &Api{
-		androidAPIVersion: 28,
-		model:             "Pixel XL",
-		make:              "Google",
-		clientVersionCode: 49029607,
-		language:          language,
-		authData:          strings.TrimSpace(credentials),
-		client:            client,
+		language: language,
+		device:   device,
+		authData: strings.TrimSpace(credentials),
+		client:   client,
 		authResponseCache: map[string]string{
 			"Expiry": "0",
 			"Auth":   "",
@@ -141,23 +139,24 @@ func NewApi() (*Api, error) {
 		Email: selectedEmail,
 	}
 
-	api.userAgent = fmt.Sprintf(
-		"com.google.android.apps.photos/%d (Linux; U; Android 9; %s; %s; Build/PQ2A.190205.001; Cronet/127.0.6510.5) (gzip)",
-		api.clientVersionCode,
-		api.language,
-		api.model,
-	)
+	api.userAgent = device.userAgent(api.language)
 
 	return api, nil
 }
 
-func buildUserAgent(clientVersionCode int64, language string, model string) string {
-	return fmt.Sprintf(
-		"com.google.android.apps.photos/%d (Linux; U; Android 9; %s; %s; Build/PQ2A.190205.001; Cronet/127.0.6510.5) (gzip)",
-		clientVersionCode,
-		language,
-		model,
-	)
+// commitDevice resolves the device an upload is committed from: a device profile name,
+// or a client model that replaces the model of the account's profile. Empty means the
+// account's profile.
+func (a *Api) commitDevice(name string) DeviceProfile {
+	if name == "" {
+		return a.device
+	}
+	if profile, ok := LookupDeviceProfile(name); ok {
+		return profile
+	}
+	device := a.device
+	device.Model = name
+	return device
 }
 
 func (a *Api) BearerToken() (string, error) {
@@ -170,7 +169,7 @@ func (a *Api) BearerToken() (string, error) {
 	if expiry <= time.Now().Unix() {
 		resp, err := a.getAuthToken()
 		if err != nil {
-			return "", fmt.Errorf("failed to get auth token: %w", err)
+			return "", fmt.Errorf("%w: %w", errAuthToken, err)
 		}
 		a.authResponseCache = resp
 	}
@@ -210,7 +209,7 @@ func (a *Api) getAuthToken() (map[string]string, error) {
 		"Connection":      "Keep-Alive",
 		"Content-Type":    "application/x-www-form-urlencoded",
 		"device":          authRequestData.Get("androidId"),
-		"User-Agent":      "GoogleAuth/1.4 (Pixel XL PQ2A.190205.001); gzip",
+		"User-Agent":      a.device.authUserAgent(),
 	}
 
 	req, err := http.NewRequest(
@@ -236,7 +235,7 @@ func (a *Api) getAuthToken() (map[string]string, error) {
 	// Check for errors
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
-		return make(map[string]string), fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return make(map[string]string), &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	// Handle gzip encoding if present
@@ -351,7 +350,7 @@ func (a *Api) GetUploadToken(shaHashB64 string, fileSize int64) (string, error)
 	// Check for errors
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return "", &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	// Get the upload token from headers
@@ -365,6 +364,11 @@ func (a *Api) GetUploadToken(shaHashB64 string, fileSize int64) (string, error)
 
 // Check library for existing files with the hash
 func (a *Api) FindRemoteMediaByHash(shaHash []byte) (string, error) {
+	return a.FindRemoteMediaByHashContext(context.Background(), shaHash)
+}
+
+// FindRemoteMediaByHashContext is FindRemoteMediaByHash with a cancellable context
+func (a *Api) FindRemoteMediaByHashContext(ctx context.Context, shaHash []byte) (string, error) {
 	// Create the protobuf message
 
 	// Create and initialize the protobuf message with all required nested structures
@@ -399,7 +403,8 @@ func (a *Api) FindRemoteMediaByHash(shaHash []byte) (string, error) {
 	}
 
 	// Create the request
-	req, err := http.NewRequest(
+	req, err := http.NewRequestWithContext(
+		ctx,
 		"POST",
 		"https://photosdata-pa.googleapis.com/6439526531001121323/5084965799730810217",
 		bytes.NewReader(serializedData),
@@ -423,7 +428,7 @@ func (a *Api) FindRemoteMediaByHash(shaHash []byte) (string, error) {
 	// Check for errors
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return "", &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var reader io.Reader
@@ -441,7 +446,7 @@ func (a *Api) FindRemoteMediaByHash(shaHash []byte) (string, error) {
 
 	var pbResp generated.RemoteMatches
 	if err := proto.Unmarshal(bodyBytes, &pbResp); err != nil {
-		log.Fatalf("Failed to unmarshal protobuf: %v", err)
+		return "", fmt.Errorf("failed to unmarshal protobuf: %w", err)
 	}
 
 	mediaKey := pbResp.GetMediaKey()
@@ -455,10 +460,14 @@ func (a *Api) UploadFile(ctx context.Context, filePath string, uploadToken strin
 		return nil, fmt.Errorf("error opening file: %w", err)
 	}
 	defer file.Close()
+	return a.UploadReader(ctx, file, uploadToken)
+}
 
+// UploadReader uploads the contents of r, such as an archive entry, like UploadFile
+func (a *Api) UploadReader(ctx context.Context, r io.Reader, uploadToken string) (*generated.CommitToken, error) {
 	uploadURL := "https://photos.googleapis.com/data/upload/uploadmedia/interactive?upload_id=" + uploadToken
 
-	req, err := http.NewRequestWithContext(ctx, "PUT", uploadURL, file)
+	req, err := http.NewRequestWithContext(ctx, "PUT", uploadURL, r)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
@@ -490,7 +499,7 @@ func (a *Api) UploadFile(ctx context.Context, filePath string, uploadToken strin
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	bodyBytes, err := io.ReadAll(resp.Body)
@@ -506,163 +515,37 @@ func (a *Api) UploadFile(ctx context.Context, filePath string, uploadToken strin
 	return &pbResp, nil
 }
 
-// CommitUpload commits the upload to Google Photos
+// CommitUpload commits the upload to Google Photos with the quality and device set by
+// Saver and UseQuota
 func (a *Api) CommitUpload(
 	uploadResponseDecoded *generated.CommitToken,
 	fileName string,
 	sha1Hash []byte,
 	uploadTimestamp int64,
 ) (string, error) {
-	if uploadTimestamp == 0 {
-		uploadTimestamp = time.Now().Unix()
-	}
-
-	model := a.model
-	userAgent := a.userAgent
-
-	var qualityVal int64 = 3
-	if AppConfig.Saver {
-		qualityVal = 1
-		model = "Pixel 2"
-		userAgent = buildUserAgent(a.clientVersionCode, a.language, model)
-	}
-
-	if AppConfig.UseQuota {
-		model = "Pixel 8"
-		userAgent = buildUserAgent(a.clientVersionCode, a.language, model)
-	}
-
-	unknownInt := int64(46000000)
-
-	// Create the protobuf message
-	protoBody := generated.CommitUpload{
-		Field1: &generated.CommitUploadField1Type{
-			Field1: &generated.CommitUploadField1TypeField1Type{
-				Field1: uploadResponseDecoded.Field1,
-				Field2: uploadResponseDecoded.Field2,
-			},
-			FileName: fileName,
-			Sha1Hash: sha1Hash,
-			Field4: &generated.CommitUploadField1TypeField4Type{
-				FileLastModifiedTimestamp: uploadTimestamp,
-				Field2:                    unknownInt,
-			},
-			Quality: qualityVal,
-			Field10: 1,
-		},
-		Field2: &generated.CommitUploadField2Type{
-			Model:             model,
-			Make:              a.make,
-			AndroidApiVersion: a.androidAPIVersion,
-		},
-		Field3: []byte{1, 3},
-	}
-
-	// Serialize the protobuf message
-	serializedData, err := proto.Marshal(&protoBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal protobuf: %w", err)
-	}
-
-	// Get the bearer token
-	bearerToken, err := a.BearerToken()
-	if err != nil {
-		return "", fmt.Errorf("failed to get bearer token: %w", err)
-	}
-
-	// Prepare headers
-	headers := map[string]string{
-		"accept-Encoding":          "gzip",
-		"accept-Language":          a.language,
-		"content-Type":             "application/x-protobuf",
-		"user-Agent":               userAgent,
-		"authorization":            "Bearer " + bearerToken,
-		"x-goog-ext-173412678-bin": "CgcIAhClARgC",
-		"x-goog-ext-174067345-bin": "CgIIAg==",
-	}
-
-	// Create the request
-	req, err := http.NewRequest(
-		"POST",
-		"https://photosdata-pa.googleapis.com/6439526531001121323/16538846908252377752",
-		bytes.NewReader(serializedData),
-	)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set headers
-	for k, v := range headers {
-		req.Header.Set(k, v)
-	}
-
-	// Make the request
-	resp, err := a.client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Check for errors
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
-	// Handle gzip response if needed
-	var reader io.Reader = resp.Body
-	if resp.Header.Get("Content-Encoding") == "gzip" {
-		reader, err = gzip.NewReader(resp.Body)
-		if err != nil {
-			return "", fmt.Errorf("failed to create gzip reader: %w", err)
-		}
-		defer reader.(*gzip.Reader).Close()
-	}
-
-	// Parse the response body
-	bodyBytes, err := io.ReadAll(reader)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	var pbResp generated.CommitUploadResponse
-	if err := proto.Unmarshal(bodyBytes, &pbResp); err != nil {
-		return "", fmt.Errorf("failed to unmarshal protobuf: %w", err)
-	}
-
-	// Get media key from response
-	if pbResp.GetField1() == nil || pbResp.GetField1().GetField3() == nil {
-		return "", fmt.Errorf("upload rejected by API: invalid response structure")
-	}
-
-	mediaKey := pbResp.GetField1().GetField3().GetMediaKey()
-	if mediaKey == "" {
-		return "", fmt.Errorf("upload rejected by API: no media key returned")
-	}
-
-	return mediaKey, nil
+	profile := globalUploadProfile()
+	return a.CommitUploadOverride(uploadResponseDecoded, fileName, sha1Hash, uploadTimestamp, profile.Device, profile.qualityValue())
 }
 
-// CommitUploadOverride commits an upload with explicit client model and quality, bypassing AppConfig-based defaults.
+// CommitUploadOverride commits an upload with explicit device and quality, bypassing AppConfig-based defaults.
+// The device is a device profile name or a client model, see commitDevice.
 // This is used for workflows like "washing" quota-consuming items by re-uploading with a different client profile.
 func (a *Api) CommitUploadOverride(
 	uploadResponseDecoded *generated.CommitToken,
 	fileName string,
 	sha1Hash []byte,
 	uploadTimestamp int64,
-	model string,
+	deviceName string,
 	qualityVal int64,
 ) (string, error) {
 	if uploadTimestamp == 0 {
 		uploadTimestamp = time.Now().Unix()
 	}
-	if model == "" {
-		model = a.model
-	}
+	device := a.commitDevice(deviceName)
 	if qualityVal == 0 {
 		qualityVal = 3
 	}
-	userAgent := buildUserAgent(a.clientVersionCode, a.language, model)
+	userAgent := device.userAgent(a.language)
 
 	unknownInt := int64(46000000)
 
@@ -682,9 +565,9 @@ func (a *Api) CommitUploadOverride(
 			Field10: 1,
 		},
 		Field2: &generated.CommitUploadField2Type{
-			Model:             model,
-			Make:              a.make,
-			AndroidApiVersion: a.androidAPIVersion,
+			Model:             device.Model,
+			Make:              device.Make,
+			AndroidApiVersion: device.AndroidAPIVersion,
 		},
 		Field3: []byte{1, 3},
 	}
@@ -730,7 +613,7 @@ func (a *Api) CommitUploadOverride(
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return "", &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var reader io.Reader = resp.Body
@@ -845,7 +728,7 @@ func (a *Api) GetDownloadURLs(mediaKey string) (*DownloadURLs, error) {
 	// Check for errors
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	// Handle gzip response if needed
@@ -907,7 +790,10 @@ func (a *Api) GetDownloadURLs(mediaKey string) (*DownloadURLs, error) {
 // This includes the filename and other metadata
 func (a *Api) GetMediaInfo(mediaKey string) (*MediaItem, error) {
 	// Build the request to get media info for a specific media key
-	requestData := buildGetMediaInfoRequest(mediaKey)
+	requestData, err := buildGetMediaInfoRequest(mediaKey)
+	if err != nil {
+		return nil, err
+	}
 
 	// Get the bearer token
 	bearerToken, err := a.BearerToken()
@@ -951,7 +837,7 @@ func (a *Api) GetMediaInfo(mediaKey string) (*MediaItem, error) {
 	// Check for errors
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	// Handle gzip response if needed
@@ -1000,7 +886,10 @@ func (a *Api) MoveToTrash(dedupKeys []string) error {
 		return fmt.Errorf("no valid keys provided")
 	}
 
-	requestData := buildMoveToTrashRequest(keys, a.clientVersionCode, a.androidAPIVersion)
+	requestData, err := buildMoveToTrashRequest(keys, a.device.ClientVersionCode, a.device.AndroidAPIVersion)
+	if err != nil {
+		return err
+	}
 
 	bearerToken, err := a.BearerToken()
 	if err != nil {
@@ -1042,135 +931,32 @@ func (a *Api) MoveToTrash(dedupKeys []string) error {
 			reader = gz
 		}
 		body, _ := io.ReadAll(reader)
-		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	// Success: the current implementation treats any 2xx as OK and ignores response protobuf.
 	return nil
 }
 
-func buildMoveToTrashRequest(dedupKeys []string, clientVersionCode int64, androidAPIVersion int64) []byte {
-	var buf bytes.Buffer
-
-	// Field 2: operation type = 1 (move to trash)
-	writeProtobufVarint(&buf, 2, 1)
-
-	// Field 3: repeated item keys (mediaKey strings)
-	for _, k := range dedupKeys {
-		writeProtobufString(&buf, 3, k)
-	}
-
-	// Field 4: operation mode = 1
-	writeProtobufVarint(&buf, 4, 1)
-
-	// Field 8: fixed nested meta structure
-	var field8 bytes.Buffer
-	var field8_4 bytes.Buffer
-	writeProtobufField(&field8_4, 2, []byte{}) // 8.4.2 = {}
-	var field8_4_3 bytes.Buffer
-	writeProtobufField(&field8_4_3, 1, []byte{}) // 8.4.3.1 = {}
-	writeProtobufField(&field8_4, 3, field8_4_3.Bytes())
-	writeProtobufField(&field8_4, 4, []byte{}) // 8.4.4 = {}
-	var field8_4_5 bytes.Buffer
-	writeProtobufField(&field8_4_5, 1, []byte{}) // 8.4.5.1 = {}
-	writeProtobufField(&field8_4, 5, field8_4_5.Bytes())
-	writeProtobufField(&field8, 4, field8_4.Bytes())
-	writeProtobufField(&buf, 8, field8.Bytes())
-
-	// Field 9: client info
-	var field9 bytes.Buffer
-	writeProtobufVarint(&field9, 1, 5) // 9.1 = 5
-	var field9_2 bytes.Buffer
-	writeProtobufVarint(&field9_2, 1, clientVersionCode)                    // 9.2.1
-	writeProtobufString(&field9_2, 2, fmt.Sprintf("%d", androidAPIVersion)) // 9.2.2
-	writeProtobufField(&field9, 2, field9_2.Bytes())
-	writeProtobufField(&buf, 9, field9.Bytes())
-
-	return buf.Bytes()
-}
-
-// buildGetMediaInfoRequest creates a protobuf request to get info for a specific media key
-func buildGetMediaInfoRequest(mediaKey string) []byte {
-	var buf bytes.Buffer
-
-	// Build field 1 (request data)
-	field1 := buildGetMediaInfoRequestField1(mediaKey)
-	writeProtobufField(&buf, 1, field1)
-
-	// Build field 2 (additional options)
-	field2 := buildMediaListRequestField2()
-	writeProtobufField(&buf, 2, field2)
-
-	return buf.Bytes()
+// buildMoveToTrashRequest fills the move-to-trash template
+func buildMoveToTrashRequest(dedupKeys []string, clientVersionCode int64, androidAPIVersion int64) ([]byte, error) {
+	return buildTemplateRequest("move-to-trash", map[string]any{
+		"3":     dedupKeys,
+		"9.2.1": clientVersionCode,
+		"9.2.2": fmt.Sprintf("%d", androidAPIVersion),
+	})
 }
 
-func buildGetMediaInfoRequestField1(mediaKey string) []byte {
-	var buf bytes.Buffer
-
-	// field1.1 - media metadata options (file info, timestamps, etc.)
-	// Structure:
-	// 1.1:
-	//   1: { 19: "", 20: "", 25: "", 30: { 2: "" } }
-	//   3..41: ""
-	//   21: { 1: <mode>, 5: { 3: "" } }
-
-	var field1_1 bytes.Buffer
-
-	// 1.1.1
-	var field1_1_1 bytes.Buffer
-	writeProtobufString(&field1_1_1, 19, "")
-	writeProtobufString(&field1_1_1, 20, "")
-	writeProtobufString(&field1_1_1, 25, "")
-	
-	var field1_1_1_30 bytes.Buffer
-	writeProtobufString(&field1_1_1_30, 2, "")
-	writeProtobufField(&field1_1_1, 30, field1_1_1_30.Bytes())
-	
-	writeProtobufField(&field1_1, 1, field1_1_1.Bytes())
-
-	// 1.1.x (simple strings)
-	simpleFields := []int{3, 4, 5, 6, 7, 15, 16, 17, 19, 20, 25, 30, 31, 32, 33, 34, 36, 37, 38, 39, 40, 41}
-	for _, f := range simpleFields {
-		writeProtobufString(&field1_1, f, "")
-	}
-
-	// 1.1.21 (dedup key request)
+// buildGetMediaInfoRequest fills the media-info template for a specific media key
+func buildGetMediaInfoRequest(mediaKey string) ([]byte, error) {
 	trashMode := int64(2)
 	if !AppConfig.RequestTrashItems {
 		trashMode = 1
 	}
-	var field1_1_21 bytes.Buffer
-	writeProtobufVarint(&field1_1_21, 1, trashMode)
-	var field1_1_21_5 bytes.Buffer
-	writeProtobufString(&field1_1_21_5, 3, "")
-	writeProtobufField(&field1_1_21, 5, field1_1_21_5.Bytes())
-	writeProtobufField(&field1_1, 21, field1_1_21.Bytes())
-
-	writeProtobufField(&buf, 1, field1_1.Bytes())
-
-	// field1.3 - album and collection options
-	albumOptions := []int{2, 3, 7, 8, 14, 16, 17, 18, 19, 20, 21, 22, 23, 27, 29, 30, 31, 32, 34, 37, 38, 39, 41}
-	field1_3 := buildEmptyNestedMessage(albumOptions)
-	writeProtobufField(&buf, 3, field1_3)
-
-	// field1.5 - media key filter
-	var field5 bytes.Buffer
-	writeProtobufString(&field5, 1, mediaKey)
-	writeProtobufField(&buf, 5, field5.Bytes())
-
-	// field1.7 - type (varint = 2)
-	writeProtobufVarint(&buf, 7, 2)
-
-	// field1.11 - repeated ints [1, 2]
-	writeProtobufVarint(&buf, 11, 1)
-	writeProtobufVarint(&buf, 11, 2)
-
-	// field1.22 - some config
-	var field22 bytes.Buffer
-	writeProtobufVarint(&field22, 1, 2)
-	writeProtobufField(&buf, 22, field22.Bytes())
-
-	return buf.Bytes()
+	return buildTemplateRequest("media-info", map[string]any{
+		"1.1.21.1": trashMode,
+		"1.5.1":    mediaKey,
+	})
 }
 
 // selectBetterItem compares two media items and returns the better one
@@ -1314,7 +1100,10 @@ func (a *Api) PermanentlyDelete(dedupKeys []string) error {
 		return fmt.Errorf("no valid keys provided")
 	}
 
-	requestData := buildPermanentlyDeleteRequest(keys)
+	requestData, err := buildPermanentlyDeleteRequest(keys)
+	if err != nil {
+		return err
+	}
 
 	bearerToken, err := a.BearerToken()
 	if err != nil {
@@ -1356,52 +1145,15 @@ func (a *Api) PermanentlyDelete(dedupKeys []string) error {
 			reader = gz
 		}
 		body, _ := io.ReadAll(reader)
-		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	return nil
 }
 
-// buildPermanentlyDeleteRequest builds the protobuf request described by:
-// {
-//   "2": 2,
-//   "3": "<dedupKey>",
-//   "4": 2,
-//   "8": { "4": { "2": "", "3": { "1": "" }, "4": "", "5": { "1": "" } } },
-//   "9": ""
-// }
-func buildPermanentlyDeleteRequest(dedupKeys []string) []byte {
-	var buf bytes.Buffer
-
-	// Field 2: operation type = 2 (permanent delete)
-	writeProtobufVarint(&buf, 2, 2)
-
-	// Field 3: repeated item keys (dedup keys)
-	for _, k := range dedupKeys {
-		writeProtobufString(&buf, 3, k)
-	}
-
-	// Field 4: operation mode = 2
-	writeProtobufVarint(&buf, 4, 2)
-
-	// Field 8: fixed nested meta structure (same shape as trash request)
-	var field8 bytes.Buffer
-	var field8_4 bytes.Buffer
-	writeProtobufField(&field8_4, 2, []byte{}) // 8.4.2 = ""
-	var field8_4_3 bytes.Buffer
-	writeProtobufField(&field8_4_3, 1, []byte{}) // 8.4.3.1 = ""
-	writeProtobufField(&field8_4, 3, field8_4_3.Bytes())
-	writeProtobufField(&field8_4, 4, []byte{}) // 8.4.4 = ""
-	var field8_4_5 bytes.Buffer
-	writeProtobufField(&field8_4_5, 1, []byte{}) // 8.4.5.1 = ""
-	writeProtobufField(&field8_4, 5, field8_4_5.Bytes())
-	writeProtobufField(&field8, 4, field8_4.Bytes())
-	writeProtobufField(&buf, 8, field8.Bytes())
-
-	// Field 9: present as empty string in captured request
-	writeProtobufString(&buf, 9, "")
-
-	return buf.Bytes()
+// buildPermanentlyDeleteRequest fills the permanently-delete template
+func buildPermanentlyDeleteRequest(dedupKeys []string) ([]byte, error) {
+	return buildTemplateRequest("permanently-delete", map[string]any{"3": dedupKeys})
 }
 
 // tryParseMediaItemWithKey parses a message that might contain a media item with the target key
@@ -1821,7 +1573,7 @@ func (a *Api) GetThumbnail(mediaKey string, width, height int, forceJPEG bool, c
 	// Check for errors
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	// Handle gzip response if needed
@@ -1878,7 +1630,7 @@ func (a *Api) DownloadFile(downloadURL, outputPath string) error {
 	// Check for errors
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	// Create output file
@@ -1907,6 +1659,40 @@ func (a *Api) DownloadFile(downloadURL, outputPath string) error {
 	return nil
 }
 
+// OpenDownload starts a streaming GET of a download URL, forwarding an optional HTTP Range header.
+// The caller must close the response body. Non-2xx responses are returned as-is so range errors
+// (416) can be passed through to the client.
+func (a *Api) OpenDownload(ctx context.Context, downloadURL string, rangeHeader string) (*http.Response, error) {
+	bearerToken, err := a.BearerToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bearer token: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", downloadURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Ask for identity encoding so byte ranges refer to the original file
+	headers := map[string]string{
+		"Authorization":   "Bearer " + bearerToken,
+		"User-Agent":      a.userAgent,
+		"Accept-Encoding": "identity",
+	}
+	if rangeHeader != "" {
+		headers["Range"] = rangeHeader
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	return resp, nil
+}
+
 // MediaItem represents a media item in the library
 type MediaItem struct {
 	MediaKey  string `json:"mediaKey"`
@@ -1951,9 +1737,11 @@ const minMediaKeyLength = 10
 // syncToken should be passed for incremental updates (field 1.6)
 // triggerMode controls the update mode (1=Active/Fetch Changes, 2=Passive/Scan)
 func (a *Api) GetMediaList(pageToken string, syncToken string, triggerMode int, limit int) (*MediaListResult, error) {
-	// Build the request using raw protobuf wire format
-	// The request structure is complex, so we use a helper to build it
-	requestData := buildMediaListRequest(pageToken, syncToken, triggerMode, limit)
+	// Build the request from the media-list template, see requesttemplates.go
+	requestData, err := buildMediaListRequest(pageToken, syncToken, triggerMode, limit)
+	if err != nil {
+		return nil, err
+	}
 
 	// Get the bearer token
 	bearerToken, err := a.BearerToken()
@@ -1997,7 +1785,7 @@ func (a *Api) GetMediaList(pageToken string, syncToken string, triggerMode int,
 	// Check for errors
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	// Handle gzip response if needed
@@ -2025,180 +1813,23 @@ func (a *Api) GetMediaList(pageToken string, syncToken string, triggerMode int,
 	return result, nil
 }
 
-// buildMediaListRequest creates the protobuf request for fetching media list
+// buildMediaListRequest fills the media-list template
 // pageToken comes from the previous response's field 1.1 and goes into request field 1.4
-func buildMediaListRequest(pageToken string, syncToken string, triggerMode int, limit int) []byte {
-	if req, err := buildMediaListRequestFromTemplate(pageToken, syncToken, triggerMode, limit); err == nil && len(req) > 0 {
-		return req
-	}
-	return buildMediaListRequestLegacy(pageToken, syncToken, triggerMode, limit)
-}
-
-func buildMediaListRequestFromTemplate(pageToken string, syncToken string, triggerMode int, limit int) ([]byte, error) {
-	base, err := getMediaListTemplate()
-	if err != nil {
-		return nil, err
-	}
-
-	rootAny := deepCopyJSON(base)
-	root, ok := rootAny.(map[string]any)
-	if !ok {
-		return nil, fmt.Errorf("template root is not an object")
-	}
-
-	field1Any, ok := root["1"].(map[string]any)
-	if !ok {
-		return nil, fmt.Errorf("template missing field 1 object")
-	}
-
-	// Dynamic: 1.2 = limit (the new template uses field 1.2 as the page size)
-	if limit > 0 {
-		field1Any["2"] = int64(limit)
-	}
-
-	// Dynamic: 1.4 page token (optional)
-	if pageToken != "" {
-		field1Any["4"] = pageToken
-	} else {
-		delete(field1Any, "4")
-	}
-
-	// Dynamic: 1.6 sync token (can be empty)
-	field1Any["6"] = syncToken
-
-	// Dynamic: 1.22.1 trigger mode
+func buildMediaListRequest(pageToken string, syncToken string, triggerMode int, limit int) ([]byte, error) {
 	tMode := int64(2)
 	if triggerMode == 1 {
 		tMode = 1
 	}
-	field22, err := ensureMapPath(field1Any, "22")
-	if err != nil {
-		return nil, err
-	}
-	field22["1"] = tMode
-
-	return buildProtobufFromMap(root)
-}
-
-func buildMediaListRequestLegacy(pageToken string, syncToken string, triggerMode int, limit int) []byte {
-	var buf bytes.Buffer
-
-	// Build field 1 (request data)
-	field1 := buildMediaListRequestField1(pageToken, syncToken, triggerMode, limit)
-	writeProtobufField(&buf, 1, field1)
-
-	// Build field 2 (additional options)
-	field2 := buildMediaListRequestField2()
-	writeProtobufField(&buf, 2, field2)
-
-	return buf.Bytes()
-}
-
-func buildMediaListRequestField1(pageToken string, syncToken string, triggerMode int, limit int) []byte {
-	var buf bytes.Buffer
-
-	// These field numbers correspond to the Google Photos protobuf schema for media list requests
-	// They define which metadata fields to include in the response
-	// field1.1 - media metadata options (file info, timestamps, etc.)
-	mediaMetadataFields := []int{1, 3, 4, 5, 6, 7, 15, 16, 17, 19, 20, 21, 25, 30, 31, 32, 33, 34, 36, 37, 38, 39, 40, 41}
-	trashMode := int64(2)
-	if !AppConfig.RequestTrashItems {
-		trashMode = 1
+	values := map[string]any{
+		"1.4":    pageToken,
+		"1.6":    syncToken,
+		"1.22.1": tMode,
 	}
-	field1_1 := buildMediaListMetadataOptions(mediaMetadataFields, trashMode)
-	writeProtobufField(&buf, 1, field1_1)
-
-	// field1.2 - page size limit (varint)
+	// 1.2 = page size; the template's value applies without a limit
 	if limit > 0 {
-		writeProtobufVarint(&buf, 2, int64(limit))
+		values["1.2"] = int64(limit)
 	}
-
-	// field1.3 - album and collection options
-	albumOptions := []int{2, 3, 7, 8, 14, 16, 17, 18, 19, 20, 21, 22, 23, 27, 29, 30, 31, 32, 34, 37, 38, 39, 41}
-	field1_3 := buildEmptyNestedMessage(albumOptions)
-	writeProtobufField(&buf, 3, field1_3)
-
-	// field1.4 - pagination token (string) - this is the only field that changes between requests
-	// The value comes from the previous response's field 1.1
-	if pageToken != "" {
-		writeProtobufString(&buf, 4, pageToken)
-	}
-
-	// field1.6 - sync token (string) - for incremental updates
-	// The value comes from the previous response's field 1.6
-	if syncToken != "" {
-		writeProtobufString(&buf, 6, syncToken)
-	}
-
-	// field1.7 - type (varint = 2)
-	writeProtobufVarint(&buf, 7, 2)
-
-	// field1.11 - repeated ints [1, 2]
-	writeProtobufVarint(&buf, 11, 1)
-	writeProtobufVarint(&buf, 11, 2)
-
-	// field1.22 - some config including Trigger Mode
-	// 1.22.1: Trigger Mode (1=Active, 2=Passive)
-	var field22 bytes.Buffer
-	tMode := int64(2)
-	if triggerMode == 1 {
-		tMode = 1
-	}
-	writeProtobufVarint(&field22, 1, tMode)
-	writeProtobufField(&buf, 22, field22.Bytes())
-
-	return buf.Bytes()
-}
-
-func buildMediaListMetadataOptions(fields []int, trashMode int64) []byte {
-	// Target shape:
-	// 1.1 = { 1: { 1: { <fields> } } }
-	var inner bytes.Buffer
-
-	for _, f := range fields {
-		if f == 21 {
-			continue
-		}
-		writeProtobufString(&inner, f, "")
-	}
-
-	// field 21 - nested message (override)
-	// 21: { 1: <1|2>, 5: { 3: "" } }
-	var field21 bytes.Buffer
-	writeProtobufVarint(&field21, 1, trashMode)
-	var field21_5 bytes.Buffer
-	writeProtobufString(&field21_5, 3, "")
-	writeProtobufField(&field21, 5, field21_5.Bytes())
-	writeProtobufField(&inner, 21, field21.Bytes())
-
-	var level2 bytes.Buffer
-	writeProtobufField(&level2, 1, inner.Bytes())
-	var level1 bytes.Buffer
-	writeProtobufField(&level1, 1, level2.Bytes())
-	return level1.Bytes()
-}
-
-func buildMediaListRequestField2() []byte {
-	var buf bytes.Buffer
-	// Empty nested structure for field 2
-	var field2_1 bytes.Buffer
-	var field2_1_1 bytes.Buffer
-	var field2_1_1_1 bytes.Buffer
-	writeProtobufField(&field2_1_1_1, 1, []byte{})
-	writeProtobufField(&field2_1_1, 1, field2_1_1_1.Bytes())
-	writeProtobufField(&field2_1_1, 2, []byte{})
-	writeProtobufField(&field2_1, 1, field2_1_1.Bytes())
-	writeProtobufField(&buf, 1, field2_1.Bytes())
-	writeProtobufField(&buf, 2, []byte{})
-	return buf.Bytes()
-}
-
-func buildEmptyNestedMessage(fields []int) []byte {
-	var buf bytes.Buffer
-	for _, f := range fields {
-		writeProtobufField(&buf, f, []byte{})
-	}
-	return buf.Bytes()
+	return buildTemplateRequest("media-list", values)
 }
 
 // writeProtobufField writes a length-delimited protobuf field
@@ -2804,8 +2435,11 @@ func isPrintableString(data []byte) bool {
 // This uses a specific protobuf format for requesting album lists
 // pageToken should be passed from previous responses for proper pagination
 func (a *Api) GetAlbumList(pageToken string) (*AlbumListResult, error) {
-	// Build the request using the exact protobuf structure
-	requestData := buildAlbumListRequest(pageToken)
+	// Build the request from the album-list template, see requesttemplates.go
+	requestData, err := buildAlbumListRequest(pageToken)
+	if err != nil {
+		return nil, err
+	}
 
 	// Get the bearer token
 	bearerToken, err := a.BearerToken()
@@ -2849,7 +2483,7 @@ func (a *Api) GetAlbumList(pageToken string) (*AlbumListResult, error) {
 	// Check for errors
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	// Handle gzip response if needed
@@ -2877,825 +2511,10 @@ func (a *Api) GetAlbumList(pageToken string) (*AlbumListResult, error) {
 	return result, nil
 }
 
-// buildAlbumListRequest creates the protobuf request for fetching album list
-// According to the provided format, only field 1.4 (pageToken) changes between requests
-func buildAlbumListRequest(pageToken string) []byte {
-	var buf bytes.Buffer
-
-	// Build field 1 (main request data)
-	field1 := buildAlbumListRequestField1(pageToken)
-	writeProtobufField(&buf, 1, field1)
-
-	// Build field 2 (additional options)
-	field2 := buildAlbumListRequestField2()
-	writeProtobufField(&buf, 2, field2)
-
-	return buf.Bytes()
-}
-
-// buildAlbumListRequestField1 builds the complex nested field 1 structure
-func buildAlbumListRequestField1(pageToken string) []byte {
-	var buf bytes.Buffer
-
-	// field 1.1 - nested message with media/album metadata options
-	field1_1 := buildAlbumListField1_1()
-	writeProtobufField(&buf, 1, field1_1)
-
-	// field 1.2 - nested message with various options
-	field1_2 := buildAlbumListField1_2()
-	writeProtobufField(&buf, 2, field1_2)
-
-	// field 1.3 - nested message with collection options
-	field1_3 := buildAlbumListField1_3()
-	writeProtobufField(&buf, 3, field1_3)
-
-	// field 1.4 - pagination token (string) - THE ONLY FIELD THAT CHANGES
-	if pageToken != "" {
-		writeProtobufString(&buf, 4, pageToken)
-	}
-
-	// field 1.7 - type (varint = 2)
-	writeProtobufVarint(&buf, 7, 2)
-
-	// field 1.9 - nested message
-	field1_9 := buildAlbumListField1_9()
-	writeProtobufField(&buf, 9, field1_9)
-
-	// field 1.11 - repeated ints [1, 2, 6]
-	writeProtobufVarint(&buf, 11, 1)
-	writeProtobufVarint(&buf, 11, 2)
-	writeProtobufVarint(&buf, 11, 6)
-
-	// field 1.12 - nested message
-	field1_12 := buildAlbumListField1_12()
-	writeProtobufField(&buf, 12, field1_12)
-
-	// field 1.13 - empty string
-	writeProtobufString(&buf, 13, "")
-
-	// field 1.15 - nested message
-	field1_15 := buildAlbumListField1_15()
-	writeProtobufField(&buf, 15, field1_15)
-
-	// field 1.18 - nested message with specific ID
-	field1_18 := buildAlbumListField1_18()
-	writeProtobufField(&buf, 18, field1_18)
-
-	// field 1.19 - nested message
-	field1_19 := buildAlbumListField1_19()
-	writeProtobufField(&buf, 19, field1_19)
-
-	// field 1.20 - nested message
-	field1_20 := buildAlbumListField1_20()
-	writeProtobufField(&buf, 20, field1_20)
-
-	// field 1.21 - nested message
-	field1_21 := buildAlbumListField1_21()
-	writeProtobufField(&buf, 21, field1_21)
-
-	// field 1.22 - nested message
-	field1_22 := buildAlbumListField1_22()
-	writeProtobufField(&buf, 22, field1_22)
-
-	// field 1.25 - nested message
-	field1_25 := buildAlbumListField1_25()
-	writeProtobufField(&buf, 25, field1_25)
-
-	// field 1.26 - empty string
-	writeProtobufString(&buf, 26, "")
-
-	return buf.Bytes()
-}
-
-// buildAlbumListField1_1 builds field 1.1 - media/album metadata options
-func buildAlbumListField1_1() []byte {
-	var buf bytes.Buffer
-
-	// field 1.1.1 - nested message with all metadata fields
-	var field1_1_1 bytes.Buffer
-
-	// Empty fields: 1, 3, 4, 6, 15, 16, 17, 19, 20, 25, 31, 32, 34, 36, 37, 38, 39, 40, 41, 42
-	emptyFields := []int{1, 3, 4, 6, 15, 16, 17, 19, 20, 25, 31, 32, 34, 36, 37, 38, 39, 40, 41, 42}
-	for _, f := range emptyFields {
-		writeProtobufString(&field1_1_1, f, "")
-	}
-
-	// field 1.1.1.5 - nested message
-	var field5 bytes.Buffer
-	for _, f := range []int{1, 2, 3, 4, 5, 7} {
-		writeProtobufString(&field5, f, "")
-	}
-	writeProtobufField(&field1_1_1, 5, field5.Bytes())
-
-	// field 1.1.1.7 - nested message
-	var field7 bytes.Buffer
-	writeProtobufString(&field7, 2, "")
-	writeProtobufField(&field1_1_1, 7, field7.Bytes())
-
-	// field 1.1.1.21 - nested message
-	var field21 bytes.Buffer
-	var field21_5 bytes.Buffer
-	writeProtobufString(&field21_5, 3, "")
-	writeProtobufField(&field21, 5, field21_5.Bytes())
-	writeProtobufString(&field21, 6, "")
-	var field21_7 bytes.Buffer
-	writeProtobufVarint(&field21_7, 2, 0)
-	writeProtobufVarint(&field21_7, 3, 1)
-	writeProtobufField(&field21, 7, field21_7.Bytes())
-	writeProtobufField(&field1_1_1, 21, field21.Bytes())
-
-	// field 1.1.1.30 - nested message
-	var field30 bytes.Buffer
-	writeProtobufString(&field30, 2, "")
-	writeProtobufField(&field1_1_1, 30, field30.Bytes())
-
-	// field 1.1.1.33 - nested message
-	var field33 bytes.Buffer
-	writeProtobufString(&field33, 1, "")
-	writeProtobufField(&field1_1_1, 33, field33.Bytes())
-
-	writeProtobufField(&buf, 1, field1_1_1.Bytes())
-	return buf.Bytes()
-}
-
-// buildAlbumListField1_2 builds field 1.2 - complex nested options
-func buildAlbumListField1_2() []byte {
-	var buf bytes.Buffer
-
-	// field 1.2.1 - nested message
-	var field1_2_1 bytes.Buffer
-	for _, f := range []int{2, 3, 4, 5, 7, 8, 10, 12, 18} {
-		writeProtobufString(&field1_2_1, f, "")
-	}
-
-	// field 1.2.1.6 - nested
-	var field1_2_1_6 bytes.Buffer
-	for _, f := range []int{1, 2, 3, 4, 5, 7} {
-		writeProtobufString(&field1_2_1_6, f, "")
-	}
-	writeProtobufField(&field1_2_1, 6, field1_2_1_6.Bytes())
-
-	// field 1.2.1.13 - nested
-	var field1_2_1_13 bytes.Buffer
-	writeProtobufString(&field1_2_1_13, 2, "")
-	writeProtobufString(&field1_2_1_13, 3, "")
-	writeProtobufField(&field1_2_1, 13, field1_2_1_13.Bytes())
-
-	// field 1.2.1.15 - nested
-	var field1_2_1_15 bytes.Buffer
-	writeProtobufString(&field1_2_1_15, 1, "")
-	writeProtobufField(&field1_2_1, 15, field1_2_1_15.Bytes())
-
-	writeProtobufField(&buf, 1, field1_2_1.Bytes())
-
-	// field 1.2.4 - nested
-	var field1_2_4 bytes.Buffer
-	var field1_2_4_1 bytes.Buffer
-	writeProtobufString(&field1_2_4_1, 1, "")
-	writeProtobufField(&field1_2_4, 1, field1_2_4_1.Bytes())
-	writeProtobufField(&buf, 4, field1_2_4.Bytes())
-
-	// field 1.2.9 - empty
-	writeProtobufString(&buf, 9, "")
-
-	// field 1.2.11 - nested
-	var field1_2_11 bytes.Buffer
-	var field1_2_11_1 bytes.Buffer
-	for _, f := range []int{1, 4, 5, 6, 9} {
-		writeProtobufString(&field1_2_11_1, f, "")
-	}
-	writeProtobufField(&field1_2_11, 1, field1_2_11_1.Bytes())
-	writeProtobufField(&buf, 11, field1_2_11.Bytes())
-
-	// field 1.2.14 - complex nested
-	var field1_2_14 bytes.Buffer
-	var field1_2_14_1 bytes.Buffer
-
-	// field 1.2.14.1.1
-	var field1_2_14_1_1 bytes.Buffer
-	writeProtobufString(&field1_2_14_1_1, 1, "")
-
-	// field 1.2.14.1.1.2
-	var field1_2_14_1_1_2 bytes.Buffer
-	var field1_2_14_1_1_2_2 bytes.Buffer
-	var field1_2_14_1_1_2_2_1 bytes.Buffer
-	writeProtobufString(&field1_2_14_1_1_2_2_1, 1, "")
-	writeProtobufField(&field1_2_14_1_1_2_2, 1, field1_2_14_1_1_2_2_1.Bytes())
-	writeProtobufString(&field1_2_14_1_1_2_2, 3, "")
-	writeProtobufField(&field1_2_14_1_1_2, 2, field1_2_14_1_1_2_2.Bytes())
-	writeProtobufField(&field1_2_14_1_1, 2, field1_2_14_1_1_2.Bytes())
-
-	// field 1.2.14.1.1.3
-	var field1_2_14_1_1_3 bytes.Buffer
-
-	// field 1.2.14.1.1.3.4
-	var field1_2_14_1_1_3_4 bytes.Buffer
-	var field1_2_14_1_1_3_4_1 bytes.Buffer
-	writeProtobufString(&field1_2_14_1_1_3_4_1, 1, "")
-	writeProtobufField(&field1_2_14_1_1_3_4, 1, field1_2_14_1_1_3_4_1.Bytes())
-	writeProtobufString(&field1_2_14_1_1_3_4, 3, "")
-	writeProtobufField(&field1_2_14_1_1_3, 4, field1_2_14_1_1_3_4.Bytes())
-
-	// field 1.2.14.1.1.3.5
-	var field1_2_14_1_1_3_5 bytes.Buffer
-	var field1_2_14_1_1_3_5_1 bytes.Buffer
-	writeProtobufString(&field1_2_14_1_1_3_5_1, 1, "")
-	writeProtobufField(&field1_2_14_1_1_3_5, 1, field1_2_14_1_1_3_5_1.Bytes())
-	writeProtobufString(&field1_2_14_1_1_3_5, 3, "")
-	writeProtobufField(&field1_2_14_1_1_3, 5, field1_2_14_1_1_3_5.Bytes())
-
-	writeProtobufField(&field1_2_14_1_1, 3, field1_2_14_1_1_3.Bytes())
-	writeProtobufField(&field1_2_14_1, 1, field1_2_14_1_1.Bytes())
-	writeProtobufString(&field1_2_14_1, 2, "")
-	writeProtobufField(&field1_2_14, 1, field1_2_14_1.Bytes())
-	writeProtobufField(&buf, 14, field1_2_14.Bytes())
-
-	// field 1.2.17 - empty
-	writeProtobufString(&buf, 17, "")
-
-	// field 1.2.18 - nested
-	var field1_2_18 bytes.Buffer
-	writeProtobufString(&field1_2_18, 1, "")
-	var field1_2_18_2 bytes.Buffer
-	writeProtobufString(&field1_2_18_2, 1, "")
-	writeProtobufField(&field1_2_18, 2, field1_2_18_2.Bytes())
-	writeProtobufField(&buf, 18, field1_2_18.Bytes())
-
-	// field 1.2.20 - nested
-	var field1_2_20 bytes.Buffer
-	var field1_2_20_2 bytes.Buffer
-	writeProtobufString(&field1_2_20_2, 1, "")
-	writeProtobufString(&field1_2_20_2, 2, "")
-	writeProtobufField(&field1_2_20, 2, field1_2_20_2.Bytes())
-	writeProtobufField(&buf, 20, field1_2_20.Bytes())
-
-	// field 1.2.22 and 1.2.23 - empty
-	writeProtobufString(&buf, 22, "")
-	writeProtobufString(&buf, 23, "")
-
-	return buf.Bytes()
-}
-
-// buildAlbumListField1_3 builds field 1.3 - collection options
-func buildAlbumListField1_3() []byte {
-	var buf bytes.Buffer
-
-	// field 1.3.2 - empty
-	writeProtobufString(&buf, 2, "")
-
-	// field 1.3.3 - nested with many empty fields
-	var field1_3_3 bytes.Buffer
-	emptyFields := []int{2, 3, 7, 8, 16, 18, 19, 20, 21, 22, 23, 29, 30, 31, 32, 34, 37, 38, 39, 41, 47}
-	for _, f := range emptyFields {
-		writeProtobufString(&field1_3_3, f, "")
-	}
-
-	// field 1.3.3.14
-	var field1_3_3_14 bytes.Buffer
-	writeProtobufString(&field1_3_3_14, 1, "")
-	writeProtobufField(&field1_3_3, 14, field1_3_3_14.Bytes())
-
-	// field 1.3.3.17
-	var field1_3_3_17 bytes.Buffer
-	writeProtobufString(&field1_3_3_17, 2, "")
-	writeProtobufField(&field1_3_3, 17, field1_3_3_17.Bytes())
-
-	// field 1.3.3.27
-	var field1_3_3_27 bytes.Buffer
-	writeProtobufString(&field1_3_3_27, 1, "")
-	var field1_3_3_27_2 bytes.Buffer
-	writeProtobufString(&field1_3_3_27_2, 1, "")
-	writeProtobufField(&field1_3_3_27, 2, field1_3_3_27_2.Bytes())
-	writeProtobufField(&field1_3_3, 27, field1_3_3_27.Bytes())
-
-	// field 1.3.3.45
-	var field1_3_3_45 bytes.Buffer
-	var field1_3_3_45_1 bytes.Buffer
-	writeProtobufString(&field1_3_3_45_1, 1, "")
-	writeProtobufField(&field1_3_3_45, 1, field1_3_3_45_1.Bytes())
-	writeProtobufField(&field1_3_3, 45, field1_3_3_45.Bytes())
-
-	// field 1.3.3.46
-	var field1_3_3_46 bytes.Buffer
-	writeProtobufString(&field1_3_3_46, 1, "")
-	var field1_3_3_46_2 bytes.Buffer
-	var field1_3_3_46_2_1 bytes.Buffer
-	writeProtobufString(&field1_3_3_46_2_1, 1, "")
-	writeProtobufField(&field1_3_3_46_2, 1, field1_3_3_46_2_1.Bytes())
-	writeProtobufField(&field1_3_3_46, 2, field1_3_3_46_2.Bytes())
-	writeProtobufString(&field1_3_3_46, 3, "")
-	writeProtobufField(&field1_3_3, 46, field1_3_3_46.Bytes())
-
-	writeProtobufField(&buf, 3, field1_3_3.Bytes())
-
-	// field 1.3.4 - nested
-	var field1_3_4 bytes.Buffer
-	writeProtobufString(&field1_3_4, 2, "")
-	var field1_3_4_3 bytes.Buffer
-	writeProtobufString(&field1_3_4_3, 1, "")
-	writeProtobufField(&field1_3_4, 3, field1_3_4_3.Bytes())
-	writeProtobufString(&field1_3_4, 4, "")
-	var field1_3_4_5 bytes.Buffer
-	writeProtobufString(&field1_3_4_5, 1, "")
-	writeProtobufField(&field1_3_4, 5, field1_3_4_5.Bytes())
-	writeProtobufField(&buf, 4, field1_3_4.Bytes())
-
-	// field 1.3.7 - empty
-	writeProtobufString(&buf, 7, "")
-
-	// field 1.3.8 - nested
-	var field1_3_8 bytes.Buffer
-	var field1_3_8_2 bytes.Buffer
-	writeProtobufVarint(&field1_3_8_2, 1, 1)
-	writeProtobufVarint(&field1_3_8_2, 2, 1)
-	writeProtobufField(&field1_3_8, 2, field1_3_8_2.Bytes())
-	writeProtobufField(&buf, 8, field1_3_8.Bytes())
-
-	// fields 1.3.12, 1.3.13, 1.3.15, 1.3.18, 1.3.20, 1.3.22, 1.3.24, 1.3.25
-	for _, f := range []int{12, 13, 15, 18, 20, 22, 24, 25} {
-		writeProtobufString(&buf, f, "")
-	}
-
-	// field 1.3.14 - complex nested
-	field1_3_14 := buildAlbumListField1_3_14()
-	writeProtobufField(&buf, 14, field1_3_14)
-
-	// field 1.3.16 - nested
-	var field1_3_16 bytes.Buffer
-	writeProtobufString(&field1_3_16, 1, "")
-	writeProtobufField(&buf, 16, field1_3_16.Bytes())
-
-	// field 1.3.19 - nested
-	field1_3_19 := buildAlbumListField1_3_19()
-	writeProtobufField(&buf, 19, field1_3_19)
-
-	return buf.Bytes()
-}
-
-// buildAlbumListField1_3_14 builds the complex nested field 1.3.14
-func buildAlbumListField1_3_14() []byte {
-	var buf bytes.Buffer
-
-	// field 1.3.14.1 - empty
-	writeProtobufString(&buf, 1, "")
-
-	// field 1.3.14.2 - nested
-	var field1_3_14_2 bytes.Buffer
-	writeProtobufString(&field1_3_14_2, 1, "")
-	var field1_3_14_2_2 bytes.Buffer
-	writeProtobufString(&field1_3_14_2_2, 1, "")
-	writeProtobufField(&field1_3_14_2, 2, field1_3_14_2_2.Bytes())
-	writeProtobufString(&field1_3_14_2, 3, "")
-	var field1_3_14_2_4 bytes.Buffer
-	writeProtobufString(&field1_3_14_2_4, 1, "")
-	writeProtobufField(&field1_3_14_2, 4, field1_3_14_2_4.Bytes())
-	writeProtobufField(&buf, 2, field1_3_14_2.Bytes())
-
-	// field 1.3.14.3 - nested
-	var field1_3_14_3 bytes.Buffer
-	writeProtobufString(&field1_3_14_3, 1, "")
-	var field1_3_14_3_2 bytes.Buffer
-	writeProtobufString(&field1_3_14_3_2, 1, "")
-	writeProtobufField(&field1_3_14_3, 2, field1_3_14_3_2.Bytes())
-	writeProtobufString(&field1_3_14_3, 3, "")
-	writeProtobufString(&field1_3_14_3, 4, "")
-	writeProtobufField(&buf, 3, field1_3_14_3.Bytes())
-
-	return buf.Bytes()
-}
-
-// buildAlbumListField1_3_19 builds field 1.3.19
-func buildAlbumListField1_3_19() []byte {
-	var buf bytes.Buffer
-
-	// field 1.3.19.4 - nested
-	var field1_3_19_4 bytes.Buffer
-	writeProtobufString(&field1_3_19_4, 2, "")
-	writeProtobufField(&buf, 4, field1_3_19_4.Bytes())
-
-	// field 1.3.19.6 - nested
-	var field1_3_19_6 bytes.Buffer
-	writeProtobufString(&field1_3_19_6, 2, "")
-	writeProtobufString(&field1_3_19_6, 3, "")
-	writeProtobufField(&buf, 6, field1_3_19_6.Bytes())
-
-	// field 1.3.19.7 - nested
-	var field1_3_19_7 bytes.Buffer
-	writeProtobufString(&field1_3_19_7, 2, "")
-	writeProtobufString(&field1_3_19_7, 3, "")
-	writeProtobufField(&buf, 7, field1_3_19_7.Bytes())
-
-	// field 1.3.19.8 - empty
-	writeProtobufString(&buf, 8, "")
-
-	return buf.Bytes()
-}
-
-// buildAlbumListField1_9 builds field 1.9
-func buildAlbumListField1_9() []byte {
-	var buf bytes.Buffer
-
-	// field 1.9.1 - nested
-	var field1_9_1 bytes.Buffer
-	var field1_9_1_2 bytes.Buffer
-	writeProtobufString(&field1_9_1_2, 1, "")
-	writeProtobufString(&field1_9_1_2, 2, "")
-	writeProtobufField(&field1_9_1, 2, field1_9_1_2.Bytes())
-	writeProtobufField(&buf, 1, field1_9_1.Bytes())
-
-	// field 1.9.2 - nested
-	var field1_9_2 bytes.Buffer
-	var field1_9_2_3 bytes.Buffer
-	writeProtobufVarint(&field1_9_2_3, 2, 1)
-	writeProtobufField(&field1_9_2, 3, field1_9_2_3.Bytes())
-	writeProtobufField(&buf, 2, field1_9_2.Bytes())
-
-	// field 1.9.3 - nested
-	var field1_9_3 bytes.Buffer
-	writeProtobufString(&field1_9_3, 2, "")
-	writeProtobufField(&buf, 3, field1_9_3.Bytes())
-
-	// field 1.9.4 - empty
-	writeProtobufString(&buf, 4, "")
-
-	// field 1.9.7 - nested
-	var field1_9_7 bytes.Buffer
-	writeProtobufString(&field1_9_7, 1, "")
-	writeProtobufField(&buf, 7, field1_9_7.Bytes())
-
-	// field 1.9.8 - nested
-	var field1_9_8 bytes.Buffer
-	writeProtobufVarint(&field1_9_8, 1, 2)
-	// repeated field
-	for _, v := range []int64{1, 2, 3, 5, 6} {
-		writeProtobufVarint(&field1_9_8, 2, v)
-	}
-	writeProtobufField(&buf, 8, field1_9_8.Bytes())
-
-	// field 1.9.9 - empty
-	writeProtobufString(&buf, 9, "")
-
-	return buf.Bytes()
-}
-
-// buildAlbumListField1_12 builds field 1.12
-func buildAlbumListField1_12() []byte {
-	var buf bytes.Buffer
-
-	// field 1.12.2 - nested
-	var field1_12_2 bytes.Buffer
-	writeProtobufString(&field1_12_2, 1, "")
-	writeProtobufString(&field1_12_2, 2, "")
-	writeProtobufField(&buf, 2, field1_12_2.Bytes())
-
-	// field 1.12.3 - nested
-	var field1_12_3 bytes.Buffer
-	writeProtobufString(&field1_12_3, 1, "")
-	writeProtobufField(&buf, 3, field1_12_3.Bytes())
-
-	// field 1.12.4 - empty
-	writeProtobufString(&buf, 4, "")
-
-	return buf.Bytes()
-}
-
-// buildAlbumListField1_15 builds field 1.15
-func buildAlbumListField1_15() []byte {
-	var buf bytes.Buffer
-
-	// field 1.15.3 - nested
-	var field1_15_3 bytes.Buffer
-	writeProtobufVarint(&field1_15_3, 1, 1)
-	writeProtobufField(&buf, 3, field1_15_3.Bytes())
-
-	return buf.Bytes()
-}
-
-// buildAlbumListField1_18 builds field 1.18
-func buildAlbumListField1_18() []byte {
-	var buf bytes.Buffer
-
-	// field 1.18 contains a specific ID (169945741) as the field number
-	var field169945741 bytes.Buffer
-	var field169945741_1 bytes.Buffer
-	var field169945741_1_1 bytes.Buffer
-
-	// repeated field
-	for _, v := range []int64{2, 1, 6, 8, 10, 15, 18, 13, 17, 19, 14, 20} {
-		writeProtobufVarint(&field169945741_1_1, 4, v)
-	}
-	writeProtobufVarint(&field169945741_1_1, 5, 6)
-	writeProtobufVarint(&field169945741_1_1, 6, 2)
-	writeProtobufVarint(&field169945741_1_1, 7, 1)
-	writeProtobufVarint(&field169945741_1_1, 8, 2)
-	writeProtobufVarint(&field169945741_1_1, 11, 3)
-	writeProtobufVarint(&field169945741_1_1, 12, 1)
-	writeProtobufVarint(&field169945741_1_1, 13, 3)
-	writeProtobufVarint(&field169945741_1_1, 15, 1)
-	writeProtobufVarint(&field169945741_1_1, 16, 1)
-	writeProtobufVarint(&field169945741_1_1, 17, 1)
-	writeProtobufVarint(&field169945741_1_1, 18, 2)
-
-	writeProtobufField(&field169945741_1, 1, field169945741_1_1.Bytes())
-	writeProtobufField(&field169945741, 1, field169945741_1.Bytes())
-	writeProtobufField(&buf, 169945741, field169945741.Bytes())
-
-	return buf.Bytes()
-}
-
-// buildAlbumListField1_19 builds field 1.19
-func buildAlbumListField1_19() []byte {
-	var buf bytes.Buffer
-
-	// field 1.19.1 - nested
-	var field1_19_1 bytes.Buffer
-	writeProtobufString(&field1_19_1, 1, "")
-	writeProtobufString(&field1_19_1, 2, "")
-	writeProtobufField(&buf, 1, field1_19_1.Bytes())
-
-	// field 1.19.2 - nested with repeated
-	var field1_19_2 bytes.Buffer
-	for _, v := range []int64{1, 2, 4, 6, 5, 7} {
-		writeProtobufVarint(&field1_19_2, 1, v)
-	}
-	writeProtobufField(&buf, 2, field1_19_2.Bytes())
-
-	// field 1.19.3 - nested
-	var field1_19_3 bytes.Buffer
-	writeProtobufString(&field1_19_3, 1, "")
-	writeProtobufString(&field1_19_3, 2, "")
-	writeProtobufField(&buf, 3, field1_19_3.Bytes())
-
-	// field 1.19.5 - nested
-	var field1_19_5 bytes.Buffer
-	writeProtobufString(&field1_19_5, 1, "")
-	writeProtobufString(&field1_19_5, 2, "")
-	writeProtobufField(&buf, 5, field1_19_5.Bytes())
-
-	// field 1.19.6 - nested
-	var field1_19_6 bytes.Buffer
-	writeProtobufString(&field1_19_6, 1, "")
-	writeProtobufField(&buf, 6, field1_19_6.Bytes())
-
-	// field 1.19.7 - nested
-	var field1_19_7 bytes.Buffer
-	writeProtobufString(&field1_19_7, 1, "")
-	writeProtobufString(&field1_19_7, 2, "")
-	writeProtobufField(&buf, 7, field1_19_7.Bytes())
-
-	// field 1.19.8 - nested
-	var field1_19_8 bytes.Buffer
-	writeProtobufString(&field1_19_8, 1, "")
-	writeProtobufField(&buf, 8, field1_19_8.Bytes())
-
-	return buf.Bytes()
-}
-
-// buildAlbumListField1_20 builds field 1.20
-func buildAlbumListField1_20() []byte {
-	var buf bytes.Buffer
-
-	// field 1.20.1
-	writeProtobufVarint(&buf, 1, 1)
-
-	// field 1.20.3 - nested
-	var field1_20_3 bytes.Buffer
-	writeProtobufString(&field1_20_3, 1, "type.googleapis.com/photos.printing.client.PrintingPromotionSyncOptions")
-
-	var field1_20_3_2 bytes.Buffer
-	var field1_20_3_2_1 bytes.Buffer
-
-	// repeated field
-	for _, v := range []int64{2, 1, 6, 8, 10, 15, 18, 13, 17, 19, 14, 20} {
-		writeProtobufVarint(&field1_20_3_2_1, 4, v)
-	}
-	writeProtobufVarint(&field1_20_3_2_1, 5, 6)
-	writeProtobufVarint(&field1_20_3_2_1, 6, 2)
-	writeProtobufVarint(&field1_20_3_2_1, 7, 1)
-	writeProtobufVarint(&field1_20_3_2_1, 8, 2)
-	writeProtobufVarint(&field1_20_3_2_1, 11, 3)
-	writeProtobufVarint(&field1_20_3_2_1, 12, 1)
-	writeProtobufVarint(&field1_20_3_2_1, 13, 3)
-	writeProtobufVarint(&field1_20_3_2_1, 15, 1)
-	writeProtobufVarint(&field1_20_3_2_1, 16, 1)
-	writeProtobufVarint(&field1_20_3_2_1, 17, 1)
-	writeProtobufVarint(&field1_20_3_2_1, 18, 2)
-
-	writeProtobufField(&field1_20_3_2, 1, field1_20_3_2_1.Bytes())
-	writeProtobufField(&field1_20_3, 2, field1_20_3_2.Bytes())
-	writeProtobufField(&buf, 3, field1_20_3.Bytes())
-
-	return buf.Bytes()
-}
-
-// buildAlbumListField1_21 builds field 1.21
-func buildAlbumListField1_21() []byte {
-	var buf bytes.Buffer
-
-	// field 1.21.2 - nested
-	var field1_21_2 bytes.Buffer
-	writeProtobufString(&field1_21_2, 2, "")
-	writeProtobufString(&field1_21_2, 4, "")
-	writeProtobufString(&field1_21_2, 5, "")
-	writeProtobufField(&buf, 2, field1_21_2.Bytes())
-
-	// field 1.21.3 - nested
-	var field1_21_3 bytes.Buffer
-	var field1_21_3_2 bytes.Buffer
-	writeProtobufVarint(&field1_21_3_2, 1, 1)
-	writeProtobufField(&field1_21_3, 2, field1_21_3_2.Bytes())
-
-	var field1_21_3_4 bytes.Buffer
-	writeProtobufString(&field1_21_3_4, 2, "")
-	var field1_21_3_4_7 bytes.Buffer
-	writeProtobufVarint(&field1_21_3_4_7, 2, 0)
-	writeProtobufField(&field1_21_3_4, 7, field1_21_3_4_7.Bytes())
-	writeProtobufField(&field1_21_3, 4, field1_21_3_4.Bytes())
-
-	writeProtobufString(&field1_21_3, 8, "")
-	writeProtobufField(&buf, 3, field1_21_3.Bytes())
-
-	// field 1.21.5 - nested
-	var field1_21_5 bytes.Buffer
-	writeProtobufString(&field1_21_5, 1, "")
-	writeProtobufField(&buf, 5, field1_21_5.Bytes())
-
-	// field 1.21.6 - nested
-	var field1_21_6 bytes.Buffer
-	writeProtobufString(&field1_21_6, 1, "")
-	var field1_21_6_2 bytes.Buffer
-	writeProtobufString(&field1_21_6_2, 1, "")
-	writeProtobufField(&field1_21_6, 2, field1_21_6_2.Bytes())
-	writeProtobufField(&buf, 6, field1_21_6.Bytes())
-
-	// field 1.21.7 - nested
-	var field1_21_7 bytes.Buffer
-	writeProtobufVarint(&field1_21_7, 1, 2)
-	// repeated field with many values
-	for _, v := range []int64{1, 7, 8, 9, 10, 13, 14, 15, 17, 19, 20, 22, 23, 45, 46, 47, 48, 49, 58, 6, 24, 50, 54, 55, 59, 62, 63, 64, 65, 56, 57, 60, 69} {
-		writeProtobufVarint(&field1_21_7, 2, v)
-	}
-	writeProtobufVarint(&field1_21_7, 3, 1)
-	writeProtobufField(&buf, 7, field1_21_7.Bytes())
-
-	// field 1.21.8 - complex nested
-	var field1_21_8 bytes.Buffer
-
-	// field 1.21.8.3
-	var field1_21_8_3 bytes.Buffer
-	var field1_21_8_3_1 bytes.Buffer
-	var field1_21_8_3_1_1 bytes.Buffer
-
-	var field1_21_8_3_1_1_2 bytes.Buffer
-	writeProtobufVarint(&field1_21_8_3_1_1_2, 1, 1)
-	writeProtobufField(&field1_21_8_3_1_1, 2, field1_21_8_3_1_1_2.Bytes())
-
-	var field1_21_8_3_1_1_4 bytes.Buffer
-	writeProtobufString(&field1_21_8_3_1_1_4, 2, "")
-	var field1_21_8_3_1_1_4_7 bytes.Buffer
-	writeProtobufVarint(&field1_21_8_3_1_1_4_7, 2, 0)
-	writeProtobufField(&field1_21_8_3_1_1_4, 7, field1_21_8_3_1_1_4_7.Bytes())
-	writeProtobufField(&field1_21_8_3_1_1, 4, field1_21_8_3_1_1_4.Bytes())
-
-	writeProtobufString(&field1_21_8_3_1_1, 8, "")
-	writeProtobufField(&field1_21_8_3_1, 1, field1_21_8_3_1_1.Bytes())
-	writeProtobufField(&field1_21_8_3, 1, field1_21_8_3_1.Bytes())
-	writeProtobufString(&field1_21_8_3, 3, "")
-	writeProtobufField(&field1_21_8, 3, field1_21_8_3.Bytes())
-
-	// field 1.21.8.4
-	var field1_21_8_4 bytes.Buffer
-	writeProtobufString(&field1_21_8_4, 1, "")
-	writeProtobufField(&field1_21_8, 4, field1_21_8_4.Bytes())
-
-	// field 1.21.8.5
-	var field1_21_8_5 bytes.Buffer
-	var field1_21_8_5_1 bytes.Buffer
-	var field1_21_8_5_1_2 bytes.Buffer
-	writeProtobufVarint(&field1_21_8_5_1_2, 1, 1)
-	writeProtobufField(&field1_21_8_5_1, 2, field1_21_8_5_1_2.Bytes())
-	var field1_21_8_5_1_4 bytes.Buffer
-	writeProtobufString(&field1_21_8_5_1_4, 2, "")
-	var field1_21_8_5_1_4_7 bytes.Buffer
-	writeProtobufVarint(&field1_21_8_5_1_4_7, 2, 0)
-	writeProtobufField(&field1_21_8_5_1_4, 7, field1_21_8_5_1_4_7.Bytes())
-	writeProtobufField(&field1_21_8_5_1, 4, field1_21_8_5_1_4.Bytes())
-	writeProtobufString(&field1_21_8_5_1, 8, "")
-	writeProtobufField(&field1_21_8_5, 1, field1_21_8_5_1.Bytes())
-	writeProtobufField(&field1_21_8, 5, field1_21_8_5.Bytes())
-
-	// field 1.21.8.6
-	var field1_21_8_6 bytes.Buffer
-
-	// field 1.21.8.6.1
-	var field1_21_8_6_1 bytes.Buffer
-	var field1_21_8_6_1_1 bytes.Buffer
-	var field1_21_8_6_1_1_2 bytes.Buffer
-	writeProtobufVarint(&field1_21_8_6_1_1_2, 1, 1)
-	writeProtobufField(&field1_21_8_6_1_1, 2, field1_21_8_6_1_1_2.Bytes())
-	var field1_21_8_6_1_1_4 bytes.Buffer
-	writeProtobufString(&field1_21_8_6_1_1_4, 2, "")
-	var field1_21_8_6_1_1_4_7 bytes.Buffer
-	writeProtobufVarint(&field1_21_8_6_1_1_4_7, 2, 0)
-	writeProtobufField(&field1_21_8_6_1_1_4, 7, field1_21_8_6_1_1_4_7.Bytes())
-	writeProtobufField(&field1_21_8_6_1_1, 4, field1_21_8_6_1_1_4.Bytes())
-	writeProtobufString(&field1_21_8_6_1_1, 8, "")
-	writeProtobufField(&field1_21_8_6_1, 1, field1_21_8_6_1_1.Bytes())
-	writeProtobufField(&field1_21_8_6, 1, field1_21_8_6_1.Bytes())
-
-	// field 1.21.8.6.2
-	var field1_21_8_6_2 bytes.Buffer
-	var field1_21_8_6_2_1 bytes.Buffer
-	var field1_21_8_6_2_1_2 bytes.Buffer
-	writeProtobufVarint(&field1_21_8_6_2_1_2, 1, 1)
-	writeProtobufField(&field1_21_8_6_2_1, 2, field1_21_8_6_2_1_2.Bytes())
-	var field1_21_8_6_2_1_4 bytes.Buffer
-	writeProtobufString(&field1_21_8_6_2_1_4, 2, "")
-	var field1_21_8_6_2_1_4_7 bytes.Buffer
-	writeProtobufVarint(&field1_21_8_6_2_1_4_7, 2, 0)
-	writeProtobufField(&field1_21_8_6_2_1_4, 7, field1_21_8_6_2_1_4_7.Bytes())
-	writeProtobufField(&field1_21_8_6_2_1, 4, field1_21_8_6_2_1_4.Bytes())
-	writeProtobufString(&field1_21_8_6_2_1, 8, "")
-	writeProtobufField(&field1_21_8_6_2, 1, field1_21_8_6_2_1.Bytes())
-	writeProtobufField(&field1_21_8_6, 2, field1_21_8_6_2.Bytes())
-
-	writeProtobufField(&field1_21_8, 6, field1_21_8_6.Bytes())
-	writeProtobufField(&buf, 8, field1_21_8.Bytes())
-
-	// field 1.21.9
-	var field1_21_9 bytes.Buffer
-	writeProtobufString(&field1_21_9, 1, "")
-	writeProtobufField(&buf, 9, field1_21_9.Bytes())
-
-	// field 1.21.10 - nested
-	var field1_21_10 bytes.Buffer
-	var field1_21_10_1 bytes.Buffer
-	writeProtobufString(&field1_21_10_1, 1, "")
-	writeProtobufField(&field1_21_10, 1, field1_21_10_1.Bytes())
-	for _, f := range []int{3, 5, 7, 9, 10} {
-		writeProtobufString(&field1_21_10, f, "")
-	}
-	var field1_21_10_6 bytes.Buffer
-	writeProtobufString(&field1_21_10_6, 1, "")
-	writeProtobufField(&field1_21_10, 6, field1_21_10_6.Bytes())
-	writeProtobufField(&buf, 10, field1_21_10.Bytes())
-
-	// fields 1.21.11, 1.21.12, 1.21.13, 1.21.14
-	for _, f := range []int{11, 12, 13, 14} {
-		writeProtobufString(&buf, f, "")
-	}
-
-	// field 1.21.19
-	var field1_21_19 bytes.Buffer
-	writeProtobufString(&field1_21_19, 1, "")
-	writeProtobufString(&field1_21_19, 2, "")
-	writeProtobufField(&buf, 19, field1_21_19.Bytes())
-
-	return buf.Bytes()
-}
-
-// buildAlbumListField1_22 builds field 1.22
-func buildAlbumListField1_22() []byte {
-	var buf bytes.Buffer
-	writeProtobufVarint(&buf, 1, 2)
-	return buf.Bytes()
-}
-
-// buildAlbumListField1_25 builds field 1.25
-func buildAlbumListField1_25() []byte {
-	var buf bytes.Buffer
-
-	var field1_25_1 bytes.Buffer
-	var field1_25_1_1 bytes.Buffer
-	var field1_25_1_1_1 bytes.Buffer
-	writeProtobufString(&field1_25_1_1_1, 1, "")
-	writeProtobufField(&field1_25_1_1, 1, field1_25_1_1_1.Bytes())
-	writeProtobufField(&field1_25_1, 1, field1_25_1_1.Bytes())
-	writeProtobufField(&buf, 1, field1_25_1.Bytes())
-
-	writeProtobufString(&buf, 2, "")
-
-	return buf.Bytes()
-}
-
-// buildAlbumListRequestField2 builds field 2 of the request
-func buildAlbumListRequestField2() []byte {
-	var buf bytes.Buffer
-
-	// field 2.1 - nested
-	var field2_1 bytes.Buffer
-	var field2_1_1 bytes.Buffer
-	var field2_1_1_1 bytes.Buffer
-	writeProtobufString(&field2_1_1_1, 1, "")
-	writeProtobufField(&field2_1_1, 1, field2_1_1_1.Bytes())
-	writeProtobufString(&field2_1_1, 2, "")
-	writeProtobufField(&field2_1, 1, field2_1_1.Bytes())
-	writeProtobufField(&buf, 1, field2_1.Bytes())
-
-	// field 2.2 - empty
-	writeProtobufString(&buf, 2, "")
-
-	return buf.Bytes()
+// buildAlbumListRequest fills the album-list template
+// Only field 1.4 (pageToken) changes between requests
+func buildAlbumListRequest(pageToken string) ([]byte, error) {
+	return buildTemplateRequest("album-list", map[string]any{"1.4": pageToken})
 }
 
 // parseAlbumListResponse parses the protobuf response and extracts albums
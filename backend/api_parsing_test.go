@@ -60,3 +60,23 @@ func TestParseMediaListResponse_SkipsGroups(t *testing.T) {
 		t.Fatalf("unexpected item[2] media key: %q", res.Items[2].MediaKey)
 	}
 }
+
+func TestProtobufStringAt(t *testing.T) {
+	// Create album response: field 1 (message) with the album key in 1.1, after an unrelated varint
+	var album bytes.Buffer
+	writeProtobufVarint(&album, 2, 7)
+	writeProtobufString(&album, 1, "AF1Qip_ALBUM_KEY")
+	var top bytes.Buffer
+	writeProtobufVarint(&top, 3, 1)
+	writeProtobufField(&top, 1, album.Bytes())
+
+	if got := protobufStringAt(top.Bytes(), 1, 1); got != "AF1Qip_ALBUM_KEY" {
+		t.Fatalf("protobufStringAt(1.1) = %q", got)
+	}
+	if got := protobufStringAt(top.Bytes(), 1, 3); got != "" {
+		t.Fatalf("protobufStringAt(1.3) = %q, want empty", got)
+	}
+	if got := protobufStringAt([]byte{0x0a, 0x05, 'a'}, 1); got != "" {
+		t.Fatalf("protobufStringAt on truncated data = %q, want empty", got)
+	}
+}
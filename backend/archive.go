@@ -0,0 +1,298 @@
+package backend
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+const (
+	// archiveSeparator joins an archive's path and the name of an entry inside it,
+	// as in archive.zip!/dir/file.jpg
+	archiveSeparator = "!/"
+	// maxIdleTarCursors bounds how many positioned readers are kept per tar archive
+	maxIdleTarCursors = 8
+)
+
+// isArchive reports whether path names an archive uploads can read without extracting it
+func isArchive(p string) bool {
+	name := strings.ToLower(p)
+	return strings.HasSuffix(name, ".zip") || isTarArchive(name)
+}
+
+func isTarArchive(name string) bool {
+	return strings.HasSuffix(name, ".tar") || isGzipArchive(name)
+}
+
+func isGzipArchive(name string) bool {
+	return strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")
+}
+
+// splitArchivePath splits archive.zip!/dir/file.jpg into the archive path and the entry name
+func splitArchivePath(p string) (string, string, bool) {
+	archivePath, name, ok := strings.Cut(p, archiveSeparator)
+	if !ok || !isArchive(archivePath) {
+		return "", "", false
+	}
+	return archivePath, name, true
+}
+
+// archiveEntry is a regular file inside an archive
+type archiveEntry struct {
+	name string // Slash-separated path inside the archive
+	path string // archive.zip!/name
+	info os.FileInfo
+	open func() (io.ReadCloser, error)
+}
+
+// mediaArchive lists the files of a zip or tar archive and reads them in place
+type mediaArchive struct {
+	path    string
+	entries []archiveEntry
+	byName  map[string]int
+	closer  io.Closer
+}
+
+// openArchive opens a zip, tar, tar.gz or tgz archive. Directories, links and the
+// __MACOSX resource forks macOS adds to zips are left out.
+func openArchive(archivePath string) (*mediaArchive, error) {
+	a := &mediaArchive{path: archivePath, byName: map[string]int{}}
+	var err error
+	if isTarArchive(strings.ToLower(archivePath)) {
+		err = a.openTar()
+	} else {
+		err = a.openZip()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive %s: %w", archivePath, err)
+	}
+	return a, nil
+}
+
+func (a *mediaArchive) openZip() error {
+	r, err := zip.OpenReader(a.path)
+	if err != nil {
+		return err
+	}
+	a.closer = r
+	for _, f := range r.File {
+		if f.Mode().IsRegular() {
+			a.add(f.Name, f.FileInfo(), f.Open)
+		}
+	}
+	return nil
+}
+
+func (a *mediaArchive) openTar() error {
+	t := &tarArchive{path: a.path}
+	c, err := t.newCursor()
+	if err != nil {
+		return err
+	}
+	defer c.file.Close()
+	for index := 0; ; index++ {
+		header, err := c.tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if info := header.FileInfo(); info.Mode().IsRegular() {
+			a.add(header.Name, info, func() (io.ReadCloser, error) {
+				return t.openEntry(index)
+			})
+		}
+	}
+	a.closer = t
+	return nil
+}
+
+// add lists an entry under its cleaned name
+func (a *mediaArchive) add(name string, info os.FileInfo, open func() (io.ReadCloser, error)) {
+	name = path.Clean(strings.TrimLeft(name, "/"))
+	if name == "." || name == ".." || strings.HasPrefix(name, "../") ||
+		name == "__MACOSX" || strings.HasPrefix(name, "__MACOSX/") {
+		return
+	}
+	a.byName[name] = len(a.entries)
+	a.entries = append(a.entries, archiveEntry{
+		name: name,
+		path: a.path + archiveSeparator + name,
+		info: info,
+		open: open,
+	})
+}
+
+// entry returns the entry with the given name
+func (a *mediaArchive) entry(name string) (archiveEntry, bool) {
+	i, ok := a.byName[name]
+	if !ok {
+		return archiveEntry{}, false
+	}
+	return a.entries[i], true
+}
+
+// Close closes the archive; entries being read are closed once their readers are
+func (a *mediaArchive) Close() error {
+	if a.closer == nil {
+		return nil
+	}
+	return a.closer.Close()
+}
+
+// tarArchive reads the entries of a tar archive. An entry can only be reached by reading
+// through the ones before it, so readers left positioned after an entry are kept for
+// the next one. Entries are hashed and uploaded roughly in archive order, which keeps
+// a gzip-compressed archive to a few decompression passes.
+type tarArchive struct {
+	path   string
+	mu     sync.Mutex
+	idle   []*tarCursor
+	closed bool
+}
+
+// tarCursor is a sequential reader of a tar archive
+type tarCursor struct {
+	file *os.File
+	tr   *tar.Reader
+	next int // Index of the entry tr.Next returns
+}
+
+func (t *tarArchive) newCursor() (*tarCursor, error) {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return nil, err
+	}
+	// Uncompressed archives are read from the file, which tar.Reader seeks to skip entries
+	var r io.Reader = file
+	if isGzipArchive(strings.ToLower(t.path)) {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		r = gz
+	}
+	return &tarCursor{file: file, tr: tar.NewReader(r)}, nil
+}
+
+// openEntry reads the entry with the given index, starting from the idle cursor closest before it
+func (t *tarArchive) openEntry(index int) (io.ReadCloser, error) {
+	t.mu.Lock()
+	best := -1
+	for i, c := range t.idle {
+		if c.next <= index && (best < 0 || c.next > t.idle[best].next) {
+			best = i
+		}
+	}
+	var c *tarCursor
+	if best >= 0 {
+		c = t.idle[best]
+		t.idle = append(t.idle[:best], t.idle[best+1:]...)
+	}
+	t.mu.Unlock()
+
+	if c == nil {
+		var err error
+		if c, err = t.newCursor(); err != nil {
+			return nil, err
+		}
+	}
+	for c.next <= index {
+		if _, err := c.tr.Next(); err != nil {
+			c.file.Close()
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("failed to read %s: %w", t.path, err)
+		}
+		c.next++
+	}
+	return &tarEntryReader{archive: t, cursor: c}, nil
+}
+
+// release keeps a cursor for later entries, dropping the oldest one if too many are idle
+func (t *tarArchive) release(c *tarCursor) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		c.file.Close()
+		return
+	}
+	t.idle = append(t.idle, c)
+	if len(t.idle) > maxIdleTarCursors {
+		t.idle[0].file.Close()
+		t.idle = t.idle[1:]
+	}
+}
+
+// Close closes the idle cursors; cursors in use are closed when released
+func (t *tarArchive) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	for _, c := range t.idle {
+		c.file.Close()
+	}
+	t.idle = nil
+	return nil
+}
+
+// tarEntryReader reads one entry and hands its cursor back when closed
+type tarEntryReader struct {
+	archive *tarArchive
+	cursor  *tarCursor
+	once    sync.Once
+}
+
+func (r *tarEntryReader) Read(p []byte) (int, error) {
+	return r.cursor.tr.Read(p)
+}
+
+func (r *tarEntryReader) Close() error {
+	r.once.Do(func() { r.archive.release(r.cursor) })
+	return nil
+}
+
+// archiveSet keeps the archives opened while scanning a batch until its uploads are done
+type archiveSet struct {
+	mu       sync.Mutex
+	archives map[string]*mediaArchive
+}
+
+func newArchiveSet() *archiveSet {
+	return &archiveSet{archives: map[string]*mediaArchive{}}
+}
+
+// get opens an archive, or returns it if it is already open
+func (s *archiveSet) get(archivePath string) (*mediaArchive, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a, ok := s.archives[archivePath]; ok {
+		return a, nil
+	}
+	a, err := openArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	s.archives[archivePath] = a
+	return a, nil
+}
+
+// Close closes every archive of the set
+func (s *archiveSet) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range s.archives {
+		a.Close()
+	}
+	s.archives = map[string]*mediaArchive{}
+	return nil
+}
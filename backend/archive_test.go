@@ -0,0 +1,138 @@
+package backend
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writeTarGz writes a gzip-compressed tar holding files in the given order
+func writeTarGz(t *testing.T, path string, files [][2]string, modTime time.Time) {
+	t.Helper()
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	gz := gzip.NewWriter(out)
+	w := tar.NewWriter(gz)
+	for _, file := range files {
+		header := &tar.Header{Name: file[0], Mode: 0o644, Size: int64(len(file[1])), ModTime: modTime, Typeflag: tar.TypeReg}
+		if file[1] == "/" {
+			header = &tar.Header{Name: file[0], Mode: 0o755, ModTime: modTime, Typeflag: tar.TypeDir}
+		}
+		if err := w.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			w.Write([]byte(file[1]))
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readEntry(t *testing.T, job uploadJob) string {
+	t.Helper()
+	r, err := job.reader()
+	if err != nil {
+		t.Fatalf("open %s: %v", job.path, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read %s: %v", job.path, err)
+	}
+	return string(data)
+}
+
+func TestScanUploadFiles_Archives(t *testing.T) {
+	saved := AppConfig
+	defer func() { AppConfig = saved }()
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	shot := time.Date(2020, 5, 6, 7, 8, 9, 0, time.UTC)
+	writeZip(t, filepath.Join(dir, "shoot.zip"), map[string]string{
+		"shoot/a.jpg":            "zip a",
+		"shoot/notes.txt":        "notes",
+		"__MACOSX/shoot/._a.jpg": "resource fork",
+	})
+	writeTarGz(t, filepath.Join(dir, "sub", "day.tar.gz"), [][2]string{
+		{"./day/", "/"},
+		{"./day/1.jpg", "tar one"},
+		{"./day/.thumbs/1.jpg", "thumbnail"},
+		{"./day/2.mp4", "tar two"},
+	}, shot)
+
+	AppConfig = Config{Recursive: true, UploadSkipHidden: true}
+	archives := newArchiveSet()
+	defer archives.Close()
+	jobs, skipped, _, err := scanUploadFiles([]string{dir}, archives)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zipPath := filepath.Join(dir, "shoot.zip")
+	tarPath := filepath.Join(dir, "sub", "day.tar.gz")
+	var paths []string
+	for _, job := range jobs {
+		paths = append(paths, job.path)
+	}
+	want := []string{zipPath + "!/shoot/a.jpg", tarPath + "!/day/1.jpg", tarPath + "!/day/2.mp4"}
+	if !slices.Equal(paths, want) {
+		t.Fatalf("scanned %v, want %v", paths, want)
+	}
+	reasons := map[string]string{}
+	for _, s := range skipped {
+		reasons[s.Path] = s.Reason
+	}
+	if reasons[zipPath+"!/shoot/notes.txt"] != SkipUnsupported || reasons[tarPath+"!/day/.thumbs/1.jpg"] != SkipHidden {
+		t.Errorf("skipped = %v", skipped)
+	}
+
+	// Entries are read in place, in any order and concurrently
+	if got := readEntry(t, jobs[2]); got != "tar two" {
+		t.Errorf("2.mp4 = %q", got)
+	}
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, i := range []int{1, 0, 2} {
+				want := map[int]string{0: "zip a", 1: "tar one", 2: "tar two"}[i]
+				if got := readEntry(t, jobs[i]); got != want {
+					t.Errorf("%s = %q, want %q", jobs[i].path, got, want)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if jobs[0].onDisk() || !jobs[1].info.ModTime().Equal(shot) {
+		t.Errorf("entry info = %v", jobs[1].info.ModTime())
+	}
+	if ts, source := uploadTimestamp(jobs[1]); source != TimestampMtime || !ts.Equal(shot) {
+		t.Errorf("uploadTimestamp = %v from %s, want the entry mtime", ts, source)
+	}
+
+	// Resumed uploads name single entries
+	AppConfig = Config{}
+	jobs, _, _, err = scanUploadFiles([]string{tarPath + "!/day/2.mp4", zipPath + "!/missing.jpg"}, archives)
+	if err != nil || len(jobs) != 1 || readEntry(t, jobs[0]) != "tar two" {
+		t.Errorf("resumed entries = %v, %v", jobs, err)
+	}
+}
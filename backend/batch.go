@@ -0,0 +1,307 @@
+package backend
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+)
+
+const (
+	// batchDownloadWorkers bounds concurrent downloads in a batch
+	batchDownloadWorkers = 4
+	// batchWashWorkers is kept low because each wash downloads and re-uploads a full file
+	batchWashWorkers = 2
+	// batchDeleteChunkSize is how many keys are sent per trash/delete request
+	batchDeleteChunkSize = 100
+)
+
+// Batch operation names reported in results and events
+const (
+	BatchOpDownload          = "download"
+	BatchOpDelete            = "delete"
+	BatchOpPermanentlyDelete = "permanentlyDelete"
+	BatchOpWash              = "wash"
+)
+
+// BatchItemResult is the outcome of one item of a batch operation.
+// It is emitted as "batchItemDone" once the item has been processed.
+type BatchItemResult struct {
+	BatchID   string     `json:"batchId"`
+	Operation string     `json:"operation"`
+	Key       string     `json:"key"`
+	Success   bool       `json:"success"`
+	Cancelled bool       `json:"cancelled,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	Path      string     `json:"path,omitempty"` // Saved file for downloads
+	Item      *MediaItem `json:"item,omitempty"` // Uploaded item for washes
+}
+
+// BatchProgress is the aggregate state of a batch, emitted as "batchProgress"
+// after every processed item and once more when the batch finishes.
+// Items cancelled before they started count in CancelledItems only, not in Done or Failed.
+type BatchProgress struct {
+	BatchID        string `json:"batchId"`
+	Operation      string `json:"operation"`
+	Total          int    `json:"total"`
+	Done           int    `json:"done"`
+	Failed         int    `json:"failed"`
+	CancelledItems int    `json:"cancelledItems"`
+	Finished       bool   `json:"finished"`
+	Cancelled      bool   `json:"cancelled"`
+}
+
+// BatchResult is returned by the batch methods once every item has a result
+type BatchResult struct {
+	BatchProgress
+	Results []BatchItemResult `json:"results"`
+}
+
+// WashTarget identifies an item to wash; the dedup key is needed to delete the original
+type WashTarget struct {
+	MediaKey string `json:"mediaKey"`
+	DedupKey string `json:"dedupKey"`
+}
+
+// batchRun tracks one running batch
+type batchRun struct {
+	m        *MediaBrowser
+	progress BatchProgress
+	results  []BatchItemResult
+	mu       sync.Mutex
+}
+
+// record stores the result for item i and emits per-item and aggregate progress
+func (b *batchRun) record(i int, result BatchItemResult) {
+	result.BatchID = b.progress.BatchID
+	result.Operation = b.progress.Operation
+
+	b.mu.Lock()
+	b.results[i] = result
+	if result.Cancelled {
+		b.progress.CancelledItems++
+	} else {
+		b.progress.Done++
+		if !result.Success {
+			b.progress.Failed++
+		}
+	}
+	progress := b.progress
+	b.mu.Unlock()
+
+	b.m.emit("batchItemDone", result)
+	b.m.emit("batchProgress", progress)
+}
+
+// newBatchID returns a random identifier for batches started without one
+func newBatchID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// startBatch registers a cancellable batch. Callers pick the ID so they can cancel
+// the batch while the call that started it is still running.
+func (m *MediaBrowser) startBatch(batchID string) (string, context.Context, error) {
+	if batchID == "" {
+		batchID = newBatchID()
+	}
+
+	m.batchMu.Lock()
+	defer m.batchMu.Unlock()
+	if m.batches == nil {
+		m.batches = make(map[string]context.CancelFunc)
+	}
+	if _, exists := m.batches[batchID]; exists {
+		return "", nil, fmt.Errorf("batch %s is already running", batchID)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.batches[batchID] = cancel
+	return batchID, ctx, nil
+}
+
+func (m *MediaBrowser) finishBatch(batchID string) {
+	m.batchMu.Lock()
+	defer m.batchMu.Unlock()
+	if cancel, ok := m.batches[batchID]; ok {
+		cancel()
+		delete(m.batches, batchID)
+	}
+}
+
+// CancelBatch stops a running batch. Items that have not started are reported as cancelled;
+// items already in progress run to completion. Returns false if no such batch is running.
+func (m *MediaBrowser) CancelBatch(batchID string) bool {
+	m.batchMu.Lock()
+	defer m.batchMu.Unlock()
+	cancel, ok := m.batches[batchID]
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// runBatch processes keys in chunks of chunkSize using up to workers goroutines.
+// process must return one result per key of the chunk it was given, in order.
+func (m *MediaBrowser) runBatch(batchID, operation string, keys []string, chunkSize, workers int,
+	process func(ctx context.Context, start, end int) []BatchItemResult) (*BatchResult, error) {
+	batchID, ctx, err := m.startBatch(batchID)
+	if err != nil {
+		return nil, err
+	}
+	defer m.finishBatch(batchID)
+
+	run := &batchRun{
+		m: m,
+		progress: BatchProgress{
+			BatchID:   batchID,
+			Operation: operation,
+			Total:     len(keys),
+		},
+		results: make([]BatchItemResult, len(keys)),
+	}
+	m.emit("batchProgress", run.progress)
+
+	type chunk struct{ start, end int }
+	chunks := make(chan chunk)
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range chunks {
+				if ctx.Err() != nil {
+					for i := c.start; i < c.end; i++ {
+						run.record(i, BatchItemResult{Key: keys[i], Cancelled: true, Error: "cancelled"})
+					}
+					continue
+				}
+				for j, result := range process(ctx, c.start, c.end) {
+					run.record(c.start+j, result)
+				}
+			}
+		}()
+	}
+
+	for start := 0; start < len(keys); start += chunkSize {
+		chunks <- chunk{start: start, end: min(start+chunkSize, len(keys))}
+	}
+	close(chunks)
+	wg.Wait()
+
+	result := &BatchResult{BatchProgress: run.progress, Results: run.results}
+	result.Finished = true
+	result.Cancelled = result.CancelledItems > 0
+	m.emit("batchProgress", result.BatchProgress)
+	return result, nil
+}
+
+// chunkResults reports the same outcome for every key of a chunk
+func chunkResults(keys []string, err error) []BatchItemResult {
+	results := make([]BatchItemResult, len(keys))
+	for i, key := range keys {
+		if err != nil {
+			results[i] = BatchItemResult{Key: key, Error: err.Error()}
+		} else {
+			results[i] = BatchItemResult{Key: key, Success: true}
+		}
+	}
+	return results
+}
+
+// DownloadMediaBatch downloads several media items concurrently.
+// Pass a batchID to be able to cancel the batch with CancelBatch; an empty ID generates one.
+func (m *MediaBrowser) DownloadMediaBatch(batchID string, mediaKeys []string) (*BatchResult, error) {
+	return m.runBatch(batchID, BatchOpDownload, mediaKeys, 1, batchDownloadWorkers,
+		func(ctx context.Context, start, end int) []BatchItemResult {
+			key := mediaKeys[start]
+			path, err := m.DownloadMedia(key)
+			if err != nil {
+				return []BatchItemResult{{Key: key, Error: err.Error()}}
+			}
+			return []BatchItemResult{{Key: key, Success: true, Path: path}}
+		})
+}
+
+// DeleteMediaBatch moves media items to trash, sending up to batchDeleteChunkSize keys per request
+func (m *MediaBrowser) DeleteMediaBatch(batchID string, mediaKeys []string) (*BatchResult, error) {
+	api, err := m.getAPI()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API client: %w", err)
+	}
+
+	return m.runBatch(batchID, BatchOpDelete, mediaKeys, batchDeleteChunkSize, 1,
+		func(ctx context.Context, start, end int) []BatchItemResult {
+			return m.deleteValidKeys(mediaKeys[start:end], func(valid []string) error {
+				if err := api.MoveToTrash(valid); err != nil {
+					return fmt.Errorf("failed to move to trash: %w", err)
+				}
+				return nil
+			}, m.markTrashed, minMediaKeyLength)
+		})
+}
+
+// PermanentlyDeleteMediaBatch permanently deletes items by dedup key,
+// sending up to batchDeleteChunkSize keys per request
+func (m *MediaBrowser) PermanentlyDeleteMediaBatch(batchID string, dedupKeys []string) (*BatchResult, error) {
+	api, err := m.getAPI()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API client: %w", err)
+	}
+
+	return m.runBatch(batchID, BatchOpPermanentlyDelete, dedupKeys, batchDeleteChunkSize, 1,
+		func(ctx context.Context, start, end int) []BatchItemResult {
+			return m.deleteValidKeys(dedupKeys[start:end], func(valid []string) error {
+				if err := api.PermanentlyDelete(valid); err != nil {
+					return fmt.Errorf("failed to permanently delete: %w", err)
+				}
+				return nil
+			}, m.markPermanentlyDeleted, 1)
+		})
+}
+
+// deleteValidKeys rejects keys shorter than minLength and sends the rest in one request
+func (m *MediaBrowser) deleteValidKeys(keys []string, send func([]string) error, onSuccess func([]string), minLength int) []BatchItemResult {
+	results := make([]BatchItemResult, len(keys))
+	var valid []string
+	var validIdx []int
+	for i, key := range keys {
+		if len(key) < minLength {
+			results[i] = BatchItemResult{Key: key, Error: "invalid key"}
+			continue
+		}
+		valid = append(valid, key)
+		validIdx = append(validIdx, i)
+	}
+	if len(valid) == 0 {
+		return results
+	}
+
+	err := send(valid)
+	if err == nil {
+		onSuccess(valid)
+	}
+	for j, r := range chunkResults(valid, err) {
+		results[validIdx[j]] = r
+	}
+	return results
+}
+
+// WashMediaBatch washes several items with a small worker pool
+func (m *MediaBrowser) WashMediaBatch(batchID string, targets []WashTarget) (*BatchResult, error) {
+	keys := make([]string, len(targets))
+	for i, t := range targets {
+		keys[i] = t.MediaKey
+	}
+
+	return m.runBatch(batchID, BatchOpWash, keys, 1, batchWashWorkers,
+		func(ctx context.Context, start, end int) []BatchItemResult {
+			target := targets[start]
+			item, err := m.WashMedia(target.MediaKey, target.DedupKey)
+			if err != nil {
+				return []BatchItemResult{{Key: target.MediaKey, Error: err.Error()}}
+			}
+			return []BatchItemResult{{Key: target.MediaKey, Success: true, Item: item}}
+		})
+}
@@ -0,0 +1,45 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRunBatch_ChunksAndCancellation(t *testing.T) {
+	m := &MediaBrowser{}
+	keys := []string{"a", "b", "c", "d", "e"}
+
+	var chunkSizes []int
+	result, err := m.runBatch("test-batch", BatchOpDelete, keys, 2, 1,
+		func(ctx context.Context, start, end int) []BatchItemResult {
+			chunkSizes = append(chunkSizes, end-start)
+			var err error
+			if start == 2 {
+				err = errors.New("rejected")
+			}
+			// Cancel after the second chunk so the last one is never processed
+			if start == 2 && !m.CancelBatch("test-batch") {
+				t.Error("CancelBatch did not find the running batch")
+			}
+			return chunkResults(keys[start:end], err)
+		})
+	if err != nil {
+		t.Fatalf("runBatch: %v", err)
+	}
+
+	if len(chunkSizes) != 2 || chunkSizes[0] != 2 || chunkSizes[1] != 2 {
+		t.Fatalf("unexpected chunks processed: %v", chunkSizes)
+	}
+	// The item cancelled before it started is counted apart from the processed ones
+	if result.Total != 5 || result.Done != 4 || result.Failed != 2 || result.CancelledItems != 1 ||
+		!result.Finished || !result.Cancelled {
+		t.Fatalf("unexpected progress: %+v", result.BatchProgress)
+	}
+	if !result.Results[0].Success || result.Results[2].Error != "rejected" || !result.Results[4].Cancelled {
+		t.Fatalf("unexpected results: %+v", result.Results)
+	}
+	if m.CancelBatch("test-batch") {
+		t.Fatal("finished batch should no longer be cancellable")
+	}
+}
@@ -0,0 +1,680 @@
+package backend
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Upload timestamp policies, which also name the source a commit timestamp came from
+const (
+	TimestampMetadata = "metadata" // Capture time from EXIF, QuickTime or PNG metadata, else the file name, else the mtime
+	TimestampMtime    = "mtime"    // Always the file modification time
+	TimestampFilename = "filename" // A date in the file name, else as TimestampMetadata
+)
+
+const (
+	// maxMetadataBox is the largest metadata block read into memory
+	maxMetadataBox = 4 << 20
+	// maxMetadataBoxes bounds how many boxes, segments or chunks are walked per file
+	maxMetadataBoxes = 4096
+	// quickTimeEpochOffset is the number of seconds between 1904-01-01 and 1970-01-01
+	quickTimeEpochOffset = 2082844800
+)
+
+var errNoCaptureTime = errors.New("no capture time in file metadata")
+
+// uploadTimestamp picks the time a job's file is committed with according to
+// UploadTimestampPolicy, returning it with the source it came from. job.info must be set.
+func uploadTimestamp(job uploadJob) (time.Time, string) {
+	info := job.info
+	policy := normalizeTimestampPolicy(AppConfig.UploadTimestampPolicy)
+	if policy == TimestampMtime {
+		return info.ModTime(), TimestampMtime
+	}
+	if policy == TimestampFilename {
+		if t, _, ok := filenameTime(info.Name()); ok {
+			return t, TimestampFilename
+		}
+	}
+	if t, err := job.captureTime(); err == nil {
+		return t, TimestampMetadata
+	}
+	// Messengers and screenshot tools strip metadata but often date the file name
+	if t, _, ok := filenameTime(info.Name()); ok {
+		return t, TimestampFilename
+	}
+	return info.ModTime(), TimestampMtime
+}
+
+// normalizeTimestampPolicy maps unknown values to the default metadata policy
+func normalizeTimestampPolicy(policy string) string {
+	switch policy {
+	case TimestampMtime, TimestampFilename:
+		return policy
+	default:
+		return TimestampMetadata
+	}
+}
+
+// readCaptureTime reads when a photo or video was taken from its metadata: EXIF
+// DateTimeOriginal in JPEG, HEIF and TIFF-based raw files, the QuickTime movie header
+// in MP4 and MOV, and eXIf, XMP or "Creation Time" chunks in PNG.
+func readCaptureTime(path string) (time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return captureTimeAt(file, info.Size())
+}
+
+// captureTime reads the capture time of a job's file. Archive entries cannot be read at
+// an offset, so only their first maxMetadataBox bytes are searched; movies whose header
+// follows the media data fall back to the file name or the entry's mtime.
+func (j uploadJob) captureTime() (time.Time, error) {
+	if j.onDisk() {
+		return readCaptureTime(j.path)
+	}
+	r, err := j.reader()
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer r.Close()
+	head, err := io.ReadAll(io.LimitReader(r, maxMetadataBox))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return captureTimeAt(bytes.NewReader(head), int64(len(head)))
+}
+
+// captureTimeAt reads the capture time of a file of the given size
+func captureTimeAt(file io.ReaderAt, size int64) (time.Time, error) {
+	var head [12]byte
+	if _, err := file.ReadAt(head[:], 0); err != nil {
+		return time.Time{}, errNoCaptureTime
+	}
+
+	var t time.Time
+	var err error
+	switch {
+	case head[0] == 0xFF && head[1] == 0xD8:
+		t, err = jpegCaptureTime(file, size)
+	case bytes.HasPrefix(head[:], []byte("\x89PNG\r\n\x1a\n")):
+		t, err = pngCaptureTime(file, size)
+	case bytes.HasPrefix(head[:], []byte("II*\x00")) || bytes.HasPrefix(head[:], []byte("MM\x00*")):
+		data := make([]byte, min(size, maxMetadataBox))
+		if _, err = file.ReadAt(data, 0); err == nil || err == io.EOF {
+			t, err = exifCaptureTime(data)
+		}
+	case isISOBoxType(string(head[4:8])):
+		t, err = isoCaptureTime(file, size)
+	default:
+		return time.Time{}, errNoCaptureTime
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !plausibleCaptureTime(t) {
+		return time.Time{}, fmt.Errorf("implausible capture time %s", t)
+	}
+	return t, nil
+}
+
+// plausibleCaptureTime rejects unset camera clocks and dates in the future
+func plausibleCaptureTime(t time.Time) bool {
+	return t.Year() >= 1900 && t.Before(time.Now().Add(24*time.Hour))
+}
+
+// jpegCaptureTime finds the EXIF APP1 segment before the image data
+func jpegCaptureTime(r io.ReaderAt, size int64) (time.Time, error) {
+	offset := int64(2)
+	for range maxMetadataBoxes {
+		var header [4]byte
+		if _, err := r.ReadAt(header[:], offset); err != nil {
+			return time.Time{}, errNoCaptureTime
+		}
+		if header[0] != 0xFF {
+			return time.Time{}, errNoCaptureTime
+		}
+		marker := header[1]
+		if marker == 0xFF {
+			offset++ // Fill byte
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			break // Image data or end of image
+		}
+		length := int64(binary.BigEndian.Uint16(header[2:]))
+		if length < 2 || offset+2+length > size {
+			break
+		}
+		if marker == 0xE1 && length > 8 {
+			data := make([]byte, length-2)
+			if _, err := r.ReadAt(data, offset+4); err != nil {
+				break
+			}
+			// APP1 also carries XMP, so keep looking if this is not EXIF
+			if tiff, ok := bytes.CutPrefix(data, []byte("Exif\x00\x00")); ok {
+				if t, err := exifCaptureTime(tiff); err == nil {
+					return t, nil
+				}
+			}
+		}
+		offset += 2 + length
+	}
+	return time.Time{}, errNoCaptureTime
+}
+
+// EXIF tags read for the capture time
+const (
+	tagDateTime                = 0x0132
+	tagExifIFD                 = 0x8769
+	tagDateTimeOriginal        = 0x9003
+	tagDateTimeDigitized       = 0x9004
+	tagOffsetTime              = 0x9010
+	tagOffsetTimeOriginal      = 0x9011
+	tagOffsetTimeDigitized     = 0x9012
+	exifTypeASCII              = 2
+	exifTypeLong               = 4
+	exifEntrySize              = 12
+	exifMaxEntriesPerDirectory = 1024
+)
+
+// tiffData is a TIFF structure, the container of EXIF
+type tiffData struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// exifCaptureTime reads the capture time from a TIFF header onwards. DateTimeOriginal is
+// preferred, then DateTimeDigitized, then DateTime, each with its offset tag if present.
+func exifCaptureTime(data []byte) (time.Time, error) {
+	if len(data) < 8 {
+		return time.Time{}, errNoCaptureTime
+	}
+	t := tiffData{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return time.Time{}, errNoCaptureTime
+	}
+
+	ifd0 := t.directory(t.order.Uint32(data[4:8]))
+	exif := map[uint16][]byte{}
+	if entry, ok := ifd0[tagExifIFD]; ok {
+		exif = t.directory(t.long(entry))
+	}
+
+	for _, c := range []struct {
+		dir            map[uint16][]byte
+		tag, offsetTag uint16
+	}{
+		{exif, tagDateTimeOriginal, tagOffsetTimeOriginal},
+		{exif, tagDateTimeDigitized, tagOffsetTimeDigitized},
+		{ifd0, tagDateTime, tagOffsetTime},
+	} {
+		entry, ok := c.dir[c.tag]
+		if !ok {
+			continue
+		}
+		offset := ""
+		if e, ok := exif[c.offsetTag]; ok {
+			offset = t.ascii(e)
+		}
+		if when, ok := parseExifTime(t.ascii(entry), offset); ok {
+			return when, nil
+		}
+	}
+	return time.Time{}, errNoCaptureTime
+}
+
+// directory returns the entries of the IFD at offset by tag
+func (t tiffData) directory(offset uint32) map[uint16][]byte {
+	entries := map[uint16][]byte{}
+	start := int(offset)
+	if offset == 0 || start+2 > len(t.data) {
+		return entries
+	}
+	count := min(int(t.order.Uint16(t.data[start:])), exifMaxEntriesPerDirectory)
+	for i := range count {
+		pos := start + 2 + i*exifEntrySize
+		if pos+exifEntrySize > len(t.data) {
+			break
+		}
+		entry := t.data[pos : pos+exifEntrySize]
+		entries[t.order.Uint16(entry)] = entry
+	}
+	return entries
+}
+
+// ascii returns the value of an ASCII entry without its NUL terminator
+func (t tiffData) ascii(entry []byte) string {
+	if t.order.Uint16(entry[2:]) != exifTypeASCII {
+		return ""
+	}
+	count := int(t.order.Uint32(entry[4:]))
+	var value []byte
+	if count <= 4 {
+		value = entry[8 : 8+count]
+	} else {
+		offset := int(t.order.Uint32(entry[8:]))
+		if offset < 0 || offset+count > len(t.data) || offset+count < offset {
+			return ""
+		}
+		value = t.data[offset : offset+count]
+	}
+	return strings.TrimRight(string(value), "\x00 ")
+}
+
+// long returns the value of a LONG entry
+func (t tiffData) long(entry []byte) uint32 {
+	if t.order.Uint16(entry[2:]) != exifTypeLong {
+		return 0
+	}
+	return t.order.Uint32(entry[8:])
+}
+
+// parseExifTime parses an EXIF "2006:01:02 15:04:05" time with an optional "+07:00"
+// offset. Without an offset the time is taken as local time, like cameras record it.
+func parseExifTime(value, offset string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.HasPrefix(value, "0000") {
+		return time.Time{}, false
+	}
+	if len(value) > 19 {
+		value = value[:19]
+	}
+	value = strings.Replace(value, "-", ":", 2)
+	if zone, err := time.Parse("-07:00", strings.TrimSpace(offset)); err == nil {
+		t, err := time.ParseInLocation("2006:01:02 15:04:05", value, zone.Location())
+		return t, err == nil
+	}
+	t, err := time.ParseInLocation("2006:01:02 15:04:05", value, time.Local)
+	return t, err == nil
+}
+
+// isISOBoxType reports whether a file starting with this box type is ISO BMFF (MP4,
+// HEIF) or QuickTime. Old QuickTime files may start without an ftyp box.
+func isISOBoxType(boxType string) bool {
+	switch boxType {
+	case "ftyp", "moov", "mdat", "wide", "free", "skip", "pnot":
+		return true
+	}
+	return false
+}
+
+// isoBox is a box of an ISO BMFF or QuickTime file
+type isoBox struct {
+	boxType string
+	payload int64 // Offset of the payload
+	end     int64
+}
+
+// isoBoxes calls fn for each box between start and end until fn returns false
+func isoBoxes(r io.ReaderAt, start, end int64, fn func(isoBox) bool) {
+	offset := start
+	for range maxMetadataBoxes {
+		if offset+8 > end {
+			return
+		}
+		var header [16]byte
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return
+		}
+		box := isoBox{boxType: string(header[4:8]), payload: offset + 8}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		switch size {
+		case 0: // Extends to the end
+			size = end - offset
+		case 1: // 64-bit size follows the type
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			box.payload += 8
+		}
+		if size < box.payload-offset || offset+size > end {
+			return
+		}
+		box.end = offset + size
+		if !fn(box) {
+			return
+		}
+		offset = box.end
+	}
+}
+
+// isoCaptureTime reads EXIF from a HEIF meta box or the creation time of a QuickTime movie header
+func isoCaptureTime(r io.ReaderAt, size int64) (time.Time, error) {
+	result, err := time.Time{}, errNoCaptureTime
+	isoBoxes(r, 0, size, func(box isoBox) bool {
+		switch box.boxType {
+		case "meta":
+			if t, e := heifCaptureTime(r, box); e == nil {
+				result, err = t, nil
+				return false
+			}
+		case "moov":
+			isoBoxes(r, box.payload, box.end, func(child isoBox) bool {
+				if child.boxType == "mvhd" {
+					if t, e := mvhdCreationTime(r, child); e == nil {
+						result, err = t, nil
+					}
+					return false
+				}
+				return true
+			})
+			return err != nil
+		}
+		return true
+	})
+	return result, err
+}
+
+// mvhdCreationTime reads the creation time of a movie header, which is UTC seconds since 1904
+func mvhdCreationTime(r io.ReaderAt, box isoBox) (time.Time, error) {
+	var data [12]byte
+	if box.end-box.payload < int64(len(data)) {
+		return time.Time{}, errNoCaptureTime
+	}
+	if _, err := r.ReadAt(data[:], box.payload); err != nil {
+		return time.Time{}, errNoCaptureTime
+	}
+	var seconds uint64
+	if data[0] == 1 {
+		seconds = binary.BigEndian.Uint64(data[4:12])
+	} else {
+		seconds = uint64(binary.BigEndian.Uint32(data[4:8]))
+	}
+	if seconds <= quickTimeEpochOffset {
+		return time.Time{}, errNoCaptureTime
+	}
+	return time.Unix(int64(seconds-quickTimeEpochOffset), 0).UTC(), nil
+}
+
+// heifCaptureTime finds the Exif item of a HEIF meta box through its iinf and iloc boxes
+func heifCaptureTime(r io.ReaderAt, meta isoBox) (time.Time, error) {
+	if meta.end-meta.payload > maxMetadataBox || meta.end-meta.payload < 4 {
+		return time.Time{}, errNoCaptureTime
+	}
+	data := make([]byte, meta.end-meta.payload)
+	if _, err := r.ReadAt(data, meta.payload); err != nil {
+		return time.Time{}, errNoCaptureTime
+	}
+	// meta is a full box: version and flags come before its children
+	body := bytes.NewReader(data[4:])
+
+	exifID := uint32(0)
+	var iloc []byte
+	isoBoxes(body, 0, int64(body.Len()), func(box isoBox) bool {
+		payload := data[4+box.payload : 4+box.end]
+		switch box.boxType {
+		case "iinf":
+			exifID = heifExifItem(payload)
+		case "iloc":
+			iloc = payload
+		}
+		return true
+	})
+	if exifID == 0 || iloc == nil {
+		return time.Time{}, errNoCaptureTime
+	}
+
+	exif, err := heifItemData(r, iloc, exifID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// The item starts with the offset of the TIFF header after this 4-byte field
+	if len(exif) < 4 {
+		return time.Time{}, errNoCaptureTime
+	}
+	start := 4 + int(binary.BigEndian.Uint32(exif))
+	if start >= 4 && start < len(exif) {
+		if t, err := exifCaptureTime(exif[start:]); err == nil {
+			return t, nil
+		}
+	}
+	if i := bytes.Index(exif, []byte("Exif\x00\x00")); i >= 0 {
+		return exifCaptureTime(exif[i+6:])
+	}
+	return time.Time{}, errNoCaptureTime
+}
+
+// heifExifItem returns the ID of the Exif item listed in an iinf payload, or 0
+func heifExifItem(payload []byte) uint32 {
+	if len(payload) < 6 {
+		return 0
+	}
+	start := int64(6) // Version, flags and a 16-bit entry count
+	if payload[0] != 0 {
+		start = 8
+	}
+	exifID := uint32(0)
+	body := bytes.NewReader(payload)
+	isoBoxes(body, start, int64(len(payload)), func(box isoBox) bool {
+		infe := payload[box.payload:box.end]
+		if box.boxType != "infe" || len(infe) < 4 {
+			return true
+		}
+		// Versions 2 and 3 hold the item ID, a protection index and the item type
+		switch {
+		case infe[0] == 2 && len(infe) >= 12:
+			if string(infe[8:12]) == "Exif" {
+				exifID = uint32(binary.BigEndian.Uint16(infe[4:6]))
+			}
+		case infe[0] == 3 && len(infe) >= 14:
+			if string(infe[10:14]) == "Exif" {
+				exifID = binary.BigEndian.Uint32(infe[4:8])
+			}
+		}
+		return exifID == 0
+	})
+	return exifID
+}
+
+// heifItemData reads the extents of an item stored in the file, as located by an iloc payload
+func heifItemData(r io.ReaderAt, iloc []byte, itemID uint32) ([]byte, error) {
+	p := &byteParser{data: iloc}
+	version := p.uint(1)
+	p.uint(3) // Flags
+	sizes := p.uint(2)
+	offsetSize, lengthSize := int(sizes>>12&0xF), int(sizes>>8&0xF)
+	baseOffsetSize, indexSize := int(sizes>>4&0xF), int(sizes&0xF)
+	if version == 0 {
+		indexSize = 0
+	}
+	itemCount := p.uint(2)
+	if version == 2 {
+		itemCount = p.uint(4)
+	}
+
+	for i := uint64(0); i < itemCount && p.err == nil; i++ {
+		id := p.uint(2)
+		if version == 2 {
+			id = p.uint(4)
+		}
+		method := uint64(0)
+		if version == 1 || version == 2 {
+			method = p.uint(2) & 0xF
+		}
+		p.uint(2) // Data reference index
+		base := p.uint(baseOffsetSize)
+		extents := p.uint(2)
+
+		var data []byte
+		for range extents {
+			p.uint(indexSize)
+			offset, length := p.uint(offsetSize), p.uint(lengthSize)
+			if uint32(id) != itemID || method != 0 {
+				continue
+			}
+			if length == 0 || length > maxMetadataBox || len(data)+int(length) > maxMetadataBox {
+				return nil, errNoCaptureTime
+			}
+			extent := make([]byte, length)
+			if _, err := r.ReadAt(extent, int64(base+offset)); err != nil {
+				return nil, errNoCaptureTime
+			}
+			data = append(data, extent...)
+		}
+		if uint32(id) == itemID && p.err == nil {
+			if data == nil {
+				return nil, errNoCaptureTime
+			}
+			return data, nil
+		}
+	}
+	return nil, errNoCaptureTime
+}
+
+// byteParser reads big-endian integers of 0 to 8 bytes, remembering the first overrun
+type byteParser struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (p *byteParser) uint(size int) uint64 {
+	if p.err != nil {
+		return 0
+	}
+	if size < 0 || size > 8 || p.pos+size > len(p.data) {
+		p.err = errNoCaptureTime
+		return 0
+	}
+	var v uint64
+	for _, b := range p.data[p.pos : p.pos+size] {
+		v = v<<8 | uint64(b)
+	}
+	p.pos += size
+	return v
+}
+
+// xmpDate matches the capture date properties of an XMP packet, as attributes or elements
+var xmpDate = regexp.MustCompile(`(?:exif:DateTimeOriginal|photoshop:DateCreated|xmp:CreateDate)(?:="|>)([^"<]+)`)
+
+// pngCaptureTime reads the eXIf chunk, XMP in an iTXt chunk, or a "Creation Time" text chunk
+func pngCaptureTime(r io.ReaderAt, size int64) (time.Time, error) {
+	var fromXMP, fromText time.Time
+	offset := int64(8)
+	for range maxMetadataBoxes {
+		var header [8]byte
+		if _, err := r.ReadAt(header[:], offset); err != nil {
+			break
+		}
+		length := int64(binary.BigEndian.Uint32(header[:4]))
+		chunk := string(header[4:8])
+		if chunk == "IEND" || offset+12+length > size {
+			break
+		}
+
+		if length <= maxMetadataBox && (chunk == "eXIf" || chunk == "tEXt" || chunk == "zTXt" || chunk == "iTXt") {
+			data := make([]byte, length)
+			if _, err := r.ReadAt(data, offset+8); err != nil {
+				break
+			}
+			if chunk == "eXIf" {
+				if t, err := exifCaptureTime(data); err == nil {
+					return t, nil
+				}
+			} else if keyword, text, ok := pngText(chunk, data); ok {
+				switch keyword {
+				case "XML:com.adobe.xmp":
+					if m := xmpDate.FindStringSubmatch(text); m != nil && fromXMP.IsZero() {
+						fromXMP, _ = parseTextTime(m[1])
+					}
+				case "Creation Time":
+					if fromText.IsZero() {
+						fromText, _ = parseTextTime(text)
+					}
+				}
+			}
+		}
+		offset += 12 + length
+	}
+
+	switch {
+	case !fromXMP.IsZero():
+		return fromXMP, nil
+	case !fromText.IsZero():
+		return fromText, nil
+	}
+	return time.Time{}, errNoCaptureTime
+}
+
+// pngText returns the keyword and text of a tEXt, zTXt or iTXt chunk
+func pngText(chunk string, data []byte) (string, string, bool) {
+	keyword, rest, ok := bytes.Cut(data, []byte{0})
+	if !ok {
+		return "", "", false
+	}
+	compressed := false
+	switch chunk {
+	case "zTXt":
+		if len(rest) < 1 {
+			return "", "", false
+		}
+		rest, compressed = rest[1:], true
+	case "iTXt":
+		if len(rest) < 2 {
+			return "", "", false
+		}
+		compressed = rest[0] == 1
+		// Skip the compression flag and method, then the language tag and translated keyword
+		parts := bytes.SplitN(rest[2:], []byte{0}, 3)
+		if len(parts) < 3 {
+			return "", "", false
+		}
+		rest = parts[2]
+	}
+	if compressed {
+		reader, err := zlib.NewReader(bytes.NewReader(rest))
+		if err != nil {
+			return "", "", false
+		}
+		defer reader.Close()
+		if rest, err = io.ReadAll(io.LimitReader(reader, maxMetadataBox)); err != nil {
+			return "", "", false
+		}
+	}
+	return string(keyword), string(rest), true
+}
+
+// textTimeLayouts are the formats seen in PNG "Creation Time" chunks and XMP dates
+var textTimeLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC1123Z,
+	time.RFC1123,
+	"2006:01:02 15:04:05-07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006:01:02 15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// parseTextTime parses a free-form date; times without a zone are taken as local time
+func parseTextTime(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range textTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
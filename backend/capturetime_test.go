@@ -0,0 +1,205 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// exifTIFF builds a little-endian TIFF block with DateTimeOriginal and an optional offset
+func exifTIFF(original, offset string) []byte {
+	le := binary.LittleEndian
+	entries := 1
+	if offset != "" {
+		entries = 2
+	}
+	exifIFD := 8 + 2 + 12 + 4
+	dataStart := exifIFD + 2 + 12*entries + 4
+
+	var b bytes.Buffer
+	entry := func(tag, typ uint16, count, value uint32) {
+		binary.Write(&b, le, tag)
+		binary.Write(&b, le, typ)
+		binary.Write(&b, le, count)
+		binary.Write(&b, le, value)
+	}
+	b.WriteString("II")
+	binary.Write(&b, le, uint16(42))
+	binary.Write(&b, le, uint32(8))
+	// IFD0 only points at the Exif IFD
+	binary.Write(&b, le, uint16(1))
+	entry(tagExifIFD, exifTypeLong, 1, uint32(exifIFD))
+	binary.Write(&b, le, uint32(0))
+
+	binary.Write(&b, le, uint16(entries))
+	entry(tagDateTimeOriginal, exifTypeASCII, 20, uint32(dataStart))
+	if offset != "" {
+		entry(tagOffsetTimeOriginal, exifTypeASCII, 7, uint32(dataStart+20))
+	}
+	binary.Write(&b, le, uint32(0))
+	b.WriteString(original + "\x00")
+	if offset != "" {
+		b.WriteString(offset + "\x00")
+	}
+	return b.Bytes()
+}
+
+// isoBoxBytes builds an ISO BMFF box
+func isoBoxBytes(boxType string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	box := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	return append(append(box, boxType...), body...)
+}
+
+func be16(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+func be32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+
+func TestReadCaptureTime(t *testing.T) {
+	dir := t.TempDir()
+	want := time.Date(2019, 7, 4, 10, 11, 12, 0, time.FixedZone("", 2*3600))
+	tiff := exifTIFF("2019:07:04 10:11:12", "+02:00")
+
+	jpeg := []byte{0xFF, 0xD8}
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	jpeg = append(jpeg, 0xFF, 0xE1)
+	jpeg = append(jpeg, be16(uint16(len(app1)+2))...)
+	jpeg = append(jpeg, app1...)
+	jpeg = append(jpeg, 0xFF, 0xDA, 0, 2, 0xFF, 0xD9)
+
+	// HEIF: the meta box lists an Exif item whose data sits in mdat
+	ftyp := isoBoxBytes("ftyp", []byte("heic"), be32(0), []byte("mif1heic"))
+	exifItem := append(append(be32(6), "Exif\x00\x00"...), tiff...)
+	infe := isoBoxBytes("infe", []byte{2, 0, 0, 0}, be16(1), be16(0), []byte("Exif\x00"))
+	iinf := isoBoxBytes("iinf", []byte{0, 0, 0, 0}, be16(1), infe)
+	ilocFor := func(offset uint32) []byte {
+		return isoBoxBytes("iloc", []byte{0, 0, 0, 0, 0x44, 0x00}, be16(1), be16(1), be16(0), be16(1),
+			be32(offset), be32(uint32(len(exifItem))))
+	}
+	meta := isoBoxBytes("meta", []byte{0, 0, 0, 0}, iinf, ilocFor(0))
+	mdatOffset := uint32(len(ftyp) + len(meta) + 8)
+	meta = isoBoxBytes("meta", []byte{0, 0, 0, 0}, iinf, ilocFor(mdatOffset))
+	heic := bytes.Join([][]byte{ftyp, meta, isoBoxBytes("mdat", exifItem)}, nil)
+
+	// MP4: mvhd creation time in seconds since 1904, UTC
+	created := uint32(want.Unix() + quickTimeEpochOffset)
+	mvhd := isoBoxBytes("mvhd", []byte{0, 0, 0, 0}, be32(created), be32(created), be32(1000), be32(0))
+	mp4 := bytes.Join([][]byte{
+		isoBoxBytes("ftyp", []byte("isom"), be32(0), []byte("isom")),
+		isoBoxBytes("mdat", make([]byte, 32)),
+		isoBoxBytes("moov", mvhd),
+	}, nil)
+
+	pngChunk := func(chunk string, data []byte) []byte {
+		out := append(be32(uint32(len(data))), chunk...)
+		return append(append(out, data...), 0, 0, 0, 0)
+	}
+	png := bytes.Join([][]byte{
+		[]byte("\x89PNG\r\n\x1a\n"),
+		pngChunk("IHDR", make([]byte, 13)),
+		pngChunk("tEXt", []byte("Creation Time\x00Thu, 04 Jul 2019 10:11:12 +0200")),
+		pngChunk("IDAT", make([]byte, 8)),
+		pngChunk("IEND", nil),
+	}, nil)
+
+	for name, data := range map[string][]byte{"a.jpg": jpeg, "a.heic": heic, "a.mp4": mp4, "a.png": png} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := readCaptureTime(path)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("%s: capture time %s, want %s", name, got, want)
+		}
+	}
+
+	// Without an offset the EXIF time is local; a zeroed clock is ignored
+	local := filepath.Join(dir, "local.tif")
+	os.WriteFile(local, exifTIFF("2019:07:04 10:11:12", ""), 0o644)
+	if got, err := readCaptureTime(local); err != nil || !got.Equal(time.Date(2019, 7, 4, 10, 11, 12, 0, time.Local)) {
+		t.Errorf("local time: got %s, %v", got, err)
+	}
+	zero := filepath.Join(dir, "zero.tif")
+	os.WriteFile(zero, exifTIFF("0000:00:00 00:00:00", ""), 0o644)
+	if _, err := readCaptureTime(zero); err == nil {
+		t.Error("a zeroed capture time should be ignored")
+	}
+}
+
+func TestUploadTimestampPolicy(t *testing.T) {
+	saved := AppConfig
+	defer func() { AppConfig = saved }()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "IMG_20200102_030405.tif")
+	if err := os.WriteFile(path, exifTIFF("2019:07:04 10:11:12", "+00:00"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(path)
+
+	for policy, want := range map[string]string{
+		TimestampMetadata: TimestampMetadata,
+		TimestampMtime:    TimestampMtime,
+		TimestampFilename: TimestampFilename,
+		"bogus":           TimestampMetadata,
+	} {
+		AppConfig.UploadTimestampPolicy = policy
+		if _, source := uploadTimestamp(uploadJob{path: path, info: info}); source != want {
+			t.Errorf("policy %q: source %q, want %q", policy, source, want)
+		}
+	}
+
+	// Without metadata the file name is used before the mtime
+	AppConfig.UploadTimestampPolicy = TimestampMetadata
+	for name, want := range map[string]string{"IMG-20190704-WA0012.jpg": TimestampFilename, "DSC01234.jpg": TimestampMtime} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte("no metadata"), 0o644)
+		info, _ := os.Stat(path)
+		if _, source := uploadTimestamp(uploadJob{path: path, info: info}); source != want {
+			t.Errorf("%s: source %q, want %q", name, source, want)
+		}
+	}
+}
+
+func TestFilenameTime(t *testing.T) {
+	saved := AppConfig
+	defer func() { AppConfig = saved }()
+	AppConfig.UploadFilenamePatterns = []string{`^export_(?P<day>\d\d)(?P<month>\d\d)(?P<year>\d{4})`, `^msg_(?P<unix>\d{13})`}
+
+	tests := []struct {
+		name    string
+		want    time.Time
+		pattern string
+	}{
+		{"IMG_20190704_101112.jpg", time.Date(2019, 7, 4, 10, 11, 12, 0, time.Local), "datetime"},
+		{"PXL_20230512_183011123.mp4", time.Date(2023, 5, 12, 18, 30, 11, 123e6, time.UTC), "pixel"},
+		{"IMG-20190704-WA0012.jpg", time.Date(2019, 7, 4, 0, 0, 0, 0, time.Local), "whatsapp"},
+		{"Screenshot_20210101-101010.png", time.Date(2021, 1, 1, 10, 10, 10, 0, time.Local), "screenshot"},
+		{"Screenshot 2021-01-01 at 10.10.10.png", time.Date(2021, 1, 1, 10, 10, 10, 0, time.Local), "screenshot"},
+		{"export_04072019.jpg", time.Date(2019, 7, 4, 0, 0, 0, 0, time.Local), AppConfig.UploadFilenamePatterns[0]},
+		{"msg_1562235072000.jpg", time.UnixMilli(1562235072000), AppConfig.UploadFilenamePatterns[1]},
+	}
+	for _, tt := range tests {
+		if got, pattern, ok := filenameTime(tt.name); !ok || !got.Equal(tt.want) || pattern != tt.pattern {
+			t.Errorf("filenameTime(%q) = %s, %q, %v, want %s from %q", tt.name, got, pattern, ok, tt.want, tt.pattern)
+		}
+	}
+	for _, name := range []string{"DSC01234.jpg", "IMG_20191340_000000.jpg", "12345678.jpg", "msg_9999999999999.jpg"} {
+		if got, _, ok := filenameTime(name); ok {
+			t.Errorf("filenameTime(%q) = %s, want no match", name, got)
+		}
+	}
+
+	if err := ValidateFilenamePatterns([]string{`(?P<year>\d{4})`}); err == nil {
+		t.Error("a pattern without month and day groups should be rejected")
+	}
+	if err := ValidateFilenamePatterns([]string{`(?P<year>\d{4}`}); err == nil {
+		t.Error("an invalid regex should be rejected")
+	}
+}
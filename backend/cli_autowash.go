@@ -126,12 +126,12 @@ func performAutoWashCycle(api *Api, db *MediaDB, config AutoWashConfig) error {
 	
 	// Cycle complete: update SyncToken and clear resume token
 	if newSyncToken != "" {
-		db.SyncToken = newSyncToken
+		db.SetSyncToken(newSyncToken)
 		fmt.Println("  [Info] SyncToken updated and saved.")
 	} else if isInitial {
 		fmt.Println("  [Warning] Initial scan completed but NO SyncToken received. Next run might be full scan again.")
 	}
-	db.NextPageToken = ""
+	db.SetNextPageToken("")
 	
 	if err := db.Save(); err != nil {
 		fmt.Printf("Warning: Failed to save final database state: %v\n", err)
@@ -235,7 +235,7 @@ func processItemWash(api *Api, item MediaItem, config AutoWashConfig) error {
 		return err
 	}
 
-	// Use standard CommitUpload (Pixel XL logic inside)
+	// Use standard CommitUpload (device profile logic inside)
 	_, err = api.CommitUpload(commitToken, fileInfo.Name(), sha1Bytes, fileInfo.ModTime().Unix())
 	if err != nil {
 		fmt.Println("Failed (Commit).")
@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/file"
@@ -14,20 +15,46 @@ import (
 )
 
 type Config struct {
-	Credentials                   []string `json:"credentials" koanf:"credentials"`
-	Selected                      string   `json:"selected" koanf:"selected"`
-	Proxy                         string   `json:"proxy" koanf:"proxy"`
-	UseQuota                      bool     `json:"useQuota" koanf:"use_quota"`
-	Saver                         bool     `json:"saver" koanf:"saver"`
-	Recursive                     bool     `json:"recursive" koanf:"recursive"`
-	ForceUpload                   bool     `json:"forceUpload" koanf:"force_upload"`
-	UploadThreads                 int      `json:"uploadThreads" koanf:"upload_threads"`
-	DeleteFromHost                bool     `json:"deleteFromHost" koanf:"delete_from_host"`
-	DisableUnsupportedFilesFilter bool     `json:"disableUnsupportedFilesFilter" koanf:"disable_unsupported_files_filter"`
-	ThumbnailSize                 string   `json:"thumbnailSize" koanf:"thumbnail_size"`
-	UpdateCheckIntervalSeconds    int      `json:"updateCheckIntervalSeconds" koanf:"update_check_interval_seconds"`
-	AutoWashQuotaItems            bool     `json:"autoWashQuotaItems" koanf:"auto_wash_quota_items"`
-	RequestTrashItems             bool     `json:"requestTrashItems" koanf:"request_trash_items"`
+	Credentials                   []string               `json:"credentials" koanf:"credentials"`
+	Selected                      string                 `json:"selected" koanf:"selected"`
+	Proxy                         string                 `json:"proxy" koanf:"proxy"`
+	UseQuota                      bool                   `json:"useQuota" koanf:"use_quota"`
+	Saver                         bool                   `json:"saver" koanf:"saver"`
+	Recursive                     bool                   `json:"recursive" koanf:"recursive"`
+	ForceUpload                   bool                   `json:"forceUpload" koanf:"force_upload"`
+	UploadThreads                 int                    `json:"uploadThreads" koanf:"upload_threads"`
+	HashThreads                   int                    `json:"hashThreads" koanf:"hash_threads"`
+	UploadMaxAttempts             int                    `json:"uploadMaxAttempts" koanf:"upload_max_attempts"`
+	DeleteFromHost                bool                   `json:"deleteFromHost" koanf:"delete_from_host"`
+	PostUploadAction              string                 `json:"postUploadAction" koanf:"post_upload_action"`
+	PostUploadArchiveDir          string                 `json:"postUploadArchiveDir" koanf:"post_upload_archive_dir"`
+	DisableUnsupportedFilesFilter bool                   `json:"disableUnsupportedFilesFilter" koanf:"disable_unsupported_files_filter"`
+	ThumbnailSize                 string                 `json:"thumbnailSize" koanf:"thumbnail_size"`
+	UpdateCheckIntervalSeconds    int                    `json:"updateCheckIntervalSeconds" koanf:"update_check_interval_seconds"`
+	AutoWashQuotaItems            bool                   `json:"autoWashQuotaItems" koanf:"auto_wash_quota_items"`
+	RequestTrashItems             bool                   `json:"requestTrashItems" koanf:"request_trash_items"`
+	ThumbnailCacheMaxMB           int                    `json:"thumbnailCacheMaxMB" koanf:"thumbnail_cache_max_mb"`
+	PrewarmThumbnails             bool                   `json:"prewarmThumbnails" koanf:"prewarm_thumbnails"`
+	DownloadDir                   string                 `json:"downloadDir" koanf:"download_dir"`
+	DownloadNameTemplate          string                 `json:"downloadNameTemplate" koanf:"download_name_template"`
+	DownloadCollisionPolicy       string                 `json:"downloadCollisionPolicy" koanf:"download_collision_policy"`
+	UploadInclude                 []string               `json:"uploadInclude" koanf:"upload_include"`
+	UploadExclude                 []string               `json:"uploadExclude" koanf:"upload_exclude"`
+	UploadMinSize                 string                 `json:"uploadMinSize" koanf:"upload_min_size"`
+	UploadMaxSize                 string                 `json:"uploadMaxSize" koanf:"upload_max_size"`
+	UploadModifiedSince           string                 `json:"uploadModifiedSince" koanf:"upload_modified_since"`
+	UploadModifiedBefore          string                 `json:"uploadModifiedBefore" koanf:"upload_modified_before"`
+	UploadSkipHidden              bool                   `json:"uploadSkipHidden" koanf:"upload_skip_hidden"`
+	UploadSkipSymlinks            bool                   `json:"uploadSkipSymlinks" koanf:"upload_skip_symlinks"`
+	UploadTimestampPolicy         string                 `json:"uploadTimestampPolicy" koanf:"upload_timestamp_policy"`
+	UploadFilenamePatterns        []string               `json:"uploadFilenamePatterns" koanf:"upload_filename_patterns"`
+	UploadLivePhotoPolicy         string                 `json:"uploadLivePhotoPolicy" koanf:"upload_live_photo_policy"`
+	UploadRawJpegPolicy           string                 `json:"uploadRawJpegPolicy" koanf:"upload_raw_jpeg_policy"`
+	UploadStripMetadata           []string               `json:"uploadStripMetadata" koanf:"upload_strip_metadata"`
+	UploadRules                   []UploadRule           `json:"uploadRules" koanf:"upload_rules"`
+	DeviceProfile                 string                 `json:"deviceProfile" koanf:"device_profile"`
+	DeviceProfiles                []DeviceProfile        `json:"deviceProfiles" koanf:"device_profiles"`
+	AccountDeviceProfiles         []AccountDeviceProfile `json:"accountDeviceProfiles" koanf:"account_device_profiles"`
 }
 
 type ConfigManager struct{}
@@ -36,12 +63,21 @@ var AppConfig Config
 var UploadRunning bool = false
 var ConfigPath string
 var DefaultConfig = Config{
-	UploadThreads: 3,
-	ThumbnailSize: "medium",
+	UploadThreads:     3,
+	HashThreads:       defaultHashThreads,
+	UploadMaxAttempts: defaultUploadMaxAttempts,
+	ThumbnailSize:     "medium",
 	// Disabled by default; user can enable in Settings.
 	UpdateCheckIntervalSeconds: 0,
 	AutoWashQuotaItems:         false,
 	RequestTrashItems:          true,
+	ThumbnailCacheMaxMB:        defaultThumbnailCacheMaxMB,
+	DownloadNameTemplate:       defaultDownloadTemplate,
+	DownloadCollisionPolicy:    CollisionRename,
+	UploadTimestampPolicy:      TimestampMetadata,
+	UploadLivePhotoPolicy:      LivePhotoBoth,
+	UploadRawJpegPolicy:        RawJpegBoth,
+	PostUploadAction:           PostUploadKeep,
 }
 
 // ParseAuthString parses an auth string and returns url.Values (exported for CLI use)
@@ -85,6 +121,22 @@ func (g *ConfigManager) SetDeleteFromHost(deleteFromHost bool) {
 	saveAppConfig()
 }
 
+// SetPostUploadAction sets what happens to local files once they are in the library
+func (g *ConfigManager) SetPostUploadAction(action string) error {
+	if err := ValidatePostUploadAction(action); err != nil {
+		return err
+	}
+	AppConfig.PostUploadAction = action
+	saveAppConfig()
+	return nil
+}
+
+// SetPostUploadArchiveDir sets the directory the archive action moves files under
+func (g *ConfigManager) SetPostUploadArchiveDir(dir string) {
+	AppConfig.PostUploadArchiveDir = dir
+	saveAppConfig()
+}
+
 func (g *ConfigManager) SetDisableUnsupportedFilesFilter(disableUnsupportedFilesFilter bool) {
 	AppConfig.DisableUnsupportedFilesFilter = disableUnsupportedFilesFilter
 	saveAppConfig()
@@ -98,6 +150,22 @@ func (g *ConfigManager) SetUploadThreads(uploadThreads int) {
 	saveAppConfig()
 }
 
+func (g *ConfigManager) SetHashThreads(hashThreads int) {
+	if hashThreads < 1 {
+		return
+	}
+	AppConfig.HashThreads = hashThreads
+	saveAppConfig()
+}
+
+func (g *ConfigManager) SetUploadMaxAttempts(attempts int) {
+	if attempts < 1 {
+		return
+	}
+	AppConfig.UploadMaxAttempts = attempts
+	saveAppConfig()
+}
+
 func (g *ConfigManager) SetThumbnailSize(thumbnailSize string) {
 	// Validate thumbnail size
 	// Note: These values must match the frontend implementation in:
@@ -133,6 +201,157 @@ func (g *ConfigManager) SetRequestTrashItems(enabled bool) {
 	saveAppConfig()
 }
 
+func (g *ConfigManager) SetThumbnailCacheMaxMB(maxMB int) {
+	if maxMB < minThumbnailCacheMaxMB {
+		return
+	}
+	AppConfig.ThumbnailCacheMaxMB = maxMB
+	saveAppConfig()
+}
+
+func (g *ConfigManager) SetPrewarmThumbnails(enabled bool) {
+	AppConfig.PrewarmThumbnails = enabled
+	saveAppConfig()
+}
+
+// SetDownloadDir sets the download root; an empty path restores ~/Downloads/gotohp
+func (g *ConfigManager) SetDownloadDir(dir string) {
+	AppConfig.DownloadDir = dir
+	saveAppConfig()
+}
+
+func (g *ConfigManager) SetDownloadNameTemplate(template string) {
+	if template == "" {
+		template = defaultDownloadTemplate
+	}
+	AppConfig.DownloadNameTemplate = template
+	saveAppConfig()
+}
+
+func (g *ConfigManager) SetDownloadCollisionPolicy(policy string) {
+	switch policy {
+	case CollisionRename, CollisionSkip, CollisionOverwrite:
+	default:
+		return
+	}
+	AppConfig.DownloadCollisionPolicy = policy
+	saveAppConfig()
+}
+
+func (g *ConfigManager) SetUploadTimestampPolicy(policy string) {
+	switch policy {
+	case TimestampMetadata, TimestampMtime, TimestampFilename:
+	default:
+		return
+	}
+	AppConfig.UploadTimestampPolicy = policy
+	saveAppConfig()
+}
+
+// SetUploadLivePhotoPolicy sets whether the video of a Live Photo is uploaded with its still
+func (g *ConfigManager) SetUploadLivePhotoPolicy(policy string) {
+	switch policy {
+	case LivePhotoBoth, LivePhotoStill:
+	default:
+		return
+	}
+	AppConfig.UploadLivePhotoPolicy = policy
+	saveAppConfig()
+}
+
+// SetUploadRawJpegPolicy sets which files of a RAW+JPEG pair are uploaded
+func (g *ConfigManager) SetUploadRawJpegPolicy(policy string) {
+	switch policy {
+	case RawJpegBoth, RawJpegRaw, RawJpegJpeg:
+	default:
+		return
+	}
+	AppConfig.UploadRawJpegPolicy = policy
+	saveAppConfig()
+}
+
+// SetUploadStripMetadata sets the tag sets removed from JPEG, PNG and WebP files before
+// upload; an empty list uploads files as they are
+func (g *ConfigManager) SetUploadStripMetadata(sets []string) error {
+	for _, set := range sets {
+		if !slices.Contains(StripTagSets, set) {
+			return fmt.Errorf("unknown tag set %q", set)
+		}
+	}
+	AppConfig.UploadStripMetadata = normalizeStripTagSets(sets)
+	saveAppConfig()
+	return nil
+}
+
+// SetUploadRules sets the rules that pick the quality and device of each uploaded file
+func (g *ConfigManager) SetUploadRules(rules []UploadRule) error {
+	if err := ValidateUploadRules(rules); err != nil {
+		return err
+	}
+	AppConfig.UploadRules = rules
+	saveAppConfig()
+	return nil
+}
+
+// SetDeviceProfiles sets the device profiles defined in the config
+func (g *ConfigManager) SetDeviceProfiles(profiles []DeviceProfile) error {
+	if err := ValidateDeviceProfiles(profiles); err != nil {
+		return err
+	}
+	AppConfig.DeviceProfiles = profiles
+	saveAppConfig()
+	return nil
+}
+
+// GetDeviceProfiles returns the built-in and configured device profiles
+func (g *ConfigManager) GetDeviceProfiles() []DeviceProfile {
+	return DeviceProfiles()
+}
+
+// SetDeviceProfile sets the device profile of accounts without one of their own
+func (g *ConfigManager) SetDeviceProfile(name string) error {
+	if err := ValidateDeviceProfileName(name); err != nil {
+		return err
+	}
+	AppConfig.DeviceProfile = name
+	saveAppConfig()
+	return nil
+}
+
+// SetAccountDeviceProfile sets the device profile of one account; an empty name makes it
+// use DeviceProfile again
+func (g *ConfigManager) SetAccountDeviceProfile(email, name string) error {
+	if !slices.ContainsFunc(AppConfig.Credentials, func(cred string) bool {
+		params, err := url.ParseQuery(cred)
+		return err == nil && params.Get("Email") == email
+	}) {
+		return fmt.Errorf("no credentials found for email %s", email)
+	}
+	if name != "" {
+		if err := ValidateDeviceProfileName(name); err != nil {
+			return err
+		}
+	}
+	AppConfig.AccountDeviceProfiles = slices.DeleteFunc(AppConfig.AccountDeviceProfiles, func(account AccountDeviceProfile) bool {
+		return account.Email == email
+	})
+	if name != "" {
+		AppConfig.AccountDeviceProfiles = append(AppConfig.AccountDeviceProfiles, AccountDeviceProfile{Email: email, Profile: name})
+	}
+	saveAppConfig()
+	return nil
+}
+
+// SetUploadFilenamePatterns sets the regexes tried before the built-in file name presets
+func (g *ConfigManager) SetUploadFilenamePatterns(patterns []string) error {
+	if err := ValidateFilenamePatterns(patterns); err != nil {
+		return err
+	}
+	AppConfig.UploadFilenamePatterns = patterns
+	saveAppConfig()
+	return nil
+}
+
 func (g *ConfigManager) AddCredentials(newAuthString string) error {
 	// Required fields that must be present in the auth string
 	requiredFields := []string{
@@ -220,6 +439,9 @@ func (g *ConfigManager) RemoveCredentials(email string) error {
 	if AppConfig.Selected == email {
 		AppConfig.Selected = ""
 	}
+	AppConfig.AccountDeviceProfiles = slices.DeleteFunc(AppConfig.AccountDeviceProfiles, func(account AccountDeviceProfile) bool {
+		return account.Email == email
+	})
 
 	saveAppConfig()
 	return nil
@@ -315,12 +537,62 @@ func loadAppConfig() Config {
 	if c.UploadThreads < 1 {
 		c.UploadThreads = DefaultConfig.UploadThreads
 	}
+	if c.HashThreads < 1 {
+		c.HashThreads = DefaultConfig.HashThreads
+	}
+	if c.UploadMaxAttempts < 1 {
+		c.UploadMaxAttempts = DefaultConfig.UploadMaxAttempts
+	}
 	if c.ThumbnailSize == "" {
 		c.ThumbnailSize = DefaultConfig.ThumbnailSize
 	}
 	if c.UpdateCheckIntervalSeconds < 0 {
 		c.UpdateCheckIntervalSeconds = DefaultConfig.UpdateCheckIntervalSeconds
 	}
+	if c.ThumbnailCacheMaxMB < minThumbnailCacheMaxMB {
+		c.ThumbnailCacheMaxMB = DefaultConfig.ThumbnailCacheMaxMB
+	}
+	if c.DownloadNameTemplate == "" {
+		c.DownloadNameTemplate = DefaultConfig.DownloadNameTemplate
+	}
+	c.DownloadCollisionPolicy = normalizeCollisionPolicy(c.DownloadCollisionPolicy)
+	c.UploadTimestampPolicy = normalizeTimestampPolicy(c.UploadTimestampPolicy)
+	c.UploadLivePhotoPolicy = normalizeLivePhotoPolicy(c.UploadLivePhotoPolicy)
+	c.UploadRawJpegPolicy = normalizeRawJpegPolicy(c.UploadRawJpegPolicy)
+	c.UploadStripMetadata = normalizeStripTagSets(c.UploadStripMetadata)
+	c.PostUploadAction = normalizePostUploadAction(c.PostUploadAction)
+	c.UploadFilenamePatterns = slices.DeleteFunc(c.UploadFilenamePatterns, func(expr string) bool {
+		if _, err := compileFilenamePattern(expr); err != nil {
+			log.Printf("ignoring %v", err)
+			return true
+		}
+		return false
+	})
+	var rules []UploadRule
+	for i, rule := range c.UploadRules {
+		if err := rule.validate(i); err != nil {
+			log.Printf("ignoring upload rule: %v", err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	c.UploadRules = rules
+	if err := ValidateDeviceProfiles(c.DeviceProfiles); err != nil {
+		log.Printf("ignoring device profiles: %v", err)
+		c.DeviceProfiles = nil
+	}
+	for _, size := range []*string{&c.UploadMinSize, &c.UploadMaxSize} {
+		if _, err := ParseByteSize(*size); err != nil {
+			log.Printf("ignoring upload size limit: %v", err)
+			*size = ""
+		}
+	}
+	for _, date := range []*string{&c.UploadModifiedSince, &c.UploadModifiedBefore} {
+		if _, err := ParseScanDate(*date); err != nil {
+			log.Printf("ignoring upload date limit: %v", err)
+			*date = ""
+		}
+	}
 
 	return c
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 )
@@ -16,6 +17,7 @@ type MediaDB struct {
 	NextPageToken string               `json:"nextPageToken"` // Token for resuming interrupted scans
 	mu            sync.RWMutex
 	path          string
+	sorted        []string // MediaKeys ordered newest first; nil when stale
 }
 
 // NewMediaDB creates or loads a MediaDB from the specified file path
@@ -45,20 +47,33 @@ func (db *MediaDB) Load() error {
 		return err
 	}
 
-	return json.Unmarshal(data, db)
+	if err := json.Unmarshal(data, db); err != nil {
+		return err
+	}
+	if db.Items == nil {
+		db.Items = make(map[string]MediaItem)
+	}
+	db.sorted = nil
+	return nil
 }
 
-// Save writes the database to disk
+// Save writes the database to disk. It takes the write lock, so concurrent saves never
+// share the temp file.
 func (db *MediaDB) Save() error {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	db.mu.Lock()
+	defer db.mu.Unlock()
 
 	data, err := json.MarshalIndent(db, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(db.path, data, 0644)
+	// Write through a temp file so an interrupted save never truncates the database.
+	tmpPath := db.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, db.path)
 }
 
 // UpdateOrAdd adds or updates a media item. Returns true if the item was new or changed.
@@ -69,6 +84,7 @@ func (db *MediaDB) UpdateOrAdd(item MediaItem) bool {
 	existing, exists := db.Items[item.MediaKey]
 	if !exists {
 		db.Items[item.MediaKey] = item
+		db.sorted = nil
 		return true
 	}
 
@@ -91,6 +107,15 @@ func (db *MediaDB) UpdateOrAdd(item MediaItem) bool {
         existing.Filename = item.Filename
         changed = true
     }
+	if existing.MediaType == "" && item.MediaType != "" {
+		existing.MediaType = item.MediaType
+		changed = true
+	}
+	if existing.Timestamp == 0 && item.Timestamp != 0 {
+		existing.Timestamp = item.Timestamp
+		db.sorted = nil
+		changed = true
+	}
 
 	if changed {
 		db.Items[item.MediaKey] = existing
@@ -98,6 +123,139 @@ func (db *MediaDB) UpdateOrAdd(item MediaItem) bool {
 	return changed
 }
 
+// Remove deletes an item by MediaKey. Returns true if the item was present.
+func (db *MediaDB) Remove(mediaKey string) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if _, ok := db.Items[mediaKey]; !ok {
+		return false
+	}
+	delete(db.Items, mediaKey)
+	db.sorted = nil
+	return true
+}
+
+// FindByDedupKey looks up an item by its dedup key (2.21.1).
+func (db *MediaDB) FindByDedupKey(dedupKey string) (MediaItem, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	for _, item := range db.Items {
+		if item.DedupKey == dedupKey {
+			return item, true
+		}
+	}
+	return MediaItem{}, false
+}
+
+// RemoveByDedupKeys deletes the items with the given dedup keys in a single pass over the
+// database and returns their media keys.
+func (db *MediaDB) RemoveByDedupKeys(dedupKeys []string) []string {
+	wanted := make(map[string]bool, len(dedupKeys))
+	for _, dedupKey := range dedupKeys {
+		if dedupKey != "" {
+			wanted[dedupKey] = true
+		}
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var removed []string
+	for mediaKey, item := range db.Items {
+		if wanted[item.DedupKey] {
+			delete(db.Items, mediaKey)
+			removed = append(removed, mediaKey)
+		}
+	}
+	if len(removed) > 0 {
+		db.sorted = nil
+	}
+	return removed
+}
+
+// Reset drops all items and sync state so the next sync starts with a full scan.
+func (db *MediaDB) Reset() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.Items = make(map[string]MediaItem)
+	db.SyncToken = ""
+	db.NextPageToken = ""
+	db.sorted = nil
+}
+
+// Tokens returns the sync token and the page token an interrupted scan resumes from
+func (db *MediaDB) Tokens() (syncToken, nextPageToken string) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.SyncToken, db.NextPageToken
+}
+
+// Synced reports whether a full scan completed, so incremental syncs can follow
+func (db *MediaDB) Synced() bool {
+	syncToken, _ := db.Tokens()
+	return syncToken != ""
+}
+
+// SetSyncToken sets the token for incremental updates
+func (db *MediaDB) SetSyncToken(token string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.SyncToken = token
+}
+
+// SetNextPageToken sets the page token an interrupted scan resumes from
+func (db *MediaDB) SetNextPageToken(token string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.NextPageToken = token
+}
+
+// Len returns the number of items in the database
+func (db *MediaDB) Len() int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return len(db.Items)
+}
+
+// Page returns up to limit items starting at offset, ordered newest first,
+// along with the total number of items.
+func (db *MediaDB) Page(offset, limit int) ([]MediaItem, int) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.sorted == nil {
+		db.sorted = make([]string, 0, len(db.Items))
+		for key := range db.Items {
+			db.sorted = append(db.sorted, key)
+		}
+		sort.Slice(db.sorted, func(i, j int) bool {
+			a, b := db.Items[db.sorted[i]], db.Items[db.sorted[j]]
+			if a.Timestamp != b.Timestamp {
+				return a.Timestamp > b.Timestamp
+			}
+			return a.MediaKey < b.MediaKey
+		})
+	}
+
+	total := len(db.sorted)
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= total {
+		return []MediaItem{}, total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+
+	items := make([]MediaItem, 0, end-offset)
+	for _, key := range db.sorted[offset:end] {
+		items = append(items, db.Items[key])
+	}
+	return items, total
+}
+
 // GetItem retrieves an item by MediaKey
 func (db *MediaDB) GetItem(mediaKey string) (MediaItem, bool) {
 	db.mu.RLock()
@@ -0,0 +1,189 @@
+package backend
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Built-in device profiles; DeviceProfiles in the config can redefine them by name
+const (
+	DeviceProfilePixelXL = "pixel-xl" // Default client
+	DeviceProfilePixel2  = "pixel-2"  // Model of storage saver commits
+	DeviceProfilePixel8  = "pixel-8"  // Model of commits with UseQuota
+)
+
+// defaultUserAgentTemplate is the Google Photos app user agent, see DeviceProfile.userAgent
+const defaultUserAgentTemplate = "com.google.android.apps.photos/{version} (Linux; U; Android {android}; {language}; {model}; Build/{build}; Cronet/127.0.6510.5) (gzip)"
+
+// DeviceProfile is the Android client the API identifies as. Fields left empty in the
+// config are taken from the built-in profile of the same name, else from pixel-xl.
+type DeviceProfile struct {
+	Name              string `json:"name" koanf:"name"`
+	Model             string `json:"model,omitempty" koanf:"model"`
+	Make              string `json:"make,omitempty" koanf:"make"`
+	AndroidAPIVersion int64  `json:"androidApiVersion,omitempty" koanf:"android_api_version"`
+	AndroidVersion    string `json:"androidVersion,omitempty" koanf:"android_version"`
+	ClientVersionCode int64  `json:"clientVersionCode,omitempty" koanf:"client_version_code"` // Google Photos app version
+	BuildID           string `json:"buildId,omitempty" koanf:"build_id"`
+	// User agent with {version}, {android}, {api}, {language}, {model}, {make} and {build} placeholders
+	UserAgent string `json:"userAgent,omitempty" koanf:"user_agent"`
+}
+
+// AccountDeviceProfile selects the device profile of one account
+type AccountDeviceProfile struct {
+	Email   string `json:"email" koanf:"email"`
+	Profile string `json:"profile" koanf:"profile"`
+}
+
+// CommandDeviceProfile is the profile set with --device for a single command. It takes
+// precedence over AccountDeviceProfiles and DeviceProfile and is never saved.
+var CommandDeviceProfile string
+
+var builtinDeviceProfiles = []DeviceProfile{
+	pixelProfile(DeviceProfilePixelXL, "Pixel XL"),
+	pixelProfile(DeviceProfilePixel2, "Pixel 2"),
+	pixelProfile(DeviceProfilePixel8, "Pixel 8"),
+}
+
+// pixelProfile returns a built-in profile; they differ only in the model
+func pixelProfile(name, model string) DeviceProfile {
+	return DeviceProfile{
+		Name:              name,
+		Model:             model,
+		Make:              "Google",
+		AndroidAPIVersion: 28,
+		AndroidVersion:    "9",
+		ClientVersionCode: 49029607,
+		BuildID:           "PQ2A.190205.001",
+		UserAgent:         defaultUserAgentTemplate,
+	}
+}
+
+// withDefaults fills the empty fields of a profile from base
+func (p DeviceProfile) withDefaults(base DeviceProfile) DeviceProfile {
+	for _, field := range []struct{ value, fallback *string }{
+		{&p.Model, &base.Model},
+		{&p.Make, &base.Make},
+		{&p.AndroidVersion, &base.AndroidVersion},
+		{&p.BuildID, &base.BuildID},
+		{&p.UserAgent, &base.UserAgent},
+	} {
+		if *field.value == "" {
+			*field.value = *field.fallback
+		}
+	}
+	if p.AndroidAPIVersion == 0 {
+		p.AndroidAPIVersion = base.AndroidAPIVersion
+	}
+	if p.ClientVersionCode == 0 {
+		p.ClientVersionCode = base.ClientVersionCode
+	}
+	return p
+}
+
+// userAgent fills the profile's user agent template for a language
+func (p DeviceProfile) userAgent(language string) string {
+	return strings.NewReplacer(
+		"{version}", strconv.FormatInt(p.ClientVersionCode, 10),
+		"{android}", p.AndroidVersion,
+		"{api}", strconv.FormatInt(p.AndroidAPIVersion, 10),
+		"{language}", language,
+		"{model}", p.Model,
+		"{make}", p.Make,
+		"{build}", p.BuildID,
+	).Replace(p.UserAgent)
+}
+
+// authUserAgent returns the user agent of auth token requests
+func (p DeviceProfile) authUserAgent() string {
+	return fmt.Sprintf("GoogleAuth/1.4 (%s %s); gzip", p.Model, p.BuildID)
+}
+
+// DeviceProfiles returns the built-in profiles, as redefined by the config, followed by
+// the profiles the config adds
+func DeviceProfiles() []DeviceProfile {
+	profiles := slices.Clone(builtinDeviceProfiles)
+	for _, custom := range AppConfig.DeviceProfiles {
+		i := slices.IndexFunc(profiles, func(p DeviceProfile) bool { return p.Name == custom.Name })
+		if i >= 0 && i < len(builtinDeviceProfiles) {
+			profiles[i] = custom.withDefaults(builtinDeviceProfiles[i])
+		} else if i < 0 {
+			profiles = append(profiles, custom.withDefaults(builtinDeviceProfiles[0]))
+		}
+	}
+	return profiles
+}
+
+// LookupDeviceProfile returns the profile with the given name
+func LookupDeviceProfile(name string) (DeviceProfile, bool) {
+	profiles := DeviceProfiles()
+	i := slices.IndexFunc(profiles, func(p DeviceProfile) bool { return p.Name == name })
+	if i < 0 {
+		return DeviceProfile{}, false
+	}
+	return profiles[i], true
+}
+
+// DefaultDeviceProfileName returns the profile of accounts without one of their own
+func DefaultDeviceProfileName() string {
+	if AppConfig.DeviceProfile != "" {
+		return AppConfig.DeviceProfile
+	}
+	return DeviceProfilePixelXL
+}
+
+// AccountDeviceProfileName returns the profile an account uses: the --device profile,
+// else the one selected for the account, else the default
+func AccountDeviceProfileName(email string) string {
+	if CommandDeviceProfile != "" {
+		return CommandDeviceProfile
+	}
+	for _, account := range AppConfig.AccountDeviceProfiles {
+		if account.Email == email && account.Profile != "" {
+			return account.Profile
+		}
+	}
+	return DefaultDeviceProfileName()
+}
+
+// accountDeviceProfile resolves the profile an account uses
+func accountDeviceProfile(email string) (DeviceProfile, error) {
+	name := AccountDeviceProfileName(email)
+	profile, ok := LookupDeviceProfile(name)
+	if !ok {
+		return DeviceProfile{}, fmt.Errorf("unknown device profile %q", name)
+	}
+	return profile, nil
+}
+
+// ValidateDeviceProfiles checks the profiles defined in the config
+func ValidateDeviceProfiles(profiles []DeviceProfile) error {
+	seen := map[string]bool{}
+	for i, profile := range profiles {
+		if strings.TrimSpace(profile.Name) == "" {
+			return fmt.Errorf("device profile %d has no name", i+1)
+		}
+		if seen[profile.Name] {
+			return fmt.Errorf("device profile %q is defined twice", profile.Name)
+		}
+		seen[profile.Name] = true
+		if profile.AndroidAPIVersion < 0 || profile.ClientVersionCode < 0 {
+			return fmt.Errorf("device profile %q: versions cannot be negative", profile.Name)
+		}
+	}
+	return nil
+}
+
+// ValidateDeviceProfileName checks that a profile with the given name exists
+func ValidateDeviceProfileName(name string) error {
+	if _, ok := LookupDeviceProfile(name); ok {
+		return nil
+	}
+	names := make([]string, 0, len(DeviceProfiles()))
+	for _, profile := range DeviceProfiles() {
+		names = append(names, profile.Name)
+	}
+	return fmt.Errorf("unknown device profile %q: use one of %s", name, strings.Join(names, ", "))
+}
@@ -0,0 +1,90 @@
+package backend
+
+import "testing"
+
+func TestDeviceProfiles(t *testing.T) {
+	saved, savedCommand := AppConfig, CommandDeviceProfile
+	defer func() { AppConfig, CommandDeviceProfile = saved, savedCommand }()
+
+	AppConfig = Config{
+		Credentials: []string{"Email=a%40example.com&lang=en_US", "Email=b%40example.com&lang=de_DE"},
+		Selected:    "a@example.com",
+	}
+
+	// The built-in default matches the values the client always used
+	api, err := NewApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "com.google.android.apps.photos/49029607 (Linux; U; Android 9; en_US; Pixel XL; Build/PQ2A.190205.001; Cronet/127.0.6510.5) (gzip)"
+	if api.userAgent != want || api.device.Model != "Pixel XL" || api.device.AndroidAPIVersion != 28 {
+		t.Errorf("default client: %q, %s, %d", api.userAgent, api.device.Model, api.device.AndroidAPIVersion)
+	}
+	if got := api.device.authUserAgent(); got != "GoogleAuth/1.4 (Pixel XL PQ2A.190205.001); gzip" {
+		t.Errorf("auth user agent = %q", got)
+	}
+	if got := api.commitDevice(DeviceProfilePixel2); got.Model != "Pixel 2" || got.ClientVersionCode != 49029607 {
+		t.Errorf("commitDevice(pixel-2) = %+v", got)
+	}
+	if got := api.commitDevice("Pixel 5"); got.Model != "Pixel 5" || got.Name != DeviceProfilePixelXL {
+		t.Errorf("commitDevice(model) = %+v", got)
+	}
+
+	// Config profiles redefine built-ins by name and fill empty fields from them
+	AppConfig.DeviceProfiles = []DeviceProfile{
+		{Name: DeviceProfilePixel2, ClientVersionCode: 50000000},
+		{Name: "pixel-7", Model: "Pixel 7", AndroidAPIVersion: 34, AndroidVersion: "14", UserAgent: "photos/{version} ({model}; API {api}; {language})"},
+	}
+	if err := ValidateDeviceProfiles(AppConfig.DeviceProfiles); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := LookupDeviceProfile(DeviceProfilePixel2); got.Model != "Pixel 2" || got.ClientVersionCode != 50000000 {
+		t.Errorf("redefined pixel-2 = %+v", got)
+	}
+	if got := len(DeviceProfiles()); got != 4 {
+		t.Errorf("%d profiles, want 4", got)
+	}
+
+	// --device beats the account's profile, which beats the default
+	AppConfig.DeviceProfile = "pixel-7"
+	AppConfig.AccountDeviceProfiles = []AccountDeviceProfile{{Email: "b@example.com", Profile: DeviceProfilePixel8}}
+	for _, tt := range []struct{ command, email, want string }{
+		{"", "a@example.com", "pixel-7"},
+		{"", "b@example.com", DeviceProfilePixel8},
+		{DeviceProfilePixel2, "b@example.com", DeviceProfilePixel2},
+	} {
+		CommandDeviceProfile = tt.command
+		if got := AccountDeviceProfileName(tt.email); got != tt.want {
+			t.Errorf("AccountDeviceProfileName(%s) with --device %q = %s, want %s", tt.email, tt.command, got, tt.want)
+		}
+	}
+	CommandDeviceProfile = ""
+	if api, err = NewApi(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "photos/49029607 (Pixel 7; API 34; en_US)"; api.userAgent != want || api.device.Make != "Google" {
+		t.Errorf("pixel-7 client: %q, %s", api.userAgent, api.device.Make)
+	}
+
+	// Saver and UseQuota commits keep the account's profile and only change the model
+	AppConfig.Saver = true
+	for _, tt := range []struct {
+		useQuota bool
+		model    string
+	}{{false, "Pixel 2"}, {true, "Pixel 8"}} {
+		AppConfig.UseQuota = tt.useQuota
+		got := api.commitDevice(globalUploadProfile().Device)
+		if got.Name != "pixel-7" || got.Model != tt.model || got.AndroidAPIVersion != 34 {
+			t.Errorf("commit device with UseQuota %v = %+v", tt.useQuota, got)
+		}
+	}
+	AppConfig.Saver, AppConfig.UseQuota = false, false
+
+	CommandDeviceProfile = "nokia"
+	if _, err := NewApi(); err == nil {
+		t.Error("NewApi with an unknown profile succeeded")
+	}
+	if err := ValidateDeviceProfiles([]DeviceProfile{{Name: "x"}, {Name: "x"}}); err == nil {
+		t.Error("duplicate profiles passed validation")
+	}
+}
@@ -0,0 +1,184 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Download collision policies
+const (
+	CollisionRename    = "rename"
+	CollisionSkip      = "skip"
+	CollisionOverwrite = "overwrite"
+)
+
+// defaultDownloadTemplate keeps the original file name directly in the download root
+const defaultDownloadTemplate = "{filename}"
+
+// maxCollisionRenames bounds the search for a free " (n)" suffix
+const maxCollisionRenames = 10000
+
+// downloadRoot returns the configured download directory, defaulting to ~/Downloads/gotohp
+func downloadRoot() (string, error) {
+	if AppConfig.DownloadDir != "" {
+		return AppConfig.DownloadDir, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, "Downloads", "gotohp"), nil
+}
+
+// mediaItemTime converts a MediaItem timestamp to time.Time.
+// The API reports milliseconds for most items; small values are treated as seconds.
+func mediaItemTime(ts int64) time.Time {
+	if ts <= 0 {
+		return time.Time{}
+	}
+	if ts > 1e11 {
+		return time.UnixMilli(ts)
+	}
+	return time.Unix(ts, 0)
+}
+
+// renderDownloadPath expands a download name template into a path relative to the download root.
+// Supported placeholders: {filename}, {name}, {ext}, {key}, {type}, {year}, {month}, {day}, {date}.
+// Date placeholders use the item timestamp and fall back to "unknown" when it is missing.
+// "/" in the template separates directories; every component is sanitized.
+func renderDownloadPath(template string, item MediaItem, filename string) string {
+	if strings.TrimSpace(template) == "" {
+		template = defaultDownloadTemplate
+	}
+
+	ext := filepath.Ext(filename)
+	key := item.MediaKey
+	if len(key) > mediaKeyPrefixLength {
+		key = key[:mediaKeyPrefixLength]
+	}
+	year, month, day, date := "unknown", "unknown", "unknown", "unknown"
+	if t := mediaItemTime(item.Timestamp); !t.IsZero() {
+		t = t.Local()
+		year, month, day, date = t.Format("2006"), t.Format("01"), t.Format("02"), t.Format("2006-01-02")
+	}
+	mediaType := item.MediaType
+	if mediaType == "" {
+		mediaType = "unknown"
+	}
+
+	rendered := strings.NewReplacer(
+		"{filename}", filename,
+		"{name}", strings.TrimSuffix(filename, ext),
+		"{ext}", ext,
+		"{key}", key,
+		"{type}", mediaType,
+		"{year}", year,
+		"{month}", month,
+		"{day}", day,
+		"{date}", date,
+	).Replace(filepath.ToSlash(template))
+
+	var parts []string
+	for _, part := range strings.Split(rendered, "/") {
+		if part = sanitizeFilename(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return sanitizeFilename(filename)
+	}
+	return filepath.Join(parts...)
+}
+
+// sanitizeFilename makes a single path component safe on all supported platforms.
+// Unlike sanitizePathComponent it keeps spaces and non-ASCII characters.
+func sanitizeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(strings.TrimSpace(name), ". ")
+	if name == "." || name == ".." {
+		return ""
+	}
+	return name
+}
+
+// reserveDownloadPath applies the collision policy to path. It returns the path to write to,
+// or skip=true when the existing file should be kept. With the rename and skip policies the
+// chosen path is created empty, so concurrent downloads cannot pick or both fetch the same name.
+func reserveDownloadPath(path string, policy string) (string, bool, error) {
+	switch policy {
+	case CollisionOverwrite:
+		return path, false, nil
+	case CollisionSkip:
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, fs.ErrExist) {
+			return path, true, nil
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("failed to create output file: %w", err)
+		}
+		f.Close()
+		return path, false, nil
+	}
+
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	candidate := path
+	for i := 1; i <= maxCollisionRenames; i++ {
+		f, err := os.OpenFile(candidate, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			f.Close()
+			return candidate, false, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", false, fmt.Errorf("failed to create output file: %w", err)
+		}
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+	return "", false, fmt.Errorf("no free file name for %s", path)
+}
+
+// downloadToReservedPath downloads into a uniquely named ".part" file next to the path
+// reserveDownloadPath returned and moves it into place on success. A failed download leaves
+// an existing file untouched and removes only what this download created.
+func downloadToReservedPath(download func(path string) error, path string, policy string) error {
+	part, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.part")
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	partPath := part.Name()
+	part.Chmod(0644) // CreateTemp uses 0600
+	part.Close()
+
+	if err := download(partPath); err != nil {
+		os.Remove(partPath)
+		if policy != CollisionOverwrite {
+			os.Remove(path) // The empty placeholder reserveDownloadPath created
+		}
+		return err
+	}
+	if err := os.Rename(partPath, path); err != nil {
+		os.Remove(partPath)
+		return fmt.Errorf("failed to move download into place: %w", err)
+	}
+	return nil
+}
+
+// normalizeCollisionPolicy maps unknown values to the default rename policy
+func normalizeCollisionPolicy(policy string) string {
+	switch policy {
+	case CollisionSkip, CollisionOverwrite:
+		return policy
+	default:
+		return CollisionRename
+	}
+}
@@ -0,0 +1,174 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRenderDownloadPath(t *testing.T) {
+	ts := time.Date(2023, 7, 4, 12, 0, 0, 0, time.Local)
+	item := MediaItem{MediaKey: "AF1QipABCDEFGHIJ", Timestamp: ts.UnixMilli(), MediaType: "photo"}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"", "IMG_0001.JPG"},
+		{"{year}/{month}/{filename}", filepath.Join("2023", "07", "IMG_0001.JPG")},
+		{"{date}/{name}_{key}{ext}", filepath.Join("2023-07-04", "IMG_0001_AF1QipABCD.JPG")},
+		{"../{type}/{filename}", filepath.Join("photo", "IMG_0001.JPG")},
+	}
+	for _, tt := range tests {
+		if got := renderDownloadPath(tt.template, item, "IMG_0001.JPG"); got != tt.want {
+			t.Errorf("renderDownloadPath(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+
+	if got := renderDownloadPath("{year}/{filename}", MediaItem{}, "a:b.jpg"); got != filepath.Join("unknown", "a_b.jpg") {
+		t.Errorf("unexpected path for item without timestamp: %q", got)
+	}
+}
+
+func TestReserveDownloadPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "IMG_0001.JPG")
+	if err := os.WriteFile(path, []byte("existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, skip, err := reserveDownloadPath(path, CollisionRename)
+	if err != nil || skip || filepath.Base(got) != "IMG_0001 (1).JPG" {
+		t.Fatalf("rename: got %q skip=%v err=%v", got, skip, err)
+	}
+	// The renamed path is reserved, so the next download picks another name
+	got, _, _ = reserveDownloadPath(path, CollisionRename)
+	if filepath.Base(got) != "IMG_0001 (2).JPG" {
+		t.Fatalf("expected second rename, got %q", got)
+	}
+
+	if got, skip, _ := reserveDownloadPath(path, CollisionSkip); !skip || got != path {
+		t.Fatalf("skip: got %q skip=%v", got, skip)
+	}
+	if got, skip, _ := reserveDownloadPath(path, CollisionOverwrite); skip || got != path {
+		t.Fatalf("overwrite: got %q skip=%v", got, skip)
+	}
+}
+
+func TestDownloadToReservedPath(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "IMG_0001.JPG")
+	failed := func(string) error { return os.ErrDeadlineExceeded }
+
+	// A failed download under overwrite keeps the existing file
+	os.WriteFile(path, []byte("existing"), 0644)
+	if err := downloadToReservedPath(failed, path, CollisionOverwrite); err == nil {
+		t.Fatal("failed download returned no error")
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "existing" {
+		t.Fatalf("existing file after failed overwrite: %q, %v", data, err)
+	}
+
+	// A failed download under rename removes only its placeholder
+	reserved, _, _ := reserveDownloadPath(path, CollisionRename)
+	downloadToReservedPath(failed, reserved, CollisionRename)
+	if _, err := os.Stat(reserved); !os.IsNotExist(err) {
+		t.Errorf("placeholder %s left behind: %v", reserved, err)
+	}
+
+	// A successful download replaces the file and leaves no part file
+	err := downloadToReservedPath(func(part string) error {
+		return os.WriteFile(part, []byte("new"), 0644)
+	}, path, CollisionOverwrite)
+	if data, _ := os.ReadFile(path); err != nil || string(data) != "new" {
+		t.Fatalf("overwrite: %q, %v", data, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("%d files left in the download folder, want 1", len(entries))
+	}
+}
+
+func TestDownloadToReservedPathConcurrent(t *testing.T) {
+	// Two items rendering to the same name, downloaded at the same time as by DownloadMediaBatch
+	for _, policy := range []string{CollisionOverwrite, CollisionSkip, CollisionRename} {
+		t.Run(policy, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "IMG_0001.JPG")
+
+			var started sync.WaitGroup
+			var mu sync.Mutex
+			var parts []string
+			downloaded := 0
+			download := func(content string) func(string) error {
+				return func(part string) error {
+					mu.Lock()
+					parts = append(parts, part)
+					downloaded++
+					mu.Unlock()
+					started.Done()
+					started.Wait() // Both downloads are in flight before either finishes
+					return os.WriteFile(part, []byte(content), 0644)
+				}
+			}
+
+			started.Add(2)
+			var wg sync.WaitGroup
+			errs := make([]error, 2)
+			for i, content := range []string{"first", "second"} {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					reserved, skip, err := reserveDownloadPath(path, policy)
+					if err != nil {
+						errs[i] = err
+						return
+					}
+					if skip {
+						started.Done()
+						return
+					}
+					errs[i] = downloadToReservedPath(download(content), reserved, policy)
+				}()
+			}
+			wg.Wait()
+			for _, err := range errs {
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if len(parts) == 2 && parts[0] == parts[1] {
+				t.Errorf("both downloads wrote %s", parts[0])
+			}
+			entries, _ := os.ReadDir(dir)
+			var names, contents []string
+			for _, entry := range entries {
+				data, _ := os.ReadFile(filepath.Join(dir, entry.Name()))
+				names = append(names, entry.Name())
+				contents = append(contents, string(data))
+			}
+			for _, name := range names {
+				if strings.HasSuffix(name, ".part") {
+					t.Errorf("part file %s left behind", name)
+				}
+			}
+
+			switch policy {
+			case CollisionOverwrite:
+				if downloaded != 2 || len(names) != 1 || (contents[0] != "first" && contents[0] != "second") {
+					t.Errorf("overwrite: %d downloads, files %v %q", downloaded, names, contents)
+				}
+			case CollisionSkip:
+				if downloaded != 1 || len(names) != 1 || contents[0] == "" {
+					t.Errorf("skip: %d downloads, files %v %q", downloaded, names, contents)
+				}
+			case CollisionRename:
+				if downloaded != 2 || len(names) != 2 || contents[0] == contents[1] {
+					t.Errorf("rename: %d downloads, files %v %q", downloaded, names, contents)
+				}
+			}
+		})
+	}
+}
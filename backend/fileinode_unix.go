@@ -0,0 +1,26 @@
+//go:build !windows
+
+package backend
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// fileInode returns the inode number of a file, or 0 if the platform does not expose it
+func fileInode(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
+
+// dirIdentity identifies a directory by device and inode, so a directory reached
+// through different symlinks is recognised as the same one
+func dirIdentity(path string, info os.FileInfo) string {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return fmt.Sprintf("%d:%d", st.Dev, st.Ino)
+	}
+	return path
+}
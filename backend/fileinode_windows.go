@@ -0,0 +1,27 @@
+//go:build windows
+
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// fileInode returns 0 on Windows, where FileInfo does not carry a file index.
+// Size and modification time still identify unchanged files.
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
+
+// dirIdentity identifies a directory by its resolved path, as Windows FileInfo carries
+// no file index. Links and junctions resolve to their target, so loops are still detected.
+func dirIdentity(path string, info os.FileInfo) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return strings.ToLower(path)
+}
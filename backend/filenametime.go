@@ -0,0 +1,134 @@
+package backend
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// filenamePattern reads a capture time from a file name. Patterns use the named groups
+// year, month and day, with optional hour, minute, second and millis, or a unix group
+// holding epoch seconds or milliseconds.
+type filenamePattern struct {
+	name string
+	re   *regexp.Regexp
+	loc  *time.Location // Zone of the digits; unix times are always UTC
+}
+
+// filenamePresets are tried after UploadFilenamePatterns, most specific first
+var filenamePresets = []filenamePattern{
+	// Pixel camera, in UTC: PXL_20230512_183011123.mp4
+	{name: "pixel", loc: time.UTC, re: regexp.MustCompile(
+		`^PXL_(?P<year>\d{4})(?P<month>\d\d)(?P<day>\d\d)_(?P<hour>\d\d)(?P<minute>\d\d)(?P<second>\d\d)(?P<millis>\d{3})`)},
+	// WhatsApp media, date only: IMG-20190704-WA0012.jpg
+	{name: "whatsapp", loc: time.Local, re: regexp.MustCompile(
+		`^(?:IMG|VID|AUD|PTT|STK|DOC)-(?P<year>\d{4})(?P<month>\d\d)(?P<day>\d\d)-WA\d+`)},
+	// Android, macOS and GNOME screenshots: Screenshot_20210101-101010.png,
+	// Screenshot 2021-01-01 at 10.10.10.png, Screenshot from 2021-01-01 10-10-10.png
+	{name: "screenshot", loc: time.Local, re: regexp.MustCompile(
+		`(?i)^screen ?shot[ _-](?:from )?(?P<year>\d{4})-?(?P<month>\d\d)-?(?P<day>\d\d)` +
+			`(?:(?: at |[ _-])(?P<hour>\d\d)[-_.:]?(?P<minute>\d\d)[-_.:]?(?P<second>\d\d))?`)},
+	// Any other date and optional time, such as IMG_20190704_101112.jpg
+	{name: "datetime", loc: time.Local, re: regexp.MustCompile(
+		`(?:^|\D)(?P<year>(?:19|20)\d\d)[-_.]?(?P<month>\d\d)[-_.]?(?P<day>\d\d)` +
+			`(?:\D{1,5}?(?P<hour>\d\d)[-_.:]?(?P<minute>\d\d)[-_.:]?(?P<second>\d\d)|\D|$)`)},
+}
+
+// compileFilenamePattern compiles a user pattern, read as local time
+func compileFilenamePattern(expr string) (filenamePattern, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return filenamePattern{}, fmt.Errorf("invalid file name pattern %q: %w", expr, err)
+	}
+	names := re.SubexpNames()
+	hasDate := slices.Contains(names, "year") && slices.Contains(names, "month") && slices.Contains(names, "day")
+	if !hasDate && !slices.Contains(names, "unix") {
+		return filenamePattern{}, fmt.Errorf("file name pattern %q needs year, month and day groups or a unix group", expr)
+	}
+	return filenamePattern{name: expr, re: re, loc: time.Local}, nil
+}
+
+// ValidateFilenamePatterns checks user file name patterns before they are saved or used
+func ValidateFilenamePatterns(exprs []string) error {
+	for _, expr := range exprs {
+		if _, err := compileFilenamePattern(expr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// match reads a time from name, validating every field
+func (p filenamePattern) match(name string) (time.Time, bool) {
+	m := p.re.FindStringSubmatch(name)
+	if m == nil {
+		return time.Time{}, false
+	}
+	fields := make(map[string]int)
+	for i, group := range p.re.SubexpNames() {
+		if group == "" || m[i] == "" {
+			continue
+		}
+		v, err := strconv.ParseInt(m[i], 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		if group == "unix" {
+			t := time.Unix(v, 0)
+			if len(m[i]) > 10 {
+				t = time.UnixMilli(v)
+			}
+			return t, plausibleCaptureTime(t)
+		}
+		fields[group] = int(v)
+	}
+
+	t := time.Date(fields["year"], time.Month(fields["month"]), fields["day"], fields["hour"], fields["minute"],
+		fields["second"], fields["millis"]*int(time.Millisecond), p.loc)
+	// time.Date normalizes overflow, so a changed field means the digits were not a date
+	if t.Month() != time.Month(fields["month"]) || t.Day() != fields["day"] || t.Hour() != fields["hour"] ||
+		t.Minute() != fields["minute"] || t.Second() != fields["second"] || fields["millis"] > 999 || !plausibleCaptureTime(t) {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// filenamePatternCache holds the compiled UploadFilenamePatterns so files are not recompiling them
+var filenamePatternCache struct {
+	sync.Mutex
+	exprs    []string
+	patterns []filenamePattern
+}
+
+// configFilenamePatterns returns UploadFilenamePatterns followed by the presets.
+// Invalid user patterns are skipped; they are rejected when set.
+func configFilenamePatterns() []filenamePattern {
+	c := &filenamePatternCache
+	c.Lock()
+	defer c.Unlock()
+	if c.patterns != nil && slices.Equal(c.exprs, AppConfig.UploadFilenamePatterns) {
+		return c.patterns
+	}
+	c.exprs = slices.Clone(AppConfig.UploadFilenamePatterns)
+	c.patterns = nil
+	for _, expr := range c.exprs {
+		if p, err := compileFilenamePattern(expr); err == nil {
+			c.patterns = append(c.patterns, p)
+		}
+	}
+	c.patterns = append(c.patterns, filenamePresets...)
+	return c.patterns
+}
+
+// filenameTime reads a capture time from a file name, returning the name of the pattern that matched
+func filenameTime(name string) (time.Time, string, bool) {
+	for _, p := range configFilenamePatterns() {
+		if t, ok := p.match(name); ok {
+			return t, p.name, true
+		}
+	}
+	return time.Time{}, "", false
+}
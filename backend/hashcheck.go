@@ -0,0 +1,88 @@
+package backend
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sync"
+)
+
+// hashCheckConcurrency bounds in-flight existence checks during batched lookups
+const hashCheckConcurrency = 8
+
+// HashLookupError is returned by FindRemoteMediaByHashes when some lookups failed.
+// Hashes in Failed have unknown status; all other hashes were checked.
+type HashLookupError struct {
+	Failed map[string]error // Keyed by base64 SHA1
+}
+
+func (e *HashLookupError) Error() string {
+	for _, err := range e.Failed {
+		return fmt.Sprintf("%d hash lookups failed, first error: %v", len(e.Failed), err)
+	}
+	return "hash lookups failed"
+}
+
+// FindRemoteMediaByHashes checks many SHA1 hashes against the library and returns a map from
+// base64 SHA1 to media key for the hashes that already exist.
+//
+// The HashCheck message carries a single hash, so lookups are pipelined with bounded
+// concurrency over the shared HTTP client rather than packed into one request.
+// Results for successful lookups are returned even when others fail; in that case the
+// error is a *HashLookupError listing the hashes whose status is unknown.
+func (a *Api) FindRemoteMediaByHashes(ctx context.Context, hashes [][]byte) (map[string]string, error) {
+	return findRemoteMediaByHashes(ctx, hashes, a.FindRemoteMediaByHashContext)
+}
+
+// findRemoteMediaByHashes runs lookup for each distinct hash, at most hashCheckConcurrency
+// at a time. No lookups are started once ctx is done.
+func findRemoteMediaByHashes(ctx context.Context, hashes [][]byte, lookup func(context.Context, []byte) (string, error)) (map[string]string, error) {
+	found := make(map[string]string)
+	failed := make(map[string]error)
+	var mu sync.Mutex
+
+	sem := make(chan struct{}, hashCheckConcurrency)
+	var wg sync.WaitGroup
+	seen := make(map[string]bool, len(hashes))
+
+	for _, hash := range hashes {
+		key := base64.StdEncoding.EncodeToString(hash)
+		if len(hash) == 0 || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			// A slot may free up together with the cancellation
+			break
+		}
+
+		wg.Add(1)
+		go func(hash []byte, key string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			mediaKey, err := lookup(ctx, hash)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed[key] = err
+			} else if mediaKey != "" {
+				found[key] = mediaKey
+			}
+		}(hash, key)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return found, ctx.Err()
+	}
+	if len(failed) > 0 {
+		return found, &HashLookupError{Failed: failed}
+	}
+	return found, nil
+}
@@ -0,0 +1,129 @@
+package backend
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFindRemoteMediaByHashes(t *testing.T) {
+	hash := func(i int) []byte { return []byte{byte(i), 0xff} }
+	key := func(i int) string { return base64.StdEncoding.EncodeToString(hash(i)) }
+	errLookup := errors.New("lookup failed")
+
+	// Hashes divisible by 3 are in the library, by 5 fail, and the rest are missing
+	var calls, running, peak atomic.Int32
+	lookup := func(ctx context.Context, h []byte) (string, error) {
+		calls.Add(1)
+		n := running.Add(1)
+		defer running.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(time.Millisecond)
+		switch i := int(h[0]); {
+		case i%5 == 0:
+			return "", errLookup
+		case i%3 == 0:
+			return "media-" + key(i), nil
+		default:
+			return "", nil
+		}
+	}
+
+	var hashes [][]byte
+	for i := 1; i <= 40; i++ {
+		hashes = append(hashes, hash(i))
+	}
+	// Duplicates and empty hashes are looked up at most once
+	hashes = append(hashes, hash(3), hash(5), nil, []byte{})
+
+	found, err := findRemoteMediaByHashes(context.Background(), hashes, lookup)
+	var lookupErr *HashLookupError
+	if !errors.As(err, &lookupErr) {
+		t.Fatalf("error = %v, want a HashLookupError", err)
+	}
+	if calls.Load() != 40 {
+		t.Errorf("%d lookups, want 40", calls.Load())
+	}
+	if p := peak.Load(); p > hashCheckConcurrency {
+		t.Errorf("%d concurrent lookups, want at most %d", p, hashCheckConcurrency)
+	}
+	for i := 1; i <= 40; i++ {
+		_, failed := lookupErr.Failed[key(i)]
+		mediaKey, ok := found[key(i)]
+		switch {
+		case i%5 == 0:
+			if !failed || ok || !errors.Is(lookupErr.Failed[key(i)], errLookup) {
+				t.Errorf("hash %d: failed %v, found %v", i, failed, ok)
+			}
+		case i%3 == 0:
+			if failed || mediaKey != "media-"+key(i) {
+				t.Errorf("hash %d: failed %v, media key %q", i, failed, mediaKey)
+			}
+		default:
+			if failed || ok {
+				t.Errorf("hash %d: failed %v, found %v", i, failed, ok)
+			}
+		}
+	}
+
+	// Without failures the error is nil
+	found, err = findRemoteMediaByHashes(context.Background(), [][]byte{hash(3), hash(4)}, lookup)
+	if err != nil || len(found) != 1 {
+		t.Errorf("no failures: %v, %v", found, err)
+	}
+}
+
+func TestFindRemoteMediaByHashesCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The first hash is found at once, the next ones hold every slot until cancellation
+	var calls atomic.Int32
+	var blocked sync.WaitGroup
+	blocked.Add(hashCheckConcurrency)
+	lookup := func(ctx context.Context, h []byte) (string, error) {
+		calls.Add(1)
+		if h[0] == 0 {
+			return "first", nil
+		}
+		blocked.Done()
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	go func() {
+		blocked.Wait()
+		cancel()
+	}()
+
+	var hashes [][]byte
+	for i := range 3 * hashCheckConcurrency {
+		hashes = append(hashes, []byte{byte(i)})
+	}
+	done := make(chan struct{})
+	var found map[string]string
+	var err error
+	go func() {
+		found, err = findRemoteMediaByHashes(ctx, hashes, lookup)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("findRemoteMediaByHashes did not return after cancellation")
+	}
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+	if n := calls.Load(); n != hashCheckConcurrency+1 {
+		t.Errorf("%d lookups, want none started after cancellation", n)
+	}
+	if found[base64.StdEncoding.EncodeToString([]byte{0})] != "first" || len(found) != 1 {
+		t.Errorf("found = %v, want the lookup finished before cancellation", found)
+	}
+}
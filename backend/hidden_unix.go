@@ -0,0 +1,13 @@
+//go:build !windows
+
+package backend
+
+import (
+	"io/fs"
+	"strings"
+)
+
+// isHiddenEntry reports whether a file or directory is hidden, which on Unix means a dot name
+func isHiddenEntry(entry fs.DirEntry) bool {
+	return strings.HasPrefix(entry.Name(), ".")
+}
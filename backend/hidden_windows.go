@@ -0,0 +1,24 @@
+//go:build windows
+
+package backend
+
+import (
+	"io/fs"
+	"strings"
+	"syscall"
+)
+
+// isHiddenEntry reports whether a file or directory has a dot name or the hidden attribute
+func isHiddenEntry(entry fs.DirEntry) bool {
+	if strings.HasPrefix(entry.Name(), ".") {
+		return true
+	}
+	info, err := entry.Info()
+	if err != nil {
+		return false
+	}
+	if attrs, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return attrs.FileAttributes&syscall.FILE_ATTRIBUTE_HIDDEN != 0
+	}
+	return false
+}
@@ -18,6 +18,11 @@ func SetHTTPClientLogger(logger *log.Logger) {
 }
 
 func NewHTTPClientWithProxy(proxyURLStr string) (*http.Client, error) {
+	return newHTTPClient(proxyURLStr, 3)
+}
+
+// newHTTPClient creates an HTTP client that retries failed requests up to retryMax times
+func newHTTPClient(proxyURLStr string, retryMax int) (*http.Client, error) {
 	// Create the base transport with default values
 	transport := http.DefaultTransport.(*http.Transport).Clone()
 	transport.TLSClientConfig.InsecureSkipVerify = false
@@ -34,7 +39,7 @@ func NewHTTPClientWithProxy(proxyURLStr string) (*http.Client, error) {
 
 	// Create retryable client with proper configuration
 	retryClient := retryablehttp.NewClient()
-	retryClient.RetryMax = 3
+	retryClient.RetryMax = retryMax
 	retryClient.RetryWaitMin = 1 * time.Second   // Start with 1 second
 	retryClient.RetryWaitMax = 30 * time.Second  // Maximum wait time
 	retryClient.HTTPClient.Transport = transport // Set transport here
@@ -49,6 +54,19 @@ func NewHTTPClientWithProxy(proxyURLStr string) (*http.Client, error) {
 
 	// Important: Configure the retry policy to retry on connection errors
 	retryClient.CheckRetry = retryablehttp.ErrorPropagatedRetryPolicy
+	retryClient.ErrorHandler = passthroughLastResponse
 
 	return retryClient.StandardClient(), nil
 }
+
+// passthroughLastResponse hands back the last response once retries run out, so callers see
+// its real status code instead of a generic "giving up" error. Unlike
+// retryablehttp.PassthroughErrorHandler it drops the retry policy's "unexpected HTTP status"
+// error, which would make http.Client discard the response. Without a response the last
+// transport error is returned as is.
+func passthroughLastResponse(resp *http.Response, err error, numTries int) (*http.Response, error) {
+	if resp != nil {
+		return resp, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,268 @@
+package backend
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const journalDirName = "journals"
+
+// Journal record types, one JSON object per line
+const (
+	journalBatch   = "batch"   // Upload started; Paths are the roots on the first record
+	journalRoots   = "roots"   // Paths were added to the running batch
+	journalQueued  = "queued"  // Path was found by the scan
+	journalScanned = "scanned" // The scan finished, so every file of the batch has been queued
+	journalDone    = "done"    // Path uploaded or already in the library
+	journalFailed  = "failed"  // Path failed; a later done record supersedes it
+	journalAlbum   = "album"   // Takeout import created AlbumKey for the album folder Album
+	journalAdded   = "added"   // Takeout import added MediaKeys to AlbumKey
+)
+
+type journalRecord struct {
+	Type      string    `json:"type"`
+	Time      time.Time `json:"time"`
+	Paths     []string  `json:"paths,omitempty"`
+	Recursive bool      `json:"recursive,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	MediaKey  string    `json:"mediaKey,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Album     string    `json:"album,omitempty"`
+	AlbumKey  string    `json:"albumKey,omitempty"`
+	MediaKeys []string  `json:"mediaKeys,omitempty"`
+}
+
+// UploadJournal appends the progress of an upload batch to a JSON lines file as it
+// happens, so a batch interrupted by Ctrl+C, sleep or a crash can be resumed.
+// A nil *UploadJournal records nothing.
+type UploadJournal struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	done   map[string]bool // Files uploaded by an earlier run of this batch
+	failed int
+}
+
+// JournalState is what a journal says about its batch
+type JournalState struct {
+	Roots        []string // Paths the batch was started with
+	Recursive    bool     // Whether the roots were scanned recursively
+	ScanComplete bool
+	Pending      []string // Queued but never finished
+	Failed       []string
+	Done         []string
+	MediaKeys    map[string]string   // Media keys of done paths
+	Albums       map[string]string   // Album keys by album folder, for Takeout imports
+	AlbumMedia   map[string][]string // Media keys added to each album key
+}
+
+// DefaultJournalPath returns a new journal path in the selected account's data directory
+func DefaultJournalPath() (string, error) {
+	dir, err := accountDataDir(AppConfig.Selected)
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("upload-%s.jsonl", time.Now().Format("20060102-150405"))
+	return filepath.Join(dir, journalDirName, name), nil
+}
+
+// CreateUploadJournal starts a journal for a new batch of roots
+func CreateUploadJournal(path string, roots []string) (*UploadJournal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create journal: %w", err)
+	}
+	j := &UploadJournal{path: path, file: file, done: map[string]bool{}}
+	j.write(journalRecord{Type: journalBatch, Paths: roots, Recursive: AppConfig.Recursive})
+	return j, nil
+}
+
+// ResumeUploadJournal reopens a journal for appending. Files it records as done are
+// skipped by the resumed upload.
+func ResumeUploadJournal(path string) (*UploadJournal, *JournalState, error) {
+	state, err := ReadUploadJournal(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+
+	j := &UploadJournal{path: path, file: file, done: make(map[string]bool, len(state.Done))}
+	for _, path := range state.Done {
+		j.done[path] = true
+	}
+	j.write(journalRecord{Type: journalBatch})
+	return j, state, nil
+}
+
+// ReadUploadJournal replays a journal. A truncated last line, as left by a crash, is ignored.
+func ReadUploadJournal(path string) (*JournalState, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+
+	state := &JournalState{
+		MediaKeys:  map[string]string{},
+		Albums:     map[string]string{},
+		AlbumMedia: map[string][]string{},
+	}
+	status := map[string]string{}
+	var order []string
+	haveBatch := false
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record journalRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		switch record.Type {
+		case journalBatch:
+			if !haveBatch {
+				state.Roots = record.Paths
+				state.Recursive = record.Recursive
+				haveBatch = true
+			}
+		case journalRoots:
+			state.Roots = append(state.Roots, record.Paths...)
+			state.ScanComplete = false
+		case journalScanned:
+			state.ScanComplete = true
+		case journalAlbum:
+			state.Albums[record.Album] = record.AlbumKey
+		case journalAdded:
+			state.AlbumMedia[record.AlbumKey] = append(state.AlbumMedia[record.AlbumKey], record.MediaKeys...)
+		case journalQueued, journalDone, journalFailed:
+			previous, seen := status[record.Path]
+			if !seen {
+				order = append(order, record.Path)
+			}
+			// A resumed run queues files again; that must not undo an earlier result
+			if record.Type != journalQueued || !seen || previous == journalQueued {
+				status[record.Path] = record.Type
+			}
+			if record.Type == journalDone && record.MediaKey != "" {
+				state.MediaKeys[record.Path] = record.MediaKey
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	if !haveBatch {
+		return nil, fmt.Errorf("%s is not an upload journal", path)
+	}
+
+	for _, path := range order {
+		switch status[path] {
+		case journalQueued:
+			state.Pending = append(state.Pending, path)
+		case journalFailed:
+			state.Failed = append(state.Failed, path)
+		case journalDone:
+			state.Done = append(state.Done, path)
+		}
+	}
+	return state, nil
+}
+
+// Path returns the journal file path
+func (j *UploadJournal) Path() string {
+	return j.path
+}
+
+// Failed returns how many files failed in this run
+func (j *UploadJournal) Failed() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.failed
+}
+
+// isDone reports whether an earlier run of the batch already uploaded path
+func (j *UploadJournal) isDone(path string) bool {
+	if j == nil {
+		return false
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.done[path]
+}
+
+func (j *UploadJournal) queued(path string) {
+	j.write(journalRecord{Type: journalQueued, Path: path})
+}
+
+func (j *UploadJournal) addRoots(paths []string) {
+	j.write(journalRecord{Type: journalRoots, Paths: paths})
+}
+
+func (j *UploadJournal) scanned() {
+	j.write(journalRecord{Type: journalScanned})
+}
+
+func (j *UploadJournal) album(folder, albumKey string) {
+	j.write(journalRecord{Type: journalAlbum, Album: folder, AlbumKey: albumKey})
+}
+
+func (j *UploadJournal) albumAdded(albumKey string, mediaKeys []string) {
+	j.write(journalRecord{Type: journalAdded, AlbumKey: albumKey, MediaKeys: mediaKeys})
+}
+
+func (j *UploadJournal) result(result FileUploadResult) {
+	if j == nil || result.Path == "" {
+		return
+	}
+	if result.IsError {
+		j.mu.Lock()
+		j.failed++
+		j.mu.Unlock()
+		j.write(journalRecord{Type: journalFailed, Path: result.Path, Error: fmt.Sprint(result.Error)})
+		return
+	}
+	j.write(journalRecord{Type: journalDone, Path: result.Path, MediaKey: result.MediaKey})
+}
+
+// write appends a record. Every record is written straight to the file so it survives a crash.
+func (j *UploadJournal) write(record journalRecord) {
+	if j == nil {
+		return
+	}
+	record.Time = time.Now()
+	line, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file != nil {
+		j.file.Write(append(line, '\n'))
+	}
+}
+
+// Close closes the journal file
+func (j *UploadJournal) Close() error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
@@ -0,0 +1,67 @@
+package backend
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestUploadJournal_ResumeState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journals", "upload.jsonl")
+
+	journal, err := CreateUploadJournal(path, []string{"/photos"})
+	if err != nil {
+		t.Fatalf("CreateUploadJournal: %v", err)
+	}
+	for _, p := range []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg"} {
+		journal.queued(p)
+	}
+	journal.result(FileUploadResult{Path: "a.jpg", MediaKey: "key-a"})
+	journal.result(FileUploadResult{Path: "b.jpg", IsError: true, Error: errors.New("boom")})
+	journal.Close()
+
+	state, err := ReadUploadJournal(path)
+	if err != nil {
+		t.Fatalf("ReadUploadJournal: %v", err)
+	}
+	if state.ScanComplete || !slices.Equal(state.Roots, []string{"/photos"}) {
+		t.Fatalf("unexpected batch state: %+v", state)
+	}
+	if !slices.Equal(state.Done, []string{"a.jpg"}) || !slices.Equal(state.Failed, []string{"b.jpg"}) ||
+		!slices.Equal(state.Pending, []string{"c.jpg", "d.jpg"}) {
+		t.Fatalf("unexpected file state: %+v", state)
+	}
+
+	// The resumed run re-queues the failed file, retries it and finishes the scan
+	journal, _, err = ResumeUploadJournal(path)
+	if err != nil {
+		t.Fatalf("ResumeUploadJournal: %v", err)
+	}
+	if !journal.isDone("a.jpg") || journal.isDone("b.jpg") {
+		t.Fatal("resumed journal should only treat a.jpg as done")
+	}
+	journal.queued("b.jpg")
+	journal.queued("c.jpg")
+	journal.result(FileUploadResult{Path: "c.jpg", MediaKey: "key-c"})
+	journal.scanned()
+	journal.Close()
+
+	// A crash can leave a partial last line
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"type":"done","pa`)
+	file.Close()
+
+	state, err = ReadUploadJournal(path)
+	if err != nil {
+		t.Fatalf("ReadUploadJournal: %v", err)
+	}
+	if !state.ScanComplete || !slices.Equal(state.Done, []string{"a.jpg", "c.jpg"}) ||
+		!slices.Equal(state.Failed, []string{"b.jpg"}) || !slices.Equal(state.Pending, []string{"d.jpg"}) {
+		t.Fatalf("unexpected state after resume: %+v", state)
+	}
+}
@@ -1,6 +1,7 @@
 package backend
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -20,6 +21,31 @@ const (
 type MediaBrowser struct {
 	api *Api
 	mu  sync.Mutex
+
+	// app is attached on service startup in GUI mode; nil otherwise.
+	app AppInterface
+
+	cache      *MediaDB
+	cacheEmail string
+	cacheMu    sync.Mutex
+	syncMu     sync.Mutex
+	statusMu   sync.Mutex
+	status     MediaSyncStatus
+
+	prewarmOnce  sync.Once
+	prewarmQueue chan string
+
+	server mediaServerState
+
+	batchMu sync.Mutex
+	batches map[string]context.CancelFunc
+}
+
+// emit forwards an event to the frontend when running inside the GUI.
+func (m *MediaBrowser) emit(event string, data any) {
+	if m.app != nil {
+		m.app.EmitEvent(event, data)
+	}
 }
 
 // getAPI lazily initializes and caches the API client so repeated calls (such as
@@ -55,6 +81,7 @@ func (m *MediaBrowser) GetMediaList(pageToken string, syncToken string, triggerM
 		return nil, fmt.Errorf("failed to get media list: %w", err)
 	}
 
+	m.prewarmThumbnails(result.Items)
 	return result, nil
 }
 
@@ -73,27 +100,28 @@ func (m *MediaBrowser) GetAlbumList(pageToken string) (*AlbumListResult, error)
 	return result, nil
 }
 
-// GetThumbnail retrieves a thumbnail for a media item and returns it as base64
-func (m *MediaBrowser) GetThumbnail(mediaKey string, size string) (string, error) {
-	api, err := m.getAPI()
-	if err != nil {
-		return "", fmt.Errorf("failed to create API client: %w", err)
-	}
-
-	// Parse size to width/height
-	var width, height int
+// thumbnailDimensions maps a thumbnail size preset to width and height
+func thumbnailDimensions(size string) (int, int) {
 	switch size {
 	case "small":
-		width, height = 200, 200
-	case "medium":
-		width, height = 400, 400
+		return 200, 200
 	case "large":
-		width, height = 800, 800
+		return 800, 800
 	default:
-		width, height = 400, 400 // default to medium
+		return 400, 400 // medium
 	}
+}
 
-	thumbnailData, err := api.GetThumbnail(mediaKey, width, height, false, 0, false)
+// GetThumbnail retrieves a thumbnail for a media item and returns it as base64.
+// Thumbnails are served from the on-disk cache when available.
+func (m *MediaBrowser) GetThumbnail(mediaKey string, size string) (string, error) {
+	api, err := m.getAPI()
+	if err != nil {
+		return "", fmt.Errorf("failed to create API client: %w", err)
+	}
+
+	width, height := thumbnailDimensions(size)
+	thumbnailData, err := FetchThumbnail(api, mediaKey, width, height, false, false)
 	if err != nil {
 		return "", fmt.Errorf("failed to get thumbnail: %w", err)
 	}
@@ -103,6 +131,57 @@ func (m *MediaBrowser) GetThumbnail(mediaKey string, size string) (string, error
 	return base64Data, nil
 }
 
+// ClearThumbnailCache removes all thumbnails from the on-disk cache
+func (m *MediaBrowser) ClearThumbnailCache() error {
+	if err := getThumbnailCache().Clear(); err != nil {
+		return fmt.Errorf("failed to clear thumbnail cache: %w", err)
+	}
+	return nil
+}
+
+// GetThumbnailCacheSize returns the current on-disk thumbnail cache size in bytes
+func (m *MediaBrowser) GetThumbnailCacheSize() int64 {
+	return getThumbnailCache().Size()
+}
+
+// prewarmThumbnails queues thumbnails of listed items that are not cached yet for background
+// download when enabled. The queue is bounded; items that do not fit are fetched on demand later.
+func (m *MediaBrowser) prewarmThumbnails(items []MediaItem) {
+	if !AppConfig.PrewarmThumbnails || len(items) == 0 {
+		return
+	}
+
+	m.prewarmOnce.Do(func() {
+		m.prewarmQueue = make(chan string, thumbnailPrewarmQueueSize)
+		go m.runThumbnailPrewarm()
+	})
+
+	cache := getThumbnailCache()
+	width, height := thumbnailDimensions(AppConfig.ThumbnailSize)
+	variant := thumbnailVariant(width, height, false, false)
+	for _, item := range items {
+		if item.MediaKey == "" || cache.Has(item.MediaKey, variant) {
+			continue
+		}
+		select {
+		case m.prewarmQueue <- item.MediaKey:
+		default:
+			// Full for now; later items may still fit as the worker drains it
+		}
+	}
+}
+
+func (m *MediaBrowser) runThumbnailPrewarm() {
+	for mediaKey := range m.prewarmQueue {
+		api, err := m.getAPI()
+		if err != nil {
+			continue
+		}
+		width, height := thumbnailDimensions(AppConfig.ThumbnailSize)
+		FetchThumbnail(api, mediaKey, width, height, false, false)
+	}
+}
+
 func validateDebugURL(raw string) (*url.URL, error) {
 	u, err := url.Parse(raw)
 	if err != nil {
@@ -156,7 +235,9 @@ func (m *MediaBrowser) DebugProtobufRequest(endpoint string, requestJSON string)
 	return string(out), nil
 }
 
-// DownloadMedia downloads a media item to the user's Downloads folder
+// DownloadMedia downloads a media item into the configured download directory.
+// The file name follows the download template and collision policy from the config,
+// and the file modification time is set to the item's timestamp.
 func (m *MediaBrowser) DownloadMedia(mediaKey string) (string, error) {
 	api, err := m.getAPI()
 	if err != nil {
@@ -179,52 +260,77 @@ func (m *MediaBrowser) DownloadMedia(mediaKey string) (string, error) {
 		return "", fmt.Errorf("no download URL available for media key: %s", mediaKey)
 	}
 
-	// Get user's Downloads folder
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("failed to get home directory: %w", err)
-	}
-
-	downloadsDir := filepath.Join(homeDir, "Downloads", "gotohp")
-	err = os.MkdirAll(downloadsDir, 0755)
-	if err != nil {
-		return "", fmt.Errorf("failed to create downloads directory: %w", err)
+	// Prefer cached metadata; only ask the API when the name or date is unknown
+	item := MediaItem{MediaKey: mediaKey}
+	if db, err := m.getMediaCache(); err == nil {
+		if cached, ok := db.GetItem(mediaKey); ok {
+			item = cached
+		}
 	}
-
-	// Determine filename - prefer filename from download response
 	filename := downloadURLs.Filename
 	if filename == "" {
-		// Fallback: try to get filename from media info
-		mediaInfo, err := api.GetMediaInfo(mediaKey)
-		if err == nil && mediaInfo.Filename != "" {
-			filename = mediaInfo.Filename
-		} else {
-			// Last resort: generate a filename based on media key
-			// Use media type to determine extension if available
-			ext := ".unknown"
-			if err == nil {
-				if mediaInfo.MediaType == "video" {
-					ext = ".mp4"
-				} else if mediaInfo.MediaType == "photo" {
-					ext = ".jpg"
-				}
+		filename = item.Filename
+	}
+	if filename == "" || item.Timestamp == 0 {
+		if mediaInfo, err := api.GetMediaInfo(mediaKey); err == nil {
+			if filename == "" {
+				filename = mediaInfo.Filename
+			}
+			if item.Timestamp == 0 {
+				item.Timestamp = mediaInfo.Timestamp
 			}
-			// Safely slice mediaKey to avoid panic
-			keyPrefix := mediaKey
-			if len(mediaKey) > mediaKeyPrefixLength {
-				keyPrefix = mediaKey[:mediaKeyPrefixLength]
+			if item.MediaType == "" {
+				item.MediaType = mediaInfo.MediaType
 			}
-			filename = fmt.Sprintf("%s%s", keyPrefix, ext)
 		}
 	}
-	outputPath := filepath.Join(downloadsDir, filename)
+	if filename == "" {
+		// Last resort: generate a filename based on media key
+		// Use media type to determine extension if available
+		ext := ".unknown"
+		if item.MediaType == "video" {
+			ext = ".mp4"
+		} else if item.MediaType == "photo" {
+			ext = ".jpg"
+		}
+		// Safely slice mediaKey to avoid panic
+		keyPrefix := mediaKey
+		if len(mediaKey) > mediaKeyPrefixLength {
+			keyPrefix = mediaKey[:mediaKeyPrefixLength]
+		}
+		filename = fmt.Sprintf("%s%s", keyPrefix, ext)
+	}
+
+	root, err := downloadRoot()
+	if err != nil {
+		return "", err
+	}
+	outputPath := filepath.Join(root, renderDownloadPath(AppConfig.DownloadNameTemplate, item, filename))
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create downloads directory: %w", err)
+	}
+
+	policy := normalizeCollisionPolicy(AppConfig.DownloadCollisionPolicy)
+	outputPath, skip, err := reserveDownloadPath(outputPath, policy)
+	if err != nil {
+		return "", err
+	}
+	if skip {
+		return outputPath, nil
+	}
 
 	// Download the file
-	err = api.DownloadFile(downloadURL, outputPath)
+	err = downloadToReservedPath(func(path string) error {
+		return api.DownloadFile(downloadURL, path)
+	}, outputPath, policy)
 	if err != nil {
 		return "", fmt.Errorf("failed to download file: %w", err)
 	}
 
+	if t := mediaItemTime(item.Timestamp); !t.IsZero() {
+		os.Chtimes(outputPath, t, t)
+	}
+
 	return outputPath, nil
 }
 
@@ -243,9 +349,31 @@ func (m *MediaBrowser) DeleteMedia(mediaKey string) error {
 		return fmt.Errorf("failed to move to trash: %w", err)
 	}
 
+	m.markTrashed([]string{mediaKey})
 	return nil
 }
 
+// markTrashed updates thumbnails and the media cache after items were moved to trash
+func (m *MediaBrowser) markTrashed(mediaKeys []string) {
+	InvalidateThumbnails(mediaKeys...)
+	m.updateMediaCache(func(db *MediaDB, changes *MediaSyncResult) {
+		for _, mediaKey := range mediaKeys {
+			item, ok := db.GetItem(mediaKey)
+			if !ok {
+				continue
+			}
+			if AppConfig.RequestTrashItems {
+				item.IsTrash = true
+				if db.UpdateOrAdd(item) {
+					changes.Updated = append(changes.Updated, item)
+				}
+			} else if db.Remove(mediaKey) {
+				changes.Removed = append(changes.Removed, mediaKey)
+			}
+		}
+	})
+}
+
 // PermanentlyDeleteMedia permanently deletes a media item by its dedup key.
 func (m *MediaBrowser) PermanentlyDeleteMedia(dedupKey string) error {
 	if dedupKey == "" {
@@ -261,5 +389,16 @@ func (m *MediaBrowser) PermanentlyDeleteMedia(dedupKey string) error {
 		return fmt.Errorf("failed to permanently delete: %w", err)
 	}
 
+	m.markPermanentlyDeleted([]string{dedupKey})
 	return nil
 }
+
+// markPermanentlyDeleted drops permanently deleted items from thumbnails and the media cache
+func (m *MediaBrowser) markPermanentlyDeleted(dedupKeys []string) {
+	var removed []string
+	m.updateMediaCache(func(db *MediaDB, changes *MediaSyncResult) {
+		removed = db.RemoveByDedupKeys(dedupKeys)
+		changes.Removed = append(changes.Removed, removed...)
+	})
+	InvalidateThumbnails(removed...)
+}
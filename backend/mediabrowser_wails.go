@@ -0,0 +1,16 @@
+//go:build !cli
+
+package backend
+
+import (
+	"context"
+
+	"github.com/wailsapp/wails/v3/pkg/application"
+)
+
+// ServiceStartup attaches the browser to the Wails event bus so background
+// work (cache sync, batch operations) can report progress to the frontend.
+func (m *MediaBrowser) ServiceStartup(ctx context.Context, options application.ServiceOptions) error {
+	m.app = &WailsApp{app: application.Get()}
+	return nil
+}
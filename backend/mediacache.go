@@ -0,0 +1,288 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+)
+
+// mediaCacheFileName is the per-account file that backs the offline gallery
+const mediaCacheFileName = "media_cache.json"
+
+// defaultCachedPageSize is used when the frontend asks for a page without a limit
+const defaultCachedPageSize = 200
+
+var errSyncInProgress = errors.New("media cache sync already in progress")
+
+// CachedMediaPage is a page of media items served from the local media cache
+type CachedMediaPage struct {
+	Items      []MediaItem `json:"items"`
+	Total      int         `json:"total"`
+	NextOffset int         `json:"nextOffset"` // Offset of the next page; equals Total when exhausted
+	Complete   bool        `json:"complete"`   // True once an initial full scan has produced a sync token
+}
+
+// MediaSyncResult describes the changes applied to the media cache.
+// It is returned by SyncMediaCache and emitted as "mediaCacheUpdated" per synced page
+// and after local trash, delete and wash operations.
+type MediaSyncResult struct {
+	Added    []MediaItem `json:"added"`
+	Updated  []MediaItem `json:"updated"`
+	Removed  []string    `json:"removed"`
+	Total    int         `json:"total"`
+	Complete bool        `json:"complete"`
+	Offline  bool        `json:"offline"`
+}
+
+// MediaSyncStatus is emitted as "mediaSyncStatus" whenever sync or connectivity state changes
+type MediaSyncStatus struct {
+	Online  bool   `json:"online"`
+	Syncing bool   `json:"syncing"`
+	Error   string `json:"error,omitempty"`
+}
+
+// getMediaCache returns the media cache of the selected account, loading it on first use
+// and whenever the selected account changes.
+func (m *MediaBrowser) getMediaCache() (*MediaDB, error) {
+	m.cacheMu.Lock()
+	defer m.cacheMu.Unlock()
+
+	email := AppConfig.Selected
+	if m.cache != nil && m.cacheEmail == email {
+		return m.cache, nil
+	}
+
+	dir, err := accountDataDir(email)
+	if err != nil {
+		return nil, err
+	}
+	db, err := NewMediaDB(filepath.Join(dir, mediaCacheFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	m.cache = db
+	m.cacheEmail = email
+	return db, nil
+}
+
+// updateMediaCache applies fn to the media cache and persists the result. fn records what it
+// changed in the given result, which is emitted as "mediaCacheUpdated" so the gallery can patch its view.
+// Cache maintenance is best effort: failures never fail the calling operation.
+func (m *MediaBrowser) updateMediaCache(fn func(db *MediaDB, changes *MediaSyncResult)) {
+	db, err := m.getMediaCache()
+	if err != nil {
+		return
+	}
+	changes := MediaSyncResult{
+		Added:   []MediaItem{},
+		Updated: []MediaItem{},
+		Removed: []string{},
+	}
+	fn(db, &changes)
+	if len(changes.Added) == 0 && len(changes.Updated) == 0 && len(changes.Removed) == 0 {
+		return
+	}
+	if err := db.Save(); err != nil {
+		return
+	}
+	changes.Total = db.Len()
+	changes.Complete = db.Synced()
+	m.emit("mediaCacheUpdated", changes)
+}
+
+// GetCachedMediaList returns a page of media items from the local cache, newest first.
+// It never touches the network, so the gallery can render immediately and while offline.
+func (m *MediaBrowser) GetCachedMediaList(offset int, limit int) (*CachedMediaPage, error) {
+	db, err := m.getMediaCache()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open media cache: %w", err)
+	}
+	if limit <= 0 {
+		limit = defaultCachedPageSize
+	}
+
+	items, total := db.Page(offset, limit)
+	return &CachedMediaPage{
+		Items:      items,
+		Total:      total,
+		NextOffset: min(max(offset, 0)+len(items), total),
+		Complete:   db.Synced(),
+	}, nil
+}
+
+// GetMediaSyncStatus returns the last known sync and connectivity state
+func (m *MediaBrowser) GetMediaSyncStatus() MediaSyncStatus {
+	m.statusMu.Lock()
+	defer m.statusMu.Unlock()
+	return m.status
+}
+
+func (m *MediaBrowser) setSyncStatus(status MediaSyncStatus) {
+	m.statusMu.Lock()
+	m.status = status
+	m.statusMu.Unlock()
+	m.emit("mediaSyncStatus", status)
+}
+
+// ResetMediaCache drops the cached library of the selected account.
+// The next SyncMediaCache call performs a full scan.
+func (m *MediaBrowser) ResetMediaCache() error {
+	m.syncMu.Lock()
+	defer m.syncMu.Unlock()
+
+	db, err := m.getMediaCache()
+	if err != nil {
+		return fmt.Errorf("failed to open media cache: %w", err)
+	}
+	db.Reset()
+	if err := db.Save(); err != nil {
+		return fmt.Errorf("failed to save media cache: %w", err)
+	}
+	return nil
+}
+
+// SyncMediaCache brings the local media cache up to date.
+// Without a sync token it continues (or starts) a full scan, otherwise it fetches incremental changes.
+// Each processed page is emitted as "mediaCacheUpdated". Network failures are not returned as errors;
+// the result is marked Offline instead so the gallery can keep serving cached data.
+func (m *MediaBrowser) SyncMediaCache() (*MediaSyncResult, error) {
+	if !m.syncMu.TryLock() {
+		return nil, errSyncInProgress
+	}
+	defer m.syncMu.Unlock()
+
+	db, err := m.getMediaCache()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open media cache: %w", err)
+	}
+
+	m.setSyncStatus(MediaSyncStatus{Online: m.GetMediaSyncStatus().Online, Syncing: true})
+
+	result := &MediaSyncResult{
+		Added:   []MediaItem{},
+		Updated: []MediaItem{},
+		Removed: []string{},
+	}
+
+	err = m.syncMediaCachePages(db, result)
+	result.Total = db.Len()
+	result.Complete = db.Synced()
+
+	if err != nil {
+		if isNetworkError(err) {
+			result.Offline = true
+			m.setSyncStatus(MediaSyncStatus{Online: false, Error: err.Error()})
+			return result, nil
+		}
+		m.setSyncStatus(MediaSyncStatus{Online: true, Error: err.Error()})
+		return nil, err
+	}
+
+	m.setSyncStatus(MediaSyncStatus{Online: true})
+	return result, nil
+}
+
+func (m *MediaBrowser) syncMediaCachePages(db *MediaDB, result *MediaSyncResult) error {
+	api, err := m.getAPI()
+	if err != nil {
+		return fmt.Errorf("failed to create API client: %w", err)
+	}
+
+	currentSyncToken, pageToken := db.Tokens()
+	incremental := currentSyncToken != ""
+	newSyncToken := ""
+
+	for {
+		// triggerMode: 1 for incremental changes since the sync token, 2 for a passive full scan
+		mode, syncToken := 2, ""
+		if incremental {
+			mode, syncToken = 1, currentSyncToken
+		}
+
+		list, err := api.GetMediaList(pageToken, syncToken, mode, 0)
+		if err != nil {
+			return fmt.Errorf("failed to get media list: %w", err)
+		}
+
+		page := applyMediaListToCache(db, list.Items, incremental)
+		InvalidateThumbnails(page.Removed...)
+		m.prewarmThumbnails(page.Added)
+		result.Added = append(result.Added, page.Added...)
+		result.Updated = append(result.Updated, page.Updated...)
+		result.Removed = append(result.Removed, page.Removed...)
+
+		if list.SyncToken != "" {
+			newSyncToken = list.SyncToken
+		}
+
+		// Save resumption state so an interrupted full scan continues where it stopped
+		db.SetNextPageToken(list.NextPageToken)
+		if err := db.Save(); err != nil {
+			return fmt.Errorf("failed to save media cache: %w", err)
+		}
+
+		page.Total = db.Len()
+		page.Complete = db.Synced()
+		m.emit("mediaCacheUpdated", page)
+
+		if list.NextPageToken == "" {
+			break
+		}
+		pageToken = list.NextPageToken
+	}
+
+	if newSyncToken != "" {
+		db.SetSyncToken(newSyncToken)
+	}
+	db.SetNextPageToken("")
+	if err := db.Save(); err != nil {
+		return fmt.Errorf("failed to save media cache: %w", err)
+	}
+	return nil
+}
+
+// applyMediaListToCache merges one page of list results into the cache.
+// Status 2 entries are removals, which are only reported by incremental syncs.
+func applyMediaListToCache(db *MediaDB, items []MediaItem, incremental bool) MediaSyncResult {
+	page := MediaSyncResult{
+		Added:   []MediaItem{},
+		Updated: []MediaItem{},
+		Removed: []string{},
+	}
+
+	for _, item := range items {
+		if incremental && item.Status == 2 {
+			if db.Remove(item.MediaKey) {
+				page.Removed = append(page.Removed, item.MediaKey)
+			}
+			continue
+		}
+
+		_, existed := db.GetItem(item.MediaKey)
+		if !db.UpdateOrAdd(item) {
+			continue
+		}
+		stored, _ := db.GetItem(item.MediaKey)
+		if existed {
+			page.Updated = append(page.Updated, stored)
+		} else {
+			page.Added = append(page.Added, stored)
+		}
+	}
+
+	return page
+}
+
+// isNetworkError reports whether err was caused by the API being unreachable
+// rather than by a rejected request.
+func isNetworkError(err error) bool {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
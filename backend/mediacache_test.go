@@ -0,0 +1,154 @@
+package backend
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestApplyMediaListToCache(t *testing.T) {
+	db, err := NewMediaDB(filepath.Join(t.TempDir(), mediaCacheFileName))
+	if err != nil {
+		t.Fatalf("NewMediaDB: %v", err)
+	}
+
+	page := applyMediaListToCache(db, []MediaItem{
+		{MediaKey: "old-item-key", Timestamp: 100},
+		{MediaKey: "new-item-key", Timestamp: 200},
+	}, false)
+	if len(page.Added) != 2 || len(page.Updated) != 0 || len(page.Removed) != 0 {
+		t.Fatalf("unexpected full scan delta: %+v", page)
+	}
+
+	items, total := db.Page(0, 1)
+	if total != 2 || len(items) != 1 || items[0].MediaKey != "new-item-key" {
+		t.Fatalf("expected newest item first, got %+v (total %d)", items, total)
+	}
+
+	page = applyMediaListToCache(db, []MediaItem{
+		{MediaKey: "old-item-key", Status: 2},
+		{MediaKey: "new-item-key", Timestamp: 200, CountsTowardsQuota: true},
+	}, true)
+	if len(page.Removed) != 1 || page.Removed[0] != "old-item-key" {
+		t.Fatalf("expected old item removal, got %+v", page.Removed)
+	}
+	if len(page.Updated) != 1 || !page.Updated[0].CountsTowardsQuota {
+		t.Fatalf("expected quota update, got %+v", page.Updated)
+	}
+
+	if err := db.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	reloaded, err := NewMediaDB(db.path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if reloaded.Len() != 1 {
+		t.Fatalf("expected 1 item after reload, got %d", reloaded.Len())
+	}
+}
+
+func TestMediaDBConcurrentSave(t *testing.T) {
+	db, err := NewMediaDB(filepath.Join(t.TempDir(), mediaCacheFileName))
+	if err != nil {
+		t.Fatalf("NewMediaDB: %v", err)
+	}
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			db.UpdateOrAdd(MediaItem{MediaKey: fmt.Sprintf("item-key-%d", i), Timestamp: int64(i)})
+			db.SetNextPageToken(fmt.Sprintf("page-%d", i))
+			if err := db.Save(); err != nil {
+				t.Errorf("Save: %v", err)
+			}
+			db.Synced()
+		}()
+	}
+	wg.Wait()
+	db.SetSyncToken("sync")
+	if err := db.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	reloaded, err := NewMediaDB(db.path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if syncToken, _ := reloaded.Tokens(); reloaded.Len() != 8 || syncToken != "sync" {
+		t.Fatalf("reloaded %d items, sync token %q", reloaded.Len(), syncToken)
+	}
+}
+
+func TestMediaDBRemoveByDedupKeys(t *testing.T) {
+	db, err := NewMediaDB(filepath.Join(t.TempDir(), mediaCacheFileName))
+	if err != nil {
+		t.Fatalf("NewMediaDB: %v", err)
+	}
+	for i := range 5 {
+		db.UpdateOrAdd(MediaItem{MediaKey: fmt.Sprintf("item-key-%d", i), DedupKey: fmt.Sprintf("dedup-%d", i), Timestamp: int64(i)})
+	}
+	db.UpdateOrAdd(MediaItem{MediaKey: "no-dedup-key", Timestamp: 9})
+
+	removed := db.RemoveByDedupKeys([]string{"dedup-1", "dedup-3", "dedup-missing", ""})
+	slices.Sort(removed)
+	if !slices.Equal(removed, []string{"item-key-1", "item-key-3"}) {
+		t.Fatalf("removed %v", removed)
+	}
+	if items, total := db.Page(0, 10); total != 4 || len(items) != 4 {
+		t.Fatalf("expected 4 items left, got %d", total)
+	}
+}
+
+type recordingApp struct {
+	mu     sync.Mutex
+	events []any
+}
+
+func (a *recordingApp) EmitEvent(event string, data any) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if event == "mediaCacheUpdated" {
+		a.events = append(a.events, data)
+	}
+}
+
+func (a *recordingApp) GetLogger() *slog.Logger { return slog.Default() }
+
+func TestUpdateMediaCacheEmitsChanges(t *testing.T) {
+	originalConfig := AppConfig
+	defer func() { AppConfig = originalConfig }()
+	AppConfig.Selected = "user@example.com"
+
+	db, err := NewMediaDB(filepath.Join(t.TempDir(), mediaCacheFileName))
+	if err != nil {
+		t.Fatalf("NewMediaDB: %v", err)
+	}
+	for i := range 3 {
+		db.UpdateOrAdd(MediaItem{MediaKey: fmt.Sprintf("item-key-%d", i), DedupKey: fmt.Sprintf("dedup-%d", i), Timestamp: int64(i)})
+	}
+	app := &recordingApp{}
+	m := &MediaBrowser{app: app, cache: db, cacheEmail: AppConfig.Selected}
+
+	AppConfig.RequestTrashItems = true
+	m.markTrashed([]string{"item-key-0", "item-key-missing"})
+	m.markPermanentlyDeleted([]string{"dedup-1"})
+	// Nothing changes, so nothing is emitted
+	m.markPermanentlyDeleted([]string{"dedup-missing"})
+
+	if len(app.events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(app.events))
+	}
+	trashed := app.events[0].(MediaSyncResult)
+	if len(trashed.Updated) != 1 || trashed.Updated[0].MediaKey != "item-key-0" || !trashed.Updated[0].IsTrash || len(trashed.Removed) != 0 {
+		t.Errorf("trash event = %+v", trashed)
+	}
+	deleted := app.events[1].(MediaSyncResult)
+	if !slices.Equal(deleted.Removed, []string{"item-key-1"}) || len(deleted.Updated) != 0 || deleted.Total != 2 {
+		t.Errorf("delete event = %+v", deleted)
+	}
+}
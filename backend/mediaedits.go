@@ -0,0 +1,135 @@
+package backend
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	setCaptionEndpoint      = "https://photosdata-pa.googleapis.com/6439526531001121323/1552790390512470739"
+	setFavoriteEndpoint     = "https://photosdata-pa.googleapis.com/6439526531001121323/5144645502632292153"
+	createAlbumEndpoint     = "https://photosdata-pa.googleapis.com/6439526531001121323/8386163679468898444"
+	addMediaToAlbumEndpoint = "https://photosdata-pa.googleapis.com/6439526531001121323/484917746253879292"
+
+	// albumBatchSize is the most media keys sent in one album request
+	albumBatchSize = 500
+)
+
+// DedupKey returns the key Google Photos identifies an item's content by: its
+// SHA1 in unpadded URL-safe base64
+func DedupKey(sha1 []byte) string {
+	return base64.RawURLEncoding.EncodeToString(sha1)
+}
+
+// SetCaption sets the description of the item with the given dedup key
+func (a *Api) SetCaption(dedupKey, caption string) error {
+	req, err := buildTemplateRequest("set-caption", map[string]any{"2": caption, "3": dedupKey})
+	if err != nil {
+		return err
+	}
+	if _, err := a.doProtobufPOST(setCaptionEndpoint, req); err != nil {
+		return fmt.Errorf("failed to set caption: %w", err)
+	}
+	return nil
+}
+
+// SetFavorite marks or unmarks the item with the given dedup key as a favorite
+func (a *Api) SetFavorite(dedupKey string, favorite bool) error {
+	action := int64(2)
+	if favorite {
+		action = 1
+	}
+	req, err := buildTemplateRequest("set-favorite", map[string]any{"1.2": dedupKey, "2.1": action})
+	if err != nil {
+		return err
+	}
+	if _, err := a.doProtobufPOST(setFavoriteEndpoint, req); err != nil {
+		return fmt.Errorf("failed to set favorite: %w", err)
+	}
+	return nil
+}
+
+// CreateAlbum creates an album holding mediaKeys and returns the album's media key.
+// Only the first albumBatchSize keys are sent; add the rest with AddMediaToAlbum.
+func (a *Api) CreateAlbum(title string, mediaKeys []string) (string, error) {
+	mediaKeys = mediaKeys[:min(len(mediaKeys), albumBatchSize)]
+
+	req, err := buildTemplateRequest("create-album", map[string]any{
+		"1": title,
+		"2": time.Now().Unix(),
+		"4": mediaKeys,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := a.doProtobufPOST(createAlbumEndpoint, req)
+	if err != nil {
+		return "", fmt.Errorf("failed to create album: %w", err)
+	}
+	// 1.1 = album media key
+	albumKey := protobufStringAt(resp, 1, 1)
+	if albumKey == "" {
+		return "", fmt.Errorf("failed to create album: no album key returned")
+	}
+	return albumKey, nil
+}
+
+// AddMediaToAlbum adds media items to an existing album, albumBatchSize keys per request
+func (a *Api) AddMediaToAlbum(albumKey string, mediaKeys []string) error {
+	for start := 0; start < len(mediaKeys); start += albumBatchSize {
+		batch := mediaKeys[start:min(start+albumBatchSize, len(mediaKeys))]
+
+		req, err := buildTemplateRequest("add-media-to-album", map[string]any{
+			"1": batch,
+			"2": albumKey,
+			// Client info, as in MoveToTrash
+			"7.2.1": a.device.ClientVersionCode,
+			"7.2.2": strconv.FormatInt(a.device.AndroidAPIVersion, 10),
+			"8":     time.Now().Unix(),
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := a.doProtobufPOST(addMediaToAlbumEndpoint, req); err != nil {
+			return fmt.Errorf("failed to add media to album: %w", err)
+		}
+	}
+	return nil
+}
+
+// protobufStringAt returns the string at a path of nested field numbers, or "" if absent
+func protobufStringAt(data []byte, path ...int) string {
+	for depth, want := range path {
+		found := false
+		offset := 0
+		for offset < len(data) {
+			fieldNum, wireType, newOffset := readTag(data, offset)
+			if newOffset < 0 {
+				return ""
+			}
+			if fieldNum == want && wireType == 2 {
+				length, start := readVarint(data, newOffset)
+				if start < 0 || start+int(length) > len(data) {
+					return ""
+				}
+				data = data[start : start+int(length)]
+				found = true
+				break
+			}
+			var ok bool
+			if offset, ok = skipField(data, wireType, newOffset, fieldNum); !ok {
+				return ""
+			}
+		}
+		if !found {
+			return ""
+		}
+		if depth == len(path)-1 {
+			return string(data)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,248 @@
+package backend
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MediaRoute is the asset server prefix under which MediaBrowser serves media.
+// The frontend loads thumbnails from MediaRoute+"/thumb/<mediaKey>?size=<preset>"
+// and originals from MediaRoute+"/media/<mediaKey>".
+const MediaRoute = "/gotohp"
+
+const (
+	// maxConcurrentThumbnailFetches bounds upstream thumbnail requests triggered by <img> tags
+	maxConcurrentThumbnailFetches = 6
+	// downloadURLTTL is how long a resolved original URL is reused for range requests
+	downloadURLTTL = 30 * time.Minute
+	// maxResolvedDownloads bounds the resolved original URLs kept at once
+	maxResolvedDownloads = 256
+)
+
+// resolvedDownload is an original media URL cached for streaming
+type resolvedDownload struct {
+	url      string
+	filename string
+	expires  time.Time
+}
+
+// mediaServerState holds per-browser HTTP serving state
+type mediaServerState struct {
+	once      sync.Once
+	thumbSem  chan struct{}
+	mu        sync.Mutex
+	downloads map[string]resolvedDownload
+}
+
+func (s *mediaServerState) init() {
+	s.once.Do(func() {
+		s.thumbSem = make(chan struct{}, maxConcurrentThumbnailFetches)
+		s.downloads = make(map[string]resolvedDownload)
+	})
+}
+
+// ServeHTTP serves thumbnails and original media to the webview.
+// Wails strips MediaRoute before the request reaches this handler.
+func (m *MediaBrowser) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	m.server.init()
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/thumb/"):
+		m.serveThumbnail(w, r, strings.TrimPrefix(r.URL.Path, "/thumb/"))
+	case strings.HasPrefix(r.URL.Path, "/media/"):
+		m.serveMedia(w, r, strings.TrimPrefix(r.URL.Path, "/media/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (m *MediaBrowser) serveThumbnail(w http.ResponseWriter, r *http.Request, mediaKey string) {
+	if len(mediaKey) < minMediaKeyLength {
+		http.Error(w, "invalid media key", http.StatusBadRequest)
+		return
+	}
+
+	api, err := m.getAPI()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	select {
+	case m.server.thumbSem <- struct{}{}:
+	case <-r.Context().Done():
+		return
+	}
+	width, height := thumbnailDimensions(r.URL.Query().Get("size"))
+	data, err := FetchThumbnail(api, mediaKey, width, height, false, false)
+	<-m.server.thumbSem
+	if err != nil {
+		status := http.StatusBadGateway
+		if isNetworkError(err) {
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	w.Header().Set("Content-Type", http.DetectContentType(data))
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+func (m *MediaBrowser) serveMedia(w http.ResponseWriter, r *http.Request, mediaKey string) {
+	if len(mediaKey) < minMediaKeyLength {
+		http.Error(w, "invalid media key", http.StatusBadRequest)
+		return
+	}
+
+	api, err := m.getAPI()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	download, cached, err := m.resolveDownload(api, mediaKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	resp, err := api.OpenDownload(r.Context(), download.url, r.Header.Get("Range"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if cached && downloadURLExpired(resp.StatusCode) {
+		// The signed URL may have expired early; resolve it once more and retry
+		m.forgetDownload(mediaKey)
+		if fresh, _, err := m.resolveDownload(api, mediaKey); err == nil {
+			if retried, err := api.OpenDownload(r.Context(), fresh.url, r.Header.Get("Range")); err == nil {
+				resp.Body.Close()
+				resp, download = retried, fresh
+			}
+		}
+	}
+	defer resp.Body.Close()
+
+	if downloadURLExpired(resp.StatusCode) {
+		// Resolve it again next time rather than reusing a URL that was refused
+		m.forgetDownload(mediaKey)
+	}
+
+	header := w.Header()
+	for _, name := range []string{"Content-Length", "Content-Range", "Accept-Ranges", "Last-Modified", "ETag"} {
+		if v := resp.Header.Get(name); v != "" {
+			header.Set(name, v)
+		}
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" || strings.HasPrefix(contentType, "application/octet-stream") {
+		if byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(download.filename))); byExt != "" {
+			contentType = byExt
+		}
+	}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	if header.Get("Accept-Ranges") == "" {
+		header.Set("Accept-Ranges", "bytes")
+	}
+	// Errors are not cached, so an expired URL or an outage does not stick in the webview
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		header.Set("Cache-Control", "private, max-age=3600")
+	} else {
+		header.Set("Cache-Control", "no-store")
+	}
+	if download.filename != "" {
+		header.Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": download.filename}))
+	}
+
+	w.WriteHeader(resp.StatusCode)
+	if r.Method == http.MethodHead {
+		return
+	}
+	io.Copy(w, resp.Body)
+}
+
+// downloadURLExpired reports whether an upstream status means a signed URL is no longer valid
+func downloadURLExpired(status int) bool {
+	return status == http.StatusForbidden || status == http.StatusNotFound || status == http.StatusGone
+}
+
+// resolveDownload returns the original URL of a media item, reusing a recent lookup so
+// that the many range requests issued by a <video> element cost a single API call.
+// cached reports whether the URL came from an earlier lookup.
+func (m *MediaBrowser) resolveDownload(api *Api, mediaKey string) (download resolvedDownload, cached bool, err error) {
+	m.server.mu.Lock()
+	recent, ok := m.server.downloads[mediaKey]
+	m.server.mu.Unlock()
+	if ok && time.Now().Before(recent.expires) {
+		return recent, true, nil
+	}
+
+	urls, err := api.GetDownloadURLs(mediaKey)
+	if err != nil {
+		return resolvedDownload{}, false, fmt.Errorf("failed to get download URLs: %w", err)
+	}
+	downloadURL := urls.EditedURL
+	if urls.OriginalURL != "" {
+		downloadURL = urls.OriginalURL
+	}
+	if downloadURL == "" {
+		return resolvedDownload{}, false, fmt.Errorf("no download URL available for media key: %s", mediaKey)
+	}
+
+	resolved := resolvedDownload{
+		url:      downloadURL,
+		filename: urls.Filename,
+		expires:  time.Now().Add(downloadURLTTL),
+	}
+	m.server.storeDownload(mediaKey, resolved)
+	return resolved, false, nil
+}
+
+// storeDownload caches a resolved URL, dropping expired entries and, beyond
+// maxResolvedDownloads, the ones closest to expiring
+func (s *mediaServerState) storeDownload(mediaKey string, resolved resolvedDownload) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for key, cached := range s.downloads {
+		if !now.Before(cached.expires) {
+			delete(s.downloads, key)
+		}
+	}
+	for len(s.downloads) >= maxResolvedDownloads {
+		oldest := ""
+		for key, cached := range s.downloads {
+			if oldest == "" || cached.expires.Before(s.downloads[oldest].expires) {
+				oldest = key
+			}
+		}
+		delete(s.downloads, oldest)
+	}
+	s.downloads[mediaKey] = resolved
+}
+
+func (m *MediaBrowser) forgetDownload(mediaKey string) {
+	m.server.mu.Lock()
+	delete(m.server.downloads, mediaKey)
+	m.server.mu.Unlock()
+}
@@ -0,0 +1,162 @@
+package backend
+
+import (
+	"app/generated"
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// downloadURLTransport answers GetDownloadURLs requests with the URL in urls for the
+// requested media key and passes every other request on to next
+type downloadURLTransport struct {
+	next http.RoundTripper
+	urls map[string]string // Keyed by media key
+
+	mu       sync.Mutex
+	resolved []string
+}
+
+func (t *downloadURLTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != "photosdata-pa.googleapis.com" {
+		return t.next.RoundTrip(req)
+	}
+	var body bytes.Buffer
+	body.ReadFrom(req.Body)
+	var request generated.GetDownloadUrls
+	if err := proto.Unmarshal(body.Bytes(), &request); err != nil {
+		return nil, err
+	}
+	mediaKey := request.GetField1().GetField1().GetMediaKey()
+	t.mu.Lock()
+	t.resolved = append(t.resolved, mediaKey)
+	t.mu.Unlock()
+
+	data, _ := proto.Marshal(&generated.GetDownloadUrlsResponse{
+		Field1: &generated.GetDownloadUrlsResponseField1{
+			Field2: &generated.GetDownloadUrlsResponseField1Field2{Field4: "video.mp4"},
+			Field5: &generated.GetDownloadUrlsResponseField1Field5{
+				Field3: &generated.GetDownloadUrlsResponseField1Field5Field3{Field5: t.urls[mediaKey]},
+			},
+		},
+	})
+	rec := httptest.NewRecorder()
+	rec.Write(data)
+	return rec.Result(), nil
+}
+
+func TestServeMedia(t *testing.T) {
+	saved := AppConfig
+	defer func() { AppConfig = saved }()
+	AppConfig = Config{}
+
+	content := []byte("0123456789abcdef")
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/video.mp4":
+			http.ServeContent(w, r, "video.mp4", time.Time{}, bytes.NewReader(content))
+		case "/expired":
+			http.Error(w, "expired", http.StatusForbidden)
+		case "/gone":
+			http.Error(w, "gone", http.StatusGone)
+		default:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	defer upstream.Close()
+
+	transport := &downloadURLTransport{
+		next: upstream.Client().Transport,
+		urls: map[string]string{
+			"expired-media-key": upstream.URL + "/video.mp4",
+			"gone-media-key":    upstream.URL + "/gone",
+		},
+	}
+	api := &Api{
+		client:            &http.Client{Transport: transport},
+		authResponseCache: map[string]string{"Auth": "token", "Expiry": strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)},
+	}
+	m := &MediaBrowser{api: api}
+	m.server.init()
+	resolved := func(path string) resolvedDownload {
+		return resolvedDownload{url: upstream.URL + path, filename: "video.mp4", expires: time.Now().Add(time.Hour)}
+	}
+	m.server.storeDownload("video-media-key", resolved("/video.mp4"))
+	m.server.storeDownload("expired-media-key", resolved("/expired"))
+	m.server.storeDownload("gone-media-key", resolved("/expired"))
+	m.server.storeDownload("outage-media-key", resolved("/outage"))
+
+	serve := func(mediaKey, rangeHeader string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/media/"+mediaKey, nil)
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Ranges pass through to the upstream URL, and successful responses are cacheable
+	rec := serve("video-media-key", "bytes=4-7")
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "4567" ||
+		rec.Header().Get("Content-Range") != "bytes 4-7/16" || rec.Header().Get("Cache-Control") != "private, max-age=3600" {
+		t.Errorf("range: %d %q %v", rec.Code, rec.Body, rec.Header())
+	}
+	if rec.Header().Get("Content-Type") != "video/mp4" {
+		t.Errorf("content type = %q", rec.Header().Get("Content-Type"))
+	}
+
+	// An expired cached URL is resolved once more and the request retried
+	rec = serve("expired-media-key", "bytes=0-3")
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "0123" {
+		t.Errorf("expired URL: %d %q", rec.Code, rec.Body)
+	}
+	if got := m.server.downloads["expired-media-key"].url; got != upstream.URL+"/video.mp4" {
+		t.Errorf("expired URL replaced by %q", got)
+	}
+
+	// Error statuses are passed on but never cached; a refused URL is not reused
+	for _, tt := range []struct {
+		mediaKey string
+		status   int
+		forget   bool
+	}{
+		{"gone-media-key", http.StatusGone, true},
+		{"outage-media-key", http.StatusServiceUnavailable, false},
+	} {
+		rec := serve(tt.mediaKey, "")
+		if rec.Code != tt.status || rec.Header().Get("Cache-Control") != "no-store" {
+			t.Errorf("%s: %d, Cache-Control %q", tt.mediaKey, rec.Code, rec.Header().Get("Cache-Control"))
+		}
+		m.server.mu.Lock()
+		_, kept := m.server.downloads[tt.mediaKey]
+		m.server.mu.Unlock()
+		if kept == tt.forget {
+			t.Errorf("%s: resolved URL kept = %v", tt.mediaKey, kept)
+		}
+	}
+	// Only the two expired URLs were resolved again, once each
+	if want := []string{"expired-media-key", "gone-media-key"}; fmt.Sprint(transport.resolved) != fmt.Sprint(want) {
+		t.Errorf("resolved %v, want %v", transport.resolved, want)
+	}
+
+	// Resolved URLs are bounded, dropping expired ones first
+	m.server.downloads["stale-media-key"] = resolvedDownload{expires: time.Now().Add(-time.Minute)}
+	for i := range maxResolvedDownloads + 10 {
+		m.server.storeDownload(fmt.Sprintf("media-key-%d", i), resolved("/video.mp4"))
+	}
+	if _, ok := m.server.downloads["stale-media-key"]; ok || len(m.server.downloads) != maxResolvedDownloads {
+		t.Errorf("%d resolved URLs kept, stale kept = %v", len(m.server.downloads), ok)
+	}
+}
@@ -0,0 +1,162 @@
+package backend
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Upload policies for Live Photos, whose still and motion video are separate files
+const (
+	LivePhotoBoth  = "both"  // Upload the still and the video
+	LivePhotoStill = "still" // Upload only the still
+)
+
+// Upload policies for RAW files shot together with a JPEG
+const (
+	RawJpegBoth = "both" // Upload the RAW and the JPEG
+	RawJpegRaw  = "raw"  // Upload only the RAW
+	RawJpegJpeg = "jpeg" // Upload only the JPEG
+)
+
+// Reasons reported for paired files the policies leave out
+const (
+	SkipLivePhotoVideo = "video of a live photo"
+	SkipPairedJpeg     = "JPEG of a RAW+JPEG pair"
+	SkipPairedRaw      = "RAW of a RAW+JPEG pair"
+)
+
+var (
+	livePhotoStills = []string{".heic", ".heif", ".jpg", ".jpeg"}
+	livePhotoVideos = []string{".mov", ".mp4"}
+	rawExtensions   = []string{".cr2", ".cr3", ".nef", ".arw", ".orf", ".raf", ".rw2", ".pef", ".sr2", ".dng"}
+	jpegExtensions  = []string{".jpg", ".jpeg"}
+)
+
+// UploadPairing reports the pairing policies of an upload and the pairs its scan found.
+// Pairs are files of the same folder with the same name up to the extension, such as
+// IMG_1234.HEIC and IMG_1234.MOV, or DSC_1.NEF and DSC_1.JPG.
+type UploadPairing struct {
+	LivePhotos     string `json:"livePhotos"` // UploadLivePhotoPolicy
+	RawJpeg        string `json:"rawJpeg"`    // UploadRawJpegPolicy
+	LivePhotoPairs int    `json:"livePhotoPairs"`
+	RawJpegPairs   int    `json:"rawJpegPairs"`
+}
+
+// pairingFromConfig returns the configured pairing policies with no pairs found yet
+func pairingFromConfig() UploadPairing {
+	return UploadPairing{
+		LivePhotos: normalizeLivePhotoPolicy(AppConfig.UploadLivePhotoPolicy),
+		RawJpeg:    normalizeRawJpegPolicy(AppConfig.UploadRawJpegPolicy),
+	}
+}
+
+// normalizeLivePhotoPolicy maps unknown values to uploading both files
+func normalizeLivePhotoPolicy(policy string) string {
+	if policy == LivePhotoStill {
+		return policy
+	}
+	return LivePhotoBoth
+}
+
+// normalizeRawJpegPolicy maps unknown values to uploading both files
+func normalizeRawJpegPolicy(policy string) string {
+	switch policy {
+	case RawJpegRaw, RawJpegJpeg:
+		return policy
+	default:
+		return RawJpegBoth
+	}
+}
+
+// add counts the pairs found by another scan with the same policies
+func (p *UploadPairing) add(other UploadPairing) {
+	p.LivePhotos, p.RawJpeg = other.LivePhotos, other.RawJpeg
+	p.LivePhotoPairs += other.LivePhotoPairs
+	p.RawJpegPairs += other.RawJpegPairs
+}
+
+// pairKey groups the files of a pair: the path without its extension, ignoring case
+func pairKey(path string) string {
+	return strings.ToLower(strings.TrimSuffix(path, filepath.Ext(path)))
+}
+
+// pairExtension returns the lowercase extension of a file that can be part of a pair, or ""
+func pairExtension(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if slices.Contains(livePhotoStills, ext) || slices.Contains(livePhotoVideos, ext) || slices.Contains(rawExtensions, ext) {
+		return ext
+	}
+	return ""
+}
+
+// pairCandidates returns the paths that share their pairKey with another pairable path,
+// so only those need checking against the scan rules
+func pairCandidates(paths []string) []string {
+	count := map[string]int{}
+	for _, path := range paths {
+		if pairExtension(path) != "" {
+			count[pairKey(path)]++
+		}
+	}
+	var candidates []string
+	for _, path := range paths {
+		if pairExtension(path) != "" && count[pairKey(path)] > 1 {
+			candidates = append(candidates, path)
+		}
+	}
+	return candidates
+}
+
+// pair counts the pairs among files that are going to be uploaded and returns the
+// ones the policies leave out, with the reason
+func (p *UploadPairing) pair(paths []string) map[string]string {
+	groups := map[string][]string{}
+	for _, path := range paths {
+		key := pairKey(path)
+		groups[key] = append(groups[key], path)
+	}
+
+	skip := map[string]string{}
+	for _, group := range groups {
+		var stills, videos, raws, jpegs []string
+		for _, path := range group {
+			ext := pairExtension(path)
+			if slices.Contains(livePhotoStills, ext) {
+				stills = append(stills, path)
+			}
+			if slices.Contains(livePhotoVideos, ext) {
+				videos = append(videos, path)
+			}
+			if slices.Contains(rawExtensions, ext) {
+				raws = append(raws, path)
+			}
+			if slices.Contains(jpegExtensions, ext) {
+				jpegs = append(jpegs, path)
+			}
+		}
+
+		if len(stills) > 0 && len(videos) > 0 {
+			p.LivePhotoPairs++
+			if p.LivePhotos == LivePhotoStill {
+				for _, path := range videos {
+					skip[path] = SkipLivePhotoVideo
+				}
+			}
+		}
+		if len(raws) > 0 && len(jpegs) > 0 {
+			p.RawJpegPairs++
+			switch p.RawJpeg {
+			case RawJpegRaw:
+				for _, path := range jpegs {
+					skip[path] = SkipPairedJpeg
+				}
+			case RawJpegJpeg:
+				for _, path := range raws {
+					skip[path] = SkipPairedRaw
+				}
+			}
+		}
+	}
+	return skip
+}
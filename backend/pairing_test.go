@@ -0,0 +1,74 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestScanUploadFiles_Pairing(t *testing.T) {
+	saved := AppConfig
+	defer func() { AppConfig = saved }()
+
+	dir := t.TempDir()
+	for _, name := range []string{
+		"IMG_1234.HEIC", "IMG_1234.MOV", // Live Photo
+		"DSC_1.NEF", "DSC_1.JPG", // RAW+JPEG
+		"DSC_2.ARW", "dsc_2.jpeg", "DSC_2.MP4", // Both, differing in case
+		"DSC_3.NEF", "DSC_3.JPG", // Partner excluded by a rule
+		"clip.mp4", "other.jpg", "sub/IMG_1234.MOV",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scan := func(live, rawJpeg string) ([]string, map[string]string, UploadPairing) {
+		t.Helper()
+		AppConfig = Config{Recursive: true, UploadExclude: []string{"DSC_3.NEF"},
+			UploadLivePhotoPolicy: live, UploadRawJpegPolicy: rawJpeg}
+		archives := newArchiveSet()
+		defer archives.Close()
+		jobs, skipped, pairing, err := scanUploadFiles([]string{dir}, archives)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, job := range jobs {
+			rel, _ := filepath.Rel(dir, job.path)
+			names = append(names, filepath.ToSlash(rel))
+		}
+		reasons := map[string]string{}
+		for _, s := range skipped {
+			rel, _ := filepath.Rel(dir, s.Path)
+			reasons[filepath.ToSlash(rel)] = s.Reason
+		}
+		return names, reasons, pairing
+	}
+
+	names, _, pairing := scan(LivePhotoBoth, RawJpegBoth)
+	if len(names) != 11 || pairing.LivePhotoPairs != 2 || pairing.RawJpegPairs != 2 {
+		t.Errorf("both: %v, %+v", names, pairing)
+	}
+
+	names, reasons, pairing := scan(LivePhotoStill, RawJpegRaw)
+	want := []string{"DSC_1.NEF", "DSC_2.ARW", "DSC_3.JPG", "IMG_1234.HEIC", "clip.mp4", "other.jpg", "sub/IMG_1234.MOV"}
+	if !slices.Equal(names, want) {
+		t.Errorf("still+raw uploads %v, want %v", names, want)
+	}
+	if reasons["IMG_1234.MOV"] != SkipLivePhotoVideo || reasons["DSC_2.MP4"] != SkipLivePhotoVideo ||
+		reasons["DSC_1.JPG"] != SkipPairedJpeg || reasons["dsc_2.jpeg"] != SkipPairedJpeg || reasons["DSC_3.NEF"] != SkipExcluded {
+		t.Errorf("still+raw skipped %v", reasons)
+	}
+	if pairing.LivePhotos != LivePhotoStill || pairing.RawJpeg != RawJpegRaw {
+		t.Errorf("pairing = %+v", pairing)
+	}
+
+	_, reasons, _ = scan("", RawJpegJpeg)
+	if reasons["DSC_1.NEF"] != SkipPairedRaw || reasons["IMG_1234.MOV"] != "" {
+		t.Errorf("jpeg skipped %v", reasons)
+	}
+}
@@ -0,0 +1,481 @@
+package backend
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultHashThreads keeps hashing sequential so spinning disks are read without seeking
+	defaultHashThreads = 1
+	// pipelineBuffer bounds how many files may wait between two pipeline stages
+	pipelineBuffer = 16
+	// hashCheckBatchSize is the most hashes the check stage looks up at once
+	hashCheckBatchSize = 64
+	// scanProgressInterval is how many found files pass between "uploadScan" events
+	scanProgressInterval = 200
+	// queueEventInterval is the most often "uploadQueue" events are emitted
+	queueEventInterval = 250 * time.Millisecond
+)
+
+// UploadPreflight summarizes the library check and is emitted as "uploadPreflight" after every
+// checked batch. Counts are cumulative; Complete is set once every file has been checked.
+type UploadPreflight struct {
+	Total            int
+	AlreadyInLibrary int
+	ToUpload         int
+	Failed           int
+	Checked          bool // False once a lookup failed and workers check those files themselves
+	Complete         bool
+}
+
+// uploadJob is a file moving through the upload pipeline. A non-nil sha1 skips hashing,
+// and checked means the check stage already confirmed the file is missing from the library.
+type uploadJob struct {
+	path     string
+	info     os.FileInfo
+	sha1     []byte
+	mediaKey string // Known from the upload index
+	checked  bool
+	// open reads files that are not on disk, such as archive entries; info must be set with it
+	open func() (io.ReadCloser, error)
+	// timestamp overrides UploadTimestampPolicy, such as a Takeout sidecar's photoTakenTime
+	timestamp       time.Time
+	timestampSource string
+	// deferLocal keeps the file through the upload; the caller runs the post-upload action later
+	deferLocal bool
+}
+
+// onDisk reports whether the job is a local file, which can be deleted after upload
+func (j uploadJob) onDisk() bool {
+	return j.open == nil
+}
+
+// reader opens the job's file
+func (j uploadJob) reader() (io.ReadCloser, error) {
+	if j.open != nil {
+		return j.open()
+	}
+	return os.Open(j.path)
+}
+
+// hash calculates the SHA1 of the job's content
+func (j uploadJob) hash(ctx context.Context) ([]byte, error) {
+	r, _, _, err := j.content()
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %w", err)
+	}
+	defer r.Close()
+	return hashReader(ctx, r)
+}
+
+// pipelineCounters are shared by the scan segments of a batch so counts stay cumulative
+type pipelineCounters struct {
+	found      atomic.Int64
+	hashFailed atomic.Int64
+	scan       UploadScanProgress
+	preflight  UploadPreflight
+}
+
+// run streams the upload through four stages: the path walker, hashing workers
+// (HashThreads), a batched library check, and upload workers (UploadThreads) that take
+// files from the batch queue. Uploads start while the walker is still scanning. Paths
+// added to the running batch are scanned by further segments of the first three stages.
+// ThreadStatus worker IDs are contiguous: uploaders first, then hashers, then the checker.
+func (m *UploadManager) run(app AppInterface, batch *uploadBatch, targetPaths []string, opts UploadOptions, cancel <-chan struct{}) {
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go func() {
+		select {
+		case <-cancel:
+			stop()
+		case <-ctx.Done():
+		}
+		batch.wake()
+	}()
+
+	uploadWorkers := AppConfig.UploadThreads
+	results := make(chan FileUploadResult, pipelineBuffer)
+	counters := &pipelineCounters{preflight: UploadPreflight{Checked: true}}
+	index := getUploadIndex()
+
+	// Stages 0-2: scanning, hashing and the library check, one segment at a time
+	batch.scan = func(paths []string) {
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			complete := true
+			for paths != nil && ctx.Err() == nil {
+				complete = scanSegment(ctx, app, batch, index, paths, uploadWorkers, counters, results) && complete
+				paths = batch.nextRoots(complete)
+			}
+		}()
+	}
+	batch.scan(targetPaths)
+
+	// Stage 3: uploading, paused by the breaker during outages
+	breaker := newCircuitBreaker(func(pause UploadPause) {
+		app.GetLogger().Warn(fmt.Sprintf("%d consecutive upload failures, pausing until %s: %s",
+			pause.Failures, pause.Until.Format("15:04:05"), pause.Reason))
+		app.EmitEvent("uploadPaused", pause)
+	})
+	for i := range uploadWorkers {
+		m.wg.Add(1)
+		go startUploadWorker(ctx, i, batch, results, breaker, &m.wg, app)
+	}
+
+	// Upload workers exit only once the batch has nothing left to scan or upload,
+	// so once they and the scan segments are done nothing else sends results.
+	go func() {
+		m.wg.Wait()
+		close(results)
+	}()
+
+	// Report queue changes a few times a second rather than once per file
+	go func() {
+		ticker := time.NewTicker(queueEventInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if state, ok := batch.snapshot(false); ok {
+					app.EmitEvent("uploadQueue", state)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for result := range results {
+		opts.Journal.result(result)
+		app.EmitEvent("FileStatus", result)
+		if result.IsError {
+			s := fmt.Sprintf("upload error: %v", result.Error)
+			app.GetLogger().Error(s)
+		} else {
+			s := fmt.Sprintf("upload success: %v", result.Path)
+			app.GetLogger().Info(s)
+		}
+	}
+	stop()
+	batch.archives.Close()
+	state, _ := batch.snapshot(true)
+	app.EmitEvent("uploadQueue", state)
+	m.finish(app)
+}
+
+// scanSegment runs the scan, hash and check stages over paths and queues the files to
+// upload on the batch. It returns false if the scan failed.
+func scanSegment(ctx context.Context, app AppInterface, batch *uploadBatch, index *UploadIndex, paths []string,
+	uploadWorkers int, counters *pipelineCounters, results chan<- FileUploadResult) bool {
+	hashWorkers := max(AppConfig.HashThreads, 1)
+	checkerID := uploadWorkers + hashWorkers
+
+	scanned := make(chan uploadJob, pipelineBuffer)
+	hashed := make(chan uploadJob, pipelineBuffer)
+	checked := make(chan uploadJob, pipelineBuffer)
+
+	// Stage 0: scanning
+	complete := make(chan bool, 1)
+	go func() {
+		defer close(scanned)
+		complete <- scanPaths(ctx, app, batch, paths, scanned, counters)
+	}()
+
+	// Stage 1: hashing
+	var hashWG sync.WaitGroup
+	for i := range hashWorkers {
+		hashWG.Add(1)
+		go func() {
+			defer hashWG.Done()
+			hashStageWorker(ctx, uploadWorkers+i, index, scanned, hashed, results, &counters.hashFailed, app)
+		}()
+	}
+	go func() {
+		hashWG.Wait()
+		close(hashed)
+	}()
+
+	// Stage 2: library check
+	go func() {
+		defer close(checked)
+		checkStage(ctx, checkerID, index, &counters.found, hashed, checked, results, &counters.hashFailed, &counters.preflight, nil, app)
+	}()
+
+	for job := range checked {
+		if !batch.push(job) {
+			select {
+			case results <- FileUploadResult{IsError: true, Error: ErrUploadCancelled, Path: job.path}:
+			case <-ctx.Done():
+			}
+		}
+	}
+	return <-complete
+}
+
+// scanPaths walks the upload paths, sending files new to the batch to out and reporting
+// progress as "uploadScan". It returns false if the scan failed.
+func scanPaths(ctx context.Context, app AppInterface, batch *uploadBatch, targetPaths []string, out chan<- uploadJob, counters *pipelineCounters) bool {
+	progress := &counters.scan
+	found := &counters.found
+	journal := batch.journal
+	progress.Complete = false
+	app.EmitEvent("uploadScan", UploadScanProgress{Found: int(found.Load()), Skipped: progress.Skipped})
+
+	skip := func(skipped SkippedFile) {
+		progress.Skipped++
+		progress.SkippedFiles = append(progress.SkippedFiles, skipped)
+		app.GetLogger().Debug(fmt.Sprintf("skipping %s: %s", skipped.Path, skipped.Reason))
+	}
+	emit := func(job uploadJob) bool {
+		if journal.isDone(job.path) {
+			skip(SkippedFile{Path: job.path, Reason: SkipJournalDone})
+			return true
+		}
+		if !batch.track(job.path) {
+			skip(SkippedFile{Path: job.path, Reason: SkipVisited})
+			return true
+		}
+		journal.queued(job.path)
+		select {
+		case out <- job:
+		case <-ctx.Done():
+			return false
+		}
+		if n := found.Add(1); n%scanProgressInterval == 0 {
+			app.EmitEvent("uploadScan", UploadScanProgress{Found: int(n), Skipped: progress.Skipped})
+		}
+		return true
+	}
+
+	walker, err := newUploadWalker(emit, skip, batch.archives)
+	if err == nil {
+		err = walker.walk(ctx, targetPaths)
+		progress.Pairing.add(walker.pairing)
+	}
+	if err != nil && ctx.Err() == nil {
+		app.GetLogger().Error(fmt.Sprintf("upload scan failed: %v", err))
+	}
+
+	progress.Found = int(found.Load())
+	progress.Complete = true
+	app.EmitEvent("uploadScan", *progress)
+	return err == nil
+}
+
+// hashStageWorker stats and hashes files, reusing hashes from the upload index
+func hashStageWorker(ctx context.Context, workerID int, index *UploadIndex, scanned <-chan uploadJob, out chan<- uploadJob,
+	results chan<- FileUploadResult, failed *atomic.Int64, app AppInterface) {
+	fail := func(path string, err error) {
+		failed.Add(1)
+		select {
+		case results <- FileUploadResult{IsError: true, Error: err, Path: path}:
+		case <-ctx.Done():
+		}
+	}
+
+	for job := range scanned {
+		path := job.path
+		if job.onDisk() {
+			info, err := os.Stat(path)
+			if err != nil {
+				fail(path, fmt.Errorf("error getting file info: %w", err))
+				continue
+			}
+			job.info = info
+		}
+		info := job.info
+
+		if index != nil && !ReindexUploads {
+			if entry, ok := index.Lookup(path, info); ok && len(entry.SHA1) > 0 {
+				job.sha1 = entry.SHA1
+				job.mediaKey = entry.MediaKey
+			}
+		}
+
+		if job.sha1 == nil {
+			app.EmitEvent("ThreadStatus", ThreadStatus{
+				WorkerID: workerID,
+				Status:   "hashing",
+				FilePath: path,
+				FileName: filepath.Base(path),
+				Message:  "Hashing...",
+			})
+			var err error
+			job.sha1, err = job.hash(ctx)
+			if err != nil {
+				fail(path, fmt.Errorf("error calculating hash file: %w", err))
+				continue
+			}
+			if index != nil {
+				index.Put(path, info, job.sha1, "")
+			}
+		}
+
+		select {
+		case out <- job:
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	app.EmitEvent("ThreadStatus", ThreadStatus{
+		WorkerID: workerID,
+		Status:   "idle",
+		Message:  "Hashing finished",
+	})
+}
+
+// hashLookupFunc checks SHA1 hashes against the library like Api.FindRemoteMediaByHashes
+type hashLookupFunc func(ctx context.Context, hashes [][]byte) (map[string]string, error)
+
+// checkStage groups hashed files into batches, looks them up in the library and forwards
+// the files that are missing to the upload workers. A nil lookup uses the selected account.
+func checkStage(ctx context.Context, workerID int, index *UploadIndex, scanned *atomic.Int64, in <-chan uploadJob, out chan<- uploadJob,
+	results chan<- FileUploadResult, hashFailed *atomic.Int64, summary *UploadPreflight, lookup hashLookupFunc, app AppInterface) {
+	summary.Complete = false
+	var api *Api
+	if lookup == nil {
+		lookup = func(ctx context.Context, hashes [][]byte) (map[string]string, error) {
+			if api == nil {
+				var err error
+				if api, err = NewApi(); err != nil {
+					return nil, err
+				}
+			}
+			return api.FindRemoteMediaByHashes(ctx, hashes)
+		}
+	}
+
+	for {
+		var batch []uploadJob
+		select {
+		case job, ok := <-in:
+			if !ok {
+				summary.Total = int(scanned.Load())
+				summary.Failed = int(hashFailed.Load())
+				summary.Complete = true
+				app.EmitEvent("uploadPreflight", *summary)
+				return
+			}
+			batch = append(batch, job)
+		case <-ctx.Done():
+			return
+		}
+
+		// Take whatever else is already waiting so lookups go out in batches
+	DRAIN:
+		for len(batch) < hashCheckBatchSize {
+			select {
+			case job, ok := <-in:
+				if !ok {
+					break DRAIN
+				}
+				batch = append(batch, job)
+			default:
+				break DRAIN
+			}
+		}
+
+		var found map[string]string
+		var unchecked map[string]error
+		lookupFailed := false
+		if !AppConfig.ForceUpload {
+			var hashes [][]byte
+			for _, job := range batch {
+				// Index hits are looked up too when their file would be deleted or moved
+				if job.mediaKey == "" || movesLocalFile(job) {
+					hashes = append(hashes, job.sha1)
+				}
+			}
+			if len(hashes) > 0 {
+				app.EmitEvent("ThreadStatus", ThreadStatus{
+					WorkerID: workerID,
+					Status:   "checking",
+					Message:  fmt.Sprintf("Checking %d files against library...", len(hashes)),
+				})
+				var err error
+				found, err = lookup(ctx, hashes)
+				if err != nil {
+					if ctx.Err() != nil {
+						return
+					}
+					// Files that could not be checked fall back to per-file checks in the upload workers
+					app.GetLogger().Warn(fmt.Sprintf("library check incomplete: %v", err))
+					summary.Checked = false
+					if lookupErr, ok := err.(*HashLookupError); ok {
+						unchecked = lookupErr.Failed
+					} else {
+						lookupFailed = true
+					}
+				}
+				app.EmitEvent("ThreadStatus", ThreadStatus{
+					WorkerID: workerID,
+					Status:   "idle",
+					Message:  "Waiting for hashes...",
+				})
+			}
+		}
+
+		var missing []uploadJob
+		for _, job := range batch {
+			if !AppConfig.ForceUpload {
+				hashKey := base64.StdEncoding.EncodeToString(job.sha1)
+				_, failed := unchecked[hashKey]
+				if job.mediaKey != "" && movesLocalFile(job) && !lookupFailed && !failed && found[hashKey] == "" {
+					// The library no longer holds the indexed file, so it is uploaded again
+					if index != nil {
+						index.Remove(job.path)
+					}
+					job.mediaKey = ""
+				}
+				mediaKey := job.mediaKey
+				if mediaKey == "" {
+					mediaKey = found[hashKey]
+					if mediaKey != "" && index != nil {
+						index.Put(job.path, job.info, job.sha1, mediaKey)
+					}
+				}
+				if mediaKey != "" {
+					summary.AlreadyInLibrary++
+					var local LocalFileAction
+					if job.mediaKey == "" || !movesLocalFile(job) || found[hashKey] != "" {
+						local = finishLocalFile(api, index, job, job.sha1)
+					} else {
+						// The batch could not confirm the indexed file, so check it on its own
+						local, _ = finishIndexedFile(api, index, job, job.sha1)
+					}
+					select {
+					case results <- FileUploadResult{MediaKey: mediaKey, Path: job.path, Local: local}:
+					case <-ctx.Done():
+						return
+					}
+					continue
+				}
+				job.checked = !lookupFailed && !failed
+			}
+			missing = append(missing, job)
+		}
+
+		summary.ToUpload += len(missing)
+		summary.Total = int(scanned.Load())
+		summary.Failed = int(hashFailed.Load())
+		app.EmitEvent("uploadPreflight", *summary)
+
+		for _, job := range missing {
+			select {
+			case out <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package backend
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCheckStage_IndexedFilesSkipUpload(t *testing.T) {
+	saved := AppConfig
+	defer func() { AppConfig = saved }()
+
+	var mu sync.Mutex
+	var summaries []UploadPreflight
+	app := NewCLIApp(func(event string, data any) {
+		if summary, ok := data.(UploadPreflight); ok && event == "uploadPreflight" {
+			mu.Lock()
+			summaries = append(summaries, summary)
+			mu.Unlock()
+		}
+	}, slog.LevelError)
+
+	run := func(force bool) (uploaded []uploadJob, skipped []FileUploadResult) {
+		AppConfig = Config{ForceUpload: force}
+		summaries = nil
+
+		in := make(chan uploadJob, 2)
+		out := make(chan uploadJob, 2)
+		results := make(chan FileUploadResult, 2)
+		in <- uploadJob{path: "a.jpg", sha1: []byte{1}, mediaKey: "key-a"}
+		in <- uploadJob{path: "b.jpg", sha1: []byte{2}, mediaKey: "key-b"}
+		close(in)
+
+		var found, failed atomic.Int64
+		found.Store(2)
+		checkStage(context.Background(), 0, nil, &found, in, out, results, &failed, &UploadPreflight{Checked: true}, nil, app)
+		close(out)
+		close(results)
+		for job := range out {
+			uploaded = append(uploaded, job)
+		}
+		for result := range results {
+			skipped = append(skipped, result)
+		}
+		return uploaded, skipped
+	}
+
+	uploaded, skipped := run(false)
+	if len(uploaded) != 0 || len(skipped) != 2 {
+		t.Fatalf("expected both files skipped, got %d uploaded and %d skipped", len(uploaded), len(skipped))
+	}
+	if skipped[0].MediaKey != "key-a" || skipped[1].MediaKey != "key-b" {
+		t.Fatalf("unexpected media keys: %+v", skipped)
+	}
+	last := summaries[len(summaries)-1]
+	if !last.Complete || last.Total != 2 || last.AlreadyInLibrary != 2 || last.ToUpload != 0 {
+		t.Fatalf("unexpected final summary: %+v", last)
+	}
+
+	uploaded, skipped = run(true)
+	if len(uploaded) != 2 || len(skipped) != 0 {
+		t.Fatalf("expected both files forwarded with ForceUpload, got %d uploaded and %d skipped", len(uploaded), len(skipped))
+	}
+	last = summaries[len(summaries)-1]
+	if !last.Complete || last.ToUpload != 2 {
+		t.Fatalf("unexpected final summary: %+v", last)
+	}
+}
+
+func TestCheckStage_StaleIndexHitIsUploaded(t *testing.T) {
+	saved := AppConfig
+	defer func() { AppConfig = saved }()
+	AppConfig = Config{DeleteFromHost: true}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.jpg")
+	if err := os.WriteFile(path, []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := NewUploadIndex(filepath.Join(dir, uploadIndexFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	index.Put(path, info, []byte{1}, "stale-key")
+
+	// The library answers but no longer holds the file
+	var looked atomic.Int32
+	lookup := func(ctx context.Context, hashes [][]byte) (map[string]string, error) {
+		looked.Add(int32(len(hashes)))
+		return map[string]string{}, nil
+	}
+
+	in := make(chan uploadJob, 1)
+	out := make(chan uploadJob, 1)
+	results := make(chan FileUploadResult, 1)
+	in <- uploadJob{path: path, info: info, sha1: []byte{1}, mediaKey: "stale-key"}
+	close(in)
+
+	var found, failed atomic.Int64
+	found.Store(1)
+	summary := &UploadPreflight{Checked: true}
+	checkStage(context.Background(), 0, index, &found, in, out, results, &failed, summary, lookup, NewCLIApp(nil, slog.LevelError))
+	close(out)
+	close(results)
+
+	if looked.Load() != 1 {
+		t.Errorf("%d hashes looked up, want 1", looked.Load())
+	}
+	if result, ok := <-results; ok {
+		t.Fatalf("stale index hit reported as uploaded: %+v", result)
+	}
+	job, ok := <-out
+	if !ok {
+		t.Fatal("stale index hit was not forwarded for upload")
+	}
+	if job.mediaKey != "" || !job.checked {
+		t.Errorf("forwarded job: media key %q, checked %v", job.mediaKey, job.checked)
+	}
+	if summary.AlreadyInLibrary != 0 || summary.ToUpload != 1 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+	if _, ok := index.Lookup(path, info); ok {
+		t.Error("stale index entry was kept")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("local file: %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Actions taken on local files once they are in the library
+const (
+	PostUploadKeep         = "keep"          // Leave files in place
+	PostUploadDelete       = "delete"        // Delete files, like DeleteFromHost
+	PostUploadVerifyDelete = "verify-delete" // Delete files once their hash is found in the library a second time
+	PostUploadArchive      = "archive"       // Move files to a mirror of their path under PostUploadArchiveDir
+	PostUploadTrash        = "trash"         // Move files to the freedesktop.org trash (Linux only)
+)
+
+// What happened to a local file, as recorded in LocalFileAction
+const (
+	LocalKept     = "kept"
+	LocalDeleted  = "deleted"
+	LocalArchived = "archived"
+	LocalTrashed  = "trashed"
+)
+
+// SkipArchiveDir is reported for the archive directory when it lies inside a scanned folder
+const SkipArchiveDir = "post-upload archive directory"
+
+// LocalFileAction records what was done with a local file after it reached the library
+type LocalFileAction struct {
+	Action string `json:"action"`          // LocalKept, LocalDeleted, LocalArchived or LocalTrashed
+	Dest   string `json:"dest,omitempty"`  // Where an archived or trashed file was moved
+	Error  string `json:"error,omitempty"` // Why a file was kept despite the configured action
+}
+
+// normalizePostUploadAction maps unknown values to keeping files
+func normalizePostUploadAction(action string) string {
+	switch action {
+	case PostUploadDelete, PostUploadVerifyDelete, PostUploadArchive, PostUploadTrash:
+		return action
+	default:
+		return PostUploadKeep
+	}
+}
+
+// postUploadAction returns the configured action. DeleteFromHost deletes files unless
+// another action is set.
+func postUploadAction() string {
+	action := normalizePostUploadAction(AppConfig.PostUploadAction)
+	if action == PostUploadKeep && AppConfig.DeleteFromHost {
+		return PostUploadDelete
+	}
+	return action
+}
+
+// PostUploadActionLabel describes the configured post-upload action, or "" if files are kept
+func PostUploadActionLabel() string {
+	switch postUploadAction() {
+	case PostUploadDelete:
+		return "deleted from this computer"
+	case PostUploadVerifyDelete:
+		return "deleted from this computer once found in the library again"
+	case PostUploadArchive:
+		return "moved to " + AppConfig.PostUploadArchiveDir
+	case PostUploadTrash:
+		return "moved to the trash"
+	default:
+		return ""
+	}
+}
+
+// ValidatePostUploadAction checks that an action can be carried out on this system
+func ValidatePostUploadAction(action string) error {
+	switch action {
+	case PostUploadKeep, PostUploadDelete, PostUploadVerifyDelete, PostUploadArchive:
+		return nil
+	case PostUploadTrash:
+		return trashSupported()
+	default:
+		return fmt.Errorf("unknown post-upload action %q: use keep, delete, verify-delete, archive or trash", action)
+	}
+}
+
+// finishLocalFile carries out the post-upload action on a job's file once it is in the
+// library, and forgets it in the upload index if it was moved or deleted. Files that are
+// not on disk, such as archive entries, are always kept. api may be nil.
+func finishLocalFile(api *Api, index *UploadIndex, job uploadJob, sha1 []byte) LocalFileAction {
+	return finishLocal(api, index, job, sha1, false)
+}
+
+// finishLocal is finishLocalFile; confirmed skips verify-delete's library check when the
+// caller already made it
+func finishLocal(api *Api, index *UploadIndex, job uploadJob, sha1 []byte, confirmed bool) LocalFileAction {
+	if !movesLocalFile(job) {
+		return LocalFileAction{Action: LocalKept}
+	}
+	action := postUploadAction()
+	kept := func(err error) LocalFileAction {
+		return LocalFileAction{Action: LocalKept, Error: err.Error()}
+	}
+
+	var result LocalFileAction
+	switch action {
+	case PostUploadVerifyDelete:
+		if !confirmed {
+			if err := confirmInLibrary(api, sha1); err != nil {
+				return kept(err)
+			}
+		}
+		fallthrough
+	case PostUploadDelete:
+		if err := os.Remove(job.path); err != nil {
+			return kept(fmt.Errorf("failed to delete file: %w", err))
+		}
+		result = LocalFileAction{Action: LocalDeleted}
+	case PostUploadArchive:
+		dest, err := archiveLocalFile(job.path, AppConfig.PostUploadArchiveDir)
+		if err != nil {
+			return kept(fmt.Errorf("failed to archive file: %w", err))
+		}
+		result = LocalFileAction{Action: LocalArchived, Dest: dest}
+	case PostUploadTrash:
+		dest, err := trashFile(job.path)
+		if err != nil {
+			return kept(fmt.Errorf("failed to move file to trash: %w", err))
+		}
+		result = LocalFileAction{Action: LocalTrashed, Dest: dest}
+	}
+	if index != nil {
+		index.Remove(job.path)
+	}
+	return result
+}
+
+// movesLocalFile reports whether the post-upload action would delete or move a job's file
+func movesLocalFile(job uploadJob) bool {
+	return postUploadAction() != PostUploadKeep && job.onDisk() && !job.deferLocal
+}
+
+// finishIndexedFile carries out the post-upload action on a file the upload index lists as
+// uploaded. The index is local state that can be stale or copied from another account, so
+// the file is only deleted or moved once the library confirms it holds the file's hash.
+// The returned error is why that confirmation failed; it wraps errNotInLibrary when the
+// library answered without the file.
+func finishIndexedFile(api *Api, index *UploadIndex, job uploadJob, sha1 []byte) (LocalFileAction, error) {
+	if !movesLocalFile(job) {
+		return finishLocalFile(api, index, job, sha1), nil
+	}
+	if err := confirmInLibrary(api, sha1); err != nil {
+		return LocalFileAction{Action: LocalKept, Error: err.Error()}, err
+	}
+	return finishLocal(api, index, job, sha1, true), nil
+}
+
+// errNotInLibrary is why a file is kept when the library does not hold its hash
+var errNotInLibrary = errors.New("not found in library on verification")
+
+// confirmInLibrary checks that the library holds a file with the given hash. api may be nil.
+func confirmInLibrary(api *Api, sha1 []byte) error {
+	var err error
+	if api == nil {
+		if api, err = NewApi(); err != nil {
+			return fmt.Errorf("failed to verify upload: %w", err)
+		}
+	}
+	mediaKey, err := api.FindRemoteMediaByHash(sha1)
+	if err != nil {
+		return fmt.Errorf("failed to verify upload: %w", err)
+	}
+	if mediaKey == "" {
+		return errNotInLibrary
+	}
+	return nil
+}
+
+// archiveDestination mirrors the absolute path of a file under dir, so /home/me/a.jpg
+// becomes dir/home/me/a.jpg and C:\Photos\a.jpg becomes dir\C\Photos\a.jpg
+func archiveDestination(path, dir string) (string, error) {
+	if dir == "" {
+		return "", errors.New("no archive directory is set")
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if inDir(abs, root) {
+		return "", fmt.Errorf("%s is already in the archive directory", path)
+	}
+	volume := filepath.VolumeName(abs)
+	rest := abs[len(volume):]
+	volume = strings.Trim(strings.ReplaceAll(volume, ":", ""), `\/`)
+	return filepath.Join(root, volume, rest), nil
+}
+
+// inDir reports whether path is dir or lies inside it; both must be absolute and clean
+func inDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// archiveLocalFile moves a file to its mirror under dir, adding " (n)" to the name if
+// that is taken, and returns where it was moved
+func archiveLocalFile(path, dir string) (string, error) {
+	dest, err := archiveDestination(path, dir)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", err
+	}
+	ext := filepath.Ext(dest)
+	base := strings.TrimSuffix(dest, ext)
+	for i := 1; i <= maxCollisionRenames; i++ {
+		if _, err := os.Lstat(dest); errors.Is(err, fs.ErrNotExist) {
+			if err := moveFile(path, dest); err != nil {
+				return "", err
+			}
+			return dest, nil
+		}
+		dest = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+	return "", fmt.Errorf("no free file name for %s", dest)
+}
+
+// moveFile renames a file, copying it when the destination is on another file system
+func moveFile(src, dest string) error {
+	if err := os.Rename(src, dest); err == nil {
+		return nil
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dest)
+		return err
+	}
+	os.Chtimes(dest, info.ModTime(), info.ModTime())
+	in.Close()
+	if err := os.Remove(src); err != nil {
+		return fmt.Errorf("copied to %s but failed to remove the original: %w", dest, err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package backend
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestFinishLocalFile(t *testing.T) {
+	saved := AppConfig
+	defer func() { AppConfig = saved }()
+
+	dir := t.TempDir()
+	write := func(name string) uploadJob {
+		t.Helper()
+		path := filepath.Join(dir, "photos", name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return uploadJob{path: path}
+	}
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+
+	// Keeping is the default; DeleteFromHost still deletes
+	AppConfig = Config{}
+	job := write("a.jpg")
+	if local := finishLocalFile(nil, nil, job, nil); local.Action != LocalKept || !exists(job.path) {
+		t.Errorf("keep: %+v", local)
+	}
+	AppConfig = Config{DeleteFromHost: true}
+	deferred := job
+	deferred.deferLocal = true // As in a Takeout import, until the sidecar metadata is set
+	if local := finishLocalFile(nil, nil, deferred, nil); local.Action != LocalKept || !exists(job.path) {
+		t.Errorf("deferred: %+v", local)
+	}
+	if local := finishLocalFile(nil, nil, job, nil); local.Action != LocalDeleted || exists(job.path) {
+		t.Errorf("DeleteFromHost: %+v", local)
+	}
+	// An upload index hit alone never deletes: without a library to confirm it the file stays.
+	// A failed check is not mistaken for the library missing the file.
+	job = write("indexed.jpg")
+	if local, err := finishIndexedFile(nil, nil, job, []byte("sha1")); local.Action != LocalKept || local.Error == "" ||
+		err == nil || errors.Is(err, errNotInLibrary) || !exists(job.path) {
+		t.Errorf("unconfirmed index hit: %+v, %v", local, err)
+	}
+	os.Remove(job.path)
+	entry := uploadJob{path: "x.zip!/a.jpg", open: func() (io.ReadCloser, error) { return nil, nil }}
+	if local := finishLocalFile(nil, nil, entry, nil); local.Action != LocalKept {
+		t.Errorf("archive entry: %+v", local)
+	}
+
+	// Archiving mirrors the absolute path and renames on collisions
+	archive := filepath.Join(dir, "archive")
+	AppConfig = Config{DeleteFromHost: true, PostUploadAction: PostUploadArchive, PostUploadArchiveDir: archive}
+	want, _ := archiveDestination(filepath.Join(dir, "photos", "b.jpg"), archive)
+	if !strings.HasPrefix(want, archive) || !strings.HasSuffix(want, filepath.Join("photos", "b.jpg")) {
+		t.Fatalf("archiveDestination = %s", want)
+	}
+	for i, wantDest := range []string{want, strings.TrimSuffix(want, ".jpg") + " (1).jpg"} {
+		job := write("b.jpg")
+		local := finishLocalFile(nil, nil, job, nil)
+		if local.Action != LocalArchived || local.Dest != wantDest || exists(job.path) || !exists(wantDest) {
+			t.Errorf("archive %d: %+v, want %s", i, local, wantDest)
+		}
+	}
+	inArchive := uploadJob{path: want}
+	if local := finishLocalFile(nil, nil, inArchive, nil); local.Action != LocalKept || local.Error == "" || !exists(want) {
+		t.Errorf("file inside the archive: %+v", local)
+	}
+
+	// The walker leaves the archive directory out when it is inside a scanned folder
+	AppConfig.Recursive = true
+	jobs, skipped, _, err := scanUploadFiles([]string{dir}, newArchiveSet())
+	if err != nil || len(jobs) != 0 || len(skipped) != 1 || skipped[0].Reason != SkipArchiveDir {
+		t.Errorf("scan: %v, %v, %v", jobs, skipped, err)
+	}
+
+	if runtime.GOOS != "linux" {
+		return
+	}
+	t.Setenv("XDG_DATA_HOME", filepath.Join(dir, "data"))
+	AppConfig = Config{PostUploadAction: PostUploadTrash}
+	for _, wantName := range []string{"c d.jpg", "c d.1.jpg"} {
+		job := write("c d.jpg")
+		local := finishLocalFile(nil, nil, job, nil)
+		trash := filepath.Join(dir, "data", "Trash")
+		if local.Action != LocalTrashed || local.Dest != filepath.Join(trash, "files", wantName) || exists(job.path) {
+			t.Fatalf("trash: %+v", local)
+		}
+		info, err := os.ReadFile(filepath.Join(trash, "info", wantName+".trashinfo"))
+		if err != nil || !strings.Contains(string(info), "Path="+filepath.ToSlash(filepath.Dir(job.path))+"/c%20d.jpg\n") {
+			t.Errorf("trashinfo = %q, %v", info, err)
+		}
+	}
+}
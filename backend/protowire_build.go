@@ -117,22 +117,3 @@ func deepCopyJSON(v any) any {
 		return vv
 	}
 }
-
-func ensureMapPath(root map[string]any, keys ...string) (map[string]any, error) {
-	cur := root
-	for _, k := range keys {
-		next, ok := cur[k]
-		if !ok || next == nil {
-			created := map[string]any{}
-			cur[k] = created
-			cur = created
-			continue
-		}
-		asMap, ok := next.(map[string]any)
-		if !ok {
-			return nil, fmt.Errorf("path %q is not an object", k)
-		}
-		cur = asMap
-	}
-	return cur, nil
-}
@@ -0,0 +1,331 @@
+package backend
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Built-in request templates: protobuf requests as numeric-key JSON, see buildProtobufFromMap.
+// A file of the same name in RequestTemplateDir replaces one without recompiling.
+//
+//go:embed templates/*.json
+var builtinRequestTemplates embed.FS
+
+// Kinds of the values filled into a template
+const (
+	templateString = "string"
+	templateInt    = "int"
+)
+
+// templateField is a field a request fills in; the template holds a placeholder of its kind
+type templateField struct {
+	path     string // Dot-separated field numbers, such as "1.4"
+	kind     string // templateString or templateInt
+	desc     string
+	optional bool // Removed from the request when the value is empty
+	// Repeated fields hold a one-element list at path; each value is written into a copy of
+	// that element at the each path, or replaces the element if each is empty
+	repeated bool
+	each     string
+}
+
+// requestTemplates lists the dynamic fields of every built-in template
+var requestTemplates = map[string][]templateField{
+	"media-list": {
+		{path: "1.2", kind: templateInt, desc: "page size"},
+		{path: "1.4", kind: templateString, desc: "page token", optional: true},
+		{path: "1.6", kind: templateString, desc: "sync token"},
+		{path: "1.22.1", kind: templateInt, desc: "trigger mode: 1 active, 2 passive"},
+	},
+	"media-info": {
+		{path: "1.1.21.1", kind: templateInt, desc: "trash items: 1 without, 2 with"},
+		{path: "1.5.1", kind: templateString, desc: "media key"},
+	},
+	"album-list": {
+		{path: "1.4", kind: templateString, desc: "page token", optional: true},
+	},
+	"move-to-trash": {
+		{path: "3", kind: templateString, desc: "dedup keys", repeated: true},
+		{path: "9.2.1", kind: templateInt, desc: "client version code"},
+		{path: "9.2.2", kind: templateString, desc: "Android API level"},
+	},
+	"permanently-delete": {
+		{path: "3", kind: templateString, desc: "dedup keys", repeated: true},
+	},
+	"set-caption": {
+		{path: "2", kind: templateString, desc: "caption"},
+		{path: "3", kind: templateString, desc: "dedup key"},
+	},
+	"set-favorite": {
+		{path: "1.2", kind: templateString, desc: "dedup key"},
+		{path: "2.1", kind: templateInt, desc: "action: 1 favorite, 2 unfavorite"},
+	},
+	"create-album": {
+		{path: "1", kind: templateString, desc: "title"},
+		{path: "2", kind: templateInt, desc: "creation time, Unix seconds"},
+		{path: "4", kind: templateString, desc: "media keys", repeated: true, each: "1.1"},
+	},
+	"add-media-to-album": {
+		{path: "1", kind: templateString, desc: "media keys", repeated: true},
+		{path: "2", kind: templateString, desc: "album media key"},
+		{path: "7.2.1", kind: templateInt, desc: "client version code"},
+		{path: "7.2.2", kind: templateString, desc: "Android API level"},
+		{path: "8", kind: templateInt, desc: "request time, Unix seconds"},
+	},
+}
+
+// RequestTemplate is the effective template of one request
+type RequestTemplate struct {
+	Name   string   `json:"name"`
+	Source string   `json:"source"` // Path of the override file, or "built-in"
+	Fields []string `json:"fields"` // Dynamic fields, as "1.4 (string, optional): page token"
+	JSON   string   `json:"json"`
+	Error  string   `json:"error,omitempty"` // Why an override file cannot be used
+}
+
+type loadedTemplate struct {
+	root map[string]any
+	err  error
+}
+
+var (
+	requestTemplateMu    sync.Mutex
+	requestTemplateCache = map[string]loadedTemplate{}
+)
+
+// RequestTemplateDir returns the directory of template overrides, next to the config file
+func RequestTemplateDir() string {
+	if ConfigPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(ConfigPath), "templates")
+}
+
+// RequestTemplateNames returns the names of all request templates
+func RequestTemplateNames() []string {
+	entries, _ := fs.ReadDir(builtinRequestTemplates, "templates")
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	return names
+}
+
+// readRequestTemplate returns the text of a template and where it came from
+func readRequestTemplate(name string) (text []byte, source string, err error) {
+	if dir := RequestTemplateDir(); dir != "" {
+		path := filepath.Join(dir, name+".json")
+		text, err = os.ReadFile(path)
+		if err == nil {
+			return text, path, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, path, err
+		}
+	}
+	text, err = builtinRequestTemplates.ReadFile("templates/" + name + ".json")
+	return text, "built-in", err
+}
+
+// parseRequestTemplate decodes a template and checks that it encodes and holds a
+// placeholder of the right kind for every dynamic field
+func parseRequestTemplate(text []byte, fields []templateField) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(text))
+	dec.UseNumber()
+	var root map[string]any
+	if err := dec.Decode(&root); err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+	if _, err := buildProtobufFromMap(root); err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		parent, key, err := templateParent(root, field.path)
+		if err != nil {
+			return nil, err
+		}
+		value, ok := parent[key]
+		if !ok {
+			return nil, fmt.Errorf("field %s (%s) is missing", field.path, field.desc)
+		}
+		if field.repeated {
+			list, ok := value.([]any)
+			if !ok || len(list) != 1 {
+				return nil, fmt.Errorf("field %s (%s) must be a list with one element", field.path, field.desc)
+			}
+			value = list[0]
+			if field.each != "" {
+				element, ok := value.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("field %s (%s) must hold messages", field.path, field.desc)
+				}
+				eachParent, eachKey, err := templateParent(element, field.each)
+				if err != nil {
+					return nil, fmt.Errorf("field %s: %w", field.path, err)
+				}
+				value = eachParent[eachKey]
+			}
+		}
+		if !placeholderOfKind(value, field.kind) {
+			return nil, fmt.Errorf("field %s (%s) must be a%s %s", field.path, field.desc,
+				map[string]string{templateInt: "n"}[field.kind], field.kind)
+		}
+	}
+	return root, nil
+}
+
+// templateParent returns the message holding the last field of a path
+func templateParent(root map[string]any, path string) (map[string]any, string, error) {
+	keys := strings.Split(path, ".")
+	cur := root
+	for i, key := range keys[:len(keys)-1] {
+		next, ok := cur[key].(map[string]any)
+		if !ok {
+			return nil, "", fmt.Errorf("field %s is not a message", strings.Join(keys[:i+1], "."))
+		}
+		cur = next
+	}
+	return cur, keys[len(keys)-1], nil
+}
+
+func placeholderOfKind(value any, kind string) bool {
+	switch kind {
+	case templateString:
+		_, ok := value.(string)
+		return ok
+	case templateInt:
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := n.Int64()
+		return err == nil
+	}
+	return false
+}
+
+// loadRequestTemplate returns the parsed template of a request, reading an override once
+func loadRequestTemplate(name string) (map[string]any, error) {
+	requestTemplateMu.Lock()
+	defer requestTemplateMu.Unlock()
+	if cached, ok := requestTemplateCache[name]; ok {
+		return cached.root, cached.err
+	}
+	fields, ok := requestTemplates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown request template %q", name)
+	}
+	text, source, err := readRequestTemplate(name)
+	var root map[string]any
+	if err == nil {
+		root, err = parseRequestTemplate(text, fields)
+	}
+	if err != nil {
+		err = fmt.Errorf("request template %s (%s): %w", name, source, err)
+	}
+	requestTemplateCache[name] = loadedTemplate{root, err}
+	return root, err
+}
+
+// resetRequestTemplates forgets loaded templates, so overrides are read again
+func resetRequestTemplates() {
+	requestTemplateMu.Lock()
+	defer requestTemplateMu.Unlock()
+	clear(requestTemplateCache)
+}
+
+// buildTemplateRequest encodes a request from its template with the dynamic fields set.
+// values maps field paths to a string, an int64, or a []string or []int64 for repeated
+// fields; fields left out keep the template's value.
+func buildTemplateRequest(name string, values map[string]any) ([]byte, error) {
+	base, err := loadRequestTemplate(name)
+	if err != nil {
+		return nil, err
+	}
+	root := deepCopyJSON(base).(map[string]any)
+	for _, field := range requestTemplates[name] {
+		value, ok := values[field.path]
+		if !ok {
+			continue
+		}
+		parent, key, _ := templateParent(root, field.path)
+		switch {
+		case field.repeated:
+			prototype := parent[key].([]any)[0]
+			var list []any
+			for _, v := range repeatedValues(value) {
+				if field.each == "" {
+					list = append(list, v)
+					continue
+				}
+				element := deepCopyJSON(prototype).(map[string]any)
+				eachParent, eachKey, _ := templateParent(element, field.each)
+				eachParent[eachKey] = v
+				list = append(list, element)
+			}
+			if len(list) == 0 {
+				delete(parent, key)
+			} else {
+				parent[key] = list
+			}
+		case field.optional && value == "":
+			delete(parent, key)
+		default:
+			parent[key] = value
+		}
+	}
+	return buildProtobufFromMap(root)
+}
+
+func repeatedValues(value any) []any {
+	var out []any
+	switch vv := value.(type) {
+	case []string:
+		for _, v := range vv {
+			out = append(out, v)
+		}
+	case []int64:
+		for _, v := range vv {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// RequestTemplates returns the effective template of every request, with overrides
+// read from RequestTemplateDir
+func RequestTemplates() []RequestTemplate {
+	var templates []RequestTemplate
+	for _, name := range RequestTemplateNames() {
+		template := RequestTemplate{Name: name}
+		for _, field := range requestTemplates[name] {
+			kind := field.kind
+			if field.repeated {
+				kind = "repeated " + kind
+			}
+			if field.optional {
+				kind += ", optional"
+			}
+			template.Fields = append(template.Fields, fmt.Sprintf("%s (%s): %s", field.path, kind, field.desc))
+		}
+		text, source, err := readRequestTemplate(name)
+		template.Source = source
+		template.JSON = string(text)
+		if err == nil {
+			_, err = parseRequestTemplate(text, requestTemplates[name])
+		}
+		if err != nil {
+			template.Error = err.Error()
+		}
+		templates = append(templates, template)
+	}
+	return templates
+}
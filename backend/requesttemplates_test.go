@@ -0,0 +1,81 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRequestTemplates(t *testing.T) {
+	saved := ConfigPath
+	defer func() { ConfigPath = saved; resetRequestTemplates() }()
+	ConfigPath = ""
+	resetRequestTemplates()
+
+	// Every built-in template holds a placeholder for its fields
+	names := RequestTemplateNames()
+	if len(names) != len(requestTemplates) {
+		t.Errorf("%d template files for %d templates", len(names), len(requestTemplates))
+	}
+	for _, template := range RequestTemplates() {
+		if template.Source != "built-in" || template.Error != "" {
+			t.Errorf("%s: source %s, error %q", template.Name, template.Source, template.Error)
+		}
+	}
+
+	// An empty optional field and an empty repeated field are left out
+	req, err := buildMediaListRequest("", "sync", 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if protobufStringAt(req, 1, 4) != "" || protobufStringAt(req, 1, 6) != "sync" {
+		t.Errorf("media list without page token: %x", req)
+	}
+	if req, _ = buildMediaListRequest("next", "", 1, 0); protobufStringAt(req, 1, 4) != "next" {
+		t.Errorf("media list page token missing: %x", req)
+	}
+	req, err = buildTemplateRequest("create-album", map[string]any{"1": "Trip", "4": []string{"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if protobufStringAt(req, 1) != "Trip" || protobufStringAt(req, 4, 1, 1) != "a" {
+		t.Errorf("create album: %x", req)
+	}
+	if req, _ = buildTemplateRequest("create-album", map[string]any{"4": []string(nil)}); protobufStringAt(req, 4, 1, 1) != "" {
+		t.Errorf("create album without keys: %x", req)
+	}
+
+	// A file in the templates directory replaces the built-in template
+	dir := t.TempDir()
+	ConfigPath = filepath.Join(dir, "gotohp.config")
+	os.MkdirAll(RequestTemplateDir(), 0755)
+	write := func(name, text string) {
+		if err := os.WriteFile(filepath.Join(RequestTemplateDir(), name+".json"), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		resetRequestTemplates()
+	}
+	write("set-caption", `{"2": "", "3": "", "9": "extra"}`)
+	req, err = buildTemplateRequest("set-caption", map[string]any{"2": "Hello", "3": "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if protobufStringAt(req, 2) != "Hello" || protobufStringAt(req, 9) != "extra" {
+		t.Errorf("overridden caption request: %x", req)
+	}
+
+	// Overrides without the dynamic fields, or with the wrong kinds, are refused
+	for _, tt := range []struct{ name, text, want string }{
+		{"set-caption", `{"2": 5, "3": ""}`, "field 2 (caption) must be a string"},
+		{"set-favorite", `{"1": {}, "2": {"1": 1}}`, "field 1.2 (dedup key) is missing"},
+		{"move-to-trash", `{"3": ["", ""], "9": {"2": {"1": 0, "2": ""}}}`, "must be a list with one element"},
+		{"album-list", `{"1": `, "invalid json"},
+	} {
+		write(tt.name, tt.text)
+		_, err := buildTemplateRequest(tt.name, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.want) || !strings.Contains(err.Error(), RequestTemplateDir()) {
+			t.Errorf("%s override %s: error %v, want %q", tt.name, tt.text, err, tt.want)
+		}
+	}
+}
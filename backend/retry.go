@@ -0,0 +1,270 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// defaultUploadMaxAttempts is how often a file is tried before it counts as failed
+	defaultUploadMaxAttempts = 3
+	// retryBaseDelay is the wait before the second attempt; it doubles per attempt up to retryMaxDelay
+	retryBaseDelay = 2 * time.Second
+	retryMaxDelay  = time.Minute
+	// breakerThreshold is how many consecutive transient or auth failures pause the batch
+	breakerThreshold = 5
+	// breakerCooldown is the first pause; it doubles each time the breaker opens again up to breakerMaxCooldown
+	breakerCooldown    = 30 * time.Second
+	breakerMaxCooldown = 10 * time.Minute
+)
+
+// HTTPStatusError is returned for a response with a non-2xx status
+type HTTPStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// errAuthToken wraps failures to obtain a bearer token
+var errAuthToken = errors.New("failed to get auth token")
+
+// errorClass says whether an upload error is worth retrying
+type errorClass int
+
+const (
+	errorPermanent errorClass = iota // Retrying the file cannot help
+	errorTransient                   // Network trouble, throttling or a server error
+	errorAuth                        // The account was rejected; may be an outage or revoked credentials
+)
+
+func (c errorClass) String() string {
+	switch c {
+	case errorTransient:
+		return "transient"
+	case errorAuth:
+		return "auth"
+	default:
+		return "permanent"
+	}
+}
+
+// classifyError sorts an upload error into an errorClass
+func classifyError(err error) errorClass {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return errorPermanent
+	}
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		switch code := statusErr.StatusCode; {
+		case code == http.StatusUnauthorized || code == http.StatusForbidden:
+			return errorAuth
+		case code == http.StatusRequestTimeout || code == http.StatusTooEarly ||
+			code == http.StatusTooManyRequests || code >= 500:
+			return errorTransient
+		default:
+			return errorPermanent
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return errorTransient
+	}
+
+	if errors.Is(err, errAuthToken) {
+		return errorAuth
+	}
+	return errorPermanent
+}
+
+// retryDelay returns the jittered wait before the given attempt (2 is the first retry)
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay
+	for i := 2; i < attempt && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, retryMaxDelay)
+	// Half the delay is fixed and half random so workers that failed together spread out
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// sleepContext waits for d, returning ctx.Err() if ctx ends first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// UploadPause is emitted as "uploadPaused" when the circuit breaker stops all upload workers
+type UploadPause struct {
+	Until    time.Time
+	Failures int    // Consecutive failures that opened the breaker
+	Reason   string // The last error
+}
+
+// circuitBreaker pauses every upload worker once too many attempts in a row failed with
+// transient or auth errors, which points at a network or account outage rather than bad files.
+// Once the pause is over the breaker is half-open: a single worker makes a probe attempt while
+// the others keep waiting. A success closes the breaker and releases everyone; a failure opens
+// it again for twice as long.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	failures  int
+	cooldown  time.Duration
+	base      time.Duration
+	maxPause  time.Duration
+	openUntil time.Time     // Zero while closed
+	probing   bool          // A worker is making the probe attempt
+	changed   chan struct{} // Closed and replaced whenever the breaker closes or reopens
+	onOpen    func(UploadPause)
+}
+
+func newCircuitBreaker(onOpen func(UploadPause)) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: breakerThreshold,
+		cooldown:  breakerCooldown,
+		base:      breakerCooldown,
+		maxPause:  breakerMaxCooldown,
+		changed:   make(chan struct{}),
+		onOpen:    onOpen,
+	}
+}
+
+// pausedUntil returns when the pause ends, or the zero time if the breaker is closed.
+// While a probe is running the end of the last pause is returned.
+func (b *circuitBreaker) pausedUntil() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.probing || time.Now().Before(b.openUntil) {
+		return b.openUntil
+	}
+	return time.Time{}
+}
+
+// wait blocks while the breaker is open or another worker is probing. probe is set for the
+// one worker let through to make the probe attempt; it must report the outcome with success
+// or failure, or call release if it gives up without one.
+func (b *circuitBreaker) wait(ctx context.Context) (probe bool, err error) {
+	for {
+		b.mu.Lock()
+		changed := b.changed
+		var pause time.Duration
+		switch {
+		case b.openUntil.IsZero():
+			b.mu.Unlock()
+			return false, ctx.Err()
+		case b.probing:
+			pause = -1 // Until the probe reports
+		case time.Now().Before(b.openUntil):
+			pause = time.Until(b.openUntil)
+		default:
+			if err := ctx.Err(); err != nil {
+				b.mu.Unlock()
+				return false, err
+			}
+			b.probing = true
+			b.mu.Unlock()
+			return true, nil
+		}
+		b.mu.Unlock()
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if pause > 0 {
+			timer = time.NewTimer(pause)
+			timeout = timer.C
+		}
+		select {
+		case <-changed:
+		case <-timeout:
+		case <-ctx.Done():
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+	}
+}
+
+// notify wakes waiting workers. Must be called with b.mu held.
+func (b *circuitBreaker) notify() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// success closes the breaker
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.cooldown = b.base
+	if !b.openUntil.IsZero() {
+		b.openUntil = time.Time{}
+		b.probing = false
+		b.notify()
+	}
+}
+
+// release lets another worker probe after the probe ended without an outcome, e.g. when
+// its file was cancelled
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.probing {
+		b.probing = false
+		b.notify()
+	}
+}
+
+// failure records a failed attempt of the given class; probe says whether it was the probe
+func (b *circuitBreaker) failure(class errorClass, err error, probe bool) {
+	if class == errorPermanent {
+		// The server answered, so the connection and account are fine
+		b.success()
+		return
+	}
+
+	b.mu.Lock()
+	b.failures++
+	// Failures of attempts started before the breaker opened do not extend the pause;
+	// only the probe's failure opens it again
+	reopen := probe && b.probing
+	if !reopen && (b.failures < b.threshold || !b.openUntil.IsZero()) {
+		b.mu.Unlock()
+		return
+	}
+	b.openUntil = time.Now().Add(b.cooldown)
+	b.probing = false
+	b.notify()
+	pause := UploadPause{Until: b.openUntil, Failures: b.failures, Reason: err.Error()}
+	b.cooldown = min(b.cooldown*2, b.maxPause)
+	b.mu.Unlock()
+
+	if b.onOpen != nil {
+		b.onOpen(pause)
+	}
+}
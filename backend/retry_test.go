@@ -0,0 +1,183 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want errorClass
+	}{
+		{fmt.Errorf("error commiting file: %w", &HTTPStatusError{StatusCode: 503}), errorTransient},
+		{&HTTPStatusError{StatusCode: 429}, errorTransient},
+		{&HTTPStatusError{StatusCode: 401}, errorAuth},
+		{&HTTPStatusError{StatusCode: 400}, errorPermanent},
+		{fmt.Errorf("request failed: %w", &url.Error{Op: "Post", URL: "https://example.com", Err: syscall.ECONNRESET}), errorTransient},
+		{fmt.Errorf("%w: %w", errAuthToken, errors.New("BadAuthentication")), errorAuth},
+		{fmt.Errorf("%w: %w", errAuthToken, &HTTPStatusError{StatusCode: 502}), errorTransient},
+		{fmt.Errorf("failed to open file: %w", os.ErrNotExist), errorPermanent},
+		{context.Canceled, errorPermanent},
+	}
+	for _, tt := range tests {
+		if got := classifyError(tt.err); got != tt.want {
+			t.Errorf("classifyError(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempt, limit := range map[int]time.Duration{2: retryBaseDelay, 3: 2 * retryBaseDelay, 20: retryMaxDelay} {
+		for range 20 {
+			if d := retryDelay(attempt); d < limit/2 || d > limit {
+				t.Fatalf("retryDelay(%d) = %s, want between %s and %s", attempt, d, limit/2, limit)
+			}
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var pauses []UploadPause
+	b := newCircuitBreaker(func(p UploadPause) { pauses = append(pauses, p) })
+	b.threshold = 3
+	b.cooldown, b.base = 20*time.Millisecond, 20*time.Millisecond
+
+	boom := errors.New("boom")
+	b.failure(errorTransient, boom, false)
+	b.failure(errorPermanent, boom, false) // The server answered, so the streak starts over
+	b.failure(errorTransient, boom, false)
+	b.failure(errorAuth, boom, false)
+	if len(pauses) != 0 || !b.pausedUntil().IsZero() {
+		t.Fatal("breaker opened before reaching the threshold")
+	}
+
+	b.failure(errorTransient, boom, false)
+	if len(pauses) != 1 || b.pausedUntil().IsZero() {
+		t.Fatalf("breaker should open after 3 consecutive failures, pauses = %v", pauses)
+	}
+	// Failures from workers that were already mid-attempt do not extend the pause
+	b.failure(errorTransient, boom, false)
+	if len(pauses) != 1 {
+		t.Fatalf("breaker reopened while open, pauses = %v", pauses)
+	}
+
+	start := time.Now()
+	probe, err := b.wait(context.Background())
+	if err != nil || !probe {
+		t.Fatalf("wait = %v, %v; want the probe", probe, err)
+	}
+	if time.Since(start) < 10*time.Millisecond {
+		t.Fatal("wait returned before the cooldown ended")
+	}
+	// A straggler failing during the probe does not reopen the breaker
+	b.failure(errorTransient, boom, false)
+	if len(pauses) != 1 {
+		t.Fatalf("straggler reopened the breaker, pauses = %v", pauses)
+	}
+
+	// The probe fails, so the breaker opens again for longer
+	b.failure(errorTransient, boom, true)
+	if len(pauses) != 2 || pauses[1].Until.Sub(pauses[0].Until) < 40*time.Millisecond {
+		t.Fatalf("expected a second, longer pause, got %v", pauses)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := b.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("wait on a cancelled context = %v, want context.Canceled", err)
+	}
+
+	b.success()
+	if b.failures != 0 || b.cooldown != b.base {
+		t.Fatal("success should reset the breaker")
+	}
+}
+
+func TestCircuitBreakerProbe(t *testing.T) {
+	b := newCircuitBreaker(nil)
+	b.threshold = 1
+	b.cooldown, b.base = 20*time.Millisecond, 20*time.Millisecond
+	b.failure(errorTransient, errors.New("boom"), false)
+
+	// Several workers wait out the pause; only one gets through to probe
+	const workers = 4
+	var attempts atomic.Int32
+	probed := make(chan struct{})
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			probe, err := b.wait(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			attempts.Add(1)
+			if probe {
+				<-probed
+				b.success()
+			}
+		}()
+	}
+
+	time.Sleep(60 * time.Millisecond) // Well past the cooldown
+	if n := attempts.Load(); n != 1 {
+		t.Fatalf("%d attempts after the cooldown, want 1 probe", n)
+	}
+	// The probe succeeds and releases everyone else
+	close(probed)
+	wg.Wait()
+	if n := attempts.Load(); n != workers {
+		t.Fatalf("%d attempts after the probe succeeded, want %d", n, workers)
+	}
+
+	// A probe that ends without an outcome hands the probe on
+	b.failure(errorTransient, errors.New("boom"), false)
+	time.Sleep(30 * time.Millisecond)
+	if probe, _ := b.wait(context.Background()); !probe {
+		t.Fatal("first wait after the cooldown should probe")
+	}
+	b.release()
+	if probe, _ := b.wait(context.Background()); !probe {
+		t.Fatal("wait after a released probe should probe again")
+	}
+}
+
+func TestUploadClientSendsOnce(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	// uploadWithRetry owns the retries, so the client itself gives up after one request
+	client, err := newHTTPClient("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The final response is handed back, so callers classify it by its status code
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("503: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status %d, want 503", resp.StatusCode)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("%d requests, want 1", n)
+	}
+}
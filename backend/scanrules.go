@@ -0,0 +1,276 @@
+package backend
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ignoreFileName is read in every scanned directory and applies to it and its subdirectories
+const ignoreFileName = ".gotohpignore"
+
+// Reasons reported for files left out of an upload
+const (
+	SkipUnsupported = "unsupported extension"
+	SkipNotIncluded = "no include pattern matched"
+	SkipExcluded    = "exclude pattern matched"
+	SkipIgnoreFile  = "ignored by " + ignoreFileName
+	SkipHidden      = "hidden"
+	SkipTooSmall    = "smaller than minimum size"
+	SkipTooLarge    = "larger than maximum size"
+	SkipTooOld      = "modified before modified-since date"
+	SkipTooNew      = "modified after modified-before date"
+)
+
+// SkippedFile is a file or directory left out of an upload and the reason why
+type SkippedFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// ScanRules decide which scanned files are uploaded. Zero values disable a rule.
+type ScanRules struct {
+	Include        []string // Globs a file must match one of
+	Exclude        []string // Globs excluding files and directories
+	MinSize        int64
+	MaxSize        int64
+	ModifiedSince  time.Time
+	ModifiedBefore time.Time
+	SkipHidden     bool
+	AllExtensions  bool // Disables the Google Photos extension filter
+}
+
+// scanRulesFromConfig builds the scan rules from AppConfig
+func scanRulesFromConfig() (ScanRules, error) {
+	rules := ScanRules{
+		Include:       AppConfig.UploadInclude,
+		Exclude:       AppConfig.UploadExclude,
+		SkipHidden:    AppConfig.UploadSkipHidden,
+		AllExtensions: AppConfig.DisableUnsupportedFilesFilter,
+	}
+
+	var err error
+	if rules.MinSize, err = ParseByteSize(AppConfig.UploadMinSize); err != nil {
+		return rules, fmt.Errorf("invalid minimum size: %w", err)
+	}
+	if rules.MaxSize, err = ParseByteSize(AppConfig.UploadMaxSize); err != nil {
+		return rules, fmt.Errorf("invalid maximum size: %w", err)
+	}
+	if rules.ModifiedSince, err = ParseScanDate(AppConfig.UploadModifiedSince); err != nil {
+		return rules, fmt.Errorf("invalid modified-since date: %w", err)
+	}
+	if rules.ModifiedBefore, err = ParseScanDate(AppConfig.UploadModifiedBefore); err != nil {
+		return rules, fmt.Errorf("invalid modified-before date: %w", err)
+	}
+	for _, pattern := range append(append([]string{}, rules.Include...), rules.Exclude...) {
+		if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+			return rules, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return rules, nil
+}
+
+// ParseByteSize parses sizes such as "500", "200KB", "1.5 MB" or "2GiB" using 1024-based units.
+// An empty string returns 0.
+func ParseByteSize(s string) (int64, error) {
+	s = strings.TrimSpace(strings.ToUpper(s))
+	if s == "" {
+		return 0, nil
+	}
+
+	number := strings.TrimRight(s, "KMGTIB ")
+	unit := strings.TrimSpace(s[len(number):])
+	multipliers := map[string]int64{
+		"": 1, "B": 1,
+		"K": 1 << 10, "KB": 1 << 10, "KIB": 1 << 10,
+		"M": 1 << 20, "MB": 1 << 20, "MIB": 1 << 20,
+		"G": 1 << 30, "GB": 1 << 30, "GIB": 1 << 30,
+		"T": 1 << 40, "TB": 1 << 40, "TIB": 1 << 40,
+	}
+	multiplier, ok := multipliers[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit in %q", s)
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// ParseScanDate parses a local date ("2006-01-02") or an RFC 3339 timestamp.
+// An empty string returns the zero time.
+func ParseScanDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected YYYY-MM-DD or RFC 3339, got %q", s)
+	}
+	return t, nil
+}
+
+// checkFile returns why a file is skipped, or "" if it should be uploaded.
+// rel is the slash-separated path relative to the scanned root.
+func (r ScanRules) checkFile(rel string, entry fs.DirEntry, info os.FileInfo) string {
+	if r.SkipHidden && isHiddenEntry(entry) {
+		return SkipHidden
+	}
+	if matchAnyGlob(r.Exclude, rel) {
+		return SkipExcluded
+	}
+	if len(r.Include) > 0 && !matchAnyGlob(r.Include, rel) {
+		return SkipNotIncluded
+	}
+	if !r.AllExtensions && !isSupportedByGooglePhotos(rel) {
+		return SkipUnsupported
+	}
+	if r.MinSize > 0 && info.Size() < r.MinSize {
+		return SkipTooSmall
+	}
+	if r.MaxSize > 0 && info.Size() > r.MaxSize {
+		return SkipTooLarge
+	}
+	if !r.ModifiedSince.IsZero() && info.ModTime().Before(r.ModifiedSince) {
+		return SkipTooOld
+	}
+	if !r.ModifiedBefore.IsZero() && !info.ModTime().Before(r.ModifiedBefore) {
+		return SkipTooNew
+	}
+	return ""
+}
+
+// checkDir returns why a directory is not descended into, or "" to scan it
+func (r ScanRules) checkDir(rel string, entry fs.DirEntry) string {
+	if r.SkipHidden && isHiddenEntry(entry) {
+		return SkipHidden
+	}
+	if matchAnyGlob(r.Exclude, rel) {
+		return SkipExcluded
+	}
+	return ""
+}
+
+// matchAnyGlob reports whether rel matches any of the patterns
+func matchAnyGlob(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if matchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a slash-separated relative path against a glob.
+// Patterns without a slash match the base name at any depth, like .gitignore;
+// "**" matches any number of directories.
+func matchGlob(pattern, rel string) bool {
+	pattern = strings.TrimSuffix(filepath.ToSlash(pattern), "/")
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// ignoreRule is one line of a .gotohpignore file
+type ignoreRule struct {
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreFile holds the rules of one .gotohpignore and the directory they are relative to
+type ignoreFile struct {
+	dir   string
+	rules []ignoreRule
+}
+
+// loadIgnoreFile reads dir/.gotohpignore, returning nil if there is none
+func loadIgnoreFile(dir string) (*ignoreFile, error) {
+	file, err := os.Open(filepath.Join(dir, ignoreFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", ignoreFileName, err)
+	}
+	defer file.Close()
+
+	ignore := &ignoreFile{dir: dir}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+		}
+		rule.pattern = line
+		ignore.rules = append(ignore.rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", ignoreFileName, err)
+	}
+	return ignore, nil
+}
+
+// ignored reports whether the stack of ignore files, outermost first, ignores a path.
+// As in .gitignore, the last matching rule wins and "!" rules re-include.
+func ignored(stack []*ignoreFile, fullPath string, isDir bool) bool {
+	result := false
+	for _, ignore := range stack {
+		rel, err := filepath.Rel(ignore.dir, fullPath)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, rule := range ignore.rules {
+			if rule.dirOnly && !isDir {
+				continue
+			}
+			if matchGlob(rule.pattern, rel) {
+				result = !rule.negate
+			}
+		}
+	}
+	return result
+}
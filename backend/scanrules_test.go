@@ -0,0 +1,132 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern, rel string
+		want         bool
+	}{
+		{"*.jpg", "a.jpg", true},
+		{"*.jpg", "2020/trip/a.jpg", true},
+		{"*.jpg", "a.png", false},
+		{"2020/*.jpg", "2020/a.jpg", true},
+		{"2020/*.jpg", "2020/trip/a.jpg", false},
+		{"2020/**/*.jpg", "2020/trip/day1/a.jpg", true},
+		{"2020/**/*.jpg", "2020/a.jpg", true},
+		{"/raw", "raw", true},
+		{"/raw", "2020/raw", false},
+		{"raw/", "2020/raw", true},
+	}
+	for _, c := range cases {
+		if got := matchGlob(c.pattern, c.rel); got != c.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", c.pattern, c.rel, got, c.want)
+		}
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	cases := map[string]int64{
+		"":       0,
+		"500":    500,
+		"2KB":    2048,
+		"1.5 MB": 1536 * 1024,
+		"1gib":   1 << 30,
+	}
+	for input, want := range cases {
+		got, err := ParseByteSize(input)
+		if err != nil || got != want {
+			t.Errorf("ParseByteSize(%q) = %d, %v; want %d", input, got, err, want)
+		}
+	}
+	if _, err := ParseByteSize("12XB"); err == nil {
+		t.Error("expected an error for an unknown unit")
+	}
+}
+
+func TestScanUploadPaths_Rules(t *testing.T) {
+	saved := AppConfig
+	defer func() { AppConfig = saved }()
+
+	dir := t.TempDir()
+	old := time.Date(2019, 6, 1, 0, 0, 0, 0, time.Local)
+	write := func(name string, size int, modTime time.Time) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if !modTime.IsZero() {
+			if err := os.Chtimes(path, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	write("keep.jpg", 100, time.Time{})
+	write("tiny.jpg", 1, time.Time{})
+	write("old.jpg", 100, old)
+	write(".hidden.jpg", 100, time.Time{})
+	write(".cache/thumb.jpg", 100, time.Time{})
+	write("notes.txt", 100, time.Time{})
+	write("drafts/a.jpg", 100, time.Time{})
+	write("trip/b.jpg", 100, time.Time{})
+	write("trip/skip.jpg", 100, time.Time{})
+	write("trip/keep-me.jpg", 100, time.Time{})
+	if err := os.WriteFile(filepath.Join(dir, "trip", ignoreFileName), []byte("# comment\n*.jpg\n!b.jpg\n!keep-*\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	AppConfig = Config{
+		Recursive:           true,
+		UploadExclude:       []string{"drafts"},
+		UploadMinSize:       "10",
+		UploadModifiedSince: "2020-01-01",
+		UploadSkipHidden:    true,
+	}
+	files, skipped, err := scanUploadPaths([]string{dir})
+	if err != nil {
+		t.Fatalf("scanUploadPaths: %v", err)
+	}
+
+	var got []string
+	for _, file := range files {
+		rel, _ := filepath.Rel(dir, file)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	slices.Sort(got)
+	want := []string{"keep.jpg", "trip/b.jpg", "trip/keep-me.jpg"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+
+	reasons := map[string]string{}
+	for _, s := range skipped {
+		rel, _ := filepath.Rel(dir, s.Path)
+		reasons[filepath.ToSlash(rel)] = s.Reason
+	}
+	wantReasons := map[string]string{
+		"tiny.jpg":      SkipTooSmall,
+		"old.jpg":       SkipTooOld,
+		".hidden.jpg":   SkipHidden,
+		".cache":        SkipHidden,
+		"notes.txt":     SkipUnsupported,
+		"drafts":        SkipExcluded,
+		"trip/skip.jpg": SkipIgnoreFile,
+	}
+	for path, reason := range wantReasons {
+		if reasons[path] != reason {
+			t.Errorf("skip reason for %s = %q, want %q", path, reasons[path], reason)
+		}
+	}
+	if len(reasons) != len(wantReasons) {
+		t.Errorf("unexpected skipped set: %v", reasons)
+	}
+}
@@ -41,13 +41,17 @@ func CalculateSHA1(ctx context.Context, filePath string) ([]byte, error) {
 		return nil, fmt.Errorf("error opening file: %w", err)
 	}
 	defer file.Close()
+	return hashReader(ctx, file)
+}
 
+// hashReader calculates the SHA1 of everything r reads, for files that are not on disk
+func hashReader(ctx context.Context, r io.Reader) ([]byte, error) {
 	hash := sha1.New()
 	cw := &chunkedContextWriter{ctx: ctx, w: hash}
 
 	// Use a large buffer (1MB) to reduce syscall overhead
 	buf := make([]byte, copyBufferSize)
-	_, err = io.CopyBuffer(cw, file, buf)
+	_, err := io.CopyBuffer(cw, r, buf)
 	if err != nil {
 		return nil, fmt.Errorf("error calculating hash: %w", err)
 	}
@@ -0,0 +1,43 @@
+package backend
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// appDataDir returns the directory holding gotohp's persistent state.
+// It lives next to the active config file, so portable installs keep their caches local too.
+func appDataDir() string {
+	if ConfigPath == "" {
+		determineConfigPath()
+	}
+	return filepath.Dir(ConfigPath)
+}
+
+// accountDataDir returns the per-account state directory, creating it if needed.
+func accountDataDir(email string) (string, error) {
+	if email == "" {
+		return "", fmt.Errorf("no account is selected")
+	}
+	dir := filepath.Join(appDataDir(), "accounts", sanitizePathComponent(email))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create account data directory: %w", err)
+	}
+	return dir, nil
+}
+
+// sanitizePathComponent replaces characters that are unsafe in file names on any supported OS.
+func sanitizePathComponent(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '@' || r == '.' || r == '-' || r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
@@ -0,0 +1,513 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Tag sets the privacy filter can remove from JPEG, PNG and WebP files before upload
+const (
+	StripGPS       = "gps"       // GPS position, altitude, direction and time
+	StripSerial    = "serial"    // Camera body and lens serial numbers
+	StripMakerNote = "makernote" // Vendor maker notes, which often repeat serial numbers
+	StripOwner     = "owner"     // Camera owner name and artist
+	StripXMP       = "xmp"       // Whole XMP packets, which editors fill with any of the above
+)
+
+// xmpGPSProperty marks an XMP packet holding a position, as in exif:GPSLatitude; gps drops
+// such packets since properties cannot be removed without rewriting the XML
+var xmpGPSProperty = []byte(":GPS")
+
+// StripTagSets lists the tag sets in the order they are reported
+var StripTagSets = []string{StripGPS, StripSerial, StripMakerNote, StripOwner, StripXMP}
+
+// stripExtensions are the formats the privacy filter rewrites
+var stripExtensions = []string{".jpg", ".jpeg", ".png", ".webp"}
+
+// EXIF tags removed by the privacy filter, by the tag set they belong to
+const (
+	tagArtist             = 0x013B
+	tagGPSIFD             = 0x8825
+	tagMakerNote          = 0x927C
+	tagCameraOwnerName    = 0xA430
+	tagBodySerialNumber   = 0xA431
+	tagLensSerialNumber   = 0xA435
+	tagCameraSerialNumber = 0xC62F // DNG
+)
+
+var stripTags = map[uint16]string{
+	tagGPSIFD:             StripGPS,
+	tagBodySerialNumber:   StripSerial,
+	tagLensSerialNumber:   StripSerial,
+	tagCameraSerialNumber: StripSerial,
+	tagMakerNote:          StripMakerNote,
+	tagArtist:             StripOwner,
+	tagCameraOwnerName:    StripOwner,
+}
+
+// exifTypeSizes are the sizes of the EXIF value types by type number
+var exifTypeSizes = [...]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8, 13: 4}
+
+var errMalformedMetadata = errors.New("malformed metadata")
+
+// ParseStripTagSets parses a comma-separated list of tag sets; "none" strips nothing
+func ParseStripTagSets(value string) ([]string, error) {
+	var sets []string
+	for _, set := range strings.Split(value, ",") {
+		set = strings.ToLower(strings.TrimSpace(set))
+		switch {
+		case set == "" || set == "none":
+		case slices.Contains(StripTagSets, set):
+			sets = append(sets, set)
+		default:
+			return nil, fmt.Errorf("unknown tag set %q: use %s or none", set, strings.Join(StripTagSets, ", "))
+		}
+	}
+	return normalizeStripTagSets(sets), nil
+}
+
+// normalizeStripTagSets drops unknown and repeated tag sets and puts the rest in StripTagSets order
+func normalizeStripTagSets(sets []string) []string {
+	var normalized []string
+	for _, set := range StripTagSets {
+		if slices.Contains(sets, set) {
+			normalized = append(normalized, set)
+		}
+	}
+	return normalized
+}
+
+// stripSets returns the tag sets of UploadStripMetadata to remove from the file at path
+func stripSets(path string) []string {
+	if len(AppConfig.UploadStripMetadata) == 0 || !slices.Contains(stripExtensions, strings.ToLower(filepath.Ext(path))) {
+		return nil
+	}
+	return normalizeStripTagSets(AppConfig.UploadStripMetadata)
+}
+
+// stripKey identifies the privacy filter applied to the file at path, so hashes of files
+// uploaded with different filters are not mixed up in the upload index
+func stripKey(path string) string {
+	return strings.Join(stripSets(path), ",")
+}
+
+// metadataEdit replaces length bytes at offset with data, which is shorter when a block is removed
+type metadataEdit struct {
+	offset, length int64
+	data           []byte
+}
+
+// stripMetadata works out the edits that remove the given tag sets from a JPEG, PNG or WebP
+// file. EXIF tags are removed in place so no offsets move and the image data is never
+// decoded. It returns the edits in file order with the tag sets that were found.
+func stripMetadata(r io.ReaderAt, size int64, sets []string) ([]metadataEdit, []string, error) {
+	var head [12]byte
+	if _, err := r.ReadAt(head[:], 0); err != nil {
+		return nil, nil, nil
+	}
+	s := metadataStripper{r: r, size: size, sets: sets, found: map[string]bool{}}
+	var err error
+	switch {
+	case head[0] == 0xFF && head[1] == 0xD8:
+		err = s.jpeg()
+	case bytes.HasPrefix(head[:], []byte("\x89PNG\r\n\x1a\n")):
+		err = s.png()
+	case string(head[:4]) == "RIFF" && string(head[8:12]) == "WEBP":
+		err = s.webp()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	slices.SortFunc(s.edits, func(a, b metadataEdit) int { return int(a.offset - b.offset) })
+	var found []string
+	for _, set := range StripTagSets {
+		if s.found[set] {
+			found = append(found, set)
+		}
+	}
+	return s.edits, found, nil
+}
+
+// metadataStripper collects the edits for one file
+type metadataStripper struct {
+	r     io.ReaderAt
+	size  int64
+	sets  []string
+	found map[string]bool
+	edits []metadataEdit
+}
+
+// read reads length bytes at offset, which must lie within the file
+func (s *metadataStripper) read(offset, length int64) ([]byte, error) {
+	if offset < 0 || length < 0 || length > maxMetadataBox || offset+length > s.size {
+		return nil, errMalformedMetadata
+	}
+	data := make([]byte, length)
+	if _, err := s.r.ReadAt(data, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data, nil
+}
+
+// exif strips a TIFF structure read from offset, adding an edit if it changed
+func (s *metadataStripper) exif(offset int64, data []byte) {
+	stripped := bytes.Clone(data)
+	s.stripTiff(stripped)
+	if !bytes.Equal(stripped, data) {
+		s.edits = append(s.edits, metadataEdit{offset: offset, length: int64(len(data)), data: stripped})
+	}
+}
+
+// remove drops a whole block, such as an XMP packet, reporting set as found unless it is empty
+func (s *metadataStripper) remove(offset, length int64, set string) {
+	if set != "" {
+		s.found[set] = true
+	}
+	s.edits = append(s.edits, metadataEdit{offset: offset, length: length})
+}
+
+// xmpSet returns the tag set that drops an XMP packet, or "" to keep it: xmp drops every
+// packet and gps those with a position
+func (s *metadataStripper) xmpSet(packet []byte) string {
+	switch {
+	case slices.Contains(s.sets, StripXMP):
+		return StripXMP
+	case slices.Contains(s.sets, StripGPS) && bytes.Contains(packet, xmpGPSProperty):
+		return StripGPS
+	}
+	return ""
+}
+
+// jpeg strips the EXIF and XMP APP1 segments before the image data
+func (s *metadataStripper) jpeg() error {
+	offset := int64(2)
+	for range maxMetadataBoxes {
+		header, err := s.read(offset, 4)
+		if err != nil || header[0] != 0xFF {
+			return fmt.Errorf("%w in JPEG segment at %d", errMalformedMetadata, offset)
+		}
+		marker := header[1]
+		if marker == 0xFF {
+			offset++ // Fill byte
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			return nil // Image data or end of image
+		}
+		length := int64(binary.BigEndian.Uint16(header[2:]))
+		if length < 2 {
+			return fmt.Errorf("%w in JPEG segment at %d", errMalformedMetadata, offset)
+		}
+		if marker == 0xE1 {
+			data, err := s.read(offset+4, length-2)
+			if err != nil {
+				return err
+			}
+			switch {
+			case bytes.HasPrefix(data, []byte("Exif\x00\x00")):
+				s.exif(offset+10, data[6:])
+			case bytes.HasPrefix(data, []byte("http://ns.adobe.com/xap/1.0/\x00")):
+				if set := s.xmpSet(data); set != "" {
+					s.remove(offset, 2+length, set)
+				}
+			case bytes.HasPrefix(data, []byte("http://ns.adobe.com/xmp/extension/\x00")):
+				// Extended XMP is split across segments, so a position may straddle two of
+				// them; gps drops them all
+				if set := s.xmpSet(data); set != "" {
+					s.remove(offset, 2+length, set)
+				} else if slices.Contains(s.sets, StripGPS) {
+					s.remove(offset, 2+length, "")
+				}
+			}
+		}
+		offset += 2 + length
+	}
+	return nil
+}
+
+// png strips eXIf chunks, fixing their CRC, and drops XMP iTXt chunks. Compressed XMP is
+// dropped by gps unread.
+func (s *metadataStripper) png() error {
+	// Every chunk is walked since eXIf may follow the image data
+	for offset := int64(8); offset < s.size; {
+		header, err := s.read(offset, 8)
+		if err != nil {
+			return fmt.Errorf("%w in PNG chunk at %d", errMalformedMetadata, offset)
+		}
+		length := int64(binary.BigEndian.Uint32(header[:4]))
+		chunk := string(header[4:8])
+		if chunk == "IEND" {
+			return nil
+		}
+		if offset+12+length > s.size {
+			return fmt.Errorf("%w in PNG chunk at %d", errMalformedMetadata, offset)
+		}
+		switch chunk {
+		case "eXIf":
+			data, err := s.read(offset+8, length)
+			if err != nil {
+				return err
+			}
+			stripped := bytes.Clone(data)
+			s.stripTiff(stripped)
+			if !bytes.Equal(stripped, data) {
+				crc := crc32.ChecksumIEEE(append([]byte(chunk), stripped...))
+				s.edits = append(s.edits, metadataEdit{offset: offset + 8, length: length + 4,
+					data: binary.BigEndian.AppendUint32(stripped, crc)})
+			}
+		case "iTXt":
+			keyword := []byte("XML:com.adobe.xmp\x00")
+			if (slices.Contains(s.sets, StripXMP) || slices.Contains(s.sets, StripGPS)) && length > int64(len(keyword)) {
+				data, err := s.read(offset+8, length)
+				if err != nil {
+					return err
+				}
+				if bytes.HasPrefix(data, keyword) {
+					if set := s.xmpSet(data); set != "" {
+						s.remove(offset, 12+length, set)
+					} else if data[len(keyword)] != 0 && slices.Contains(s.sets, StripGPS) {
+						s.remove(offset, 12+length, "") // Compressed, so it cannot be checked
+					}
+				}
+			}
+		}
+		offset += 12 + length
+	}
+	return nil
+}
+
+// webp strips the EXIF chunk and drops the XMP chunk, updating the RIFF size and VP8X flags
+func (s *metadataStripper) webp() error {
+	var removed, vp8x int64
+	offset := int64(12)
+	for offset < s.size {
+		header, err := s.read(offset, 8)
+		if err != nil {
+			return fmt.Errorf("%w in WebP chunk at %d", errMalformedMetadata, offset)
+		}
+		length := int64(binary.LittleEndian.Uint32(header[4:]))
+		padded := length + length%2
+		if offset+8+length > s.size {
+			return fmt.Errorf("%w in WebP chunk at %d", errMalformedMetadata, offset)
+		}
+		switch string(header[:4]) {
+		case "VP8X":
+			vp8x = offset + 8
+		case "EXIF":
+			data, err := s.read(offset+8, length)
+			if err != nil {
+				return err
+			}
+			// Some writers keep the JPEG APP1 prefix
+			if bytes.HasPrefix(data, []byte("Exif\x00\x00")) {
+				s.exif(offset+14, data[6:])
+			} else {
+				s.exif(offset+8, data)
+			}
+		case "XMP ":
+			data, err := s.read(offset+8, length)
+			if err != nil {
+				return err
+			}
+			if set := s.xmpSet(data); set != "" {
+				s.remove(offset, min(8+padded, s.size-offset), set)
+				removed += min(8+padded, s.size-offset)
+			}
+		}
+		offset += 8 + padded
+	}
+
+	if removed > 0 {
+		header, err := s.read(4, 4)
+		if err != nil {
+			return err
+		}
+		riffSize := binary.LittleEndian.Uint32(header) - uint32(removed)
+		s.edits = append(s.edits, metadataEdit{offset: 4, length: 4, data: binary.LittleEndian.AppendUint32(nil, riffSize)})
+		if vp8x > 0 {
+			flags, err := s.read(vp8x, 1)
+			if err != nil {
+				return err
+			}
+			s.edits = append(s.edits, metadataEdit{offset: vp8x, length: 1, data: []byte{flags[0] &^ 0x04}})
+		}
+	}
+	return nil
+}
+
+// stripTiff removes the tags of the stripped sets from IFD0, its Exif IFD and IFD1 in place.
+// Removed entries are dropped from their directory and their values zeroed, so the
+// structure keeps its size and every other offset stays valid.
+func (s *metadataStripper) stripTiff(data []byte) {
+	if len(data) < 8 {
+		return
+	}
+	t := tiffData{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return
+	}
+
+	ifd0 := t.order.Uint32(data[4:8])
+	if entry, ok := t.directory(ifd0)[tagExifIFD]; ok {
+		s.stripDirectory(t, t.long(entry))
+	}
+	// IFD1 describes the embedded thumbnail
+	next := t.nextDirectory(ifd0)
+	s.stripDirectory(t, ifd0)
+	if next != 0 && next != ifd0 {
+		s.stripDirectory(t, next)
+	}
+}
+
+// stripDirectory removes the stripped tags from the IFD at offset
+func (s *metadataStripper) stripDirectory(t tiffData, offset uint32) {
+	start := int(offset)
+	if offset == 0 || start+2 > len(t.data) {
+		return
+	}
+	count := int(t.order.Uint16(t.data[start:]))
+	end := start + 2 + count*exifEntrySize
+	if count > exifMaxEntriesPerDirectory || end+4 > len(t.data) {
+		return
+	}
+
+	kept := 0
+	for i := range count {
+		entry := t.data[start+2+i*exifEntrySize : start+2+(i+1)*exifEntrySize]
+		set, ok := stripTags[t.order.Uint16(entry)]
+		if !ok || !slices.Contains(s.sets, set) {
+			copy(t.data[start+2+kept*exifEntrySize:], entry)
+			kept++
+			continue
+		}
+		s.found[set] = true
+		if t.order.Uint16(entry) == tagGPSIFD {
+			t.clearDirectory(t.long(entry))
+		} else {
+			t.clearValue(entry)
+		}
+	}
+	if kept == count {
+		return
+	}
+	t.order.PutUint16(t.data[start:], uint16(kept))
+	keptEnd := start + 2 + kept*exifEntrySize
+	copy(t.data[keptEnd:keptEnd+4], t.data[end:end+4])
+	clear(t.data[keptEnd+4 : end+4])
+}
+
+// nextDirectory returns the offset of the IFD following the one at offset, or 0
+func (t tiffData) nextDirectory(offset uint32) uint32 {
+	start := int(offset)
+	if offset == 0 || start+2 > len(t.data) {
+		return 0
+	}
+	end := start + 2 + int(t.order.Uint16(t.data[start:]))*exifEntrySize
+	if end+4 > len(t.data) {
+		return 0
+	}
+	return t.order.Uint32(t.data[end:])
+}
+
+// clearValue zeroes the value of an entry stored outside its directory
+func (t tiffData) clearValue(entry []byte) {
+	kind := int(t.order.Uint16(entry[2:]))
+	if kind >= len(exifTypeSizes) || exifTypeSizes[kind] == 0 {
+		return
+	}
+	size := int64(exifTypeSizes[kind]) * int64(t.order.Uint32(entry[4:]))
+	offset := int64(t.order.Uint32(entry[8:]))
+	if size > 4 && offset+size <= int64(len(t.data)) {
+		clear(t.data[offset : offset+size])
+	}
+}
+
+// clearDirectory zeroes an IFD and the values of its entries
+func (t tiffData) clearDirectory(offset uint32) {
+	start := int(offset)
+	if offset == 0 || start+2 > len(t.data) {
+		return
+	}
+	end := start + 2 + int(t.order.Uint16(t.data[start:]))*exifEntrySize + 4
+	if end > len(t.data) {
+		return
+	}
+	for pos := start + 2; pos+exifEntrySize <= end-4; pos += exifEntrySize {
+		t.clearValue(t.data[pos : pos+exifEntrySize])
+	}
+	clear(t.data[start:end])
+}
+
+// editedReader reads a file of the given size with edits applied
+func editedReader(r io.ReaderAt, size int64, edits []metadataEdit) io.Reader {
+	var parts []io.Reader
+	pos := int64(0)
+	for _, edit := range edits {
+		parts = append(parts, io.NewSectionReader(r, pos, edit.offset-pos), bytes.NewReader(edit.data))
+		pos = edit.offset + edit.length
+	}
+	parts = append(parts, io.NewSectionReader(r, pos, size-pos))
+	return io.MultiReader(parts...)
+}
+
+// editedSize returns the size of a file after edits
+func editedSize(size int64, edits []metadataEdit) int64 {
+	for _, edit := range edits {
+		size -= edit.length - int64(len(edit.data))
+	}
+	return size
+}
+
+// strippedReader reads a file with edits applied and closes the file with it
+type strippedReader struct {
+	io.Reader
+	io.Closer
+}
+
+// content opens what is hashed and uploaded for a job: its file, or a sanitized copy with
+// the tag sets of UploadStripMetadata removed. The copy is streamed from the file with the
+// edits applied, except for archive entries, which are read into memory since they cannot
+// be read at an offset. It returns the stream, its size and the tag sets that were removed.
+// job.info must be set.
+func (j uploadJob) content() (io.ReadCloser, int64, []string, error) {
+	r, err := j.reader()
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	sets := stripSets(j.path)
+	if len(sets) == 0 {
+		return r, j.info.Size(), nil, nil
+	}
+
+	var file io.ReaderAt
+	var size int64
+	if f, ok := r.(*os.File); ok {
+		file, size = f, j.info.Size()
+	} else {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			r.Close()
+			return nil, 0, nil, err
+		}
+		file, size = bytes.NewReader(data), int64(len(data))
+	}
+	edits, found, err := stripMetadata(file, size, sets)
+	if err != nil {
+		r.Close()
+		return nil, 0, nil, fmt.Errorf("failed to strip metadata: %w", err)
+	}
+	return strippedReader{editedReader(file, size, edits), r}, editedSize(size, edits), found, nil
+}
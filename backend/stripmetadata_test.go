@@ -0,0 +1,228 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// privateTIFF builds a little-endian TIFF block with a camera make, a GPS IFD, and an
+// Exif IFD holding DateTimeOriginal, a maker note and a body serial number
+func privateTIFF() []byte {
+	le := binary.LittleEndian
+	var b bytes.Buffer
+	entry := func(tag, typ uint16, count, value uint32) {
+		binary.Write(&b, le, tag)
+		binary.Write(&b, le, typ)
+		binary.Write(&b, le, count)
+		binary.Write(&b, le, value)
+	}
+	b.WriteString("II")
+	binary.Write(&b, le, uint16(42))
+	binary.Write(&b, le, uint32(8))
+	// IFD0 at 8, Exif IFD at 50, GPS IFD at 92, values from 110
+	binary.Write(&b, le, uint16(3))
+	entry(0x010F, exifTypeASCII, 6, 110)
+	entry(tagExifIFD, exifTypeLong, 1, 50)
+	entry(tagGPSIFD, exifTypeLong, 1, 92)
+	binary.Write(&b, le, uint32(0))
+	binary.Write(&b, le, uint16(3))
+	entry(tagDateTimeOriginal, exifTypeASCII, 20, 116)
+	entry(tagMakerNote, 7, 8, 147)
+	entry(tagBodySerialNumber, exifTypeASCII, 11, 136)
+	binary.Write(&b, le, uint32(0))
+	binary.Write(&b, le, uint16(1))
+	entry(0x0002, 5, 3, 155) // GPSLatitude
+	binary.Write(&b, le, uint32(0))
+	b.WriteString("Canon\x00")
+	b.WriteString("2019:07:04 10:11:12\x00")
+	b.WriteString("SN12345678\x00")
+	b.WriteString("MAKERNTE")
+	for range 6 {
+		binary.Write(&b, le, uint32(0x51525354))
+	}
+	return b.Bytes()
+}
+
+// applyEdits returns data read through the edits
+func applyEdits(t *testing.T, data []byte, sets ...string) ([]byte, []string) {
+	t.Helper()
+	edits, found, err := stripMetadata(bytes.NewReader(data), int64(len(data)), sets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(editedReader(bytes.NewReader(data), int64(len(data)), edits))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := editedSize(int64(len(data)), edits); size != int64(len(out)) {
+		t.Errorf("editedSize = %d, read %d bytes", size, len(out))
+	}
+	return out, found
+}
+
+func TestStripMetadata(t *testing.T) {
+	tiff := privateTIFF()
+	gpsValue := binary.LittleEndian.AppendUint32(nil, 0x51525354)
+
+	// JPEG: EXIF is edited in place and the XMP segment dropped
+	segment := func(marker byte, payload []byte) []byte {
+		return append(append([]byte{0xFF, marker}, be16(uint16(len(payload)+2))...), payload...)
+	}
+	xmp := []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta>exif:GPSLatitude</x:xmpmeta>")
+	scan := []byte{0xFF, 0xDA, 0, 2, 1, 2, 3, 0xFF, 0xD9}
+	jpeg := slices.Concat([]byte{0xFF, 0xD8}, segment(0xE1, append([]byte("Exif\x00\x00"), tiff...)), segment(0xE1, xmp), scan)
+
+	// gps also drops the XMP packet, which holds a position
+	out, found := applyEdits(t, jpeg, StripGPS, StripSerial)
+	if !slices.Equal(found, []string{StripGPS, StripSerial}) || len(out) != len(jpeg)-len(xmp)-4 || bytes.Contains(out, []byte("GPSLatitude")) {
+		t.Errorf("gps+serial found %v, %d bytes of %d", found, len(out), len(jpeg))
+	}
+	stripped := out[12 : 12+len(tiff)]
+	if bytes.Contains(stripped, gpsValue) || bytes.Contains(stripped, []byte("SN12345678")) || !bytes.Contains(stripped, []byte("MAKERNTE")) {
+		t.Errorf("stripped EXIF = %q", stripped)
+	}
+	st := tiffData{data: stripped, order: binary.LittleEndian}
+	ifd0 := st.directory(8)
+	if _, ok := ifd0[tagGPSIFD]; ok || len(ifd0) != 2 || st.ascii(ifd0[0x010F]) != "Canon" {
+		t.Errorf("IFD0 = %v", ifd0)
+	}
+	if taken, err := exifCaptureTime(stripped); err != nil || taken.Year() != 2019 {
+		t.Errorf("capture time after stripping = %v, %v", taken, err)
+	}
+	if !bytes.HasSuffix(out, scan) {
+		t.Error("image data changed")
+	}
+
+	// XMP without a position is kept by gps
+	plainXMP := []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta>xmp:Rating</x:xmpmeta>")
+	plain := slices.Concat([]byte{0xFF, 0xD8}, segment(0xE1, plainXMP), scan)
+	if out, found := applyEdits(t, plain, StripGPS); found != nil || !bytes.Equal(out, plain) {
+		t.Errorf("gps changed XMP without a position: %v", found)
+	}
+
+	out, found = applyEdits(t, jpeg, StripMakerNote, StripXMP)
+	if !slices.Equal(found, []string{StripMakerNote, StripXMP}) || len(out) != len(jpeg)-len(xmp)-4 ||
+		bytes.Contains(out, []byte("MAKERNTE")) || bytes.Contains(out, []byte("xmpmeta")) || !bytes.Contains(out, gpsValue) {
+		t.Errorf("makernote+xmp found %v: %q", found, out)
+	}
+
+	if out, found := applyEdits(t, jpeg, StripOwner); found != nil || !bytes.Equal(out, jpeg) {
+		t.Errorf("owner changed a file without owner tags: %v", found)
+	}
+
+	// PNG: the eXIf chunk keeps a valid CRC
+	var pngData bytes.Buffer
+	png.Encode(&pngData, image.NewGray(image.Rect(0, 0, 2, 2)))
+	exifChunk := slices.Concat(be32(uint32(len(tiff))), []byte("eXIf"), tiff, be32(0))
+	binary.BigEndian.PutUint32(exifChunk[len(exifChunk)-4:], crc32.ChecksumIEEE(exifChunk[4:len(exifChunk)-4]))
+	pngFile := slices.Concat(pngData.Bytes()[:33], exifChunk, pngData.Bytes()[33:])
+	if _, err := png.Decode(bytes.NewReader(pngFile)); err != nil {
+		t.Fatal(err)
+	}
+	xmpText := []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00<rdf:Description exif:GPSLongitude=\"2,20.5E\"/>")
+	xmpChunk := slices.Concat(be32(uint32(len(xmpText))), []byte("iTXt"), xmpText, be32(0))
+	binary.BigEndian.PutUint32(xmpChunk[len(xmpChunk)-4:], crc32.ChecksumIEEE(xmpChunk[4:len(xmpChunk)-4]))
+	pngFile = slices.Concat(pngFile[:33], xmpChunk, pngFile[33:])
+	out, found = applyEdits(t, pngFile, StripGPS)
+	if _, err := png.Decode(bytes.NewReader(out)); err != nil || !slices.Equal(found, []string{StripGPS}) || bytes.Contains(out, gpsValue) ||
+		bytes.Contains(out, []byte("GPSLongitude")) || len(out) != len(pngFile)-len(xmpChunk) {
+		t.Errorf("png: found %v, decode %v", found, err)
+	}
+
+	// WebP: the XMP chunk is dropped and the RIFF size and VP8X flags follow
+	chunk := func(fourcc string, data []byte) []byte {
+		c := slices.Concat([]byte(fourcc), binary.LittleEndian.AppendUint32(nil, uint32(len(data))), data)
+		if len(data)%2 == 1 {
+			c = append(c, 0)
+		}
+		return c
+	}
+	body := slices.Concat([]byte("WEBP"), chunk("VP8X", []byte{0x0C, 0, 0, 0, 1, 0, 0, 1, 0, 0}),
+		chunk("VP8L", []byte{1, 2, 3, 4, 5}), chunk("EXIF", tiff), chunk("XMP ", []byte("<x:xmpmeta/>.")))
+	webp := slices.Concat([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body))), body)
+	out, found = applyEdits(t, webp, StripGPS, StripXMP)
+	if !slices.Equal(found, []string{StripGPS, StripXMP}) || bytes.Contains(out, []byte("XMP ")) || bytes.Contains(out, gpsValue) ||
+		binary.LittleEndian.Uint32(out[4:]) != uint32(len(out)-8) || out[20] != 0x08 {
+		t.Errorf("webp: found %v: %q", found, out)
+	}
+
+	// gps alone drops a WebP XMP chunk with a position
+	body = slices.Concat([]byte("WEBP"), chunk("VP8X", []byte{0x0C, 0, 0, 0, 1, 0, 0, 1, 0, 0}),
+		chunk("VP8L", []byte{1, 2, 3, 4, 5}), chunk("XMP ", []byte("<exif:GPSLatitude>48,51.4N</exif:GPSLatitude>")))
+	webp = slices.Concat([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body))), body)
+	out, found = applyEdits(t, webp, StripGPS)
+	if !slices.Equal(found, []string{StripGPS}) || bytes.Contains(out, []byte("GPSLatitude")) || binary.LittleEndian.Uint32(out[4:]) != uint32(len(out)-8) {
+		t.Errorf("webp gps: found %v: %q", found, out)
+	}
+
+	// Broken segment lengths fail rather than upload metadata unchecked
+	broken := slices.Concat([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0xFF, 0xF0}, tiff)
+	if _, _, err := stripMetadata(bytes.NewReader(broken), int64(len(broken)), []string{StripGPS}); err == nil {
+		t.Error("malformed JPEG stripped without error")
+	}
+}
+
+func TestUploadJobContent_StripMetadata(t *testing.T) {
+	saved := AppConfig
+	defer func() { AppConfig = saved }()
+
+	dir := t.TempDir()
+	jpeg := slices.Concat([]byte{0xFF, 0xD8, 0xFF, 0xE1}, be16(uint16(len(privateTIFF())+8)), []byte("Exif\x00\x00"),
+		privateTIFF(), []byte{0xFF, 0xDA, 0, 2, 0xFF, 0xD9})
+	path := filepath.Join(dir, "a.jpg")
+	if err := os.WriteFile(path, jpeg, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(path)
+	job := uploadJob{path: path, info: info}
+
+	read := func() ([]byte, int64, []string, []byte) {
+		t.Helper()
+		r, size, stripped, err := job.content()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		data, _ := io.ReadAll(r)
+		hash, err := job.hash(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data, size, stripped, hash
+	}
+
+	AppConfig = Config{}
+	data, size, stripped, hash := read()
+	if !bytes.Equal(data, jpeg) || size != info.Size() || stripped != nil || stripKey(path) != "" {
+		t.Errorf("unfiltered content changed: %v", stripped)
+	}
+	original := sha1.Sum(jpeg)
+	if !bytes.Equal(hash, original[:]) {
+		t.Error("unfiltered hash differs from the file's")
+	}
+
+	AppConfig = Config{UploadStripMetadata: []string{StripSerial, StripGPS}}
+	data, size, stripped, hash = read()
+	sum := sha1.Sum(data)
+	if bytes.Equal(data, jpeg) || size != int64(len(data)) || !slices.Equal(stripped, []string{StripGPS, StripSerial}) ||
+		!bytes.Equal(hash, sum[:]) || stripKey(path) != "gps,serial" || stripKey(filepath.Join(dir, "b.heic")) != "" {
+		t.Errorf("stripped %v, key %q", stripped, stripKey(path))
+	}
+
+	if sets, err := ParseStripTagSets(" XMP,gps,gps "); err != nil || !slices.Equal(sets, []string{StripGPS, StripXMP}) {
+		t.Errorf("ParseStripTagSets = %v, %v", sets, err)
+	}
+	if _, err := ParseStripTagSets("gps,exif"); err == nil {
+		t.Error("unknown tag set accepted")
+	}
+}
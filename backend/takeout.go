@@ -0,0 +1,562 @@
+package backend
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimestampSidecar names the photoTakenTime of a Takeout sidecar as a timestamp source
+const TimestampSidecar = "sidecar"
+
+const (
+	// SkipTrashed is the skip reason for items the sidecar marks as trashed
+	SkipTrashed = "in trash"
+	// maxSidecarSize bounds how much of a JSON file is read as a sidecar
+	maxSidecarSize = 1 << 20
+	// takeoutTruncatedName is the shortest name Takeout may have truncated; shorter
+	// names are only matched in full
+	takeoutTruncatedName = 30
+	// supplementalMetadata is inserted before ".json" by newer exports, truncated to fit
+	supplementalMetadata = "supplemental-metadata"
+)
+
+var (
+	// takeoutDuplicate matches the "(1)" Takeout appends to repeated names
+	takeoutDuplicate = regexp.MustCompile(`^(.*)\((\d+)\)$`)
+	// takeoutEditedSuffixes mark the edited copy of an item, which shares the original's sidecar
+	takeoutEditedSuffixes = []string{"-edited", "-bearbeitet", "-modifié", "-editado", "-modificato", "-bewerkt", "-redigeret"}
+)
+
+// takeoutEntry is a file of a Takeout export, on disk or inside one of its archives
+type takeoutEntry struct {
+	name string // Slash-separated path inside the export; sidecars pair up by it across archives
+	path string // A disk path, or archive.zip!/name
+	info os.FileInfo
+	open func() (io.ReadCloser, error)
+}
+
+// takeoutExport lists the files of one or more Takeout exports without extracting them
+type takeoutExport struct {
+	entries  []takeoutEntry
+	archives []*mediaArchive
+}
+
+// openTakeoutExport opens exports given as zip or tgz archives or directories. Archives
+// inside a directory are read as well, so a folder of downloaded parts can be imported.
+func openTakeoutExport(paths []string) (*takeoutExport, error) {
+	export := &takeoutExport{}
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			export.Close()
+			return nil, fmt.Errorf("failed to open takeout export: %w", err)
+		}
+		if info.IsDir() {
+			err = export.addDir(p)
+		} else {
+			err = export.addArchive(p)
+		}
+		if err != nil {
+			export.Close()
+			return nil, err
+		}
+	}
+	return export, nil
+}
+
+func (e *takeoutExport) addArchive(archivePath string) error {
+	archive, err := openArchive(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open takeout archive: %w", err)
+	}
+	e.archives = append(e.archives, archive)
+	for _, entry := range archive.entries {
+		e.entries = append(e.entries, takeoutEntry(entry))
+	}
+	return nil
+}
+
+func (e *takeoutExport) addDir(root string) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if isArchive(p) {
+			return e.addArchive(p)
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		e.entries = append(e.entries, takeoutEntry{
+			name: filepath.ToSlash(rel),
+			path: p,
+			info: info,
+			open: func() (io.ReadCloser, error) { return os.Open(p) },
+		})
+		return nil
+	})
+}
+
+// Close closes the archives of the export
+func (e *takeoutExport) Close() error {
+	for _, archive := range e.archives {
+		archive.Close()
+	}
+	e.archives = nil
+	return nil
+}
+
+// takeoutSidecar is the JSON metadata Takeout writes next to each item. Album
+// folders hold one too, with only the album title and description.
+type takeoutSidecar struct {
+	Title          string `json:"title"`
+	Description    string `json:"description"`
+	PhotoTakenTime struct {
+		Timestamp string `json:"timestamp"`
+	} `json:"photoTakenTime"`
+	Favorited bool `json:"favorited"`
+	Trashed   bool `json:"trashed"`
+}
+
+// takenTime returns photoTakenTime, if set
+func (s *takeoutSidecar) takenTime() (time.Time, bool) {
+	seconds, err := strconv.ParseInt(s.PhotoTakenTime.Timestamp, 10, 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
+
+// takeoutSidecarFile is a media sidecar with the media name its file name points at
+type takeoutSidecarFile struct {
+	key  string // Media name from the sidecar's file name, possibly truncated
+	dup  string // Number of a repeated name, from "(1)"
+	meta takeoutSidecar
+}
+
+// parseTakeoutSidecarName reads the media name out of a sidecar file name such as
+// IMG_1.jpg.json, IMG_1.jpg(1).json or IMG_1.jpg.supplemental-meta.json
+func parseTakeoutSidecarName(name string) (key, dup string) {
+	key = strings.TrimSuffix(name, path.Ext(name))
+	if m := takeoutDuplicate.FindStringSubmatch(key); m != nil {
+		key, dup = m[1], m[2]
+	}
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		if suffix := strings.ToLower(key[i+1:]); suffix == "" || strings.HasPrefix(supplementalMetadata, suffix) {
+			key = key[:i]
+		}
+	}
+	return key, dup
+}
+
+// parseTakeoutMediaName returns the original name of a media file, without the "(1)"
+// of a repeated name or the suffix of an edited copy
+func parseTakeoutMediaName(name string) (original, dup string) {
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	if m := takeoutDuplicate.FindStringSubmatch(stem); m != nil {
+		stem, dup = m[1], m[2]
+	}
+	return trimTakeoutEdited(stem) + ext, dup
+}
+
+func trimTakeoutEdited(stem string) string {
+	for _, suffix := range takeoutEditedSuffixes {
+		stem = strings.TrimSuffix(stem, suffix)
+	}
+	return stem
+}
+
+// matchTakeoutSidecar finds the sidecar of a media file among those of its folder
+func matchTakeoutSidecar(name string, sidecars []takeoutSidecarFile) *takeoutSidecar {
+	original, dup := parseTakeoutMediaName(name)
+	if meta := matchTakeoutSidecarAs(original, dup, sidecars); meta != nil || dup == "" {
+		return meta
+	}
+	// A "(2)" can be part of the name rather than mark a repeat
+	ext := path.Ext(name)
+	return matchTakeoutSidecarAs(trimTakeoutEdited(strings.TrimSuffix(name, ext))+ext, "", sidecars)
+}
+
+func matchTakeoutSidecarAs(original, dup string, sidecars []takeoutSidecarFile) *takeoutSidecar {
+	stem := strings.TrimSuffix(original, path.Ext(original))
+	titleStem := func(s takeoutSidecarFile) string {
+		return strings.TrimSuffix(s.meta.Title, path.Ext(s.meta.Title))
+	}
+	passes := []func(s takeoutSidecarFile) bool{
+		func(s takeoutSidecarFile) bool { return s.key == original },
+		func(s takeoutSidecarFile) bool { return s.meta.Title == original },
+		func(s takeoutSidecarFile) bool { return s.key == stem },
+		// Long names are cut short in the sidecar name, the media name or both
+		func(s takeoutSidecarFile) bool {
+			return len(s.key) >= takeoutTruncatedName && strings.HasPrefix(original, s.key)
+		},
+		func(s takeoutSidecarFile) bool {
+			return len(stem) >= takeoutTruncatedName && strings.HasPrefix(titleStem(s), stem)
+		},
+		// The video of a Live or Motion photo shares the still's sidecar
+		func(s takeoutSidecarFile) bool { return titleStem(s) == stem },
+	}
+	for _, pass := range passes {
+		for i := range sidecars {
+			if sidecars[i].dup == dup && pass(sidecars[i]) {
+				return &sidecars[i].meta
+			}
+		}
+	}
+	return nil
+}
+
+// takeoutItem is a media file of the export with its sidecar and album folder
+type takeoutItem struct {
+	entry   takeoutEntry
+	sidecar *takeoutSidecar // nil if no sidecar matched
+	album   string          // Album folder, or "" for year folders
+}
+
+// takeoutPlan is what an export holds once sidecars are paired with media
+type takeoutPlan struct {
+	items   []takeoutItem
+	albums  map[string]string // Album titles by folder
+	skipped []SkippedFile
+}
+
+// planTakeout reads the sidecars of an export and pairs them with its media files.
+// Folders with album metadata are albums; year folders have none.
+func planTakeout(export *takeoutExport) (*takeoutPlan, error) {
+	plan := &takeoutPlan{albums: map[string]string{}}
+	sidecars := map[string][]takeoutSidecarFile{}
+	var media []takeoutEntry
+
+	for _, entry := range export.entries {
+		if !strings.EqualFold(path.Ext(entry.name), ".json") {
+			if AppConfig.DisableUnsupportedFilesFilter || isSupportedByGooglePhotos(entry.name) {
+				media = append(media, entry)
+			} else {
+				plan.skipped = append(plan.skipped, SkippedFile{Path: entry.path, Reason: SkipUnsupported})
+			}
+			continue
+		}
+
+		meta, err := readTakeoutSidecar(entry)
+		if err != nil {
+			plan.skipped = append(plan.skipped, SkippedFile{Path: entry.path, Reason: err.Error()})
+			continue
+		}
+		dir, name := path.Split(entry.name)
+		dir = strings.TrimSuffix(dir, "/")
+		key, dup := parseTakeoutSidecarName(name)
+		if meta.PhotoTakenTime.Timestamp == "" {
+			// Album metadata is named like metadata.json, without a media name
+			if meta.Title != "" && !strings.Contains(key, ".") {
+				plan.albums[dir] = meta.Title
+			}
+			continue
+		}
+		sidecars[dir] = append(sidecars[dir], takeoutSidecarFile{key: key, dup: dup, meta: *meta})
+	}
+
+	for _, entry := range media {
+		dir, name := path.Split(entry.name)
+		dir = strings.TrimSuffix(dir, "/")
+		item := takeoutItem{entry: entry, sidecar: matchTakeoutSidecar(name, sidecars[dir])}
+		if item.sidecar != nil && item.sidecar.Trashed {
+			plan.skipped = append(plan.skipped, SkippedFile{Path: entry.path, Reason: SkipTrashed})
+			continue
+		}
+		if _, ok := plan.albums[dir]; ok {
+			item.album = dir
+		}
+		plan.items = append(plan.items, item)
+	}
+	return plan, nil
+}
+
+func readTakeoutSidecar(entry takeoutEntry) (*takeoutSidecar, error) {
+	r, err := entry.open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sidecar: %w", err)
+	}
+	defer r.Close()
+	var meta takeoutSidecar
+	if err := json.NewDecoder(io.LimitReader(r, maxSidecarSize)).Decode(&meta); err != nil {
+		return nil, fmt.Errorf("failed to read sidecar: %w", err)
+	}
+	return &meta, nil
+}
+
+// TakeoutAlbum is an album re-created by a Takeout import, emitted as "takeoutAlbum"
+type TakeoutAlbum struct {
+	Title    string `json:"title"`
+	Folder   string `json:"folder"`
+	AlbumKey string `json:"albumKey,omitempty"`
+	Items    int    `json:"items"`
+	Error    string `json:"error,omitempty"`
+}
+
+// TakeoutSummary reports what a Takeout import found and re-applied
+type TakeoutSummary struct {
+	Media     int            `json:"media"`
+	Matched   int            `json:"matched"`             // Media files paired with a sidecar
+	Unmatched []string       `json:"unmatched,omitempty"` // Dated by UploadTimestampPolicy instead
+	Captions  int            `json:"captions"`
+	Favorites int            `json:"favorites"`
+	Albums    []TakeoutAlbum `json:"albums,omitempty"`
+	Skipped   []SkippedFile  `json:"skipped,omitempty"`
+}
+
+// TakeoutJournalPath returns the journal of importing paths, which is resumed when the same
+// export is imported again
+func TakeoutJournalPath(paths []string) (string, error) {
+	dir, err := accountDataDir(AppConfig.Selected)
+	if err != nil {
+		return "", err
+	}
+	abs := make([]string, 0, len(paths))
+	for _, p := range paths {
+		abs = append(abs, indexKey(p))
+	}
+	slices.Sort(abs)
+	sum := sha1.Sum([]byte(strings.Join(abs, "\x00")))
+	return filepath.Join(dir, journalDirName, "takeout-"+hex.EncodeToString(sum[:6])+".jsonl"), nil
+}
+
+// ImportTakeout uploads the media of Google Takeout exports, dated by their sidecars, then
+// re-applies captions and favorites to each item and re-creates its albums. Items the
+// journal records as done are not uploaded again, and albums it records are reused.
+// Progress is emitted like an upload, plus "takeoutAlbum" for every album.
+func ImportTakeout(ctx context.Context, app AppInterface, paths []string, journal *UploadJournal, state *JournalState) (*TakeoutSummary, error) {
+	defer app.EmitEvent("uploadStop", nil)
+	if state == nil {
+		state = &JournalState{MediaKeys: map[string]string{}, Albums: map[string]string{}, AlbumMedia: map[string][]string{}}
+	}
+
+	export, err := openTakeoutExport(paths)
+	if err != nil {
+		return nil, err
+	}
+	defer export.Close()
+	plan, err := planTakeout(export)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &TakeoutSummary{Media: len(plan.items), Skipped: plan.skipped}
+	for _, item := range plan.items {
+		if item.sidecar != nil {
+			summary.Matched++
+		} else {
+			summary.Unmatched = append(summary.Unmatched, item.entry.path)
+		}
+	}
+	app.EmitEvent("uploadStart", UploadBatchStart{Total: len(plan.items)})
+	app.EmitEvent("uploadScan", UploadScanProgress{
+		Found:        len(plan.items),
+		Skipped:      len(plan.skipped),
+		SkippedFiles: plan.skipped,
+		Complete:     true,
+	})
+
+	// Upload and re-apply item metadata
+	breaker := newCircuitBreaker(func(pause UploadPause) {
+		app.EmitEvent("uploadPaused", pause)
+	})
+	items := make(chan takeoutItem)
+	var mu sync.Mutex
+	mediaKeys := map[string]string{} // By entry path
+	var wg sync.WaitGroup
+	for workerID := range max(AppConfig.UploadThreads, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// One client per worker, so its auth token is reused across items
+			var api *Api
+			workerAPI := func() (*Api, error) {
+				if api != nil {
+					return api, nil
+				}
+				var err error
+				api, err = NewApi()
+				return api, err
+			}
+			for item := range items {
+				result, caption, favorite := importTakeoutItem(ctx, workerID, item, workerAPI, breaker, app)
+				journal.result(result)
+				app.EmitEvent("FileStatus", result)
+				mu.Lock()
+				if !result.IsError {
+					mediaKeys[item.entry.path] = result.MediaKey
+				}
+				if caption {
+					summary.Captions++
+				}
+				if favorite {
+					summary.Favorites++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, item := range plan.items {
+		if key := state.MediaKeys[item.entry.path]; key != "" && journal.isDone(item.entry.path) {
+			mu.Lock()
+			mediaKeys[item.entry.path] = key
+			mu.Unlock()
+			app.EmitEvent("FileStatus", FileUploadResult{Path: item.entry.path, MediaKey: key})
+			continue
+		}
+		select {
+		case items <- item:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(items)
+	wg.Wait()
+	if ctx.Err() != nil {
+		return summary, ctx.Err()
+	}
+
+	summary.Albums = importTakeoutAlbums(plan, mediaKeys, journal, state, app)
+	return summary, nil
+}
+
+// importTakeoutItem uploads one item dated by its sidecar, then sets its caption and favorite.
+// It reports which of them were set. getAPI returns the worker's API client.
+func importTakeoutItem(ctx context.Context, workerID int, item takeoutItem, getAPI func() (*Api, error),
+	breaker *circuitBreaker, app AppInterface) (FileUploadResult, bool, bool) {
+	job := uploadJob{path: item.entry.path, info: item.entry.info, open: item.entry.open}
+	if item.sidecar != nil {
+		if t, ok := item.sidecar.takenTime(); ok {
+			job.timestamp, job.timestampSource = t, TimestampSidecar
+		}
+	}
+
+	// Hashed here since captions and favorites are set by the content's dedup key
+	app.EmitEvent("ThreadStatus", ThreadStatus{
+		WorkerID: workerID,
+		Status:   "hashing",
+		FilePath: job.path,
+		FileName: job.info.Name(),
+		Message:  "Hashing...",
+	})
+	sha1, err := job.hash(ctx)
+	if err != nil {
+		return FileUploadResult{IsError: true, Error: fmt.Errorf("error calculating hash file: %w", err), Path: job.path}, false, false
+	}
+	job.sha1 = sha1
+	job.deferLocal = true
+
+	result := uploadWithRetry(ctx, workerID, job, breaker, app)
+	if result.IsError {
+		return result, false, false
+	}
+
+	caption, favorite := false, false
+	if item.sidecar != nil && (item.sidecar.Description != "" || item.sidecar.Favorited) {
+		// Failing here fails the item, so a resumed import finds it in the library and tries again
+		api, err := getAPI()
+		if err == nil && item.sidecar.Description != "" {
+			err = api.SetCaption(DedupKey(sha1), item.sidecar.Description)
+		}
+		if err == nil && item.sidecar.Favorited {
+			err = api.SetFavorite(DedupKey(sha1), true)
+		}
+		if err != nil {
+			result.IsError = true
+			result.Error = fmt.Errorf("uploaded but failed to apply sidecar metadata: %w", err)
+			return result, false, false
+		}
+		caption, favorite = item.sidecar.Description != "", item.sidecar.Favorited
+	}
+
+	// The local file is only deleted or moved once its sidecar metadata is applied. The upload
+	// may have been an upload index hit, so the library confirms the file first.
+	job.deferLocal = false
+	var api *Api
+	if movesLocalFile(job) {
+		// On failure confirmInLibrary tries once more and keeps the file if that fails too
+		api, _ = getAPI()
+	}
+	result.Local, _ = finishIndexedFile(api, getUploadIndex(), job, sha1)
+	return result, caption, favorite
+}
+
+// importTakeoutAlbums re-creates the album folders of the export from the uploaded items,
+// adding to albums an earlier run of the import created rather than creating them again
+func importTakeoutAlbums(plan *takeoutPlan, mediaKeys map[string]string, journal *UploadJournal, state *JournalState, app AppInterface) []TakeoutAlbum {
+	members := map[string][]string{}
+	for _, item := range plan.items {
+		if key := mediaKeys[item.entry.path]; item.album != "" && key != "" && !slices.Contains(members[item.album], key) {
+			members[item.album] = append(members[item.album], key)
+		}
+	}
+	folders := make([]string, 0, len(members))
+	for folder := range members {
+		folders = append(folders, folder)
+	}
+	slices.Sort(folders)
+
+	var albums []TakeoutAlbum
+	var api *Api
+	for _, folder := range folders {
+		album := TakeoutAlbum{Title: plan.albums[folder], Folder: folder, AlbumKey: state.Albums[folder], Items: len(members[folder])}
+		err := func() error {
+			if api == nil {
+				var err error
+				if api, err = NewApi(); err != nil {
+					return err
+				}
+			}
+			pending := slices.DeleteFunc(slices.Clone(members[folder]), func(key string) bool {
+				return slices.Contains(state.AlbumMedia[album.AlbumKey], key)
+			})
+			if album.AlbumKey == "" {
+				key, err := api.CreateAlbum(album.Title, pending)
+				if err != nil {
+					return err
+				}
+				album.AlbumKey = key
+				journal.album(folder, key)
+				added := pending[:min(len(pending), albumBatchSize)]
+				journal.albumAdded(key, added)
+				pending = pending[len(added):]
+			}
+			if len(pending) == 0 {
+				return nil
+			}
+			if err := api.AddMediaToAlbum(album.AlbumKey, pending); err != nil {
+				return err
+			}
+			journal.albumAdded(album.AlbumKey, pending)
+			return nil
+		}()
+		if err != nil {
+			album.Error = err.Error()
+		}
+		app.EmitEvent("takeoutAlbum", album)
+		albums = append(albums, album)
+	}
+	return albums
+}
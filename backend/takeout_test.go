@@ -0,0 +1,148 @@
+package backend
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// writeZip writes an archive holding the given files
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	w := zip.NewWriter(out)
+	for name, body := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(body))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sidecarJSON(title string, taken int64, extra string) string {
+	return `{"title":"` + title + `","photoTakenTime":{"timestamp":"` + strconv.FormatInt(taken, 10) + `"}` + extra + `}`
+}
+
+func TestPlanTakeout(t *testing.T) {
+	saved := AppConfig
+	defer func() { AppConfig = saved }()
+	AppConfig.DisableUnsupportedFilesFilter = false
+
+	const long = "Screenshot_20210101-101010_A very long application name"
+	dir := t.TempDir()
+	// Sidecars can end up in a different part of the export than their media
+	writeZip(t, filepath.Join(dir, "takeout-001.zip"), map[string]string{
+		"Takeout/Google Photos/Trip/metadata.json":                         `{"title":"Summer trip","description":"album"}`,
+		"Takeout/Google Photos/Trip/IMG_1.jpg.json":                        sidecarJSON("IMG_1.jpg", 1000000001, `,"description":"Beach","favorited":true`),
+		"Takeout/Google Photos/Trip/IMG_1.jpg(1).json":                     sidecarJSON("IMG_1.jpg", 1000000002, ""),
+		"Takeout/Google Photos/Trip/IMG_2.HEIC.supplemental-metadata.json": sidecarJSON("IMG_2.HEIC", 1000000003, ""),
+		"Takeout/Google Photos/Photos from 2019/" + long[:46] + ".json":    sidecarJSON(long+".png", 1000000004, ""),
+		"Takeout/Google Photos/Photos from 2019/old.jpg.json":              sidecarJSON("old.jpg", 1000000005, `,"trashed":true`),
+		"Takeout/Google Photos/Photos from 2019/Scan (2).jpg.json":         sidecarJSON("Scan (2).jpg", 1000000006, ""),
+		"Takeout/archive_browser.html":                                     "<html>",
+	})
+	writeZip(t, filepath.Join(dir, "takeout-002.zip"), map[string]string{
+		"Takeout/Google Photos/Trip/IMG_1.jpg":                         "one",
+		"Takeout/Google Photos/Trip/IMG_1(1).jpg":                      "one again",
+		"Takeout/Google Photos/Trip/IMG_1-edited.jpg":                  "one edited",
+		"Takeout/Google Photos/Trip/IMG_2.HEIC":                        "two",
+		"Takeout/Google Photos/Trip/IMG_2.MP4":                         "two motion",
+		"Takeout/Google Photos/Photos from 2019/" + long[:43] + ".png": "long",
+		"Takeout/Google Photos/Photos from 2019/old.jpg":               "trashed",
+		"Takeout/Google Photos/Photos from 2019/Scan (2).jpg":          "scan",
+		"Takeout/Google Photos/Photos from 2019/IMG_9.jpg":             "no sidecar",
+	})
+
+	export, err := openTakeoutExport([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer export.Close()
+	plan, err := planTakeout(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plan.albums["Takeout/Google Photos/Trip"] != "Summer trip" || len(plan.albums) != 1 {
+		t.Fatalf("albums = %v, want only the Trip folder", plan.albums)
+	}
+	want := map[string]int64{
+		"IMG_1.jpg":        1000000001,
+		"IMG_1(1).jpg":     1000000002,
+		"IMG_1-edited.jpg": 1000000001,
+		"IMG_2.HEIC":       1000000003,
+		"IMG_2.MP4":        1000000003,
+		long[:43] + ".png": 1000000004,
+		"Scan (2).jpg":     1000000006,
+		"IMG_9.jpg":        0,
+	}
+	if len(plan.items) != len(want) {
+		t.Fatalf("planned %d items, want %d", len(plan.items), len(want))
+	}
+	for _, item := range plan.items {
+		name := filepath.Base(item.entry.name)
+		var got int64
+		if item.sidecar != nil {
+			taken, _ := item.sidecar.takenTime()
+			got = taken.Unix()
+		}
+		if got != want[name] {
+			t.Errorf("%s: sidecar time %d, want %d", name, got, want[name])
+		}
+		if inTrip := filepath.Base(filepath.Dir(item.entry.name)) == "Trip"; inTrip != (item.album != "") {
+			t.Errorf("%s: album %q", name, item.album)
+		}
+		if name == "IMG_1.jpg" && (item.sidecar.Description != "Beach" || !item.sidecar.Favorited) {
+			t.Errorf("IMG_1.jpg: sidecar %+v", item.sidecar)
+		}
+	}
+
+	reasons := map[string]string{}
+	for _, skipped := range plan.skipped {
+		reasons[filepath.Base(skipped.Path)] = skipped.Reason
+	}
+	if reasons["old.jpg"] != SkipTrashed || reasons["archive_browser.html"] != SkipUnsupported {
+		t.Errorf("skipped = %v", plan.skipped)
+	}
+
+	// Entries are read straight from the archives
+	for _, item := range plan.items {
+		if filepath.Base(item.entry.name) != "IMG_1.jpg" {
+			continue
+		}
+		job := uploadJob{path: item.entry.path, info: item.entry.info, open: item.entry.open}
+		if sum, err := job.hash(t.Context()); err != nil || DedupKey(sum) != "_gW83NxJKAEngaXxoqd8u1OY4QY" {
+			t.Errorf("hash of an archive entry = %s, %v", DedupKey(sum), err)
+		}
+		if job.onDisk() {
+			t.Error("an archive entry is not on disk")
+		}
+	}
+}
+
+func TestParseTakeoutSidecarName(t *testing.T) {
+	tests := []struct{ name, key, dup string }{
+		{"IMG_1.jpg.json", "IMG_1.jpg", ""},
+		{"IMG_1.jpg(2).json", "IMG_1.jpg", "2"},
+		{"IMG_1.jpg.supplemental-metadata.json", "IMG_1.jpg", ""},
+		{"IMG_1.jpg.supplemental-metadata(1).json", "IMG_1.jpg", "1"},
+		{"IMG_1.jpg.suppl.json", "IMG_1.jpg", ""},
+		{"IMG_1.jpg..json", "IMG_1.jpg", ""},
+		{"metadata.json", "metadata", ""},
+	}
+	for _, tt := range tests {
+		if key, dup := parseTakeoutSidecarName(tt.name); key != tt.key || dup != tt.dup {
+			t.Errorf("parseTakeoutSidecarName(%q) = %q, %q, want %q, %q", tt.name, key, dup, tt.key, tt.dup)
+		}
+	}
+}
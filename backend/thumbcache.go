@@ -0,0 +1,322 @@
+package backend
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultThumbnailCacheMaxMB is the disk budget used when the config does not set one
+	defaultThumbnailCacheMaxMB = 512
+	// minThumbnailCacheMaxMB keeps the cache useful even with tiny configured budgets
+	minThumbnailCacheMaxMB = 16
+	// thumbnailPrewarmQueueSize bounds how many pre-warm requests may be pending at once
+	thumbnailPrewarmQueueSize = 1024
+)
+
+// ThumbnailCache is an on-disk thumbnail store keyed by media key and variant, with LRU eviction.
+// Entries live at <dir>/<hash[:2]>/<hash>/<variant>, where hash is derived from the
+// media key and variant encodes size and format, so all variants of one item can be
+// invalidated together.
+type ThumbnailCache struct {
+	dir      string
+	maxBytes func() int64
+
+	mu      sync.Mutex
+	loaded  bool
+	lru     *list.List // front = most recently used
+	entries map[string]*list.Element
+	size    int64
+}
+
+type thumbnailCacheEntry struct {
+	path string
+	size int64
+}
+
+var (
+	thumbnailCacheMu     sync.Mutex
+	sharedThumbnailCache *ThumbnailCache
+)
+
+// getThumbnailCache returns the process-wide thumbnail cache used by both the GUI and the CLI
+func getThumbnailCache() *ThumbnailCache {
+	thumbnailCacheMu.Lock()
+	defer thumbnailCacheMu.Unlock()
+
+	if sharedThumbnailCache == nil {
+		sharedThumbnailCache = NewThumbnailCache(
+			filepath.Join(appDataDir(), "cache", "thumbnails"),
+			thumbnailCacheBudget,
+		)
+	}
+	return sharedThumbnailCache
+}
+
+// thumbnailCacheBudget returns the configured thumbnail cache size in bytes
+func thumbnailCacheBudget() int64 {
+	mb := AppConfig.ThumbnailCacheMaxMB
+	if mb <= 0 {
+		mb = defaultThumbnailCacheMaxMB
+	}
+	mb = max(mb, minThumbnailCacheMaxMB)
+	return int64(mb) * 1024 * 1024
+}
+
+// NewThumbnailCache creates a cache rooted at dir. maxBytes is consulted on every
+// insertion so budget changes in the config apply without a restart.
+func NewThumbnailCache(dir string, maxBytes func() int64) *ThumbnailCache {
+	return &ThumbnailCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// thumbnailVariant names a cached rendition of a media item
+func thumbnailVariant(width, height int, forceJPEG bool, noOverlay bool) string {
+	format := "auto"
+	if forceJPEG {
+		format = "jpeg"
+	}
+	variant := fmt.Sprintf("%dx%d-%s", width, height, format)
+	if noOverlay {
+		variant += "-no"
+	}
+	return variant
+}
+
+func (c *ThumbnailCache) itemDir(mediaKey string) string {
+	sum := sha256.Sum256([]byte(mediaKey))
+	h := hex.EncodeToString(sum[:16])
+	return filepath.Join(c.dir, h[:2], h)
+}
+
+func (c *ThumbnailCache) entryPath(mediaKey, variant string) string {
+	return filepath.Join(c.itemDir(mediaKey), variant)
+}
+
+// load indexes existing files, using their modification time as the last access time,
+// and returns the files to remove beyond the budget. Must be called with c.mu held.
+func (c *ThumbnailCache) load() []string {
+	if c.loaded {
+		return nil
+	}
+	c.loaded = true
+
+	type found struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []found
+	filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, found{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+	for _, f := range files {
+		c.entries[f.path] = c.lru.PushBack(&thumbnailCacheEntry{path: f.path, size: f.size})
+		c.size += f.size
+	}
+	return c.evict()
+}
+
+// Get returns cached thumbnail bytes and refreshes the entry's position in the LRU.
+// The file is read outside the lock, which only guards the LRU bookkeeping.
+func (c *ThumbnailCache) Get(mediaKey, variant string) ([]byte, bool) {
+	path := c.entryPath(mediaKey, variant)
+	c.mu.Lock()
+	evicted := c.load()
+	_, ok := c.entries[path]
+	c.mu.Unlock()
+	removeFiles(evicted)
+	if !ok {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(path)
+
+	c.mu.Lock()
+	elem, ok := c.entries[path]
+	switch {
+	case err != nil && ok:
+		c.removeElement(elem)
+	case ok:
+		c.lru.MoveToFront(elem)
+	}
+	c.mu.Unlock()
+	if err != nil {
+		return nil, false
+	}
+
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return data, true
+}
+
+// Has reports whether a thumbnail is cached without reading it or refreshing its LRU position
+func (c *ThumbnailCache) Has(mediaKey, variant string) bool {
+	path := c.entryPath(mediaKey, variant)
+	c.mu.Lock()
+	evicted := c.load()
+	_, ok := c.entries[path]
+	c.mu.Unlock()
+	removeFiles(evicted)
+	return ok
+}
+
+// Put stores thumbnail bytes and evicts least recently used entries beyond the budget
+func (c *ThumbnailCache) Put(mediaKey, variant string, data []byte) error {
+	path := c.entryPath(mediaKey, variant)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create thumbnail cache directory: %w", err)
+	}
+	// A temp file of its own, so concurrent puts of one variant never share it
+	tmp, err := os.CreateTemp(filepath.Dir(path), variant+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write thumbnail cache entry: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write thumbnail cache entry: %w", err)
+	}
+
+	c.mu.Lock()
+	evicted := c.load()
+	defer func() { removeFiles(evicted) }()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[path]; ok {
+		entry := elem.Value.(*thumbnailCacheEntry)
+		c.size += int64(len(data)) - entry.size
+		entry.size = int64(len(data))
+		c.lru.MoveToFront(elem)
+	} else {
+		c.entries[path] = c.lru.PushFront(&thumbnailCacheEntry{path: path, size: int64(len(data))})
+		c.size += int64(len(data))
+	}
+
+	evicted = append(evicted, c.evict()...)
+	return nil
+}
+
+// Invalidate drops every cached variant of a media item
+func (c *ThumbnailCache) Invalidate(mediaKey string) {
+	dir := c.itemDir(mediaKey)
+	prefix := dir + string(filepath.Separator)
+	c.mu.Lock()
+	evicted := c.load()
+	for path, elem := range c.entries {
+		if strings.HasPrefix(path, prefix) {
+			c.removeElement(elem)
+		}
+	}
+	c.mu.Unlock()
+	removeFiles(evicted)
+	os.RemoveAll(dir)
+}
+
+// Clear removes all cached thumbnails
+func (c *ThumbnailCache) Clear() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lru.Init()
+	c.entries = make(map[string]*list.Element)
+	c.size = 0
+	c.loaded = true
+	return os.RemoveAll(c.dir)
+}
+
+// Size returns the total size of cached thumbnails in bytes
+func (c *ThumbnailCache) Size() int64 {
+	c.mu.Lock()
+	evicted := c.load()
+	size := c.size
+	c.mu.Unlock()
+	removeFiles(evicted)
+	return size
+}
+
+// evict drops least recently used entries beyond the budget and returns their files, for
+// the caller to remove once it released c.mu. Must be called with c.mu held.
+func (c *ThumbnailCache) evict() []string {
+	var evicted []string
+	limit := c.maxBytes()
+	for c.size > limit && c.lru.Len() > 0 {
+		elem := c.lru.Back()
+		evicted = append(evicted, elem.Value.(*thumbnailCacheEntry).path)
+		c.removeElement(elem)
+	}
+	return evicted
+}
+
+func removeFiles(paths []string) {
+	for _, path := range paths {
+		os.Remove(path)
+	}
+}
+
+// removeElement must be called with c.mu held
+func (c *ThumbnailCache) removeElement(elem *list.Element) {
+	entry := elem.Value.(*thumbnailCacheEntry)
+	c.lru.Remove(elem)
+	delete(c.entries, entry.path)
+	c.size -= entry.size
+}
+
+// FetchThumbnail returns a thumbnail through the shared disk cache, downloading it on a miss.
+// Both the GUI gallery and the CLI thumbnail command go through here.
+func FetchThumbnail(api *Api, mediaKey string, width, height int, forceJPEG bool, noOverlay bool) ([]byte, error) {
+	cache := getThumbnailCache()
+	variant := thumbnailVariant(width, height, forceJPEG, noOverlay)
+	if data, ok := cache.Get(mediaKey, variant); ok {
+		return data, nil
+	}
+
+	data, err := api.GetThumbnail(mediaKey, width, height, forceJPEG, 0, noOverlay)
+	if err != nil {
+		return nil, err
+	}
+
+	// A failed cache write only costs a refetch later
+	cache.Put(mediaKey, variant, data)
+	return data, nil
+}
+
+// InvalidateThumbnails drops cached thumbnails for the given media keys
+func InvalidateThumbnails(mediaKeys ...string) {
+	cache := getThumbnailCache()
+	for _, key := range mediaKeys {
+		if key != "" {
+			cache.Invalidate(key)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package backend
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestThumbnailCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewThumbnailCache(dir, func() int64 { return 25 })
+	variant := thumbnailVariant(200, 200, false, false)
+
+	cache.Put("media-key-a", variant, bytes.Repeat([]byte("a"), 10))
+	cache.Put("media-key-b", variant, bytes.Repeat([]byte("b"), 10))
+	// Touch a so that b becomes the eviction candidate
+	if _, ok := cache.Get("media-key-a", variant); !ok {
+		t.Fatal("expected cache hit for a")
+	}
+	cache.Put("media-key-c", variant, bytes.Repeat([]byte("c"), 10))
+
+	if _, ok := cache.Get("media-key-b", variant); ok {
+		t.Fatal("expected b to be evicted")
+	}
+	if _, ok := cache.Get("media-key-a", variant); !ok {
+		t.Fatal("expected a to survive eviction")
+	}
+	if got := cache.Size(); got != 20 {
+		t.Fatalf("expected 20 bytes cached, got %d", got)
+	}
+	if !cache.Has("media-key-c", variant) || cache.Has("media-key-b", variant) {
+		t.Fatal("Has should only report cached thumbnails")
+	}
+
+	// A fresh instance rebuilds its index from disk
+	reopened := NewThumbnailCache(dir, func() int64 { return 25 })
+	if got := reopened.Size(); got != 20 {
+		t.Fatalf("expected 20 bytes after reopen, got %d", got)
+	}
+
+	reopened.Invalidate("media-key-a")
+	if _, ok := reopened.Get("media-key-a", variant); ok {
+		t.Fatal("expected a to be invalidated")
+	}
+}
+
+func TestThumbnailCache_Concurrent(t *testing.T) {
+	cache := NewThumbnailCache(t.TempDir(), func() int64 { return 1 << 20 })
+	variant := thumbnailVariant(200, 200, false, false)
+	data := bytes.Repeat([]byte("t"), 100)
+
+	// Puts of one variant each write a temp file of their own
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := cache.Put("media-key-a", variant, data); err != nil {
+				t.Error(err)
+			}
+			cache.Get("media-key-a", variant)
+		}()
+	}
+	wg.Wait()
+	if got, ok := cache.Get("media-key-a", variant); !ok || !bytes.Equal(got, data) {
+		t.Fatalf("cache hit %v after concurrent puts", ok)
+	}
+	if got := cache.Size(); got != 100 {
+		t.Fatalf("expected 100 bytes cached, got %d", got)
+	}
+}
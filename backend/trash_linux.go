@@ -0,0 +1,117 @@
+//go:build linux
+
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// trashSupported reports whether files can be moved to the trash on this system
+func trashSupported() error {
+	return nil
+}
+
+// trashFile moves a file to the freedesktop.org trash of its file system: the home trash
+// under $XDG_DATA_HOME, or $topdir/.Trash-$uid on other mounts. It writes the
+// .trashinfo that lets file managers restore the file and returns where it was moved.
+func trashFile(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	trash, err := trashDir(abs)
+	if err != nil {
+		return "", err
+	}
+	files, infos := filepath.Join(trash, "files"), filepath.Join(trash, "info")
+	for _, dir := range []string{files, infos} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", err
+		}
+	}
+
+	// The .trashinfo file is created first and exclusively, which reserves the name
+	name := filepath.Base(abs)
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; i <= maxCollisionRenames; i++ {
+		infoPath := filepath.Join(infos, name+".trashinfo")
+		f, err := os.OpenFile(infoPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, fs.ErrExist) {
+			name = fmt.Sprintf("%s.%d%s", base, i, ext)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, err = fmt.Fprintf(f, "[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+			(&url.URL{Path: abs}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		dest := filepath.Join(files, name)
+		if err == nil {
+			err = os.Rename(abs, dest)
+		}
+		if err != nil {
+			os.Remove(infoPath)
+			return "", err
+		}
+		return dest, nil
+	}
+	return "", fmt.Errorf("no free trash name for %s", abs)
+}
+
+// trashDir returns the trash directory for a file: the home trash if the file is on the
+// same file system, else .Trash-$uid at the top of the file's mount
+func trashDir(path string) (string, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	if err := os.MkdirAll(dataHome, 0700); err != nil {
+		return "", err
+	}
+	dev, err := deviceOf(path)
+	if err != nil {
+		return "", err
+	}
+	if homeDev, err := deviceOf(dataHome); err == nil && homeDev == dev {
+		return filepath.Join(dataHome, "Trash"), nil
+	}
+
+	// Files cannot be renamed across file systems, so use the trash of the mount
+	top := filepath.Dir(path)
+	for {
+		parent := filepath.Dir(top)
+		if parent == top {
+			break
+		}
+		if parentDev, err := deviceOf(parent); err != nil || parentDev != dev {
+			break
+		}
+		top = parent
+	}
+	return filepath.Join(top, fmt.Sprintf(".Trash-%d", os.Getuid())), nil
+}
+
+// deviceOf returns the device a file is on
+func deviceOf(path string) (uint64, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Dev), nil
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package backend
+
+import "errors"
+
+var errTrashUnsupported = errors.New("moving files to the trash is only supported on Linux")
+
+// trashSupported reports whether files can be moved to the trash on this system
+func trashSupported() error {
+	return errTrashUnsupported
+}
+
+// trashFile is not implemented outside of the freedesktop.org trash used on Linux
+func trashFile(path string) (string, error) {
+	return "", errTrashUnsupported
+}
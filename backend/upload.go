@@ -3,21 +3,28 @@ package backend
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
 	"sync"
+	"time"
 )
 
 // ProgressCallback is a function type for upload progress updates
 type ProgressCallback func(event string, data any)
 
+// UploadManager runs one upload batch at a time. Paths passed to Upload while a batch
+// is running are added to it.
 type UploadManager struct {
+	mu      sync.Mutex
 	wg      sync.WaitGroup
 	cancel  chan struct{}
 	running bool
+	batch   *uploadBatch
+	next    []string // Added while the batch was ending; uploaded by the next batch
 	app     AppInterface
 }
 
@@ -28,111 +35,194 @@ func NewUploadManager(app AppInterface) *UploadManager {
 }
 
 func (m *UploadManager) IsRunning() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	return m.running
 }
 
 func (m *UploadManager) Cancel() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if m.cancel != nil {
 		close(m.cancel)
 		m.cancel = nil
 	}
+	m.next = nil
 }
 
+// Pause lets upload workers finish their current files and then holds them.
+// Scanning, hashing and the library check go on filling the queue.
+func (m *UploadManager) Pause() {
+	if batch := m.currentBatch(); batch != nil {
+		batch.setPaused(true)
+	}
+}
+
+// Resume lets paused upload workers go on
+func (m *UploadManager) Resume() {
+	if batch := m.currentBatch(); batch != nil {
+		batch.setPaused(false)
+	}
+}
+
+// IsPaused reports whether the running batch is paused
+func (m *UploadManager) IsPaused() bool {
+	batch := m.currentBatch()
+	return batch != nil && batch.isPaused()
+}
+
+// CancelFile cancels one file of the running batch, whether it is being uploaded, queued
+// or not scanned yet. It reports whether the file was uploading or queued.
+func (m *UploadManager) CancelFile(path string) bool {
+	batch := m.currentBatch()
+	return batch != nil && batch.cancelFile(path)
+}
+
+// MoveFile moves a queued file to index in the upload order; 0 uploads it next
+func (m *UploadManager) MoveFile(path string, index int) bool {
+	batch := m.currentBatch()
+	return batch != nil && batch.move(path, index)
+}
+
+func (m *UploadManager) currentBatch() *uploadBatch {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.batch
+}
+
+// UploadBatchStart is emitted as "uploadStart". Paths are scanned while uploading,
+// so Total is 0 and the count follows in "uploadScan" events.
 type UploadBatchStart struct {
 	Total int
 }
 
+// UploadScanProgress is emitted as "uploadScan" while the upload paths are scanned.
+// SkippedFiles is only filled in the final event, which has Complete set.
+type UploadScanProgress struct {
+	Found        int
+	Skipped      int
+	SkippedFiles []SkippedFile
+	Pairing      UploadPairing // Set once the scan is complete
+	Complete     bool
+}
+
 type FileUploadResult struct {
 	MediaKey string
 	IsError  bool
 	Error    error
 	Path     string
+	Attempts int // Upload attempts made; 0 if the file never reached an upload worker
+	// Time the upload was committed with and where it came from: TimestampMetadata,
+	// TimestampFilename or TimestampMtime. Unset for files already in the library.
+	Timestamp       time.Time
+	TimestampSource string
+	// Tag sets of UploadStripMetadata removed from the uploaded copy; unset if the file was uploaded as is
+	MetadataStripped []string
+	// What was done with the local file once it was in the library; unset for failed files
+	Local LocalFileAction
+	// Quality and device the file was committed with
+	Profile UploadProfile
 }
 
 type ThreadStatus struct {
 	WorkerID int
-	Status   string // "idle", "hashing", "checking", "uploading", "finalizing", "completed", "error"
+	Status   string // "idle", "hashing", "checking", "uploading", "finalizing", "completed", "error", "retrying", "paused"
 	FilePath string
 	FileName string
+	Attempt  int    // Current upload attempt, starting at 1; 0 outside upload workers
+	Profile  string // Quality and device the file is committed with; set while finalizing
 	Message  string
 }
 
+// UploadOptions adjusts a single upload batch
+type UploadOptions struct {
+	Journal *UploadJournal // Records progress; files it lists as done are skipped
+}
+
 func (m *UploadManager) Upload(app AppInterface, paths []string) {
-	if m.running {
-		return
-	}
+	m.UploadWithOptions(app, paths, UploadOptions{})
+}
 
-	m.running = true
-	m.cancel = make(chan struct{})
+// UploadWithOptions starts an upload batch like Upload. If a batch is running, paths are
+// added to it and opts is ignored.
+func (m *UploadManager) UploadWithOptions(app AppInterface, paths []string, opts UploadOptions) {
+	m.mu.Lock()
+	for m.running {
+		batch := m.batch
+		m.mu.Unlock()
+		if batch.add(paths) {
+			return
+		}
+		m.mu.Lock()
+		if m.batch == batch {
+			// The batch is ending; upload these paths right after it
+			m.next = append(m.next, paths...)
+			m.mu.Unlock()
+			return
+		}
+	}
 
-	targetPaths, err := filterGooglePhotosFiles(paths)
-	if err != nil {
+	// Check the scan rules up front; scanning itself runs alongside the upload
+	if _, err := scanRulesFromConfig(); err != nil {
+		m.mu.Unlock()
 		app.EmitEvent("FileStatus", FileUploadResult{
 			IsError: true,
 			Error:   err,
 		})
+		app.EmitEvent("uploadStop", nil)
 		return
 	}
 
-	if len(targetPaths) == 0 {
-		return
-	}
+	m.running = true
+	m.cancel = make(chan struct{})
+	m.batch = newUploadBatch(opts.Journal)
+	batch, cancel := m.batch, m.cancel
+	m.mu.Unlock()
 
-	app.EmitEvent("uploadStart", UploadBatchStart{
-		Total: len(targetPaths),
-	})
+	app.EmitEvent("uploadStart", UploadBatchStart{})
 
 	if AppConfig.UploadThreads < 1 {
 		AppConfig.UploadThreads = 1
 	}
 
-	// Don't start more threads than files to process
-	numWorkers := min(AppConfig.UploadThreads, len(targetPaths))
-
-	// Create a worker pool for concurrent uploads
-	workChan := make(chan string, len(targetPaths))
-	results := make(chan FileUploadResult, len(targetPaths))
+	go m.run(app, batch, paths, opts, cancel)
+}
 
-	// Start workers
-	for i := range numWorkers {
-		m.wg.Add(1)
-		go startUploadWorker(i, workChan, results, m.cancel, &m.wg, app)
+// finish persists upload state and signals the end of the batch, then starts the
+// paths that arrived while it was ending
+func (m *UploadManager) finish(app AppInterface) {
+	if err := FlushUploadIndex(); err != nil {
+		app.GetLogger().Error(fmt.Sprintf("failed to save upload index: %v", err))
 	}
+	app.EmitEvent("uploadStop", nil)
+
+	m.mu.Lock()
+	m.running = false
+	m.batch = nil
+	m.cancel = nil
+	next := m.next
+	m.next = nil
+	m.mu.Unlock()
+
+	if len(next) > 0 {
+		m.Upload(app, next)
+	}
+}
 
-	// Send work to workers
-	go func() {
-	LOOP:
-		for _, path := range targetPaths {
-			select {
-			case <-m.cancel:
-				break LOOP
-			case workChan <- path:
-			}
-		}
-		close(workChan)
-	}()
+// Photo formats supported by Google Photos
+var googlePhotosPhotoFormats = []string{
+	"avif", "bmp", "gif", "heic", "ico",
+	"jpg", "jpeg", "png", "tiff", "webp",
+	"cr2", "cr3", "nef", "arw", "orf",
+	"raf", "rw2", "pef", "sr2", "dng",
+}
 
-	// Handle results and wait for completion
-	go func() {
-		m.wg.Wait()
-		close(results)
-		app.EmitEvent("uploadStop", nil)
-		m.running = false
-	}()
-
-	// Process results
-	go func() {
-		for result := range results {
-			app.EmitEvent("FileStatus", result)
-			if result.IsError {
-				s := fmt.Sprintf("upload error: %v", result.Error)
-				app.GetLogger().Error(s)
-			} else {
-				s := fmt.Sprintf("upload success: %v", result.Path)
-				app.GetLogger().Info(s)
-			}
-		}
-	}()
+// Video formats supported by Google Photos
+var googlePhotosVideoFormats = []string{
+	"3gp", "3g2", "asf", "avi", "divx",
+	"m2t", "m2ts", "m4v", "mkv", "mmv",
+	"mod", "mov", "mp4", "mpg", "mpeg",
+	"mts", "tod", "wmv", "ts",
 }
 
 // isSupportedByGooglePhotos checks if a file extension is supported by Google Photos
@@ -146,50 +236,8 @@ func isSupportedByGooglePhotos(filename string) bool {
 	// Remove the dot from the extension
 	ext = ext[1:]
 
-	// Supported photo formats
-	photoFormats := []string{
-		"avif", "bmp", "gif", "heic", "ico",
-		"jpg", "jpeg", "png", "tiff", "webp",
-		"cr2", "cr3", "nef", "arw", "orf",
-		"raf", "rw2", "pef", "sr2", "dng",
-	}
-
-	// Supported video formats
-	videoFormats := []string{
-		"3gp", "3g2", "asf", "avi", "divx",
-		"m2t", "m2ts", "m4v", "mkv", "mmv",
-		"mod", "mov", "mp4", "mpg", "mpeg",
-		"mts", "tod", "wmv", "ts",
-	}
-
 	// Check if extension is in either supported format
-	return slices.Contains(photoFormats, ext) || slices.Contains(videoFormats, ext)
-}
-
-func scanDirectoryForFiles(path string, recursive bool) ([]string, error) {
-	var files []string
-
-	entries, err := os.ReadDir(path)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, entry := range entries {
-		fullPath := filepath.Join(path, entry.Name())
-		if entry.IsDir() {
-			if recursive {
-				subFiles, err := scanDirectoryForFiles(fullPath, recursive)
-				if err != nil {
-					return nil, err
-				}
-				files = append(files, subFiles...)
-			}
-		} else {
-			files = append(files, fullPath)
-		}
-	}
-
-	return files, nil
+	return slices.Contains(googlePhotosPhotoFormats, ext) || slices.Contains(googlePhotosVideoFormats, ext)
 }
 
 // FilterGooglePhotosFiles returns a list of files that are supported by Google Photos (exported)
@@ -199,43 +247,8 @@ func FilterGooglePhotosFiles(paths []string) ([]string, error) {
 
 // filterGooglePhotosFiles returns a list of files that are supported by Google Photos
 func filterGooglePhotosFiles(paths []string) ([]string, error) {
-	var supportedFiles []string
-
-	for _, path := range paths {
-		fileInfo, err := os.Stat(path)
-		if err != nil {
-			return nil, fmt.Errorf("error accessing path %s: %v", path, err)
-		}
-
-		if fileInfo.IsDir() {
-			files, err := scanDirectoryForFiles(path, AppConfig.Recursive)
-			if err != nil {
-				return nil, fmt.Errorf("error scanning directory %s: %v", path, err)
-			}
-
-			for _, file := range files {
-				if AppConfig.DisableUnsupportedFilesFilter {
-					supportedFiles = append(supportedFiles, file)
-				} else {
-					if isSupportedByGooglePhotos(file) {
-						supportedFiles = append(supportedFiles, file)
-					}
-				}
-
-			}
-		} else {
-			if AppConfig.DisableUnsupportedFilesFilter {
-				supportedFiles = append(supportedFiles, path)
-			} else {
-				if isSupportedByGooglePhotos(path) {
-					supportedFiles = append(supportedFiles, path)
-				}
-			}
-
-		}
-	}
-
-	return supportedFiles, nil
+	supportedFiles, _, err := scanUploadPaths(paths)
+	return supportedFiles, err
 }
 
 // UploadFile is an exported version for CLI use with callback
@@ -244,27 +257,75 @@ func UploadFile(ctx context.Context, api *Api, filePath string, workerID int, ca
 }
 
 func uploadFileWithCallback(ctx context.Context, api *Api, filePath string, workerID int, callback ProgressCallback) (string, error) {
+	result, err := uploadJobWithCallback(ctx, api, uploadJob{path: filePath}, workerID, callback)
+	return result.MediaKey, err
+}
+
+func uploadJobWithCallback(ctx context.Context, api *Api, job uploadJob, workerID int, callback ProgressCallback) (FileUploadResult, error) {
+	filePath := job.path
 	fileName := filepath.Base(filePath)
 	mediakey := ""
 
-	// Stage 1: Hashing
-	callback("ThreadStatus", ThreadStatus{
-		WorkerID: workerID,
-		Status:   "hashing",
-		FilePath: filePath,
-		FileName: fileName,
-		Message:  "Hashing...",
-	})
+	var err error
+	if job.onDisk() {
+		if job.info, err = os.Stat(filePath); err != nil {
+			return FileUploadResult{}, fmt.Errorf("error getting file info: %w", err)
+		}
+	}
+	fileInfo := job.info
+
+	// Unchanged files seen by a previous run skip hashing, and the remote check once uploaded
+	index := getUploadIndex()
+	var indexed UploadIndexEntry
+	haveIndexed := false
+	if index != nil && !ReindexUploads {
+		indexed, haveIndexed = index.Lookup(filePath, fileInfo)
+	}
 
-	sha1_hash_bytes, err := CalculateSHA1(ctx, filePath)
-	if err != nil {
-		return "", fmt.Errorf("error calculating hash file: %w", err)
+	if haveIndexed && indexed.MediaKey != "" && !AppConfig.ForceUpload {
+		local, err := finishIndexedFile(api, index, job, indexed.SHA1)
+		if !errors.Is(err, errNotInLibrary) {
+			callback("ThreadStatus", ThreadStatus{
+				WorkerID: workerID,
+				Status:   "completed",
+				FilePath: filePath,
+				FileName: fileName,
+				Message:  "Already uploaded (indexed)",
+			})
+			return FileUploadResult{Path: filePath, MediaKey: indexed.MediaKey, Local: local}, nil
+		}
+		// The library no longer holds the indexed file, so it is uploaded again
+		index.Remove(filePath)
+		job.checked = true
+	}
+
+	sha1_hash_bytes := job.sha1
+	if sha1_hash_bytes == nil && haveIndexed && len(indexed.SHA1) > 0 {
+		sha1_hash_bytes = indexed.SHA1
+	}
+	if sha1_hash_bytes == nil {
+		// Stage 1: Hashing
+		callback("ThreadStatus", ThreadStatus{
+			WorkerID: workerID,
+			Status:   "hashing",
+			FilePath: filePath,
+			FileName: fileName,
+			Message:  "Hashing...",
+		})
+
+		sha1_hash_bytes, err = job.hash(ctx)
+		if err != nil {
+			return FileUploadResult{}, fmt.Errorf("error calculating hash file: %w", err)
+		}
+		if index != nil {
+			index.Put(filePath, fileInfo, sha1_hash_bytes, "")
+		}
 	}
 
 	sha1_hash_b64 := base64.StdEncoding.EncodeToString([]byte(sha1_hash_bytes))
 
-	// Stage 2: Checking if exists in library
-	if !AppConfig.ForceUpload {
+	// Stage 2: Checking if exists in library (already done for pre-flight checked jobs)
+	if !AppConfig.ForceUpload && !job.checked {
 		callback("ThreadStatus", ThreadStatus{
 			WorkerID: workerID,
 			Status:   "checking",
@@ -278,6 +339,9 @@ func uploadFileWithCallback(ctx context.Context, api *Api, filePath string, work
 			fmt.Println("Error checking for remote matches:", err)
 		}
 		if len(mediakey) > 0 {
+			if index != nil {
+				index.Put(filePath, fileInfo, sha1_hash_bytes, mediakey)
+			}
 			callback("ThreadStatus", ThreadStatus{
 				WorkerID: workerID,
 				Status:   "completed",
@@ -285,27 +349,11 @@ func uploadFileWithCallback(ctx context.Context, api *Api, filePath string, work
 				FileName: fileName,
 				Message:  "Already in library",
 			})
-			if AppConfig.DeleteFromHost {
-				err = os.Remove(filePath)
-				if err != nil {
-					fmt.Println("Error deleting file:", err)
-				}
-			}
-			return mediakey, nil
+			local := finishLocalFile(api, index, job, sha1_hash_bytes)
+			return FileUploadResult{Path: filePath, MediaKey: mediakey, Local: local}, nil
 		}
 	}
 
-	file, err := os.Open(filePath)
-	if err != nil {
-		return "", fmt.Errorf("error opening file: %w", err)
-	}
-	fileInfo, err := file.Stat()
-	file.Close()
-
-	if err != nil {
-		return "", fmt.Errorf("error getting file info: %w", err)
-	}
-
 	// Stage 3: Uploading
 	callback("ThreadStatus", ThreadStatus{
 		WorkerID: workerID,
@@ -315,44 +363,58 @@ func uploadFileWithCallback(ctx context.Context, api *Api, filePath string, work
 		Message:  "Uploading...",
 	})
 
-	token, err := api.GetUploadToken(sha1_hash_b64, fileInfo.Size())
+	file, size, stripped, err := job.content()
 	if err != nil {
-		return "", fmt.Errorf("error uploading file: %w", err)
+		return FileUploadResult{}, fmt.Errorf("error opening file: %w", err)
 	}
-
-	CommitToken, err := api.UploadFile(ctx, filePath, token)
+	token, err := api.GetUploadToken(sha1_hash_b64, size)
 	if err != nil {
-		return "", fmt.Errorf("error uploading file: %w", err)
+		file.Close()
+		return FileUploadResult{}, fmt.Errorf("error uploading file: %w", err)
+	}
+	CommitToken, err := api.UploadReader(ctx, file, token)
+	file.Close()
+	if err != nil {
+		return FileUploadResult{}, fmt.Errorf("error uploading file: %w", err)
 
 	}
 
-	// Stage 4: Finalizing
+	// Stage 4: Finalizing, dated by the job's own timestamp or UploadTimestampPolicy
+	timestamp, source := job.timestamp, job.timestampSource
+	if timestamp.IsZero() {
+		timestamp, source = uploadTimestamp(job)
+	}
+	profile := uploadProfile(filePath, fileInfo.Size())
 	callback("ThreadStatus", ThreadStatus{
 		WorkerID: workerID,
 		Status:   "finalizing",
 		FilePath: filePath,
 		FileName: fileName,
-		Message:  "Committing upload...",
+		Profile:  profile.String(),
+		Message:  fmt.Sprintf("Committing upload (%s from %s)...", timestamp.Format("2006-01-02 15:04"), source),
 	})
 
-	mediaKey, err := api.CommitUpload(CommitToken, fileInfo.Name(), sha1_hash_bytes, fileInfo.ModTime().Unix())
+	mediaKey, err := api.CommitUploadOverride(CommitToken, fileInfo.Name(), sha1_hash_bytes, timestamp.Unix(),
+		profile.Device, profile.qualityValue())
 	if err != nil {
-		return "", fmt.Errorf("error commiting file: %w", err)
+		return FileUploadResult{}, fmt.Errorf("error commiting file: %w", err)
 	}
 
 	if len(mediaKey) == 0 {
-		return "", fmt.Errorf("media key not received")
+		return FileUploadResult{}, fmt.Errorf("media key not received")
 	}
 
-	if AppConfig.DeleteFromHost {
-		os.Remove(filePath)
+	if index != nil {
+		index.Put(filePath, fileInfo, sha1_hash_bytes, mediaKey)
 	}
 
-	return mediaKey, nil
+	local := finishLocalFile(api, index, job, sha1_hash_bytes)
+	return FileUploadResult{Path: filePath, MediaKey: mediaKey, Timestamp: timestamp, TimestampSource: source,
+		MetadataStripped: stripped, Local: local, Profile: profile}, nil
 
 }
 
-func startUploadWorker(workerID int, workChan <-chan string, results chan<- FileUploadResult, cancel <-chan struct{}, wg *sync.WaitGroup, app AppInterface) {
+func startUploadWorker(ctx context.Context, workerID int, batch *uploadBatch, results chan<- FileUploadResult, breaker *circuitBreaker, wg *sync.WaitGroup, app AppInterface) {
 	defer wg.Done()
 
 	// Emit idle status initially
@@ -362,68 +424,69 @@ func startUploadWorker(workerID int, workChan <-chan string, results chan<- File
 		Message:  "Waiting for files...",
 	})
 
-	for path := range workChan {
-		select {
-		case <-cancel:
+	for {
+		if batch.isPaused() {
 			app.EmitEvent("ThreadStatus", ThreadStatus{
 				WorkerID: workerID,
 				Status:   "idle",
-				Message:  "Cancelled",
+				Message:  "Paused",
 			})
-			return // Stop if cancellation is requested
-		default:
-			ctx, cancelUpload := context.WithCancel(context.Background())
-			go func() {
-				<-cancel // If global cancel happens, cancel this upload
-				cancelUpload()
-			}()
-
-			api, err := NewApi()
-			if err != nil {
-				results <- FileUploadResult{IsError: true, Error: err, Path: path}
-				app.EmitEvent("ThreadStatus", ThreadStatus{
-					WorkerID: workerID,
-					Status:   "error",
-					FilePath: path,
-					FileName: filepath.Base(path),
-					Message:  fmt.Sprintf("API error: %v", err),
-				})
-				continue
-			}
+		}
+		job, cancelled, ok := batch.next(ctx)
+		if !ok {
+			break
+		}
+		if cancelled {
+			results <- FileUploadResult{IsError: true, Error: ErrUploadCancelled, Path: job.path}
+			continue
+		}
 
-			// Create callback from app interface
-			callback := func(event string, data any) {
-				app.EmitEvent(event, data)
-			}
-			mediaKey, err := uploadFileWithCallback(ctx, api, path, workerID, callback)
-			if err != nil {
-				results <- FileUploadResult{IsError: true, Error: err, Path: path}
-				app.EmitEvent("ThreadStatus", ThreadStatus{
-					WorkerID: workerID,
-					Status:   "error",
-					FilePath: path,
-					FileName: filepath.Base(path),
-					Message:  fmt.Sprintf("Error: %v", err),
-				})
-			} else {
-				results <- FileUploadResult{IsError: false, Path: path, MediaKey: mediaKey}
-				app.EmitEvent("ThreadStatus", ThreadStatus{
-					WorkerID: workerID,
-					Status:   "completed",
-					FilePath: path,
-					FileName: filepath.Base(path),
-					Message:  "Completed",
-				})
-			}
-			cancelUpload()
+		// The file's own context, cancelled by CancelFile or the whole batch
+		fileCtx, cancelUpload := context.WithCancelCause(ctx)
+		batch.start(job.path, cancelUpload)
+		result := uploadWithRetry(fileCtx, workerID, job, breaker, app)
+		if result.IsError && context.Cause(fileCtx) == ErrUploadCancelled {
+			result.Error = ErrUploadCancelled
+		}
+		cancelUpload(nil)
+		batch.done(job.path)
 
-			// Mark as idle after completing file
+		results <- result
+		if result.IsError {
 			app.EmitEvent("ThreadStatus", ThreadStatus{
 				WorkerID: workerID,
-				Status:   "idle",
-				Message:  "Waiting for next file...",
+				Status:   "error",
+				FilePath: job.path,
+				FileName: filepath.Base(job.path),
+				Attempt:  result.Attempts,
+				Message:  fmt.Sprintf("Error: %v", result.Error),
+			})
+		} else {
+			app.EmitEvent("ThreadStatus", ThreadStatus{
+				WorkerID: workerID,
+				Status:   "completed",
+				FilePath: job.path,
+				FileName: filepath.Base(job.path),
+				Attempt:  result.Attempts,
+				Message:  "Completed",
 			})
 		}
+
+		// Mark as idle after completing file
+		app.EmitEvent("ThreadStatus", ThreadStatus{
+			WorkerID: workerID,
+			Status:   "idle",
+			Message:  "Waiting for next file...",
+		})
+	}
+
+	if ctx.Err() != nil {
+		app.EmitEvent("ThreadStatus", ThreadStatus{
+			WorkerID: workerID,
+			Status:   "idle",
+			Message:  "Cancelled",
+		})
+		return
 	}
 
 	// Final idle status when no more work
@@ -433,3 +496,96 @@ func startUploadWorker(workerID int, workChan <-chan string, results chan<- File
 		Message:  "Finished",
 	})
 }
+
+// newUploadApi creates an Api whose client sends each request once, so that the
+// attempts, backoff and circuit breaker of uploadWithRetry own all upload retries
+func newUploadApi() (*Api, error) {
+	api, err := NewApi()
+	if err != nil {
+		return nil, err
+	}
+	if api.client, err = newHTTPClient(AppConfig.Proxy, 0); err != nil {
+		return nil, fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+	return api, nil
+}
+
+// uploadWithRetry uploads a job, retrying transient and auth errors with backoff up to
+// UploadMaxAttempts times. Every attempt waits while the batch circuit breaker is open.
+func uploadWithRetry(ctx context.Context, workerID int, job uploadJob, breaker *circuitBreaker, app AppInterface) FileUploadResult {
+	maxAttempts := max(AppConfig.UploadMaxAttempts, 1)
+	fileName := filepath.Base(job.path)
+	callback := func(event string, data any) {
+		app.EmitEvent(event, data)
+	}
+
+	var api *Api
+	for attempt := 1; ; attempt++ {
+		if until := breaker.pausedUntil(); !until.IsZero() {
+			app.EmitEvent("ThreadStatus", ThreadStatus{
+				WorkerID: workerID,
+				Status:   "paused",
+				FilePath: job.path,
+				FileName: fileName,
+				Attempt:  attempt,
+				Message:  fmt.Sprintf("Paused until %s after repeated failures", until.Format("15:04:05")),
+			})
+		}
+		probe, err := breaker.wait(ctx)
+		if err != nil {
+			return FileUploadResult{IsError: true, Error: err, Path: job.path, Attempts: attempt - 1}
+		}
+
+		if api == nil {
+			api, err = newUploadApi()
+			if err != nil {
+				// Missing or invalid credentials are a setup problem, not an outage
+				if probe {
+					breaker.release()
+				}
+				return FileUploadResult{IsError: true, Error: err, Path: job.path, Attempts: attempt}
+			}
+		}
+
+		result, err := uploadJobWithCallback(ctx, api, job, workerID, callback)
+		if err == nil {
+			breaker.success()
+			result.Attempts = attempt
+			return result
+		}
+		if ctx.Err() != nil {
+			if probe {
+				breaker.release()
+			}
+			return FileUploadResult{IsError: true, Error: err, Path: job.path, Attempts: attempt}
+		}
+
+		class := classifyError(err)
+		breaker.failure(class, err, probe)
+		if class == errorPermanent || attempt >= maxAttempts {
+			if attempt > 1 {
+				err = fmt.Errorf("failed after %d attempts: %w", attempt, err)
+			}
+			return FileUploadResult{IsError: true, Error: err, Path: job.path, Attempts: attempt}
+		}
+		if class == errorAuth {
+			// Start over with a fresh token
+			api = nil
+		}
+
+		delay := retryDelay(attempt + 1)
+		app.GetLogger().Warn(fmt.Sprintf("%s error uploading %s (attempt %d/%d), retrying in %s: %v",
+			class, job.path, attempt, maxAttempts, delay.Round(time.Second), err))
+		app.EmitEvent("ThreadStatus", ThreadStatus{
+			WorkerID: workerID,
+			Status:   "retrying",
+			FilePath: job.path,
+			FileName: fileName,
+			Attempt:  attempt,
+			Message:  fmt.Sprintf("Retrying in %s (attempt %d/%d): %v", delay.Round(time.Second), attempt, maxAttempts, err),
+		})
+		if err := sleepContext(ctx, delay); err != nil {
+			return FileUploadResult{IsError: true, Error: err, Path: job.path, Attempts: attempt}
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// uploadIndexFileName is the per-account file that remembers hashed and uploaded files
+const uploadIndexFileName = "upload_index.json"
+
+// uploadIndexSaveInterval is how many changes are buffered before the index is saved in the background
+const uploadIndexSaveInterval = 50
+
+// ReindexUploads makes uploads ignore the upload index and hash and check every file again.
+// The results are still written back to the index. Set by the CLI --reindex flag.
+var ReindexUploads bool
+
+// UploadIndexEntry is what the upload index knows about one local file
+type UploadIndexEntry struct {
+	Size      int64  `json:"size"`
+	ModTime   int64  `json:"modTime"` // Unix nanoseconds
+	Inode     uint64 `json:"inode,omitempty"`
+	SHA1      []byte `json:"sha1"`
+	MediaKey  string `json:"mediaKey,omitempty"` // Set once the file is known to be in the library
+	Stripped  string `json:"stripped,omitempty"` // Tag sets removed before hashing, see stripKey
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+// matches reports whether the entry still describes a file with the given stat data
+func (e UploadIndexEntry) matches(info os.FileInfo) bool {
+	return e.Size == info.Size() &&
+		e.ModTime == info.ModTime().UnixNano() &&
+		e.Inode == fileInode(info)
+}
+
+// UploadIndex maps local files, identified by path, size, mtime and inode,
+// to their SHA1 and resulting media key so unchanged files skip hashing and remote checks.
+type UploadIndex struct {
+	Files map[string]UploadIndexEntry `json:"files"` // Keyed by absolute path
+
+	mu     sync.Mutex
+	path   string
+	dirty  int
+	saving bool       // A background save is pending
+	saveMu sync.Mutex // Orders writes so an older snapshot never replaces a newer one
+}
+
+var (
+	uploadIndexMu      sync.Mutex
+	uploadIndex        *UploadIndex
+	uploadIndexAccount string
+)
+
+// NewUploadIndex creates or loads an upload index from the specified file path
+func NewUploadIndex(path string) (*UploadIndex, error) {
+	idx := &UploadIndex{
+		Files: make(map[string]UploadIndexEntry),
+		path:  path,
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload index: %w", err)
+	}
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, fmt.Errorf("failed to parse upload index: %w", err)
+	}
+	if idx.Files == nil {
+		idx.Files = make(map[string]UploadIndexEntry)
+	}
+	return idx, nil
+}
+
+// getUploadIndex returns the upload index of the selected account, or nil if it cannot be opened.
+// The index is an optimization only, so uploads proceed without it.
+func getUploadIndex() *UploadIndex {
+	uploadIndexMu.Lock()
+	defer uploadIndexMu.Unlock()
+
+	email := AppConfig.Selected
+	if uploadIndex != nil && uploadIndexAccount == email {
+		return uploadIndex
+	}
+	if uploadIndex != nil {
+		uploadIndex.Flush()
+	}
+
+	dir, err := accountDataDir(email)
+	if err != nil {
+		return nil
+	}
+	idx, err := NewUploadIndex(filepath.Join(dir, uploadIndexFileName))
+	if err != nil {
+		return nil
+	}
+
+	uploadIndex = idx
+	uploadIndexAccount = email
+	return idx
+}
+
+// FlushUploadIndex writes pending upload index changes to disk
+func FlushUploadIndex() error {
+	uploadIndexMu.Lock()
+	idx := uploadIndex
+	uploadIndexMu.Unlock()
+	if idx == nil {
+		return nil
+	}
+	return idx.Flush()
+}
+
+// indexKey normalizes a path for use as an index key
+func indexKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// Lookup returns the entry for path if its stat data and privacy filter are unchanged
+func (idx *UploadIndex) Lookup(path string, info os.FileInfo) (UploadIndexEntry, bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	entry, ok := idx.Files[indexKey(path)]
+	if !ok || !entry.matches(info) || entry.Stripped != stripKey(path) {
+		return UploadIndexEntry{}, false
+	}
+	return entry, true
+}
+
+// Put records the hash and, when known, the media key of a file
+func (idx *UploadIndex) Put(path string, info os.FileInfo, sha1 []byte, mediaKey string) {
+	idx.mu.Lock()
+	idx.Files[indexKey(path)] = UploadIndexEntry{
+		Size:      info.Size(),
+		ModTime:   info.ModTime().UnixNano(),
+		Inode:     fileInode(info),
+		SHA1:      sha1,
+		MediaKey:  mediaKey,
+		Stripped:  stripKey(path),
+		UpdatedAt: time.Now().Unix(),
+	}
+	idx.dirty++
+	save := idx.dirty >= uploadIndexSaveInterval && !idx.saving
+	if save {
+		idx.saving = true
+	}
+	idx.mu.Unlock()
+
+	if save {
+		// A single saver writes while hash workers go on calling Put
+		go func() {
+			idx.Flush()
+			idx.mu.Lock()
+			idx.saving = false
+			idx.mu.Unlock()
+		}()
+	}
+}
+
+// Remove forgets a file, e.g. after it was deleted from the host
+func (idx *UploadIndex) Remove(path string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if _, ok := idx.Files[indexKey(path)]; ok {
+		delete(idx.Files, indexKey(path))
+		idx.dirty++
+	}
+}
+
+// Flush writes the index to disk if it has unsaved changes. The entries are copied under
+// the lock and encoded and written outside it, so lookups and puts go on meanwhile.
+func (idx *UploadIndex) Flush() error {
+	idx.saveMu.Lock()
+	defer idx.saveMu.Unlock()
+
+	idx.mu.Lock()
+	dirty := idx.dirty
+	if dirty == 0 {
+		idx.mu.Unlock()
+		return nil
+	}
+	snapshot := struct {
+		Files map[string]UploadIndexEntry `json:"files"`
+	}{maps.Clone(idx.Files)}
+	idx.dirty = 0
+	idx.mu.Unlock()
+
+	err := idx.write(snapshot)
+	if err != nil {
+		// Keep the changes pending so the next flush retries them
+		idx.mu.Lock()
+		idx.dirty += dirty
+		idx.mu.Unlock()
+	}
+	return err
+}
+
+// write saves v as the index file
+func (idx *UploadIndex) write(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode upload index: %w", err)
+	}
+	// Write through a temp file so an interrupted save never truncates the index.
+	tmpPath := idx.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write upload index: %w", err)
+	}
+	if err := os.Rename(tmpPath, idx.path); err != nil {
+		return fmt.Errorf("failed to write upload index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package backend
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUploadIndex_LookupAndPersist(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "IMG_0001.JPG")
+	if err := os.WriteFile(file, []byte("photo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexPath := filepath.Join(dir, uploadIndexFileName)
+	idx, err := NewUploadIndex(indexPath)
+	if err != nil {
+		t.Fatalf("NewUploadIndex: %v", err)
+	}
+	idx.Put(file, info, []byte{1, 2, 3}, "media-key-123")
+	if err := idx.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	reloaded, err := NewUploadIndex(indexPath)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	entry, ok := reloaded.Lookup(file, info)
+	if !ok || entry.MediaKey != "media-key-123" || len(entry.SHA1) != 3 {
+		t.Fatalf("expected indexed entry, got %+v (ok=%v)", entry, ok)
+	}
+
+	// A changed modification time invalidates the entry
+	later := info.ModTime().Add(time.Second)
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatal(err)
+	}
+	changed, _ := os.Stat(file)
+	if _, ok := reloaded.Lookup(file, changed); ok {
+		t.Fatal("entry should not match a modified file")
+	}
+}
+
+func TestUploadIndex_ConcurrentPuts(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "IMG_0001.JPG")
+	if err := os.WriteFile(file, []byte("photo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexPath := filepath.Join(dir, uploadIndexFileName)
+	idx, err := NewUploadIndex(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Enough puts from several hash workers to start background saves along the way
+	const workers, perWorker = 8, 2 * uploadIndexSaveInterval
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perWorker {
+				idx.Put(fmt.Sprintf("%s-%d-%d", file, w, i), info, []byte{byte(w), byte(i)}, "")
+			}
+		}()
+	}
+	wg.Wait()
+	if err := idx.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	reloaded, err := NewUploadIndex(indexPath)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if len(reloaded.Files) != workers*perWorker {
+		t.Fatalf("%d entries saved, want %d", len(reloaded.Files), workers*perWorker)
+	}
+}
@@ -0,0 +1,226 @@
+package backend
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"sync"
+)
+
+// PlanFile is a single file in an upload plan
+type PlanFile struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	MediaKey string `json:"mediaKey,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Quality and device the file would be committed with
+	Profile UploadProfile `json:"profile"`
+}
+
+// UploadPlan describes what an upload would do without uploading or deleting anything
+type UploadPlan struct {
+	ToUpload         []PlanFile    `json:"toUpload"`
+	AlreadyInLibrary []PlanFile    `json:"alreadyInLibrary"`
+	Skipped          []SkippedFile `json:"skipped"`
+	Failed           []PlanFile    `json:"failed"`
+	TotalBytes       int64         `json:"totalBytes"`  // All supported files
+	UploadBytes      int64         `json:"uploadBytes"` // Files in ToUpload
+	Checked          bool          `json:"checked"`     // Files were hashed and checked against the library
+	DeleteFromHost   bool          `json:"deleteFromHost"`
+	PostUploadAction string        `json:"postUploadAction"` // What happens to local files once in the library
+	Pairing          UploadPairing `json:"pairing"`
+	StripMetadata    []string      `json:"stripMetadata,omitempty"` // Tag sets removed from JPEG, PNG and WebP files
+}
+
+// BuildUploadPlan scans paths the same way an upload does and reports the result.
+// With check set, files are also hashed and looked up in the library; hashes are
+// remembered in the upload index so the real upload does not hash them again.
+// progress, if not nil, is called after each file is hashed.
+func BuildUploadPlan(ctx context.Context, paths []string, check bool, progress func(done, total int)) (*UploadPlan, error) {
+	archives := newArchiveSet()
+	defer archives.Close()
+	supported, skipped, pairing, err := scanUploadFiles(paths, archives)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &UploadPlan{
+		ToUpload:         []PlanFile{},
+		AlreadyInLibrary: []PlanFile{},
+		Skipped:          skipped,
+		Failed:           []PlanFile{},
+		DeleteFromHost:   postUploadAction() == PostUploadDelete || postUploadAction() == PostUploadVerifyDelete,
+		PostUploadAction: postUploadAction(),
+		StripMetadata:    normalizeStripTagSets(AppConfig.UploadStripMetadata),
+		Pairing:          pairing,
+	}
+	if plan.Skipped == nil {
+		plan.Skipped = []SkippedFile{}
+	}
+
+	files := make([]PlanFile, 0, len(supported))
+	jobs := make([]uploadJob, 0, len(supported))
+	for _, job := range supported {
+		if job.onDisk() {
+			info, err := os.Stat(job.path)
+			if err != nil {
+				plan.Failed = append(plan.Failed, PlanFile{Path: job.path, Error: err.Error()})
+				continue
+			}
+			job.info = info
+		}
+		files = append(files, PlanFile{Path: job.path, Size: job.info.Size(), Profile: uploadProfile(job.path, job.info.Size())})
+		jobs = append(jobs, job)
+		plan.TotalBytes += job.info.Size()
+	}
+
+	if !check || AppConfig.ForceUpload {
+		for _, file := range files {
+			plan.addToUpload(file)
+		}
+		return plan, nil
+	}
+
+	hashes, err := hashPlanFiles(ctx, jobs, progress)
+	if err != nil {
+		return nil, err
+	}
+
+	var lookup [][]byte
+	for i := range files {
+		if hashes[i].err == nil && hashes[i].mediaKey == "" {
+			lookup = append(lookup, hashes[i].sha1)
+		}
+	}
+
+	found := map[string]string{}
+	var unchecked map[string]error
+	if len(lookup) > 0 {
+		api, err := NewApi()
+		if err != nil {
+			return nil, err
+		}
+		found, err = api.FindRemoteMediaByHashes(ctx, lookup)
+		if err != nil {
+			lookupErr, ok := err.(*HashLookupError)
+			if !ok {
+				return nil, err
+			}
+			unchecked = lookupErr.Failed
+		}
+	}
+
+	index := getUploadIndex()
+	for i, file := range files {
+		hash := hashes[i]
+		if hash.err != nil {
+			file.Error = hash.err.Error()
+			plan.Failed = append(plan.Failed, file)
+			continue
+		}
+		hashKey := base64.StdEncoding.EncodeToString(hash.sha1)
+		file.MediaKey = hash.mediaKey
+		if file.MediaKey == "" {
+			file.MediaKey = found[hashKey]
+			if file.MediaKey != "" && index != nil {
+				index.Put(file.Path, jobs[i].info, hash.sha1, file.MediaKey)
+			}
+		}
+		if file.MediaKey != "" {
+			plan.AlreadyInLibrary = append(plan.AlreadyInLibrary, file)
+			continue
+		}
+		if lookupErr, ok := unchecked[hashKey]; ok {
+			file.Error = lookupErr.Error()
+			plan.Failed = append(plan.Failed, file)
+			continue
+		}
+		plan.addToUpload(file)
+	}
+	plan.Checked = true
+
+	return plan, FlushUploadIndex()
+}
+
+// SkipDone moves files an upload journal records as done from ToUpload to Skipped
+func (p *UploadPlan) SkipDone(done []string) {
+	doneSet := make(map[string]bool, len(done))
+	for _, path := range done {
+		doneSet[path] = true
+	}
+	remaining := p.ToUpload[:0]
+	p.UploadBytes = 0
+	for _, file := range p.ToUpload {
+		if doneSet[file.Path] {
+			p.Skipped = append(p.Skipped, SkippedFile{Path: file.Path, Reason: SkipJournalDone})
+			continue
+		}
+		remaining = append(remaining, file)
+		p.UploadBytes += file.Size
+	}
+	p.ToUpload = remaining
+}
+
+func (p *UploadPlan) addToUpload(file PlanFile) {
+	p.ToUpload = append(p.ToUpload, file)
+	p.UploadBytes += file.Size
+}
+
+type planHash struct {
+	sha1     []byte
+	mediaKey string // Known from the upload index
+	err      error
+}
+
+// hashPlanFiles hashes files with HashThreads workers, reusing and filling the upload index
+func hashPlanFiles(ctx context.Context, files []uploadJob, progress func(done, total int)) ([]planHash, error) {
+	hashes := make([]planHash, len(files))
+	index := getUploadIndex()
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+
+	for range min(max(AppConfig.HashThreads, 1), max(len(files), 1)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				path, info := files[i].path, files[i].info
+				if index != nil && !ReindexUploads {
+					if entry, ok := index.Lookup(path, info); ok && len(entry.SHA1) > 0 {
+						hashes[i] = planHash{sha1: entry.SHA1, mediaKey: entry.MediaKey}
+					}
+				}
+				if hashes[i].sha1 == nil {
+					sha1, err := files[i].hash(ctx)
+					hashes[i] = planHash{sha1: sha1, err: err}
+					if err == nil && index != nil {
+						index.Put(path, info, sha1, "")
+					}
+				}
+
+				mu.Lock()
+				done++
+				if progress != nil {
+					progress(done, len(files))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+FEED:
+	for i := range files {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break FEED
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return hashes, ctx.Err()
+}
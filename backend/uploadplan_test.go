@@ -0,0 +1,42 @@
+package backend
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuildUploadPlan_WithoutCheck(t *testing.T) {
+	saved := AppConfig
+	defer func() { AppConfig = saved }()
+	AppConfig = Config{Recursive: true, DeleteFromHost: true}
+
+	dir := t.TempDir()
+	write := func(name string, size int) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.jpg", 10)
+	write("sub/b.mp4", 20)
+	write("notes.txt", 5)
+
+	plan, err := BuildUploadPlan(context.Background(), []string{dir}, false, nil)
+	if err != nil {
+		t.Fatalf("BuildUploadPlan: %v", err)
+	}
+	if len(plan.ToUpload) != 2 || plan.UploadBytes != 30 || plan.TotalBytes != 30 {
+		t.Fatalf("unexpected upload set: %+v", plan)
+	}
+	if len(plan.Skipped) != 1 || filepath.Base(plan.Skipped[0].Path) != "notes.txt" || plan.Skipped[0].Reason != SkipUnsupported {
+		t.Fatalf("expected notes.txt skipped by extension, got %v", plan.Skipped)
+	}
+	if plan.Checked || !plan.DeleteFromHost || len(plan.AlreadyInLibrary) != 0 {
+		t.Fatalf("unexpected plan flags: %+v", plan)
+	}
+}
@@ -0,0 +1,258 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// queueSnapshotLimit is the most queued paths listed in an "uploadQueue" event
+const queueSnapshotLimit = 100
+
+// ErrUploadCancelled is the error of a file cancelled on its own with CancelFile
+var ErrUploadCancelled = errors.New("upload cancelled")
+
+// UploadQueueState is emitted as "uploadQueue" when the upload queue changes.
+// Queued lists the first files waiting for an upload worker, in upload order.
+type UploadQueueState struct {
+	Paused      bool
+	Active      []string // Files being uploaded
+	Queued      []string
+	QueuedTotal int
+}
+
+// uploadBatch is the shared state of a running upload: paths added while a scan was
+// running, the ordered queue of checked files waiting for an upload worker, and the
+// files being uploaded. The batch ends once nothing is scanning, queued or uploading.
+type uploadBatch struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	journal   *UploadJournal
+	archives  *archiveSet          // Archives being uploaded from, closed once the batch is done
+	scan      func(paths []string) // Starts scanning paths; set by run
+	roots     []string             // Added while a scan was running; scanned next
+	scanning  bool
+	queue     []uploadJob
+	dropped   []uploadJob // Cancelled while queued; handed to a worker to report
+	active    map[string]context.CancelCauseFunc
+	seen      map[string]bool // Files scanned so far, so a path added twice uploads once
+	cancelled map[string]bool // Cancelled before reaching the queue
+	paused    bool
+	closed    bool
+	dirty     bool // The queue changed since the last snapshot
+}
+
+func newUploadBatch(journal *UploadJournal) *uploadBatch {
+	b := &uploadBatch{
+		journal:   journal,
+		archives:  newArchiveSet(),
+		active:    make(map[string]context.CancelCauseFunc),
+		seen:      make(map[string]bool),
+		cancelled: make(map[string]bool),
+		scanning:  true, // run starts scanning the first paths
+	}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// add scans more paths as part of the batch. It returns false once the batch has ended
+// or was cancelled, leaving the paths to the caller.
+func (b *uploadBatch) add(paths []string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false
+	}
+	b.journal.addRoots(paths)
+	if b.scanning {
+		b.roots = append(b.roots, paths...)
+		return true
+	}
+	// Started under the lock so the batch cannot end, or its workers exit, before the scan starts
+	b.scanning = true
+	b.scan(paths)
+	return true
+}
+
+// nextRoots returns the paths added during the last scan, or nil once scanning is done.
+// complete says whether every scan so far finished without error.
+func (b *uploadBatch) nextRoots(complete bool) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.roots) > 0 {
+		roots := b.roots
+		b.roots = nil
+		return roots
+	}
+	if complete {
+		// Written under the lock so paths added right after are recorded as a new scan
+		b.journal.scanned()
+	}
+	b.scanning = false
+	b.cond.Broadcast()
+	return nil
+}
+
+// track reports whether path is new to the batch
+func (b *uploadBatch) track(path string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.seen[path] {
+		return false
+	}
+	b.seen[path] = true
+	return true
+}
+
+// push queues a checked file for upload. It returns false if the file was cancelled.
+func (b *uploadBatch) push(job uploadJob) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cancelled[job.path] {
+		delete(b.cancelled, job.path)
+		return false
+	}
+	b.queue = append(b.queue, job)
+	b.dirty = true
+	b.cond.Signal()
+	return true
+}
+
+// next blocks until a file is ready for an upload worker. cancelled is set for a file that
+// was cancelled while queued, which the worker only reports. ok is false once the batch
+// has ended or ctx is done. While paused only cancelled files are handed out.
+func (b *uploadBatch) next(ctx context.Context) (job uploadJob, cancelled, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for {
+		if ctx.Err() != nil {
+			b.closed = true
+			b.cond.Broadcast()
+			return uploadJob{}, false, false
+		}
+		if len(b.dropped) > 0 {
+			job = b.dropped[0]
+			b.dropped = b.dropped[1:]
+			return job, true, true
+		}
+		if !b.paused && len(b.queue) > 0 {
+			job = b.queue[0]
+			b.queue = b.queue[1:]
+			b.dirty = true
+			return job, false, true
+		}
+		if b.closed || len(b.queue) == 0 && !b.scanning && len(b.active) == 0 {
+			b.closed = true
+			b.cond.Broadcast()
+			return uploadJob{}, false, false
+		}
+		b.cond.Wait()
+	}
+}
+
+// start records a file an upload worker is working on
+func (b *uploadBatch) start(path string, cancel context.CancelCauseFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.active[path] = cancel
+	b.dirty = true
+}
+
+// done records that an upload worker finished a file
+func (b *uploadBatch) done(path string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.active, path)
+	b.dirty = true
+	b.cond.Broadcast()
+}
+
+// cancelFile cancels one file wherever it is in the batch, reporting whether it was
+// uploading or queued. Files not scanned or checked yet are dropped when they reach the queue.
+func (b *uploadBatch) cancelFile(path string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if cancel, ok := b.active[path]; ok {
+		cancel(ErrUploadCancelled)
+		return true
+	}
+	for i, job := range b.queue {
+		if job.path == path {
+			b.queue = append(b.queue[:i], b.queue[i+1:]...)
+			b.dropped = append(b.dropped, job)
+			b.dirty = true
+			b.cond.Signal()
+			return true
+		}
+	}
+	if !b.closed {
+		b.cancelled[path] = true
+	}
+	return false
+}
+
+// move puts a queued file at index in the upload order, clamped to the queue
+func (b *uploadBatch) move(path string, index int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	from := -1
+	for i, job := range b.queue {
+		if job.path == path {
+			from = i
+			break
+		}
+	}
+	if from < 0 {
+		return false
+	}
+	job := b.queue[from]
+	b.queue = append(b.queue[:from], b.queue[from+1:]...)
+	index = min(max(index, 0), len(b.queue))
+	b.queue = append(b.queue[:index], append([]uploadJob{job}, b.queue[index:]...)...)
+	b.dirty = true
+	return true
+}
+
+// setPaused holds upload workers once their current file is done, or lets them go on
+func (b *uploadBatch) setPaused(paused bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.paused = paused
+	b.dirty = true
+	b.cond.Broadcast()
+}
+
+func (b *uploadBatch) isPaused() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.paused
+}
+
+// wake ends the batch once its context is cancelled: blocked workers notice the
+// cancellation and add rejects further paths
+func (b *uploadBatch) wake() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.cond.Broadcast()
+}
+
+// snapshot returns the queue state if it changed since the last call, or if force is set
+func (b *uploadBatch) snapshot(force bool) (UploadQueueState, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.dirty && !force {
+		return UploadQueueState{}, false
+	}
+	b.dirty = false
+	state := UploadQueueState{Paused: b.paused, QueuedTotal: len(b.queue)}
+	for path := range b.active {
+		state.Active = append(state.Active, path)
+	}
+	slices.Sort(state.Active)
+	for _, job := range b.queue[:min(len(b.queue), queueSnapshotLimit)] {
+		state.Queued = append(state.Queued, job.path)
+	}
+	return state, true
+}
@@ -0,0 +1,104 @@
+package backend
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestUploadBatch_QueueOrderPauseAndCancel(t *testing.T) {
+	ctx := context.Background()
+	b := newUploadBatch(nil)
+	for _, path := range []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg"} {
+		b.push(uploadJob{path: path})
+	}
+
+	if !b.move("c.jpg", 0) || b.move("missing.jpg", 0) {
+		t.Fatal("move should only find queued files")
+	}
+	b.cancelFile("b.jpg")
+	b.cancelFile("e.jpg") // Not queued yet, so dropped once it arrives
+	if b.push(uploadJob{path: "e.jpg"}) {
+		t.Fatal("a file cancelled before reaching the queue should not be queued")
+	}
+
+	// The file cancelled while queued is handed out first so it can be reported
+	job, cancelled, ok := b.next(ctx)
+	if !ok || !cancelled || job.path != "b.jpg" {
+		t.Fatalf("next = %q cancelled=%v ok=%v, want the cancelled b.jpg", job.path, cancelled, ok)
+	}
+
+	var order []string
+	job, _, _ = b.next(ctx)
+	order = append(order, job.path)
+
+	// Paused workers hold until resumed
+	b.setPaused(true)
+	got := make(chan string)
+	go func() {
+		job, _, _ := b.next(ctx)
+		got <- job.path
+	}()
+	select {
+	case path := <-got:
+		t.Fatalf("next returned %q while paused", path)
+	case <-time.After(20 * time.Millisecond):
+	}
+	b.setPaused(false)
+	order = append(order, <-got)
+	job, _, _ = b.next(ctx)
+	order = append(order, job.path)
+
+	if want := []string{"c.jpg", "a.jpg", "d.jpg"}; !slices.Equal(order, want) {
+		t.Fatalf("upload order = %v, want %v", order, want)
+	}
+
+	// The batch only ends once nothing is scanning, queued or uploading
+	var scanned []string
+	b.scan = func(paths []string) { scanned = append(scanned, paths...) }
+	if !b.add([]string{"/more"}) || b.nextRoots(true) == nil {
+		t.Fatal("paths added during a scan should be scanned next")
+	}
+	if b.nextRoots(true) != nil {
+		t.Fatal("no more roots were added")
+	}
+
+	b.start("d.jpg", func(error) {})
+	if !b.add([]string{"/later"}) || !slices.Equal(scanned, []string{"/later"}) {
+		t.Fatalf("paths added while a file is uploading should start a scan, scanned %v", scanned)
+	}
+	b.nextRoots(true)
+	b.done("d.jpg")
+	if _, _, ok := b.next(ctx); ok {
+		t.Fatal("next should report the end of the batch")
+	}
+	if b.add([]string{"/late"}) {
+		t.Fatal("an ended batch should not accept paths")
+	}
+}
+
+func TestUploadBatch_CancelRejectsAdd(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := newUploadBatch(nil)
+	var scanned []string
+	b.scan = func(paths []string) { scanned = append(scanned, paths...) }
+	b.nextRoots(true)
+	b.start("a.jpg", func(error) {}) // Still uploading, so the batch has not ended by itself
+
+	cancel()
+	if _, _, ok := b.next(ctx); ok {
+		t.Fatal("next should stop once the context is cancelled")
+	}
+	if b.add([]string{"/after-cancel"}) || len(scanned) != 0 {
+		t.Fatalf("a cancelled batch accepted paths, scanned %v", scanned)
+	}
+
+	// Cancelling while every worker is busy closes the batch too
+	b = newUploadBatch(nil)
+	b.scan = func(paths []string) { scanned = append(scanned, paths...) }
+	b.wake()
+	if b.add([]string{"/after-wake"}) || len(scanned) != 0 {
+		t.Fatalf("a cancelled batch accepted paths, scanned %v", scanned)
+	}
+}
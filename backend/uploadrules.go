@@ -0,0 +1,204 @@
+package backend
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Upload qualities, committed as the quality field of an upload
+const (
+	QualityOriginal = "original" // Full resolution
+	QualitySaver    = "saver"    // Storage saver, recompressed by Google Photos
+)
+
+// Media types upload rules can match
+const (
+	MediaPhoto = "photo" // Any photo, RAW included
+	MediaVideo = "video"
+	MediaRaw   = "raw" // Camera RAW photos
+)
+
+// UploadRule picks the quality or device of the files it matches. Conditions left
+// empty match every file; the first matching rule wins.
+type UploadRule struct {
+	Name       string   `json:"name,omitempty" koanf:"name"`
+	Extensions []string `json:"extensions,omitempty" koanf:"extensions"` // Such as ".nef" or "nef", case-insensitive
+	MediaType  string   `json:"mediaType,omitempty" koanf:"media_type"`  // MediaPhoto, MediaVideo or MediaRaw
+	Path       string   `json:"path,omitempty" koanf:"path"`             // Glob matched against the absolute path, see matchGlob
+	MinSize    string   `json:"minSize,omitempty" koanf:"min_size"`      // Such as "1GB"
+	MaxSize    string   `json:"maxSize,omitempty" koanf:"max_size"`
+	Quality    string   `json:"quality,omitempty" koanf:"quality"` // QualityOriginal or QualitySaver; empty keeps the global setting
+	Device     string   `json:"device,omitempty" koanf:"device"`   // Device profile or client model, such as "Pixel XL"; empty keeps the default for the quality
+}
+
+// UploadProfile is the quality and client device a file is committed with
+type UploadProfile struct {
+	Quality string `json:"quality"`
+	Device  string `json:"device"`         // Device profile or client model
+	Rule    string `json:"rule,omitempty"` // Name of the rule that picked the profile; empty for the global settings
+}
+
+// String describes the profile, as in "saver on Pixel 2 (screenshots)"
+func (p UploadProfile) String() string {
+	s := p.Quality + " on " + p.Device
+	if p.Rule != "" {
+		s += " (" + p.Rule + ")"
+	}
+	return s
+}
+
+// qualityValue returns the quality field committed for the profile
+func (p UploadProfile) qualityValue() int64 {
+	if p.Quality == QualitySaver {
+		return 1
+	}
+	return 3
+}
+
+// mediaTypeOf returns MediaPhoto or MediaVideo by extension, or "" for other files
+func mediaTypeOf(name string) string {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+	switch {
+	case slices.Contains(googlePhotosPhotoFormats, ext):
+		return MediaPhoto
+	case slices.Contains(googlePhotosVideoFormats, ext):
+		return MediaVideo
+	default:
+		return ""
+	}
+}
+
+// defaultDevice returns the device a quality is committed from without a rule naming one.
+// Saver and UseQuota uploads keep the profile of the selected account or --device and
+// only take the model of pixel-2 or pixel-8; other uploads use that profile unchanged.
+func defaultDevice(quality string) string {
+	switch {
+	case AppConfig.UseQuota:
+		return deviceModel(DeviceProfilePixel8)
+	case quality == QualitySaver:
+		return deviceModel(DeviceProfilePixel2)
+	default:
+		return AccountDeviceProfileName(AppConfig.Selected)
+	}
+}
+
+// deviceModel returns the client model of a device profile
+func deviceModel(name string) string {
+	profile, _ := LookupDeviceProfile(name)
+	return profile.Model
+}
+
+// globalUploadProfile returns the profile set by Saver and UseQuota
+func globalUploadProfile() UploadProfile {
+	quality := QualityOriginal
+	if AppConfig.Saver {
+		quality = QualitySaver
+	}
+	return UploadProfile{Quality: quality, Device: defaultDevice(quality)}
+}
+
+// ValidateUploadRules checks the conditions and choices of upload rules
+func ValidateUploadRules(rules []UploadRule) error {
+	for i, rule := range rules {
+		if err := rule.validate(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate checks a rule at the given position of UploadRules
+func (r UploadRule) validate(index int) error {
+	name := r.label(index)
+	switch r.MediaType {
+	case "", MediaPhoto, MediaVideo, MediaRaw:
+	default:
+		return fmt.Errorf("%s: unknown media type %q: use photo, video or raw", name, r.MediaType)
+	}
+	switch r.Quality {
+	case "", QualityOriginal, QualitySaver:
+	default:
+		return fmt.Errorf("%s: unknown quality %q: use original or saver", name, r.Quality)
+	}
+	if r.Quality == "" && r.Device == "" {
+		return fmt.Errorf("%s sets neither a quality nor a device", name)
+	}
+	for _, size := range []string{r.MinSize, r.MaxSize} {
+		if _, err := ParseByteSize(size); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if _, err := path.Match(strings.ReplaceAll(r.Path, "**", "*"), ""); err != nil {
+		return fmt.Errorf("%s: invalid path pattern %q: %w", name, r.Path, err)
+	}
+	return nil
+}
+
+// label names a rule in errors and reports: its name, else its position
+func (r UploadRule) label(index int) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return fmt.Sprintf("rule %d", index+1)
+}
+
+// matches reports whether a file with the given path and size meets every condition of the rule
+func (r UploadRule) matches(filePath string, size int64) bool {
+	if len(r.Extensions) > 0 {
+		ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(filePath)), ".")
+		if !slices.ContainsFunc(r.Extensions, func(e string) bool {
+			return strings.TrimPrefix(strings.ToLower(e), ".") == ext
+		}) {
+			return false
+		}
+	}
+	switch r.MediaType {
+	case MediaRaw:
+		if !slices.Contains(rawExtensions, strings.ToLower(filepath.Ext(filePath))) {
+			return false
+		}
+	case MediaPhoto, MediaVideo:
+		if mediaTypeOf(filePath) != r.MediaType {
+			return false
+		}
+	}
+	if minSize, _ := ParseByteSize(r.MinSize); minSize > 0 && size < minSize {
+		return false
+	}
+	if maxSize, _ := ParseByteSize(r.MaxSize); maxSize > 0 && size > maxSize {
+		return false
+	}
+	if r.Path != "" {
+		abs, err := filepath.Abs(filePath)
+		if err != nil {
+			abs = filePath
+		}
+		if !matchGlob(r.Path, strings.TrimPrefix(filepath.ToSlash(abs), "/")) {
+			return false
+		}
+	}
+	return true
+}
+
+// uploadProfile picks the profile of a file from UploadRules, falling back to the global settings
+func uploadProfile(filePath string, size int64) UploadProfile {
+	profile := globalUploadProfile()
+	for i, rule := range AppConfig.UploadRules {
+		if !rule.matches(filePath, size) {
+			continue
+		}
+		if rule.Quality != "" {
+			profile.Quality = rule.Quality
+		}
+		profile.Device = rule.Device
+		if profile.Device == "" {
+			profile.Device = defaultDevice(profile.Quality)
+		}
+		profile.Rule = rule.label(i)
+		break
+	}
+	return profile
+}
@@ -0,0 +1,78 @@
+package backend
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestUploadProfile(t *testing.T) {
+	saved, savedPath := AppConfig, ConfigPath
+	defer func() { AppConfig, ConfigPath = saved, savedPath }()
+
+	rules := []UploadRule{
+		{Name: "big videos", MediaType: MediaVideo, MinSize: "1GB", Quality: QualitySaver},
+		{MediaType: MediaRaw, Device: "Pixel XL"},
+		{Path: "/**/screenshots/**", Quality: QualitySaver},
+		{Extensions: []string{".PNG", "gif"}, MaxSize: "1KB", Device: "Pixel 5"},
+	}
+	if err := ValidateUploadRules(rules); err != nil {
+		t.Fatal(err)
+	}
+	AppConfig = Config{UploadRules: rules}
+
+	tests := []struct {
+		path string
+		size int64
+		want UploadProfile
+	}{
+		{"/v/clip.mp4", 2 << 30, UploadProfile{QualitySaver, "Pixel 2", "big videos"}},
+		{"/v/clip.mp4", 1 << 20, UploadProfile{QualityOriginal, "pixel-xl", ""}},
+		{"/p/IMG_1.NEF", 1 << 20, UploadProfile{QualityOriginal, "Pixel XL", "rule 2"}},
+		{"/home/me/Screenshots/a.jpg", 100, UploadProfile{QualityOriginal, "pixel-xl", ""}},
+		{"/home/me/screenshots/2024/a.jpg", 100, UploadProfile{QualitySaver, "Pixel 2", "rule 3"}},
+		{"/p/icon.png", 100, UploadProfile{QualityOriginal, "Pixel 5", "rule 4"}},
+		{"/p/big.png", 4096, UploadProfile{QualityOriginal, "pixel-xl", ""}},
+	}
+	for _, tt := range tests {
+		if got := uploadProfile(filepath.FromSlash(tt.path), tt.size); got != tt.want {
+			t.Errorf("uploadProfile(%s, %d) = %+v, want %+v", tt.path, tt.size, got, tt.want)
+		}
+	}
+
+	// Saver and UseQuota set the profile of unmatched files and the default device of rules
+	AppConfig.Saver = true
+	if got := uploadProfile("/p/a.jpg", 100); got != (UploadProfile{QualitySaver, "Pixel 2", ""}) {
+		t.Errorf("saver: %+v", got)
+	}
+	AppConfig.UseQuota = true
+	if got := uploadProfile("/p/a.nef", 100); got != (UploadProfile{QualitySaver, "Pixel XL", "rule 2"}) {
+		t.Errorf("quota RAW: %+v", got)
+	}
+	if got := uploadProfile("/v/clip.mp4", 2<<30); got.Device != "Pixel 8" {
+		t.Errorf("quota video: %+v", got)
+	}
+
+	for _, rule := range []UploadRule{
+		{Quality: "lossless"},
+		{MediaType: "audio", Quality: QualitySaver},
+		{MinSize: "lots", Quality: QualitySaver},
+		{Path: "[", Quality: QualitySaver},
+		{MediaType: MediaVideo},
+	} {
+		if err := ValidateUploadRules([]UploadRule{rule}); err == nil {
+			t.Errorf("ValidateUploadRules(%+v) = nil", rule)
+		}
+	}
+
+	// Rules survive a round trip through the config file; invalid ones are dropped on load
+	ConfigPath = filepath.Join(t.TempDir(), "config.yaml")
+	AppConfig = DefaultConfig
+	AppConfig.UploadRules = append(rules, UploadRule{Quality: "lossless"})
+	if err := saveAppConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadAppConfig().UploadRules; fmt.Sprintf("%+v", got) != fmt.Sprintf("%+v", rules) {
+		t.Errorf("loaded rules = %+v, want %+v", got, rules)
+	}
+}
@@ -0,0 +1,437 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Reasons reported for paths the walker could not or would not enter
+const (
+	SkipSymlink     = "symlink"
+	SkipVisited     = "already scanned (symlink loop or duplicate)"
+	SkipUnreadable  = "unreadable"
+	SkipJournalDone = "already uploaded (journal)"
+)
+
+// uploadWalker streams the files of an upload as it finds them. Unreadable entries,
+// skipped symlinks and directories seen before are reported through skip instead of
+// aborting the scan. Entries are visited depth-first in os.ReadDir order, which is
+// sorted by name, so the same tree always yields the same order. Archives are read
+// as directories: given directly or found in a recursive scan, all of their files
+// are emitted as archive.zip!/name jobs that read the entry in place. Live Photo and
+// RAW+JPEG pairs are found among the files of each folder before they are emitted.
+type uploadWalker struct {
+	rules        ScanRules
+	recursive    bool
+	skipSymlinks bool
+	emit         func(job uploadJob) bool // Returns false to stop the walk
+	skip         func(SkippedFile)
+	visited      map[string]bool // Directory identities, see dirIdentity
+	archives     *archiveSet     // Must stay open until the emitted jobs are uploaded
+	pairing      UploadPairing
+	archiveDir   string // Absolute PostUploadArchiveDir, left out of scans
+}
+
+// newUploadWalker creates a walker using the configured scan rules
+func newUploadWalker(emit func(job uploadJob) bool, skip func(SkippedFile), archives *archiveSet) (*uploadWalker, error) {
+	rules, err := scanRulesFromConfig()
+	if err != nil {
+		return nil, err
+	}
+	archiveDir := ""
+	if postUploadAction() == PostUploadArchive && AppConfig.PostUploadArchiveDir != "" {
+		archiveDir, _ = filepath.Abs(AppConfig.PostUploadArchiveDir)
+	}
+	return &uploadWalker{
+		rules:        rules,
+		recursive:    AppConfig.Recursive,
+		skipSymlinks: AppConfig.UploadSkipSymlinks,
+		emit:         emit,
+		skip:         skip,
+		visited:      make(map[string]bool),
+		archives:     archives,
+		pairing:      pairingFromConfig(),
+		archiveDir:   archiveDir,
+	}, nil
+}
+
+// walk scans each path in turn and returns ctx.Err() if cancelled
+func (w *uploadWalker) walk(ctx context.Context, paths []string) error {
+	pairSkips := w.pairFiles(paths)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			// Resumed and retried uploads list archive entries by their archive.zip!/name path
+			if archivePath, name, ok := splitArchivePath(path); ok {
+				if !w.addArchiveFile(archivePath, name) {
+					return ctx.Err()
+				}
+				continue
+			}
+			w.unreadable(path, err)
+			continue
+		}
+
+		if info.IsDir() {
+			if w.enterDir(path, info) && !w.walkDir(ctx, path, path, nil) {
+				return ctx.Err()
+			}
+		} else if isArchive(path) {
+			if w.enterDir(path, info) && !w.walkArchive(ctx, path, "") {
+				return ctx.Err()
+			}
+		} else if reason := pairSkips[path]; reason != "" {
+			w.skip(SkippedFile{Path: path, Reason: reason})
+		} else if !w.addFile(path, filepath.Base(path), fs.FileInfoToDirEntry(info), info) {
+			return ctx.Err()
+		}
+	}
+	return ctx.Err()
+}
+
+// pairFiles pairs the files given directly, such as the output of find, returning
+// the ones the pairing policies leave out
+func (w *uploadWalker) pairFiles(paths []string) map[string]string {
+	var accepted []string
+	for _, path := range pairCandidates(paths) {
+		info, err := os.Stat(path)
+		if err == nil && info.Mode().IsRegular() && w.rules.checkFile(filepath.Base(path), fs.FileInfoToDirEntry(info), info) == "" {
+			accepted = append(accepted, path)
+		}
+	}
+	return w.pairing.pair(accepted)
+}
+
+// pairDir pairs the files of a directory that the rules accept, by name
+func (w *uploadWalker) pairDir(root, dir string, entries []fs.DirEntry, ignores []*ignoreFile) map[string]string {
+	byName := map[string]fs.DirEntry{}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			byName[entry.Name()] = entry
+			names = append(names, entry.Name())
+		}
+	}
+
+	var accepted []string
+	for _, name := range pairCandidates(names) {
+		entry := byName[name]
+		fullPath := filepath.Join(dir, name)
+		if entry.Type()&fs.ModeSymlink != 0 && w.skipSymlinks || ignored(ignores, fullPath, false) {
+			continue
+		}
+		info, err := os.Stat(fullPath)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		rel, err := filepath.Rel(root, fullPath)
+		if err == nil && w.rules.checkFile(filepath.ToSlash(rel), fs.FileInfoToDirEntry(namedFileInfo{info, name}), info) == "" {
+			accepted = append(accepted, name)
+		}
+	}
+	return w.pairing.pair(accepted)
+}
+
+// walkDir scans dir, matching rules against paths relative to root.
+// ignores holds the .gotohpignore files of the parent directories.
+// It returns false once the walk should stop.
+func (w *uploadWalker) walkDir(ctx context.Context, root, dir string, ignores []*ignoreFile) bool {
+	ignore, err := loadIgnoreFile(dir)
+	if err != nil {
+		w.unreadable(filepath.Join(dir, ignoreFileName), err)
+	} else if ignore != nil {
+		ignores = append(ignores[:len(ignores):len(ignores)], ignore)
+	}
+
+	// ReadDir returns the entries it read before an error, so those are still scanned
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		w.unreadable(dir, err)
+	}
+	pairSkips := w.pairDir(root, dir, entries, ignores)
+
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return false
+		}
+
+		fullPath := filepath.Join(dir, entry.Name())
+		rel, err := filepath.Rel(root, fullPath)
+		if err != nil {
+			w.unreadable(fullPath, err)
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		// Resolve symlinks to what they point at, or leave them out
+		var info os.FileInfo
+		if entry.Type()&fs.ModeSymlink != 0 {
+			if w.skipSymlinks {
+				w.skip(SkippedFile{Path: fullPath, Reason: SkipSymlink})
+				continue
+			}
+			if info, err = os.Stat(fullPath); err != nil {
+				w.unreadable(fullPath, err)
+				continue
+			}
+			entry = fs.FileInfoToDirEntry(namedFileInfo{info, entry.Name()})
+		}
+
+		if entry.IsDir() {
+			if !w.recursive {
+				continue
+			}
+			if reason := w.rules.checkDir(rel, entry); reason != "" {
+				w.skip(SkippedFile{Path: fullPath, Reason: reason})
+				continue
+			}
+			if ignored(ignores, fullPath, true) {
+				w.skip(SkippedFile{Path: fullPath, Reason: SkipIgnoreFile})
+				continue
+			}
+			if w.isArchiveDir(fullPath) {
+				w.skip(SkippedFile{Path: fullPath, Reason: SkipArchiveDir})
+				continue
+			}
+			if info == nil {
+				if info, err = entry.Info(); err != nil {
+					w.unreadable(fullPath, err)
+					continue
+				}
+			}
+			if w.enterDir(fullPath, info) && !w.walkDir(ctx, root, fullPath, ignores) {
+				return false
+			}
+			continue
+		}
+
+		if entry.Name() == ignoreFileName {
+			continue
+		}
+		if w.recursive && isArchive(entry.Name()) {
+			if reason := w.rules.checkDir(rel, entry); reason != "" {
+				w.skip(SkippedFile{Path: fullPath, Reason: reason})
+				continue
+			}
+			if ignored(ignores, fullPath, true) {
+				w.skip(SkippedFile{Path: fullPath, Reason: SkipIgnoreFile})
+				continue
+			}
+			if info == nil {
+				if info, err = entry.Info(); err != nil {
+					w.unreadable(fullPath, err)
+					continue
+				}
+			}
+			if w.enterDir(fullPath, info) && !w.walkArchive(ctx, fullPath, rel) {
+				return false
+			}
+			continue
+		}
+		if ignored(ignores, fullPath, false) {
+			w.skip(SkippedFile{Path: fullPath, Reason: SkipIgnoreFile})
+			continue
+		}
+		if info == nil {
+			if info, err = entry.Info(); err != nil {
+				w.unreadable(fullPath, err)
+				continue
+			}
+		}
+		if reason := pairSkips[entry.Name()]; reason != "" {
+			w.skip(SkippedFile{Path: fullPath, Reason: reason})
+			continue
+		}
+		if !w.addFile(fullPath, rel, entry, info) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isArchiveDir reports whether dir is where the archive action moves uploaded files
+func (w *uploadWalker) isArchiveDir(dir string) bool {
+	if w.archiveDir == "" {
+		return false
+	}
+	abs, err := filepath.Abs(dir)
+	return err == nil && abs == w.archiveDir
+}
+
+// enterDir marks a directory as visited, reporting false if it was scanned before
+func (w *uploadWalker) enterDir(path string, info os.FileInfo) bool {
+	id := dirIdentity(path, info)
+	if w.visited[id] {
+		w.skip(SkippedFile{Path: path, Reason: SkipVisited})
+		return false
+	}
+	w.visited[id] = true
+	return true
+}
+
+// addFile emits a file unless the rules skip it, returning false once the walk should stop
+func (w *uploadWalker) addFile(fullPath, rel string, entry fs.DirEntry, info os.FileInfo) bool {
+	if reason := w.rules.checkFile(rel, entry, info); reason != "" {
+		w.skip(SkippedFile{Path: fullPath, Reason: reason})
+		return true
+	}
+	return w.emit(uploadJob{path: fullPath})
+}
+
+// walkArchive scans the files of an archive, matching rules against rel joined with
+// the entry names. It returns false once the walk should stop.
+func (w *uploadWalker) walkArchive(ctx context.Context, archivePath, rel string) bool {
+	archive, err := w.archives.get(archivePath)
+	if err != nil {
+		w.unreadable(archivePath, err)
+		return true
+	}
+	// Archives hold no directory entries to skip, so each file checks its parent folders
+	skippedDirs := map[string]string{}
+	names := make([]string, 0, len(archive.entries))
+	for _, entry := range archive.entries {
+		names = append(names, entry.name)
+	}
+	var accepted []string
+	for _, name := range pairCandidates(names) {
+		entry, _ := archive.entry(name)
+		entryRel := path.Join(rel, name)
+		if w.archiveDirReason(rel, name, skippedDirs) == "" &&
+			w.rules.checkFile(entryRel, fs.FileInfoToDirEntry(entry.info), entry.info) == "" {
+			accepted = append(accepted, name)
+		}
+	}
+	pairSkips := w.pairing.pair(accepted)
+
+	for _, entry := range archive.entries {
+		if ctx.Err() != nil {
+			return false
+		}
+		if reason := w.archiveDirReason(rel, entry.name, skippedDirs); reason != "" {
+			w.skip(SkippedFile{Path: entry.path, Reason: reason})
+			continue
+		}
+		if reason := pairSkips[entry.name]; reason != "" {
+			w.skip(SkippedFile{Path: entry.path, Reason: reason})
+			continue
+		}
+		if !w.addArchiveEntry(path.Join(rel, entry.name), entry) {
+			return false
+		}
+	}
+	return true
+}
+
+// archiveDirReason returns why a folder holding an archive entry is skipped, caching
+// the result per folder
+func (w *uploadWalker) archiveDirReason(rel, name string, cache map[string]string) string {
+	dir := ""
+	for _, part := range strings.Split(path.Dir(name), "/") {
+		if part == "." {
+			break
+		}
+		dir = path.Join(dir, part)
+		reason, ok := cache[dir]
+		if !ok {
+			reason = w.rules.checkDir(path.Join(rel, dir), fs.FileInfoToDirEntry(archiveDirInfo(part)))
+			cache[dir] = reason
+		}
+		if reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+// addArchiveFile emits a single archive entry, returning false once the walk should stop
+func (w *uploadWalker) addArchiveFile(archivePath, name string) bool {
+	archive, err := w.archives.get(archivePath)
+	if err != nil {
+		w.unreadable(archivePath+archiveSeparator+name, err)
+		return true
+	}
+	entry, ok := archive.entry(name)
+	if !ok {
+		w.unreadable(archivePath+archiveSeparator+name, os.ErrNotExist)
+		return true
+	}
+	return w.addArchiveEntry(path.Base(name), entry)
+}
+
+// addArchiveEntry emits an archive entry unless the rules skip it
+func (w *uploadWalker) addArchiveEntry(rel string, entry archiveEntry) bool {
+	if reason := w.rules.checkFile(rel, fs.FileInfoToDirEntry(entry.info), entry.info); reason != "" {
+		w.skip(SkippedFile{Path: entry.path, Reason: reason})
+		return true
+	}
+	return w.emit(uploadJob{path: entry.path, info: entry.info, open: entry.open})
+}
+
+func (w *uploadWalker) unreadable(path string, err error) {
+	w.skip(SkippedFile{Path: path, Reason: fmt.Sprintf("%s: %v", SkipUnreadable, err)})
+}
+
+// namedFileInfo keeps a symlink's own name on the FileInfo of its target
+type namedFileInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (i namedFileInfo) Name() string { return i.name }
+
+// archiveDirInfo describes a folder inside an archive, which has no entry of its own
+type archiveDirInfo string
+
+func (d archiveDirInfo) Name() string       { return string(d) }
+func (d archiveDirInfo) Size() int64        { return 0 }
+func (d archiveDirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0755 }
+func (d archiveDirInfo) ModTime() time.Time { return time.Time{} }
+func (d archiveDirInfo) IsDir() bool        { return true }
+func (d archiveDirInfo) Sys() any           { return nil }
+
+// scanUploadPaths walks paths to completion, returning the files to upload and the
+// files and directories skipped with their reasons
+func scanUploadPaths(paths []string) ([]string, []SkippedFile, error) {
+	archives := newArchiveSet()
+	defer archives.Close()
+	jobs, skipped, _, err := scanUploadFiles(paths, archives)
+	if err != nil {
+		return nil, nil, err
+	}
+	files := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		files = append(files, job.path)
+	}
+	return files, skipped, nil
+}
+
+// scanUploadFiles is scanUploadPaths returning jobs, which read archive entries from
+// archives, and the pairs found
+func scanUploadFiles(paths []string, archives *archiveSet) ([]uploadJob, []SkippedFile, UploadPairing, error) {
+	var jobs []uploadJob
+	var skipped []SkippedFile
+	walker, err := newUploadWalker(
+		func(job uploadJob) bool {
+			jobs = append(jobs, job)
+			return true
+		},
+		func(s SkippedFile) {
+			skipped = append(skipped, s)
+		},
+		archives,
+	)
+	if err != nil {
+		return nil, nil, UploadPairing{}, err
+	}
+	if err := walker.walk(context.Background(), paths); err != nil {
+		return nil, nil, UploadPairing{}, err
+	}
+	return jobs, skipped, walker.pairing, nil
+}
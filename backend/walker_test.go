@@ -0,0 +1,122 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestScanUploadPaths_Symlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks needs extra privileges on Windows")
+	}
+	saved := AppConfig
+	defer func() { AppConfig = saved }()
+
+	dir := t.TempDir()
+	for _, name := range []string{"b/2.jpg", "a/1.jpg", "a/nested/3.jpg", "c.jpg"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A loop back to the root, a second route into a/ and a dangling link
+	for link, target := range map[string]string{
+		"a/nested/loop": dir,
+		"alias":         filepath.Join(dir, "a"),
+		"broken.jpg":    filepath.Join(dir, "missing.jpg"),
+		"link.jpg":      filepath.Join(dir, "c.jpg"),
+	} {
+		if err := os.Symlink(target, filepath.Join(dir, filepath.FromSlash(link))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rel := func(paths []string) []string {
+		var out []string
+		for _, path := range paths {
+			r, _ := filepath.Rel(dir, path)
+			out = append(out, filepath.ToSlash(r))
+		}
+		return out
+	}
+	reasons := func(skipped []SkippedFile) map[string]string {
+		out := map[string]string{}
+		for _, s := range skipped {
+			r, _ := filepath.Rel(dir, s.Path)
+			out[filepath.ToSlash(r)] = s.Reason
+		}
+		return out
+	}
+
+	AppConfig = Config{Recursive: true}
+	files, skipped, err := scanUploadPaths([]string{dir})
+	if err != nil {
+		t.Fatalf("scanUploadPaths: %v", err)
+	}
+	want := []string{"a/1.jpg", "a/nested/3.jpg", "b/2.jpg", "c.jpg", "link.jpg"}
+	if got := rel(files); !slices.Equal(got, want) {
+		t.Fatalf("following symlinks: files = %v, want %v", got, want)
+	}
+	got := reasons(skipped)
+	if got["a/nested/loop"] != SkipVisited || got["alias"] != SkipVisited {
+		t.Errorf("expected the loop and the alias reported as visited, got %v", got)
+	}
+	if _, ok := got["broken.jpg"]; !ok {
+		t.Errorf("expected the dangling link reported, got %v", got)
+	}
+
+	AppConfig = Config{Recursive: true, UploadSkipSymlinks: true}
+	files, skipped, err = scanUploadPaths([]string{dir})
+	if err != nil {
+		t.Fatalf("scanUploadPaths: %v", err)
+	}
+	want = []string{"a/1.jpg", "a/nested/3.jpg", "b/2.jpg", "c.jpg"}
+	if got := rel(files); !slices.Equal(got, want) {
+		t.Fatalf("skipping symlinks: files = %v, want %v", got, want)
+	}
+	got = reasons(skipped)
+	for _, link := range []string{"a/nested/loop", "alias", "broken.jpg", "link.jpg"} {
+		if got[link] != SkipSymlink {
+			t.Errorf("skip reason for %s = %q, want %q", link, got[link], SkipSymlink)
+		}
+	}
+}
+
+func TestScanUploadPaths_ContinuesPastUnreadableDirectory(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("needs Unix permissions enforced for the current user")
+	}
+	saved := AppConfig
+	defer func() { AppConfig = saved }()
+
+	dir := t.TempDir()
+	locked := filepath.Join(dir, "locked")
+	if err := os.Mkdir(locked, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ok.jpg"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(locked, 0o755)
+
+	AppConfig = Config{Recursive: true}
+	files, skipped, err := scanUploadPaths([]string{dir})
+	if err != nil {
+		t.Fatalf("scanUploadPaths: %v", err)
+	}
+	if len(files) != 1 || filepath.Base(files[0]) != "ok.jpg" {
+		t.Fatalf("files = %v, want only ok.jpg", files)
+	}
+	if len(skipped) == 0 || skipped[0].Path != locked {
+		t.Fatalf("expected the locked directory reported, got %v", skipped)
+	}
+}
@@ -107,6 +107,17 @@ func (m *MediaBrowser) WashMedia(mediaKey string, dedupKey string) (*MediaItem,
 		return nil, fmt.Errorf("failed to fetch uploaded media info: %w", err)
 	}
 
+	InvalidateThumbnails(mediaKey)
+	m.updateMediaCache(func(db *MediaDB, changes *MediaSyncResult) {
+		if db.Remove(mediaKey) {
+			changes.Removed = append(changes.Removed, mediaKey)
+		}
+		if db.UpdateOrAdd(*item) {
+			stored, _ := db.GetItem(item.MediaKey)
+			changes.Added = append(changes.Added, stored)
+		}
+	})
+
 	return item, nil
 }
 
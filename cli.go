@@ -2,11 +2,15 @@ package main
 
 import (
 	"app/backend"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/progress"
 	tea "github.com/charmbracelet/bubbletea"
@@ -17,9 +21,32 @@ import (
 type cliConfig struct {
 	recursive                     bool
 	threads                       int
+	hashThreads                   int
+	maxAttempts                   int
+	timestampPolicy               string
+	livePhotoPolicy               string
+	rawJpegPolicy                 string
+	stripMetadata                 string
+	filenamePatterns              []string
 	forceUpload                   bool
 	deleteFromHost                bool
+	postUploadAction              string
+	archiveDir                    string
 	disableUnsupportedFilesFilter bool
+	reindex                       bool
+	dryRun                        bool
+	checkLibrary                  bool
+	jsonOutput                    bool
+	include                       []string
+	exclude                       []string
+	minSize                       string
+	maxSize                       string
+	modifiedSince                 string
+	modifiedBefore                string
+	skipHidden                    bool
+	skipSymlinks                  bool
+	journalPath                   string
+	resumeJournal                 string
 	logLevel                      string
 	configPath                    string
 }
@@ -29,10 +56,24 @@ type uploadStartMsg struct {
 	total int
 }
 
+type scanMsg struct {
+	found        int
+	skipped      int
+	skippedFiles []backend.SkippedFile
+	pairing      backend.UploadPairing
+	complete     bool
+}
+
+type preflightMsg struct {
+	alreadyInLibrary int
+	toUpload         int
+}
+
 type fileProgressMsg struct {
 	workerID int
 	status   string
 	fileName string
+	profile  string // Quality and device, sent while finalizing
 	message  string
 }
 
@@ -41,6 +82,18 @@ type fileCompleteMsg struct {
 	fileName string
 	mediaKey string
 	err      error
+	attempts int
+	taken    time.Time // Commit timestamp
+	stripped []string  // Tag sets removed before upload
+	local    backend.LocalFileAction
+	profile  backend.UploadProfile
+	source   string // Where the commit timestamp came from
+}
+
+type pausedMsg struct {
+	until    time.Time
+	failures int
+	reason   string
 }
 
 type uploadCompleteMsg struct{}
@@ -49,6 +102,14 @@ type uploadCompleteMsg struct{}
 type uploadModel struct {
 	progress     progress.Model
 	totalFiles   int
+	scanning     bool
+	skipped      int
+	skippedFiles []backend.SkippedFile
+	pairing      *backend.UploadPairing // Set once a scan that pairs files completes
+	preflight    *preflightMsg          // Latest library check counts
+	paused       *pausedMsg             // Set while the circuit breaker holds the workers
+	held         bool                   // Paused with the p key
+	hold         func(bool)             // Pauses or resumes the upload workers
 	completed    int
 	failed       int
 	currentFiles map[int]string // workerID -> current file
@@ -56,20 +117,38 @@ type uploadModel struct {
 	results      []uploadResult // Track all upload results
 	width        int
 	quitting     bool
+	finished     bool // The batch ended rather than being cancelled
 }
 
 type uploadResult struct {
-	Path     string `json:"path"`
-	Success  bool   `json:"success"`
-	MediaKey string `json:"mediaKey,omitempty"`
-	Error    string `json:"error,omitempty"`
+	Path            string `json:"path"`
+	Success         bool   `json:"success"`
+	MediaKey        string `json:"mediaKey,omitempty"`
+	Error           string `json:"error,omitempty"`
+	Attempts        int    `json:"attempts,omitempty"`
+	Timestamp       string `json:"timestamp,omitempty"`
+	TimestampSource string `json:"timestampSource,omitempty"` // metadata, filename or mtime
+	// Tag sets removed before upload; the uploaded copy differs from the local file
+	MetadataStripped []string `json:"metadataStripped,omitempty"`
+	// What was done with the local file: kept, deleted, archived or trashed
+	Local *backend.LocalFileAction `json:"local,omitempty"`
+	// Quality and device the file was committed with; unset for files not uploaded
+	Profile *backend.UploadProfile `json:"profile,omitempty"`
 }
 
 type uploadSummary struct {
-	Total     int            `json:"total"`
-	Succeeded int            `json:"succeeded"`
-	Failed    int            `json:"failed"`
-	Results   []uploadResult `json:"results"`
+	Total            int                    `json:"total"`
+	Succeeded        int                    `json:"succeeded"`
+	Failed           int                    `json:"failed"`
+	Retried          int                    `json:"retried"`                    // Files that needed more than one attempt
+	TimestampSources map[string]int         `json:"timestampSources,omitempty"` // Uploads dated by each source
+	MetadataStripped int                    `json:"metadataStripped,omitempty"` // Uploads modified by the privacy filter
+	LocalActions     map[string]int         `json:"localActions,omitempty"`     // Local files kept, deleted, archived or trashed
+	Profiles         map[string]int         `json:"profiles,omitempty"`         // Uploads committed with each profile
+	Pairing          *backend.UploadPairing `json:"pairing,omitempty"`
+	Results          []uploadResult         `json:"results"`
+	Skipped          []backend.SkippedFile  `json:"skipped,omitempty"`
+	Journal          string                 `json:"journal,omitempty"`
 }
 
 func initialModel() uploadModel {
@@ -95,10 +174,34 @@ func (m uploadModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case uploadStartMsg:
 		m.totalFiles = msg.total
+		m.scanning = true
+		return m, nil
+
+	case scanMsg:
+		m.totalFiles = msg.found
+		m.skipped = msg.skipped
+		m.scanning = !msg.complete
+		if msg.complete {
+			m.skippedFiles = msg.skippedFiles
+			if msg.pairing.LivePhotos != "" {
+				m.pairing = &msg.pairing
+			}
+		}
+		return m, nil
+
+	case preflightMsg:
+		m.preflight = &msg
+		return m, nil
+
+	case pausedMsg:
+		m.paused = &msg
 		return m, nil
 
 	case fileProgressMsg:
 		m.workers[msg.workerID] = fmt.Sprintf("[%d] %s: %s", msg.workerID, msg.status, msg.fileName)
+		if msg.profile != "" {
+			m.workers[msg.workerID] += " as " + msg.profile
+		}
 		if msg.fileName != "" {
 			m.currentFiles[msg.workerID] = msg.fileName
 		}
@@ -109,6 +212,20 @@ func (m uploadModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			Path:     msg.fileName,
 			Success:  msg.success,
 			MediaKey: msg.mediaKey,
+			Attempts: msg.attempts,
+		}
+		if msg.source != "" {
+			result.Timestamp = msg.taken.Format(time.RFC3339)
+			result.TimestampSource = msg.source
+		}
+		result.MetadataStripped = msg.stripped
+		if msg.local.Action != "" {
+			local := msg.local
+			result.Local = &local
+		}
+		if msg.profile.Quality != "" {
+			profile := msg.profile
+			result.Profile = &profile
 		}
 		if msg.success {
 			m.completed++
@@ -123,12 +240,19 @@ func (m uploadModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case uploadCompleteMsg:
 		m.quitting = true
+		m.finished = true
 		return m, tea.Quit
 
 	case tea.KeyMsg:
-		if msg.String() == "ctrl+c" {
+		switch msg.String() {
+		case "ctrl+c":
 			m.quitting = true
 			return m, tea.Quit
+		case "p":
+			if m.hold != nil {
+				m.held = !m.held
+				m.hold(m.held)
+			}
 		}
 	}
 
@@ -154,6 +278,27 @@ func (m uploadModel) View() string {
 		b.WriteString(fmt.Sprintf(" (✓ %d success, ✗ %d failed)\n\n", m.completed, m.failed))
 	}
 
+	if profiles := m.profiles(); len(backend.AppConfig.UploadRules) > 0 && len(profiles) > 0 {
+		counts := make([]string, 0, len(profiles))
+		for _, profile := range slices.Sorted(maps.Keys(profiles)) {
+			counts = append(counts, fmt.Sprintf("%d %s", profiles[profile], profile))
+		}
+		b.WriteString("Profiles: " + strings.Join(counts, ", ") + "\n\n")
+	}
+	if m.preflight != nil {
+		b.WriteString(fmt.Sprintf("%d already in library, %d to upload\n\n", m.preflight.alreadyInLibrary, m.preflight.toUpload))
+	}
+	if m.scanning {
+		b.WriteString(fmt.Sprintf("Scanning... %d files found\n\n", m.totalFiles))
+	}
+	if m.skipped > 0 {
+		b.WriteString(fmt.Sprintf("%d skipped while scanning\n\n", m.skipped))
+	}
+	if m.paused != nil && time.Now().Before(m.paused.until) {
+		b.WriteString(fmt.Sprintf("Paused until %s after %d consecutive failures: %s\n\n",
+			m.paused.until.Format("15:04:05"), m.paused.failures, m.paused.reason))
+	}
+
 	// Worker status
 	for i := 0; i < len(m.workers); i++ {
 		if status, ok := m.workers[i]; ok {
@@ -162,11 +307,73 @@ func (m uploadModel) View() string {
 		}
 	}
 
-	b.WriteString("\n\nPress Ctrl+C to cancel\n")
+	if m.held {
+		b.WriteString("\nPaused: uploads hold once the current files are done\n")
+		b.WriteString("\nPress p to resume, Ctrl+C to cancel\n")
+	} else if m.hold != nil {
+		b.WriteString("\n\nPress p to pause, Ctrl+C to cancel\n")
+	} else {
+		b.WriteString("\n\nPress Ctrl+C to cancel\n")
+	}
 
 	return b.String()
 }
 
+// retried counts the files that needed more than one upload attempt
+func (m uploadModel) retried() int {
+	n := 0
+	for _, result := range m.results {
+		if result.Attempts > 1 {
+			n++
+		}
+	}
+	return n
+}
+
+// timestampSources counts the uploads dated by each timestamp source
+func (m uploadModel) timestampSources() map[string]int {
+	counts := make(map[string]int)
+	for _, result := range m.results {
+		if result.TimestampSource != "" {
+			counts[result.TimestampSource]++
+		}
+	}
+	return counts
+}
+
+// metadataStripped counts the uploads the privacy filter modified
+func (m uploadModel) metadataStripped() int {
+	n := 0
+	for _, result := range m.results {
+		if len(result.MetadataStripped) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// localActions counts what was done with the local files of the uploads
+func (m uploadModel) localActions() map[string]int {
+	counts := make(map[string]int)
+	for _, result := range m.results {
+		if result.Local != nil {
+			counts[result.Local.Action]++
+		}
+	}
+	return counts
+}
+
+// profiles counts the uploads committed with each profile
+func (m uploadModel) profiles() map[string]int {
+	counts := make(map[string]int)
+	for _, result := range m.results {
+		if result.Profile != nil {
+			counts[result.Profile.String()]++
+		}
+	}
+	return counts
+}
+
 // parseLogLevel converts a string log level to slog.Level
 func parseLogLevel(level string) slog.Level {
 	switch strings.ToLower(level) {
@@ -200,24 +407,194 @@ func runCLIUpload(filePaths []string, config cliConfig) error {
 	// Override config with CLI flags
 	backend.AppConfig.Recursive = config.recursive
 	backend.AppConfig.UploadThreads = config.threads
+	if config.hashThreads > 0 {
+		backend.AppConfig.HashThreads = config.hashThreads
+	}
+	if config.maxAttempts > 0 {
+		backend.AppConfig.UploadMaxAttempts = config.maxAttempts
+	}
+	switch config.timestampPolicy {
+	case "":
+	case backend.TimestampMetadata, backend.TimestampMtime, backend.TimestampFilename:
+		backend.AppConfig.UploadTimestampPolicy = config.timestampPolicy
+	default:
+		return fmt.Errorf("invalid --timestamp %q: use metadata, mtime or filename", config.timestampPolicy)
+	}
+	switch config.livePhotoPolicy {
+	case "":
+	case backend.LivePhotoBoth, backend.LivePhotoStill:
+		backend.AppConfig.UploadLivePhotoPolicy = config.livePhotoPolicy
+	default:
+		return fmt.Errorf("invalid --live-photos %q: use both or still", config.livePhotoPolicy)
+	}
+	switch config.rawJpegPolicy {
+	case "":
+	case backend.RawJpegBoth, backend.RawJpegRaw, backend.RawJpegJpeg:
+		backend.AppConfig.UploadRawJpegPolicy = config.rawJpegPolicy
+	default:
+		return fmt.Errorf("invalid --raw-jpeg %q: use both, raw or jpeg", config.rawJpegPolicy)
+	}
+	if config.stripMetadata != "" {
+		sets, err := backend.ParseStripTagSets(config.stripMetadata)
+		if err != nil {
+			return fmt.Errorf("invalid --strip-metadata: %w", err)
+		}
+		backend.AppConfig.UploadStripMetadata = sets
+	}
+	if err := backend.ValidateFilenamePatterns(config.filenamePatterns); err != nil {
+		return err
+	}
+	// Flag patterns are tried before the ones from the config file
+	backend.AppConfig.UploadFilenamePatterns = append(config.filenamePatterns, backend.AppConfig.UploadFilenamePatterns...)
 	backend.AppConfig.ForceUpload = config.forceUpload
 	backend.AppConfig.DeleteFromHost = config.deleteFromHost
+	if config.postUploadAction != "" {
+		backend.AppConfig.PostUploadAction = config.postUploadAction
+	}
+	if config.archiveDir != "" {
+		backend.AppConfig.PostUploadArchiveDir = config.archiveDir
+	}
+	if err := backend.ValidatePostUploadAction(backend.AppConfig.PostUploadAction); err != nil {
+		return fmt.Errorf("invalid --after-upload: %w", err)
+	}
+	if backend.AppConfig.PostUploadAction == backend.PostUploadArchive && backend.AppConfig.PostUploadArchiveDir == "" {
+		return fmt.Errorf("--after-upload archive needs --archive-dir")
+	}
+	if backend.CommandDeviceProfile != "" {
+		if err := backend.ValidateDeviceProfileName(backend.CommandDeviceProfile); err != nil {
+			return fmt.Errorf("invalid --device: %w", err)
+		}
+	}
 	backend.AppConfig.DisableUnsupportedFilesFilter = config.disableUnsupportedFilesFilter
+	backend.ReindexUploads = config.reindex
+	if err := applyScanFlags(config); err != nil {
+		return err
+	}
+
+	var alreadyDone []string
+	if config.resumeJournal != "" {
+		state, err := backend.ReadUploadJournal(config.resumeJournal)
+		if err != nil {
+			return fmt.Errorf("failed to resume upload: %w", err)
+		}
+		filePaths = uniquePaths(append(resumePaths(state), filePaths...))
+		alreadyDone = state.Done
+		backend.AppConfig.Recursive = backend.AppConfig.Recursive || state.Recursive
+		fmt.Fprintf(os.Stderr, "Resuming %s: %d done, %d pending, %d failed\n",
+			config.resumeJournal, len(state.Done), len(state.Pending), len(state.Failed))
+		if len(filePaths) == 0 {
+			fmt.Fprintln(os.Stderr, "Nothing left to upload")
+			return nil
+		}
+	}
+
+	if config.dryRun {
+		return runCLIUploadPlan(filePaths, alreadyDone, config)
+	}
+
+	journal, err := openCLIJournal(filePaths, config)
+	if err != nil {
+		return err
+	}
 
 	// Parse log level
 	logLevel := parseLogLevel(config.logLevel)
 
 	// Start bubbletea program
+	var uploadManager *backend.UploadManager
 	model := initialModel()
+	model.hold = func(held bool) {
+		if held {
+			uploadManager.Pause()
+		} else {
+			uploadManager.Resume()
+		}
+	}
 	p := tea.NewProgram(model)
 
 	// Create CLI app with event callback to bubbletea
-	eventCallback := func(event string, data any) {
+	cliApp := backend.NewCLIApp(uploadEvents(p), logLevel)
+	uploadManager = backend.NewUploadManager(cliApp)
+
+	// Run upload in background
+	go func() {
+		uploadManager.UploadWithOptions(cliApp, filePaths, backend.UploadOptions{Journal: journal})
+	}()
+
+	// Run the TUI
+	finalModel, err := p.Run()
+	if err != nil {
+		return fmt.Errorf("error running TUI: %w", err)
+	}
+
+	// Persist index entries of files finished before a Ctrl+C
+	if err := backend.FlushUploadIndex(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save upload index: %v\n", err)
+	}
+
+	// Keep the journal unless a batch with a generated journal finished without failures
+	journal.Close()
+	journalPath := journal.Path()
+	if m, ok := finalModel.(uploadModel); ok && m.finished && journal.Failed() == 0 && config.journalPath == "" && config.resumeJournal == "" {
+		os.Remove(journalPath)
+		journalPath = ""
+	} else {
+		fmt.Fprintf(os.Stderr, "Upload journal: %s\nResume with: gotohp upload --resume %s\n", journalPath, journalPath)
+	}
+
+	// Print JSON summary after TUI completes
+	if m, ok := finalModel.(uploadModel); ok {
+		summary := uploadSummary{
+			Total:            m.totalFiles,
+			Succeeded:        m.completed,
+			Failed:           m.failed,
+			Retried:          m.retried(),
+			TimestampSources: m.timestampSources(),
+			MetadataStripped: m.metadataStripped(),
+			LocalActions:     m.localActions(),
+			Profiles:         m.profiles(),
+			Pairing:          m.pairing,
+			Results:          m.results,
+			Skipped:          m.skippedFiles,
+			Journal:          journalPath,
+		}
+
+		jsonOutput, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error generating JSON: %w", err)
+		}
+
+		fmt.Println(string(jsonOutput))
+	}
+
+	return nil
+}
+
+// uploadEvents forwards backend upload events to the TUI
+func uploadEvents(p *tea.Program) func(event string, data any) {
+	return func(event string, data any) {
 		switch event {
 		case "uploadStart":
 			if start, ok := data.(backend.UploadBatchStart); ok {
 				p.Send(uploadStartMsg{total: start.Total})
 			}
+		case "uploadScan":
+			if scan, ok := data.(backend.UploadScanProgress); ok {
+				p.Send(scanMsg{
+					found:        scan.Found,
+					skipped:      scan.Skipped,
+					skippedFiles: scan.SkippedFiles,
+					pairing:      scan.Pairing,
+					complete:     scan.Complete,
+				})
+			}
+		case "uploadPreflight":
+			if summary, ok := data.(backend.UploadPreflight); ok {
+				p.Send(preflightMsg{
+					alreadyInLibrary: summary.AlreadyInLibrary,
+					toUpload:         summary.ToUpload,
+				})
+			}
 		case "ThreadStatus":
 			if status, ok := data.(backend.ThreadStatus); ok {
 				fileName := status.FileName
@@ -226,6 +603,7 @@ func runCLIUpload(filePaths []string, config cliConfig) error {
 					workerID: status.WorkerID,
 					status:   status.Status,
 					fileName: fileName,
+					profile:  status.Profile,
 					message:  status.Message,
 				})
 			}
@@ -236,47 +614,203 @@ func runCLIUpload(filePaths []string, config cliConfig) error {
 					fileName: result.Path,
 					mediaKey: result.MediaKey,
 					err:      result.Error,
+					attempts: result.Attempts,
+					taken:    result.Timestamp,
+					source:   result.TimestampSource,
+					stripped: result.MetadataStripped,
+					local:    result.Local,
+					profile:  result.Profile,
+				})
+			}
+		case "uploadPaused":
+			if pause, ok := data.(backend.UploadPause); ok {
+				p.Send(pausedMsg{
+					until:    pause.Until,
+					failures: pause.Failures,
+					reason:   pause.Reason,
 				})
 			}
 		case "uploadStop":
 			p.Send(uploadCompleteMsg{})
 		}
 	}
+}
 
-	cliApp := backend.NewCLIApp(eventCallback, logLevel)
-	uploadManager := backend.NewUploadManager(cliApp)
+// applyScanFlags adds the upload scan rule flags to the rules from the config file
+func applyScanFlags(config cliConfig) error {
+	backend.AppConfig.UploadInclude = append(backend.AppConfig.UploadInclude, config.include...)
+	backend.AppConfig.UploadExclude = append(backend.AppConfig.UploadExclude, config.exclude...)
+	if config.skipHidden {
+		backend.AppConfig.UploadSkipHidden = true
+	}
+	if config.skipSymlinks {
+		backend.AppConfig.UploadSkipSymlinks = true
+	}
 
-	// Run upload in background
-	go func() {
-		uploadManager.Upload(cliApp, filePaths)
-	}()
+	if config.minSize != "" {
+		if _, err := backend.ParseByteSize(config.minSize); err != nil {
+			return fmt.Errorf("invalid --min-size: %w", err)
+		}
+		backend.AppConfig.UploadMinSize = config.minSize
+	}
+	if config.maxSize != "" {
+		if _, err := backend.ParseByteSize(config.maxSize); err != nil {
+			return fmt.Errorf("invalid --max-size: %w", err)
+		}
+		backend.AppConfig.UploadMaxSize = config.maxSize
+	}
+	if config.modifiedSince != "" {
+		if _, err := backend.ParseScanDate(config.modifiedSince); err != nil {
+			return fmt.Errorf("invalid --modified-since: %w", err)
+		}
+		backend.AppConfig.UploadModifiedSince = config.modifiedSince
+	}
+	if config.modifiedBefore != "" {
+		if _, err := backend.ParseScanDate(config.modifiedBefore); err != nil {
+			return fmt.Errorf("invalid --modified-before: %w", err)
+		}
+		backend.AppConfig.UploadModifiedBefore = config.modifiedBefore
+	}
+	return nil
+}
 
-	// Run the TUI
-	finalModel, err := p.Run()
+// resumePaths returns what a resumed batch still has to upload. If the scan finished,
+// that is the pending and failed files; otherwise the original paths are scanned again
+// and the journal skips the files already done.
+func resumePaths(state *backend.JournalState) []string {
+	if !state.ScanComplete {
+		return state.Roots
+	}
+	return append(append([]string{}, state.Pending...), state.Failed...)
+}
+
+// openCLIJournal creates the upload journal, or reopens it with --resume
+func openCLIJournal(filePaths []string, config cliConfig) (*backend.UploadJournal, error) {
+	if config.resumeJournal != "" {
+		journal, _, err := backend.ResumeUploadJournal(config.resumeJournal)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resume upload: %w", err)
+		}
+		return journal, nil
+	}
+
+	path := config.journalPath
+	if path == "" {
+		var err error
+		if path, err = backend.DefaultJournalPath(); err != nil {
+			return nil, fmt.Errorf("failed to create upload journal: %w", err)
+		}
+	}
+	journal, err := backend.CreateUploadJournal(path, filePaths)
 	if err != nil {
-		return fmt.Errorf("error running TUI: %w", err)
+		return nil, fmt.Errorf("failed to create upload journal: %w", err)
 	}
+	return journal, nil
+}
 
-	// Print JSON summary after TUI completes
-	if m, ok := finalModel.(uploadModel); ok {
-		summary := uploadSummary{
-			Total:     m.totalFiles,
-			Succeeded: m.completed,
-			Failed:    m.failed,
-			Results:   m.results,
+// runCLIUploadPlan prints what an upload would do without uploading or deleting anything
+func runCLIUploadPlan(filePaths, alreadyDone []string, config cliConfig) error {
+	var progress func(done, total int)
+	if config.checkLibrary {
+		progress = func(done, total int) {
+			fmt.Fprintf(os.Stderr, "\rHashing %d/%d", done, total)
+			if done == total {
+				fmt.Fprintln(os.Stderr)
+			}
 		}
+	}
 
-		jsonOutput, err := json.MarshalIndent(summary, "", "  ")
+	plan, err := backend.BuildUploadPlan(context.Background(), filePaths, config.checkLibrary, progress)
+	if err != nil {
+		return fmt.Errorf("failed to build upload plan: %w", err)
+	}
+	plan.SkipDone(alreadyDone)
+
+	if config.jsonOutput {
+		jsonOutput, err := json.MarshalIndent(plan, "", "  ")
 		if err != nil {
 			return fmt.Errorf("error generating JSON: %w", err)
 		}
-
 		fmt.Println(string(jsonOutput))
+		return nil
 	}
 
+	fmt.Println(titleStyle.Render("Upload plan (dry run, nothing is uploaded or deleted)"))
+	fmt.Println()
+	fmt.Printf("To upload (%d files, %s):\n", len(plan.ToUpload), formatBytes(plan.UploadBytes))
+	for _, file := range plan.ToUpload {
+		fmt.Printf("  %s  %s\n", file.Path, exampleStyle.Render(formatBytes(file.Size)+", "+file.Profile.String()))
+	}
+	if plan.Checked {
+		fmt.Printf("Already in library (%d files):\n", len(plan.AlreadyInLibrary))
+		for _, file := range plan.AlreadyInLibrary {
+			fmt.Printf("  %s  %s\n", file.Path, exampleStyle.Render(file.MediaKey))
+		}
+	}
+	if len(plan.Skipped) > 0 {
+		fmt.Printf("Skipped (%d):\n", len(plan.Skipped))
+		for _, skipped := range plan.Skipped {
+			fmt.Printf("  %s  %s\n", skipped.Path, exampleStyle.Render(skipped.Reason))
+		}
+	}
+	if len(plan.Failed) > 0 {
+		fmt.Printf("Failed (%d files):\n", len(plan.Failed))
+		for _, file := range plan.Failed {
+			fmt.Printf("  %s: %s\n", file.Path, file.Error)
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("Total: %d files (%s), %d to upload (%s)",
+		len(plan.ToUpload)+len(plan.AlreadyInLibrary)+len(plan.Failed), formatBytes(plan.TotalBytes),
+		len(plan.ToUpload), formatBytes(plan.UploadBytes))
+	if plan.Checked {
+		fmt.Printf(", %d already in library", len(plan.AlreadyInLibrary))
+	}
+	fmt.Printf(", %d skipped\n", len(plan.Skipped))
+	fmt.Printf("Pairing: %d Live Photos (upload %s), %d RAW+JPEG pairs (upload %s)\n",
+		plan.Pairing.LivePhotoPairs, pairingPolicyLabel(plan.Pairing.LivePhotos),
+		plan.Pairing.RawJpegPairs, pairingPolicyLabel(plan.Pairing.RawJpeg))
+	if !plan.Checked {
+		fmt.Println(exampleStyle.Render("Library check skipped; add --check to hash files and check them against the library"))
+	}
+	if len(plan.StripMetadata) > 0 {
+		fmt.Printf("Stripping %s from JPEG, PNG and WebP files before upload\n", strings.Join(plan.StripMetadata, ", "))
+	}
+	if label := backend.PostUploadActionLabel(); label != "" {
+		fmt.Println(exampleStyle.Render("Files would be " + label + " after upload"))
+	}
 	return nil
 }
 
+// pairingPolicyLabel describes which files of a pair a policy uploads
+func pairingPolicyLabel(policy string) string {
+	switch policy {
+	case backend.LivePhotoStill:
+		return "still only"
+	case backend.RawJpegRaw:
+		return "RAW only"
+	case backend.RawJpegJpeg:
+		return "JPEG only"
+	default:
+		return "both"
+	}
+}
+
+// formatBytes renders a byte count using binary units
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
 // CLI download implementation
 func runCLIDownload(mediaKey, outputPath string, original bool) error {
 	// Load backend config
@@ -404,7 +938,7 @@ func runCLIThumbnail(mediaKey, outputPath string, width, height int, size string
 
 	// Get the thumbnail
 	fmt.Printf("Getting thumbnail for media key: %s\n", mediaKey)
-	thumbnailData, err := api.GetThumbnail(mediaKey, width, height, forceJPEG, 0, noOverlay)
+	thumbnailData, err := backend.FetchThumbnail(api, mediaKey, width, height, forceJPEG, noOverlay)
 	if err != nil {
 		return fmt.Errorf("failed to get thumbnail: %w", err)
 	}
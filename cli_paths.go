@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// expandPathArg expands glob patterns on Windows, whose shells pass them through
+// unexpanded. Patterns matching nothing are returned as is so the existence check
+// reports them. Elsewhere the shell has already expanded globs, and a remaining
+// '*' is part of a file name.
+func expandPathArg(arg string) []string {
+	if runtime.GOOS != "windows" || !strings.ContainsAny(arg, "*?[") {
+		return []string{arg}
+	}
+	matches, err := filepath.Glob(arg)
+	if err != nil || len(matches) == 0 {
+		return []string{arg}
+	}
+	return matches
+}
+
+// readPathListFile reads upload paths from a list file, or from stdin for "-"
+func readPathListFile(name string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open path list: %w", err)
+		}
+		defer file.Close()
+		r = file
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read path list: %w", err)
+	}
+	return parsePathList(data), nil
+}
+
+// parsePathList splits a path list on NUL bytes if it has any, as written by
+// find -print0, and on newlines otherwise. Empty entries are dropped.
+func parsePathList(data []byte) []string {
+	sep := byte('\n')
+	if bytes.IndexByte(data, 0) >= 0 {
+		sep = 0
+	}
+
+	var paths []string
+	for _, entry := range bytes.Split(data, []byte{sep}) {
+		path := string(entry)
+		if sep == '\n' {
+			path = strings.TrimSuffix(path, "\r")
+		}
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// uniquePaths drops repeated paths, keeping the first occurrence
+func uniquePaths(paths []string) []string {
+	seen := make(map[string]bool, len(paths))
+	unique := paths[:0]
+	for _, path := range paths {
+		if !seen[path] {
+			seen[path] = true
+			unique = append(unique, path)
+		}
+	}
+	return unique
+}
+
+// readFailedFromSummary returns the failed paths of the JSON summary printed by an earlier upload
+func readFailedFromSummary(name string) ([]string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload summary: %w", err)
+	}
+	var summary uploadSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, fmt.Errorf("failed to parse upload summary %s: %w", name, err)
+	}
+
+	var failed []string
+	for _, result := range summary.Results {
+		if !result.Success && result.Path != "" {
+			failed = append(failed, result.Path)
+		}
+	}
+	return failed, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+func TestParsePathList(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"newlines", "a.jpg\nb.jpg\n", []string{"a.jpg", "b.jpg"}},
+		{"crlf", "a.jpg\r\nb.jpg\r\n", []string{"a.jpg", "b.jpg"}},
+		{"blank lines", "\na.jpg\n\n\nb.jpg", []string{"a.jpg", "b.jpg"}},
+		{"nul", "a.jpg\x00b.jpg\x00", []string{"a.jpg", "b.jpg"}},
+		{"nul keeps newlines and spaces", "line\nbreak.jpg\x00 space.jpg\x00", []string{"line\nbreak.jpg", " space.jpg"}},
+		{"nul keeps carriage returns", "a.jpg\r\x00", []string{"a.jpg\r"}},
+		{"empty nul entries", "\x00\x00a.jpg\x00\x00", []string{"a.jpg"}},
+	}
+	for _, tt := range tests {
+		if got := parsePathList([]byte(tt.data)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: parsePathList(%q) = %q, want %q", tt.name, tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestReadPathListFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	stdin := write("stdin", "from/stdin.jpg\n")
+
+	tests := []struct {
+		name    string
+		file    string
+		want    []string
+		wantErr bool
+	}{
+		{"newline list", write("list.txt", "a.jpg\nb c.jpg\n"), []string{"a.jpg", "b c.jpg"}, false},
+		{"nul list", write("list0", "a.jpg\x00b\nc.jpg\x00"), []string{"a.jpg", "b\nc.jpg"}, false},
+		{"empty list", write("empty", ""), nil, false},
+		{"stdin", "-", []string{"from/stdin.jpg"}, false},
+		{"missing", filepath.Join(dir, "missing"), nil, true},
+	}
+	for _, tt := range tests {
+		file, err := os.Open(stdin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved := os.Stdin
+		os.Stdin = file
+		got, err := readPathListFile(tt.file)
+		os.Stdin = saved
+		file.Close()
+
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: paths = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUniquePaths(t *testing.T) {
+	tests := []struct {
+		paths []string
+		want  []string
+	}{
+		{nil, []string{}},
+		{[]string{"a", "b"}, []string{"a", "b"}},
+		{[]string{"b", "a", "b", "c", "a"}, []string{"b", "a", "c"}},
+		{[]string{"a", "a", "a"}, []string{"a"}},
+		{[]string{"dir/a", "dir/A"}, []string{"dir/a", "dir/A"}},
+	}
+	for _, tt := range tests {
+		paths := slices.Clone(tt.paths)
+		if got := uniquePaths(paths); !slices.Equal(got, tt.want) {
+			t.Errorf("uniquePaths(%q) = %q, want %q", tt.paths, got, tt.want)
+		}
+	}
+}
+
+func TestExpandPathArg(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.jpg", "b.jpg", "c.png"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	join := func(names ...string) []string {
+		var paths []string
+		for _, name := range names {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+		return paths
+	}
+	// Only Windows shells leave globs for the program to expand
+	globbed := func(matches, literal []string) []string {
+		if runtime.GOOS == "windows" {
+			return matches
+		}
+		return literal
+	}
+
+	tests := []struct {
+		arg  string
+		want []string
+	}{
+		{filepath.Join(dir, "a.jpg"), join("a.jpg")},
+		{filepath.Join(dir, "missing.jpg"), join("missing.jpg")},
+		{filepath.Join(dir, "*.jpg"), globbed(join("a.jpg", "b.jpg"), join("*.jpg"))},
+		{filepath.Join(dir, "?.png"), globbed(join("c.png"), join("?.png"))},
+		// Patterns matching nothing are kept for the existence check to report
+		{filepath.Join(dir, "*.gif"), join("*.gif")},
+	}
+	for _, tt := range tests {
+		if got := expandPathArg(tt.arg); !slices.Equal(got, tt.want) {
+			t.Errorf("expandPathArg(%q) = %q, want %q", tt.arg, got, tt.want)
+		}
+	}
+}
+
+func TestParseUploadArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		paths   []string
+		threads int
+		wantErr bool
+	}{
+		{"paths and flags", []string{"a.jpg", "-t", "8", "b.jpg"}, []string{"a.jpg", "b.jpg"}, 8, false},
+		{"unknown flag", []string{"--thread", "8", "a.jpg"}, nil, 0, true},
+		{"unknown short flag", []string{"a.jpg", "-x"}, nil, 0, true},
+		{"double dash ends flags", []string{"-t", "2", "--", "-x.jpg", "--threads"}, []string{"-x.jpg", "--threads"}, 2, false},
+	}
+	for _, tt := range tests {
+		got, err := parseUploadArgs(tt.args)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: parseUploadArgs(%q) succeeded, want error", tt.name, tt.args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: parseUploadArgs(%q): %v", tt.name, tt.args, err)
+			continue
+		}
+		if !slices.Equal(got.paths, tt.paths) || got.config.threads != tt.threads {
+			t.Errorf("%s: parseUploadArgs(%q) = paths %q, threads %d, want %q, %d", tt.name, tt.args, got.paths, got.config.threads, tt.paths, tt.threads)
+		}
+	}
+}
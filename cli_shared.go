@@ -32,12 +32,14 @@ func getAppVersion() string {
 func isCLICommand(arg string) bool {
 	supportedCommands := []string{
 		"upload",
+		"import-takeout", // Import a Google Takeout export
 		"download",
 		"thumbnail", "thumb", // Get thumbnail at various sizes
 		"list", "ls", // List media items
 		"albums", // List albums
 		"autowash", // Start auto-wash service
 		"credentials", "creds", // Support both full and short form
+		"templates", // Print the request templates
 		"help", "--help", "-h",
 		"version", "--version", "-v",
 	}
@@ -45,6 +47,170 @@ func isCLICommand(arg string) bool {
 	return slices.Contains(supportedCommands, arg)
 }
 
+// uploadArgs are the paths and flags of the upload command
+type uploadArgs struct {
+	config      cliConfig
+	paths       []string // Given on the command line
+	fromFiles   []string // --from-file lists
+	retryFailed []string // --retry-failed summaries
+}
+
+// parseUploadArgs parses the arguments after "upload". Arguments starting with - must be
+// known flags; paths that start with - can be given after --.
+func parseUploadArgs(args []string) (uploadArgs, error) {
+	parsed := uploadArgs{config: cliConfig{
+		threads:  3,
+		logLevel: "info", // Default to info for CLI
+	}}
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--from-file":
+			if i+1 < len(args) {
+				parsed.fromFiles = append(parsed.fromFiles, args[i+1])
+				i++
+			}
+		case "--retry-failed":
+			if i+1 < len(args) {
+				parsed.retryFailed = append(parsed.retryFailed, args[i+1])
+				i++
+			}
+		case "--resume":
+			if i+1 < len(args) {
+				parsed.config.resumeJournal = args[i+1]
+				i++
+			}
+		case "--journal":
+			if i+1 < len(args) {
+				parsed.config.journalPath = args[i+1]
+				i++
+			}
+		case "--recursive", "-r":
+			parsed.config.recursive = true
+		case "--force", "-f":
+			parsed.config.forceUpload = true
+		case "--delete", "-d":
+			parsed.config.deleteFromHost = true
+		case "--disable-filter", "-df":
+			parsed.config.disableUnsupportedFilesFilter = true
+		case "--reindex":
+			parsed.config.reindex = true
+		case "--dry-run", "-n":
+			parsed.config.dryRun = true
+		case "--check":
+			parsed.config.checkLibrary = true
+		case "--json", "-j":
+			parsed.config.jsonOutput = true
+		case "--skip-hidden":
+			parsed.config.skipHidden = true
+		case "--skip-symlinks":
+			parsed.config.skipSymlinks = true
+		case "--include":
+			if i+1 < len(args) {
+				parsed.config.include = append(parsed.config.include, args[i+1])
+				i++
+			}
+		case "--exclude":
+			if i+1 < len(args) {
+				parsed.config.exclude = append(parsed.config.exclude, args[i+1])
+				i++
+			}
+		case "--min-size":
+			if i+1 < len(args) {
+				parsed.config.minSize = args[i+1]
+				i++
+			}
+		case "--max-size":
+			if i+1 < len(args) {
+				parsed.config.maxSize = args[i+1]
+				i++
+			}
+		case "--modified-since":
+			if i+1 < len(args) {
+				parsed.config.modifiedSince = args[i+1]
+				i++
+			}
+		case "--modified-before":
+			if i+1 < len(args) {
+				parsed.config.modifiedBefore = args[i+1]
+				i++
+			}
+		case "--threads", "-t":
+			if i+1 < len(args) {
+				fmt.Sscanf(args[i+1], "%d", &parsed.config.threads)
+				i++
+			}
+		case "--hash-threads":
+			if i+1 < len(args) {
+				fmt.Sscanf(args[i+1], "%d", &parsed.config.hashThreads)
+				i++
+			}
+		case "--max-attempts":
+			if i+1 < len(args) {
+				fmt.Sscanf(args[i+1], "%d", &parsed.config.maxAttempts)
+				i++
+			}
+		case "--timestamp":
+			if i+1 < len(args) {
+				parsed.config.timestampPolicy = args[i+1]
+				i++
+			}
+		case "--live-photos":
+			if i+1 < len(args) {
+				parsed.config.livePhotoPolicy = args[i+1]
+				i++
+			}
+		case "--raw-jpeg":
+			if i+1 < len(args) {
+				parsed.config.rawJpegPolicy = args[i+1]
+				i++
+			}
+		case "--after-upload":
+			if i+1 < len(args) {
+				parsed.config.postUploadAction = args[i+1]
+				i++
+			}
+		case "--archive-dir":
+			if i+1 < len(args) {
+				parsed.config.archiveDir = args[i+1]
+				i++
+			}
+		case "--strip-metadata":
+			if i+1 < len(args) {
+				parsed.config.stripMetadata = args[i+1]
+				i++
+			}
+		case "--filename-pattern":
+			if i+1 < len(args) {
+				parsed.config.filenamePatterns = append(parsed.config.filenamePatterns, args[i+1])
+				i++
+			}
+		case "--log-level", "-l":
+			if i+1 < len(args) {
+				parsed.config.logLevel = args[i+1]
+				i++
+			}
+		case "--config", "-c":
+			if i+1 < len(args) {
+				parsed.config.configPath = args[i+1]
+				i++
+			}
+		case "--":
+			// Everything after -- is a path, even if it starts with -
+			for _, arg := range args[i+1:] {
+				parsed.paths = append(parsed.paths, expandPathArg(arg)...)
+			}
+			return parsed, nil
+		default:
+			if strings.HasPrefix(args[i], "-") {
+				return parsed, fmt.Errorf("unknown flag '%s'", args[i])
+			}
+			parsed.paths = append(parsed.paths, expandPathArg(args[i])...)
+		}
+	}
+	return parsed, nil
+}
+
 func runCLI() {
 	if len(os.Args) < 2 {
 		printCLIHelp()
@@ -52,6 +218,15 @@ func runCLI() {
 		return
 	}
 
+	// --device applies to any command and is taken out before the command parses its flags
+	for i := 2; i < len(os.Args) && os.Args[i] != "--"; i++ {
+		if os.Args[i] == "--device" && i+1 < len(os.Args) {
+			backend.CommandDeviceProfile = os.Args[i+1]
+			os.Args = append(os.Args[:i], os.Args[i+2:]...)
+			break
+		}
+	}
+
 	command := os.Args[1]
 
 	switch command {
@@ -62,43 +237,81 @@ func runCLI() {
 			return
 		}
 
-		if len(os.Args) < 3 {
-			fmt.Println("Error: filepath required")
+		parsed, err := parseUploadArgs(os.Args[2:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
 			printUploadHelp()
 			os.Exit(1)
 		}
+		config, filePaths := parsed.config, parsed.paths
 
-		// Parse arguments
-		filePath := os.Args[2]
+		// Validate that every path given on the command line exists
+		for _, filePath := range filePaths {
+			if _, err := os.Stat(filePath); os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "Error: file or directory does not exist: %s\n", filePath)
+				os.Exit(1)
+			}
+		}
 
-		// Validate that filepath exists
-		if _, err := os.Stat(filePath); os.IsNotExist(err) {
-			fmt.Fprintf(os.Stderr, "Error: file or directory does not exist: %s\n", filePath)
+		// Paths from lists are not checked here; missing ones are reported as skipped
+		for _, fromFile := range parsed.fromFiles {
+			listed, err := readPathListFile(fromFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			filePaths = append(filePaths, listed...)
+		}
+
+		for _, summaryFile := range parsed.retryFailed {
+			failed, err := readFailedFromSummary(summaryFile)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			filePaths = append(filePaths, failed...)
+		}
+
+		filePaths = uniquePaths(filePaths)
+		if len(filePaths) == 0 && config.resumeJournal == "" {
+			fmt.Println("Error: filepath required")
+			printUploadHelp()
+			os.Exit(1)
+		}
+
+		// Run upload
+		err = runCLIUpload(filePaths, config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Upload failed: %v\n", err)
 			os.Exit(1)
 		}
 
-		filePaths := []string{filePath}
+	case "import-takeout":
+		if len(os.Args) > 2 && (os.Args[2] == "--help" || os.Args[2] == "-h") {
+			printTakeoutHelp()
+			return
+		}
+
+		var paths []string
+		restart := false
 		config := cliConfig{
 			threads:  3,
-			logLevel: "info", // Default to info for CLI
+			logLevel: "info",
 		}
-
-		// Parse flags
-		for i := 3; i < len(os.Args); i++ {
+		for i := 2; i < len(os.Args); i++ {
 			switch os.Args[i] {
-			case "--recursive", "-r":
-				config.recursive = true
-			case "--force", "-f":
-				config.forceUpload = true
-			case "--delete", "-d":
-				config.deleteFromHost = true
-			case "--disable-filter", "-df":
-				config.disableUnsupportedFilesFilter = true
+			case "--restart":
+				restart = true
 			case "--threads", "-t":
 				if i+1 < len(os.Args) {
 					fmt.Sscanf(os.Args[i+1], "%d", &config.threads)
 					i++
 				}
+			case "--max-attempts":
+				if i+1 < len(os.Args) {
+					fmt.Sscanf(os.Args[i+1], "%d", &config.maxAttempts)
+					i++
+				}
 			case "--log-level", "-l":
 				if i+1 < len(os.Args) {
 					config.logLevel = os.Args[i+1]
@@ -109,13 +322,28 @@ func runCLI() {
 					config.configPath = os.Args[i+1]
 					i++
 				}
+			default:
+				if !strings.HasPrefix(os.Args[i], "-") {
+					paths = append(paths, expandPathArg(os.Args[i])...)
+				}
 			}
 		}
 
-		// Run upload
-		err := runCLIUpload(filePaths, config)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Upload failed: %v\n", err)
+		for _, path := range paths {
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "Error: file or directory does not exist: %s\n", path)
+				os.Exit(1)
+			}
+		}
+		paths = uniquePaths(paths)
+		if len(paths) == 0 {
+			fmt.Println("Error: takeout zip or directory required")
+			printTakeoutHelp()
+			os.Exit(1)
+		}
+
+		if err := runCLITakeout(paths, config, restart); err != nil {
+			fmt.Fprintf(os.Stderr, "Import failed: %v\n", err)
 			os.Exit(1)
 		}
 
@@ -492,6 +720,38 @@ func runCLI() {
 		}
 		handleCredentialsCommand(args)
 
+	case "templates":
+		if len(os.Args) < 3 || os.Args[2] != "dump" {
+			printTemplatesHelp()
+			if len(os.Args) < 3 || (os.Args[2] != "--help" && os.Args[2] != "-h") {
+				os.Exit(1)
+			}
+			return
+		}
+		var configPath, name string
+		for i := 3; i < len(os.Args); i++ {
+			switch os.Args[i] {
+			case "--config", "-c":
+				if i+1 < len(os.Args) {
+					configPath = os.Args[i+1]
+					i++
+				}
+			default:
+				name = os.Args[i]
+			}
+		}
+		if configPath != "" {
+			backend.ConfigPath = configPath
+		}
+		if err := backend.LoadConfig(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+			os.Exit(1)
+		}
+		if err := runCLITemplatesDump(name); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
 	case "help", "--help", "-h":
 		printCLIHelp()
 	case "version", "--version", "-v":
@@ -503,6 +763,42 @@ func runCLI() {
 	}
 }
 
+// runCLITemplatesDump prints the effective request templates, or only the JSON of one
+func runCLITemplatesDump(name string) error {
+	templates := backend.RequestTemplates()
+	if name != "" {
+		i := slices.IndexFunc(templates, func(t backend.RequestTemplate) bool { return t.Name == name })
+		if i < 0 {
+			return fmt.Errorf("unknown template '%s': use one of %s", name, strings.Join(backend.RequestTemplateNames(), ", "))
+		}
+		fmt.Print(templates[i].JSON)
+		if !strings.HasSuffix(templates[i].JSON, "\n") {
+			fmt.Println()
+		}
+		if templates[i].Error != "" {
+			return fmt.Errorf("%s (%s): %s", name, templates[i].Source, templates[i].Error)
+		}
+		return nil
+	}
+
+	fmt.Printf("Overrides: %s\n", backend.RequestTemplateDir())
+	for _, template := range templates {
+		fmt.Println()
+		fmt.Printf("%s  %s\n", titleStyle.Render(template.Name), exampleStyle.Render(template.Source))
+		for _, field := range template.Fields {
+			fmt.Printf("  %s\n", field)
+		}
+		if template.Error != "" {
+			fmt.Printf("  Invalid, requests using it fail: %s\n", template.Error)
+		}
+		fmt.Print(template.JSON)
+		if !strings.HasSuffix(template.JSON, "\n") {
+			fmt.Println()
+		}
+	}
+	return nil
+}
+
 func containsSubstring(str, substr string) bool {
 	// Case-insensitive substring search
 	strLower := strings.ToLower(str)
@@ -519,6 +815,7 @@ func printCLIHelp() {
 	fmt.Println()
 	fmt.Println("Core Commands:")
 	fmt.Printf("  %s          Upload files or directories to Google Photos\n", commandStyle.Render("upload"))
+	fmt.Printf("  %s  Import a Google Takeout export with its dates, captions and albums\n", commandStyle.Render("import-takeout"))
 	fmt.Printf("  %s        Download a file from Google Photos by media key\n", commandStyle.Render("download"))
 	fmt.Printf("  %s       List media items in your library\n", commandStyle.Render("list, ls"))
 	fmt.Printf("  %s          List your albums\n", commandStyle.Render("albums"))
@@ -527,6 +824,7 @@ func printCLIHelp() {
 	fmt.Printf("  %s       Manage Google Photos credentials/accounts\n", commandStyle.Render("creds"))
 	fmt.Printf("  %s       Start auto-sync and backup service\n", commandStyle.Render("autowash"))
 	fmt.Printf("  %s   Download a thumbnail (various sizes available)\n", commandStyle.Render("thumbnail"))
+	fmt.Printf("  %s   Print the request templates in effect\n", commandStyle.Render("templates"))
 	fmt.Println()
 	fmt.Println("System Commands:")
 	fmt.Printf("  %s            Show this help message\n", commandStyle.Render("help, -h"))
@@ -534,6 +832,7 @@ func printCLIHelp() {
 	fmt.Println()
 	fmt.Println("Options:")
 	fmt.Println("  Use 'gotohp <command> --help' for detailed information on any command.")
+	printFlag("", "--device", "<profile>", "Device profile to use for this command (see 'gotohp creds devices')")
 }
 
 func printFlag(short, long, arg, description string) {
@@ -558,18 +857,70 @@ func printFlag(short, long, arg, description string) {
 }
 
 func printUploadHelp() {
-	fmt.Printf("Usage: %s %s %s %s\n", commandStyle.Render("gotohp"), commandStyle.Render("upload"), argStyle.Render("<filepath>"), flagStyle.Render("[flags]"))
+	fmt.Printf("Usage: %s %s %s %s\n", commandStyle.Render("gotohp"), commandStyle.Render("upload"), argStyle.Render("<path>..."), flagStyle.Render("[flags]"))
 	fmt.Println()
-	fmt.Println("Upload files or directories to Google Photos.")
+	fmt.Println("Upload files or directories to Google Photos. Zip and tar archives are read like")
+	fmt.Println("directories, without extracting them.")
 	fmt.Println()
 	fmt.Println("Flags:")
+	printFlag("", "--from-file", "<file>", "Read paths from a file, or - for stdin; newline or NUL separated (repeatable)")
+	printFlag("", "--resume", "<journal>", "Continue an interrupted upload from its journal")
+	printFlag("", "--retry-failed", "<summary>", "Upload the failed files listed in a JSON summary of an earlier run")
+	printFlag("", "--journal", "<path>", "Where to write the upload journal (default: kept only if the batch is interrupted or fails)")
 	printFlag("-r", "--recursive", "", "Include subdirectories")
 	printFlag("-t", "--threads", "<n>", "Number of upload threads (default: 3)")
+	printFlag("", "--hash-threads", "<n>", "Number of hashing threads (default: 1)")
+	printFlag("", "--max-attempts", "<n>", "Upload attempts per file for network and server errors (default: 3)")
+	printFlag("", "--timestamp", "<policy>", "Date uploads by: metadata (capture time, else file name, else mtime), mtime or filename")
+	printFlag("", "--filename-pattern", "<re>", "Date files by a file name regex with year, month, day[, hour, minute, second] or unix groups (repeatable)")
+	printFlag("", "--live-photos", "<policy>", "Live Photo stills with their video: both (default) or still")
+	printFlag("", "--raw-jpeg", "<policy>", "RAW+JPEG pairs: both (default), raw or jpeg")
+	printFlag("", "--strip-metadata", "<sets>", "Remove tag sets from JPEG, PNG and WebP before upload: gps, serial, makernote, owner, xmp or none")
 	printFlag("-f", "--force", "", "Force upload even if file exists")
 	printFlag("-d", "--delete", "", "Delete from host after upload")
+	printFlag("", "--after-upload", "<action>", "Once in the library: keep, delete, verify-delete (check the library again first), archive or trash (Linux)")
+	printFlag("", "--archive-dir", "<dir>", "With --after-upload archive, move files to a mirror of their path under dir")
 	printFlag("-df", "--disable-filter", "", "Disable file type filtering")
+	printFlag("", "--device", "<profile>", "Device profile to identify as (see 'gotohp creds devices')")
+	printFlag("", "--reindex", "", "Re-hash and re-check files the upload index has seen")
+	printFlag("-n", "--dry-run", "", "Print what would be uploaded without uploading or deleting")
+	printFlag("", "--check", "", "With --dry-run, hash files and check them against the library")
+	printFlag("-j", "--json", "", "With --dry-run, print the plan as JSON")
+	printFlag("", "--include", "<glob>", "Only upload files matching the glob (repeatable)")
+	printFlag("", "--exclude", "<glob>", "Skip files and directories matching the glob (repeatable)")
+	printFlag("", "--min-size", "<size>", "Skip files smaller than size, e.g. 100KB")
+	printFlag("", "--max-size", "<size>", "Skip files larger than size, e.g. 2GB")
+	printFlag("", "--modified-since", "<date>", "Skip files modified before date (YYYY-MM-DD or RFC 3339)")
+	printFlag("", "--modified-before", "<date>", "Skip files modified on or after date")
+	printFlag("", "--skip-hidden", "", "Skip hidden files and dot-directories")
+	printFlag("", "--skip-symlinks", "", "Skip symlinks instead of following them")
+	printFlag("-l", "--log-level", "<level>", "Set log level: debug, info, warn, error (default: info)")
+	printFlag("-c", "--config", "<path>", "Path to config file")
+	printFlag("", "--", "", "End of flags; later arguments are paths even if they start with -")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Printf("  %s\n", exampleStyle.Render("gotohp upload photos/ videos/clip.mp4 -r"))
+	fmt.Printf("  %s\n", exampleStyle.Render("gotohp upload shoot.zip day2.tar.gz"))
+	fmt.Printf("  %s\n", exampleStyle.Render("find /nas -name '*.jpg' -print0 | gotohp upload --from-file -"))
+}
+
+func printTakeoutHelp() {
+	fmt.Printf("Usage: %s %s %s %s\n", commandStyle.Render("gotohp"), commandStyle.Render("import-takeout"), argStyle.Render("<zip|dir>..."), flagStyle.Render("[flags]"))
+	fmt.Println()
+	fmt.Println("Import a Google Takeout export without extracting it. Media is dated by its JSON")
+	fmt.Println("sidecar, then captions, favorites and albums are re-applied. Running the same")
+	fmt.Println("command again resumes an interrupted import.")
+	fmt.Println()
+	fmt.Println("Flags:")
+	printFlag("", "--restart", "", "Start over instead of resuming an earlier import of the same paths")
+	printFlag("-t", "--threads", "<n>", "Number of upload threads (default: 3)")
+	printFlag("", "--max-attempts", "<n>", "Upload attempts per file for network and server errors (default: 3)")
 	printFlag("-l", "--log-level", "<level>", "Set log level: debug, info, warn, error (default: info)")
 	printFlag("-c", "--config", "<path>", "Path to config file")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Printf("  %s\n", exampleStyle.Render("gotohp import-takeout takeout-001.zip takeout-002.zip"))
+	fmt.Printf("  %s\n", exampleStyle.Render("gotohp import-takeout ~/Downloads/Takeout"))
 }
 
 func printAutoWashHelp() {
@@ -647,6 +998,23 @@ func printAlbumsHelp() {
 	printFlag("-c", "--config", "<path>", "Path to config file")
 }
 
+func printTemplatesHelp() {
+	fmt.Printf("Usage: %s %s %s %s %s\n", commandStyle.Render("gotohp"), commandStyle.Render("templates"), commandStyle.Render("dump"), argStyle.Render("[name]"), flagStyle.Render("[flags]"))
+	fmt.Println()
+	fmt.Println("Print the JSON templates API requests are built from, with the fields filled in")
+	fmt.Println("per request. A file <name>.json in the templates directory next to the config")
+	fmt.Println("file replaces the built-in template of the same name.")
+	fmt.Println()
+	fmt.Println("With a name, only the template JSON is printed, ready to save as an override.")
+	fmt.Println()
+	fmt.Println("Flags:")
+	printFlag("-c", "--config", "<path>", "Path to config file")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Printf("  %s\n", exampleStyle.Render("gotohp templates dump"))
+	fmt.Printf("  %s\n", exampleStyle.Render("gotohp templates dump media-list > ~/.config/gotohp/templates/media-list.json"))
+}
+
 func printCredentialsHelp() {
 	fmt.Printf("Usage: %s %s %s %s\n", commandStyle.Render("gotohp"), commandStyle.Render("creds"), argStyle.Render("<subcommand>"), flagStyle.Render("[args]"))
 	fmt.Println()
@@ -670,6 +1038,8 @@ func printCredentialsHelp() {
 	printSubcommand("remove, rm", "<email>", "Remove a credential by email")
 	printSubcommand("list, ls", "", "List all stored credentials")
 	printSubcommand("set, select", "<email>", "Set active credential")
+	printSubcommand("device", "<email> <profile>", "Set the device profile of an account, or default")
+	printSubcommand("devices", "", "List device profiles")
 	
 	fmt.Println()
 	fmt.Println("Flags:")
@@ -739,7 +1109,7 @@ func handleCredentialsCommand(args []string) {
 			if email == config.Selected {
 				marker = "*"
 			}
-			fmt.Printf("  %s %s\n", marker, email)
+			fmt.Printf("  %s %s  %s\n", marker, email, exampleStyle.Render(backend.AccountDeviceProfileName(email)))
 		}
 		if config.Selected != "" {
 			fmt.Printf("\n* = active\n")
@@ -752,59 +1122,91 @@ func handleCredentialsCommand(args []string) {
 			fmt.Println("Usage: gotohp creds set <email>")
 			os.Exit(1)
 		}
-		query := args[1]
-		config := configManager.GetConfig()
+		matchedEmail := matchCredentialEmail(configManager.GetConfig().Credentials, args[1])
+		configManager.SetSelected(matchedEmail)
+		fmt.Printf("✓ Active credential set to %s\n", matchedEmail)
+
+	case "device":
+		if len(args) < 3 {
+			fmt.Println("Error: email and device profile required")
+			fmt.Println("Usage: gotohp creds device <email> <profile|default>")
+			os.Exit(1)
+		}
+		matchedEmail := matchCredentialEmail(configManager.GetConfig().Credentials, args[1])
+		profile := args[2]
+		if profile == "default" {
+			profile = ""
+		}
+		if err := configManager.SetAccountDeviceProfile(matchedEmail, profile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error setting device profile: %v\n", err)
+			os.Exit(1)
+		}
+		if profile == "" {
+			fmt.Printf("✓ %s uses the default device profile\n", matchedEmail)
+		} else {
+			fmt.Printf("✓ %s uses device profile %s\n", matchedEmail, profile)
+		}
+
+	case "devices":
+		fmt.Println("Device profiles:")
+		for _, profile := range configManager.GetDeviceProfiles() {
+			fmt.Printf("  %s  %s\n", profile.Name, exampleStyle.Render(fmt.Sprintf("%s %s, Android %s (API %d), app %d, build %s",
+				profile.Make, profile.Model, profile.AndroidVersion, profile.AndroidAPIVersion, profile.ClientVersionCode, profile.BuildID)))
+		}
+		fmt.Printf("\nDefault: %s\n", backend.DefaultDeviceProfileName())
+
+	default:
+		fmt.Printf("Error: unknown subcommand '%s'\n\n", subcommand)
+		printCredentialsHelp()
+		os.Exit(1)
+	}
+}
+
+// matchCredentialEmail returns the stored account matching query exactly, else the only
+// one containing it, and exits if there is none or several
+func matchCredentialEmail(credentials []string, query string) string {
+	// Try to find exact match first
+	var matchedEmail string
+	for _, cred := range credentials {
+		params, err := backend.ParseAuthString(cred)
+		if err != nil {
+			continue
+		}
+		email := params.Get("Email")
+		if email == query {
+			matchedEmail = email
+			break
+		}
+	}
 
-		// Try to find exact match first
-		var matchedEmail string
-		for _, cred := range config.Credentials {
+	// If no exact match, try fuzzy matching (substring match)
+	if matchedEmail == "" {
+		var candidates []string
+		for _, cred := range credentials {
 			params, err := backend.ParseAuthString(cred)
 			if err != nil {
 				continue
 			}
 			email := params.Get("Email")
-			if email == query {
-				matchedEmail = email
-				break
+			// Check if query is a substring of the email
+			if containsSubstring(email, query) {
+				candidates = append(candidates, email)
 			}
 		}
 
-		// If no exact match, try fuzzy matching (substring match)
-		if matchedEmail == "" {
-			var candidates []string
-			for _, cred := range config.Credentials {
-				params, err := backend.ParseAuthString(cred)
-				if err != nil {
-					continue
-				}
-				email := params.Get("Email")
-				// Check if query is a substring of the email
-				if containsSubstring(email, query) {
-					candidates = append(candidates, email)
-				}
-			}
-
-			if len(candidates) == 0 {
-				fmt.Fprintf(os.Stderr, "Error: no credentials found matching '%s'\n", query)
-				os.Exit(1)
-			} else if len(candidates) == 1 {
-				matchedEmail = candidates[0]
-			} else {
-				fmt.Fprintf(os.Stderr, "Error: multiple credentials match '%s':\n", query)
-				for _, email := range candidates {
-					fmt.Fprintf(os.Stderr, "  - %s\n", email)
-				}
-				fmt.Fprintf(os.Stderr, "Please be more specific\n")
-				os.Exit(1)
+		if len(candidates) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: no credentials found matching '%s'\n", query)
+			os.Exit(1)
+		} else if len(candidates) == 1 {
+			matchedEmail = candidates[0]
+		} else {
+			fmt.Fprintf(os.Stderr, "Error: multiple credentials match '%s':\n", query)
+			for _, email := range candidates {
+				fmt.Fprintf(os.Stderr, "  - %s\n", email)
 			}
+			fmt.Fprintf(os.Stderr, "Please be more specific\n")
+			os.Exit(1)
 		}
-
-		configManager.SetSelected(matchedEmail)
-		fmt.Printf("✓ Active credential set to %s\n", matchedEmail)
-
-	default:
-		fmt.Printf("Error: unknown subcommand '%s'\n\n", subcommand)
-		printCredentialsHelp()
-		os.Exit(1)
 	}
+	return matchedEmail
 }
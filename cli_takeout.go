@@ -0,0 +1,103 @@
+package main
+
+import (
+	"app/backend"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// takeoutSummary is the JSON printed after an import-takeout run
+type takeoutSummary struct {
+	uploadSummary
+	Takeout *backend.TakeoutSummary `json:"takeout,omitempty"`
+}
+
+// runCLITakeout imports Google Takeout exports, resuming an earlier import of the same paths
+func runCLITakeout(paths []string, config cliConfig, restart bool) error {
+	if config.configPath != "" {
+		backend.ConfigPath = config.configPath
+	}
+	if err := backend.LoadConfig(); err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	backend.AppConfig.UploadThreads = config.threads
+	if config.maxAttempts > 0 {
+		backend.AppConfig.UploadMaxAttempts = config.maxAttempts
+	}
+
+	journalPath, err := backend.TakeoutJournalPath(paths)
+	if err != nil {
+		return fmt.Errorf("failed to create upload journal: %w", err)
+	}
+	if restart {
+		os.Remove(journalPath)
+	}
+	var journal *backend.UploadJournal
+	var state *backend.JournalState
+	if _, err := os.Stat(journalPath); err == nil {
+		if journal, state, err = backend.ResumeUploadJournal(journalPath); err != nil {
+			return fmt.Errorf("failed to resume import: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Resuming %s: %d done, %d failed\n", journalPath, len(state.Done), len(state.Failed))
+	} else if journal, err = backend.CreateUploadJournal(journalPath, paths); err != nil {
+		return fmt.Errorf("failed to create upload journal: %w", err)
+	}
+	defer journal.Close()
+
+	p := tea.NewProgram(initialModel())
+	cliApp := backend.NewCLIApp(uploadEvents(p), parseLogLevel(config.logLevel))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var result *backend.TakeoutSummary
+	var importErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		result, importErr = backend.ImportTakeout(ctx, cliApp, paths, journal, state)
+	}()
+
+	finalModel, err := p.Run()
+	// Stop the workers after a Ctrl+C so the journal records everything finished
+	cancel()
+	<-done
+	if err != nil {
+		return fmt.Errorf("error running TUI: %w", err)
+	}
+	if importErr != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to import takeout: %w", importErr)
+	}
+	if err := backend.FlushUploadIndex(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save upload index: %v\n", err)
+	}
+	fmt.Fprintf(os.Stderr, "Import journal: %s\nRun the same command again to resume\n", journalPath)
+
+	if m, ok := finalModel.(uploadModel); ok {
+		summary := takeoutSummary{
+			uploadSummary: uploadSummary{
+				Total:            m.totalFiles,
+				Succeeded:        m.completed,
+				Failed:           m.failed,
+				Retried:          m.retried(),
+				TimestampSources: m.timestampSources(),
+				MetadataStripped: m.metadataStripped(),
+				LocalActions:     m.localActions(),
+				Profiles:         m.profiles(),
+				Results:          m.results,
+				Skipped:          m.skippedFiles,
+				Journal:          journalPath,
+			},
+			Takeout: result,
+		}
+		jsonOutput, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error generating JSON: %w", err)
+		}
+		fmt.Println(string(jsonOutput))
+	}
+	return nil
+}
@@ -4,19 +4,56 @@ import { Events, Clipboard } from "@wailsio/runtime";
 export interface UploadSuccess {
   path: string;
   mediaKey: string;
+  timestamp?: string;
+  timestampSource?: string; // "metadata", "filename" or "mtime"; unset for files already in the library
 }
 
 export interface ThreadStatus {
   WorkerID: number;
-  Status: string; // "idle", "hashing", "checking", "uploading", "finalizing", "completed", "error"
+  Status: string; // "idle", "hashing", "checking", "uploading", "finalizing", "completed", "error", "retrying", "paused"
   FilePath: string;
   FileName: string;
+  Attempt: number;
   Message: string;
 }
 
+export interface UploadPreflight {
+  Total: number;
+  AlreadyInLibrary: number;
+  ToUpload: number;
+  Failed: number;
+  Checked: boolean;
+  Complete: boolean;
+}
+
+export interface UploadScanProgress {
+  Found: number;
+  Skipped: number;
+  SkippedFiles: Array<{ path: string; reason: string }> | null;
+  Complete: boolean;
+}
+
+export interface UploadPause {
+  Until: string;
+  Failures: number;
+  Reason: string;
+}
+
+export interface UploadQueueState {
+  Paused: boolean;
+  Active: string[] | null;
+  Queued: string[] | null; // First queued files in upload order
+  QueuedTotal: number;
+}
+
 export interface UploadState {
   isUploading: boolean;
   totalFiles: number;
+  skippedFiles: number;
+  scanning: boolean;
+  preflight: UploadPreflight | null;
+  paused: UploadPause | null;
+  queue: UploadQueueState | null;
   uploadedFiles: number;
   threads: Map<number, ThreadStatus>;
   results: {
@@ -32,6 +69,11 @@ class UploadManager {
   public state = reactive<UploadState>({
     isUploading: false,
     totalFiles: 0,
+    skippedFiles: 0,
+    scanning: false,
+    preflight: null,
+    paused: null,
+    queue: null,
     uploadedFiles: 0,
     threads: new Map<number, ThreadStatus>(),
     results: {
@@ -44,6 +86,10 @@ class UploadManager {
     // Bind all methods to ensure 'this' context is preserved
     this.resetUploadResults = this.resetUploadResults.bind(this);
     this.cancelUpload = this.cancelUpload.bind(this);
+    this.pauseUpload = this.pauseUpload.bind(this);
+    this.resumeUpload = this.resumeUpload.bind(this);
+    this.cancelFile = this.cancelFile.bind(this);
+    this.moveFile = this.moveFile.bind(this);
     this.copyResultsAsJson = this.copyResultsAsJson.bind(this);
 
     this.setupEventListeners();
@@ -60,25 +106,62 @@ class UploadManager {
     // Handle upload start
     Events.On("uploadStart", (event: { data: Array<{ Total: number }> }) => {
       this.state.totalFiles = event.data[0].Total;
+      this.state.skippedFiles = 0;
+      this.state.scanning = true;
       this.state.uploadedFiles = 0;
+      this.state.preflight = null;
+      this.state.paused = null;
+      this.state.queue = null;
       this.state.isUploading = true;
       this.state.threads.clear();
       this.resetUploadResults();
     });
 
+    // Handle scan progress; files are found while earlier ones are already uploading
+    Events.On("uploadScan", (event: { data: Array<UploadScanProgress> }) => {
+      const scan = event.data[0];
+      this.state.totalFiles = scan.Found;
+      this.state.skippedFiles = scan.Skipped;
+      this.state.scanning = !scan.Complete;
+    });
+
+    // Handle library check progress; counts are cumulative and Complete is set at the end
+    Events.On("uploadPreflight", (event: { data: Array<UploadPreflight> }) => {
+      this.state.preflight = event.data[0];
+    });
+
+    // Handle changes to the upload queue; files dropped during an upload join it
+    Events.On("uploadQueue", (event: { data: Array<UploadQueueState> }) => {
+      this.state.queue = event.data[0];
+    });
+
+    // Handle the circuit breaker pausing all workers after repeated failures
+    Events.On("uploadPaused", (event: { data: Array<UploadPause> }) => {
+      this.state.paused = event.data[0];
+    });
+
     // Handle thread status updates
     Events.On("ThreadStatus", (event: { data: Array<ThreadStatus> }) => {
       const threadStatus = event.data[0];
       this.state.threads.set(threadStatus.WorkerID, threadStatus);
+      // Workers are moving again once one of them gets past the pause
+      if (this.state.paused && !["paused", "idle", "retrying"].includes(threadStatus.Status)) {
+        this.state.paused = null;
+      }
     });
 
     // Handle file status updates
-    Events.On("FileStatus", (event: { data: Array<{ IsError: boolean; Path: string; MediaKey: string }> }) => {
-      const { IsError, Path, MediaKey } = event.data[0];
+    Events.On("FileStatus", (event: { data: Array<{ IsError: boolean; Path: string; MediaKey: string; Timestamp: string; TimestampSource: string }> }) => {
+      const { IsError, Path, MediaKey, Timestamp, TimestampSource } = event.data[0];
 
       if (!IsError) {
         this.state.uploadedFiles += 1;
-        this.state.results.success.push({ path: Path, mediaKey: MediaKey });
+        const success: UploadSuccess = { path: Path, mediaKey: MediaKey };
+        if (TimestampSource) {
+          success.timestamp = Timestamp;
+          success.timestampSource = TimestampSource;
+        }
+        this.state.results.success.push(success);
       } else {
         this.state.results.fail.push(Path);
       }
@@ -87,6 +170,7 @@ class UploadManager {
     // Handle upload stop
     Events.On("uploadStop", () => {
       this.state.isUploading = false;
+      this.state.paused = null;
     });
   }
 
@@ -99,6 +183,25 @@ class UploadManager {
     Events.Emit("uploadCancel");
   }
 
+  // Upload workers finish their current files, then hold until resumed
+  public pauseUpload() {
+    Events.Emit("uploadPause");
+  }
+
+  public resumeUpload() {
+    Events.Emit("uploadResume");
+  }
+
+  // Cancel one file, whether it is uploading, queued or not scanned yet
+  public cancelFile(path: string) {
+    Events.Emit("uploadCancelFile", { path });
+  }
+
+  // Move a queued file to index in the upload order; 0 uploads it next
+  public moveFile(path: string, index: number) {
+    Events.Emit("uploadMoveFile", { path, index });
+  }
+
   public async copyResultsAsJson() {
     const resultsJson = JSON.stringify(this.state.results, null, 2);
     try {
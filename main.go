@@ -5,6 +5,7 @@ package main
 import (
 	"app/backend"
 	"embed"
+	"encoding/json"
 	"log"
 	"os"
 
@@ -35,7 +36,9 @@ func runGUI() {
 		Description: "Google Photos unofficial client",
 		Services: []application.Service{
 			application.NewService(&backend.ConfigManager{}),
-			application.NewService(&backend.MediaBrowser{}),
+			application.NewServiceWithOptions(&backend.MediaBrowser{}, application.ServiceOptions{
+				Route: backend.MediaRoute,
+			}),
 		},
 		Assets: application.AssetOptions{
 			Handler: application.AssetFileServerFS(assets),
@@ -67,11 +70,33 @@ func runGUI() {
 	app := backend.NewWailsApp(wailsApp)
 	uploadManager := backend.NewUploadManager(app)
 
-	// Listen for upload cancel event
+	// Listen for upload control events
 	wailsApp.Event.On("uploadCancel", func(e *application.CustomEvent) {
 		uploadManager.Cancel()
 	})
+	wailsApp.Event.On("uploadPause", func(e *application.CustomEvent) {
+		uploadManager.Pause()
+	})
+	wailsApp.Event.On("uploadResume", func(e *application.CustomEvent) {
+		uploadManager.Resume()
+	})
+	wailsApp.Event.On("uploadCancelFile", func(e *application.CustomEvent) {
+		var req struct{ Path string }
+		if eventData(e, &req) == nil {
+			uploadManager.CancelFile(req.Path)
+		}
+	})
+	wailsApp.Event.On("uploadMoveFile", func(e *application.CustomEvent) {
+		var req struct {
+			Path  string
+			Index int
+		}
+		if eventData(e, &req) == nil {
+			uploadManager.MoveFile(req.Path, req.Index)
+		}
+	})
 
+	// Dropped files start a batch, or join the running one
 	window.OnWindowEvent(events.Common.WindowDropZoneFilesDropped, func(event *application.WindowEvent) {
 		paths := event.Context().DroppedFiles()
 		uploadManager.Upload(app, paths)
@@ -82,3 +107,12 @@ func runGUI() {
 		log.Fatal(err)
 	}
 }
+
+// eventData decodes the data the frontend sent with an event into v
+func eventData(e *application.CustomEvent, v any) error {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}